@@ -1,7 +1,7 @@
 #! /bin/bash
 
 rm -f examples.tex
-for i in ../../examples/*.py; do
+for i in ../../examples/*.py ../../examples/*.mx2; do
 		f=$(basename $i)
 		echo "\\subsection{$f}" >> examples.tex;
 		echo "\\lstinputlisting[language=Python]{$i}" >> examples.tex;
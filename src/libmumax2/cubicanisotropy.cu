@@ -0,0 +1,131 @@
+#include "cubicanisotropy.h"
+
+#include "multigpu.h"
+#include <cuda.h>
+#include "gpu_conf.h"
+#include "gpu_safe.h"
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+// value of a mask, 1 for a NULL mask
+static __device__ inline float maskValue(float* map, int i)
+{
+    if (map == NULL)
+    {
+        return 1.0f;
+    }
+    return map[i];
+}
+
+__global__ void cubicAnisotropyKern (float *hx, float *hy, float *hz,
+                                     float *mx, float *my, float *mz,
+                                     float *K1_map, float K1_Mu0Msat_mul,
+                                     float *K2_map, float K2_Mu0Msat_mul,
+                                     float *mSat_map,
+                                     float *c1_mapx, float c1_mulx,
+                                     float *c1_mapy, float c1_muly,
+                                     float *c1_mapz, float c1_mulz,
+                                     float *c2_mapx, float c2_mulx,
+                                     float *c2_mapy, float c2_muly,
+                                     float *c2_mapz, float c2_mulz,
+                                     int Npart)
+{
+
+    int i = threadindex;
+
+    if (i < Npart)
+    {
+
+        float mSat_mask = maskValue(mSat_map, i);
+        if (mSat_mask == 0.0f)
+        {
+            mSat_mask = 1.0f; // do not divide by zero
+        }
+
+        // 2 * K / Mu0 * Msat
+        float K1_2_Mu0Msat = 2.0f * (K1_Mu0Msat_mul / mSat_mask) * maskValue(K1_map, i);
+        float K2_2_Mu0Msat = 2.0f * (K2_Mu0Msat_mul / mSat_mask) * maskValue(K2_map, i);
+
+        float c1x = c1_mulx * maskValue(c1_mapx, i);
+        float c1y = c1_muly * maskValue(c1_mapy, i);
+        float c1z = c1_mulz * maskValue(c1_mapz, i);
+
+        float c2x = c2_mulx * maskValue(c2_mapx, i);
+        float c2y = c2_muly * maskValue(c2_mapy, i);
+        float c2z = c2_mulz * maskValue(c2_mapz, i);
+
+        // the sign of c3 does not matter, it only enters as a3 * c3 or a3²
+        float c3x = c1y * c2z - c1z * c2y;
+        float c3y = c1z * c2x - c1x * c2z;
+        float c3z = c1x * c2y - c1y * c2x;
+
+        float a1 = mx[i] * c1x + my[i] * c1y + mz[i] * c1z;
+        float a2 = mx[i] * c2x + my[i] * c2y + mz[i] * c2z;
+        float a3 = mx[i] * c3x + my[i] * c3y + mz[i] * c3z;
+
+        float a1a1 = a1 * a1;
+        float a2a2 = a2 * a2;
+        float a3a3 = a3 * a3;
+
+        // H = -dE/d(Mu0 Msat m)
+        float h1 = -K1_2_Mu0Msat * a1 * (a2a2 + a3a3) - K2_2_Mu0Msat * a1 * a2a2 * a3a3;
+        float h2 = -K1_2_Mu0Msat * a2 * (a1a1 + a3a3) - K2_2_Mu0Msat * a2 * a1a1 * a3a3;
+        float h3 = -K1_2_Mu0Msat * a3 * (a1a1 + a2a2) - K2_2_Mu0Msat * a3 * a1a1 * a2a2;
+
+        hx[i] = h1 * c1x + h2 * c2x + h3 * c3x;
+        hy[i] = h1 * c1y + h2 * c2y + h3 * c3y;
+        hz[i] = h1 * c1z + h2 * c2z + h3 * c3z;
+    }
+
+}
+
+
+
+__export__ void cubicAnisotropyAsync(float **hx, float **hy, float **hz,
+                                     float **mx, float **my, float **mz,
+                                     float **K1_map, float K1_Mu0Msat_mul,
+                                     float **K2_map, float K2_Mu0Msat_mul,
+                                     float **MSat_map,
+                                     float **c1_mapx, float c1_mulx,
+                                     float **c1_mapy, float c1_muly,
+                                     float **c1_mapz, float c1_mulz,
+                                     float **c2_mapx, float c2_mulx,
+                                     float **c2_mapy, float c2_muly,
+                                     float **c2_mapz, float c2_mulz,
+                                     CUstream* stream, int Npart)
+{
+
+    dim3 gridSize, blockSize;
+    make1dconf(Npart, &gridSize, &blockSize);
+
+    for (int dev = 0; dev < nDevice(); dev++)
+    {
+        assert(hx[dev] != NULL);
+        assert(hy[dev] != NULL);
+        assert(hz[dev] != NULL);
+        assert(mx[dev] != NULL);
+        assert(my[dev] != NULL);
+        assert(mz[dev] != NULL);
+        gpu_safe(cudaSetDevice(deviceId(dev)));
+
+        cubicAnisotropyKern <<< gridSize, blockSize, 0, cudaStream_t(stream[dev])>>> (
+            hx[dev], hy[dev], hz[dev],
+            mx[dev], my[dev], mz[dev],
+            K1_map[dev], K1_Mu0Msat_mul,
+            K2_map[dev], K2_Mu0Msat_mul,
+            MSat_map[dev],
+            c1_mapx[dev], c1_mulx,
+            c1_mapy[dev], c1_muly,
+            c1_mapz[dev], c1_mulz,
+            c2_mapx[dev], c2_mulx,
+            c2_mapy[dev], c2_muly,
+            c2_mapz[dev], c2_mulz,
+            Npart);
+    }
+}
+
+#ifdef __cplusplus
+}
+#endif
@@ -0,0 +1,39 @@
+/**
+  * @file
+  * This file implements the cubic anisotropy field
+  *
+  * @author Arne Vansteenkiste
+  */
+
+#ifndef _CUBICANISOTROPY_
+#define _CUBICANISOTROPY_
+
+#include <cuda.h>
+#include "cross_platform.h"
+
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/// Energy density: K1 (a1²a2² + a2²a3² + a3²a1²) + K2 a1²a2²a3²,
+/// with ai = m . ci and c3 = c1 x c2.
+/// @param K1_Mu0Msat_mul K1 multiplier / (Mu0 * Msat multiplier), idem for K2
+/// @param Npart number of floats per GPU, so total number of floats / nDevice()
+DLLEXPORT void cubicAnisotropyAsync(float **hx, float **hy, float **hz,
+                                    float **mx, float **my, float **mz,
+                                    float **K1_map, float K1_Mu0Msat_mul,
+                                    float **K2_map, float K2_Mu0Msat_mul,
+                                    float **MSat_map,
+                                    float **c1_mapx, float c1_mulx,
+                                    float **c1_mapy, float c1_muly,
+                                    float **c1_mapz, float c1_mulz,
+                                    float **c2_mapx, float c2_mulx,
+                                    float **c2_mapy, float c2_muly,
+                                    float **c2_mapz, float c2_mulz,
+                                    CUstream* stream, int Npart);
+
+#ifdef __cplusplus
+}
+#endif
+#endif
@@ -0,0 +1,158 @@
+#include "dmi.h"
+
+#include "multigpu.h"
+#include <cuda.h>
+#include "gpu_conf.h"
+#include "gpu_safe.h"
+#include "common_func.h"
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+// DMI vector for neighbors along axis c (0 = user z), in internal component order.
+// The energy density is sum_c u_c . (m x dm/dc), with
+// u = Dind (z x e_c) - Dbulk e_c in user coordinates.
+static __device__ inline float3 dmiVector(int c, float dind, float dbulk)
+{
+    if (c == 0)
+    {
+        return make_float3(-dbulk, 0.0f, 0.0f);
+    }
+    if (c == 1)
+    {
+        return make_float3(0.0f, -dbulk, -dind);
+    }
+    return make_float3(0.0f, dind, -dbulk);
+}
+
+// Parameter / Msat in cell I.
+static __device__ inline float perMsat(float* __restrict__ param, float mul, float* __restrict__ mSat_map, int I)
+{
+    return mul * fdivZero(getMaskUnity(param, I), getMaskUnity(mSat_map, I));
+}
+
+// Adds the contribution of the neighbor on one side (-1 or 1) along axis c to H.
+// A neighbor outside the grid (when not periodic) or with Msat = 0 is missing,
+// it is replaced by a ghost cell that satisfies the boundary condition 2A dm/dn = -u x m.
+// exchange6 treats it as equal to m0, so the difference in exchange field is added here.
+static __device__ inline float3 dmiNeighbor(float3 H, int c, int side, int here, int N, int wrap, int stride, int I, float cell,
+        float* __restrict__  mx, float* __restrict__  my, float* __restrict__  mz,
+        float* __restrict__  mSat_map, float lex0,
+        float* __restrict__  dind, float dindMul, float dind0,
+        float* __restrict__  dbulk, float dbulkMul, float dbulk0)
+{
+    float3 m0 = make_float3(mx[I], my[I], mz[I]);
+    int there = here + side;
+    int missing = 0;
+    if (there < 0 || there >= N)
+    {
+        if (wrap)
+        {
+            there = (there + N) % N;
+        }
+        else
+        {
+            missing = 1;
+        }
+    }
+    int addr = I + (there - here) * stride;
+    if (!missing && getMaskUnity(mSat_map, addr) == 0.0f)
+    {
+        missing = 1;
+    }
+
+    float3 u, m1;
+    if (missing)
+    {
+        u = dmiVector(c, dind0, dbulk0);
+        float3 um = crossf(u, m0);
+        float s = side * cell;
+        m1 = make_float3(m0.x - s * fdivZero(um.x, lex0), m0.y - s * fdivZero(um.y, lex0), m0.z - s * fdivZero(um.z, lex0));
+        float ex = lex0 / (cell * cell);
+        H.x += ex * (m1.x - m0.x);
+        H.y += ex * (m1.y - m0.y);
+        H.z += ex * (m1.z - m0.z);
+    }
+    else
+    {
+        u = dmiVector(c, avgGeomZero(dind0, perMsat(dind, dindMul, mSat_map, addr)), avgGeomZero(dbulk0, perMsat(dbulk, dbulkMul, mSat_map, addr)));
+        m1 = make_float3(mx[addr], my[addr], mz[addr]);
+    }
+
+    float3 um1 = crossf(u, m1);
+    float s = side / cell;
+    H.x += s * um1.x;
+    H.y += s * um1.y;
+    H.z += s * um1.z;
+    return H;
+}
+
+// full 3D blocks
+__global__ void dmiKern(float* __restrict__ hx, float* __restrict__  hy, float* __restrict__  hz,
+                        float* __restrict__  mx, float* __restrict__  my, float* __restrict__  mz,
+                        float* __restrict__  mSat_map, float* __restrict__  Aex_map, const float aexMul,
+                        float* __restrict__  Dind_map, const float dindMul,
+                        float* __restrict__  Dbulk_map, const float dbulkMul,
+                        const int N0, const int N1, const int N2,
+                        const int wrap0, const int wrap1, const int wrap2,
+                        const float cellX, const float cellY, const float cellZ)
+{
+
+    int i = blockIdx.x * blockDim.x + threadIdx.x;
+    int j = blockIdx.y * blockDim.y + threadIdx.y;
+    int k = blockIdx.z * blockDim.z + threadIdx.z;
+
+    if (i < N0 && j < N1 && k < N2)
+    {
+
+        int I = i * N1 * N2 + j * N2 + k;
+
+        float lex0 = perMsat(Aex_map, aexMul, mSat_map, I);
+        float dind0 = perMsat(Dind_map, dindMul, mSat_map, I);
+        float dbulk0 = perMsat(Dbulk_map, dbulkMul, mSat_map, I);
+
+        float3 H = make_float3(0.0f, 0.0f, 0.0f);
+        // same order as DMIAsync in libmumax2_cpu.go
+        for (int side = -1; side <= 1; side += 2)
+        {
+            H = dmiNeighbor(H, 0, side, i, N0, wrap0, N1 * N2, I, cellX, mx, my, mz, mSat_map, lex0, Dind_map, dindMul, dind0, Dbulk_map, dbulkMul, dbulk0);
+        }
+        for (int side = -1; side <= 1; side += 2)
+        {
+            H = dmiNeighbor(H, 1, side, j, N1, wrap1, N2, I, cellY, mx, my, mz, mSat_map, lex0, Dind_map, dindMul, dind0, Dbulk_map, dbulkMul, dbulk0);
+        }
+        for (int side = -1; side <= 1; side += 2)
+        {
+            H = dmiNeighbor(H, 2, side, k, N2, wrap2, 1, I, cellZ, mx, my, mz, mSat_map, lex0, Dind_map, dindMul, dind0, Dbulk_map, dbulkMul, dbulk0);
+        }
+
+        // Write back to global memory
+        hx[I] = H.x;
+        hy[I] = H.y;
+        hz[I] = H.z;
+
+    }
+
+}
+
+
+__export__ void dmiAsync(float** hx, float** hy, float** hz, float** mx, float** my, float** mz, float** msat, float** aex, float Aex2_mu0MsatMul, float** dind, float Dind_mu0MsatMul, float** dbulk, float Dbulk_mu0MsatMul, int N0, int N1Part, int N2, int periodic0, int periodic1, int periodic2, float cellSizeX, float cellSizeY, float cellSizeZ, CUstream* streams)
+{
+
+    dim3 gridsize, blocksize;
+
+    make3dconf(N0, N1Part, N2, &gridsize, &blocksize);
+
+    int nDev = nDevice();
+
+    for (int dev = 0; dev < nDev; dev++)
+    {
+        gpu_safe(cudaSetDevice(deviceId(dev)));
+        dmiKern <<< gridsize, blocksize, 0, cudaStream_t(streams[dev])>>>(hx[dev], hy[dev], hz[dev], mx[dev], my[dev], mz[dev], msat[dev], aex[dev], Aex2_mu0MsatMul, dind[dev], Dind_mu0MsatMul, dbulk[dev], Dbulk_mu0MsatMul, N0, N1Part, N2, periodic0, periodic1, periodic2, cellSizeX, cellSizeY, cellSizeZ);
+    }
+}
+
+
+#ifdef __cplusplus
+}
+#endif
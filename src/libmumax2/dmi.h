@@ -0,0 +1,28 @@
+/**
+  * @file
+  * This file implements the interfacial and bulk Dzyaloshinskii-Moriya field,
+  * with the boundary conditions of exchange + DMI.
+  *
+  * @author Arne Vansteenkiste
+  */
+
+#ifndef _DMI_H_
+#define _DMI_H_
+
+#include <cuda.h>
+#include "cross_platform.h"
+
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/// @param Aex2_mu0MsatMul 2 * Aex / Mu0 * Msat.multiplier, as for exchange6Async
+/// @param Dind_mu0MsatMul Dind / Mu0 * Msat.multiplier, idem for Dbulk
+DLLEXPORT void dmiAsync(float** hx, float** hy, float** hz, float** mx, float** my, float** mz, float** msat, float** aex, float Aex2_mu0MsatMul, float** dind, float Dind_mu0MsatMul, float** dbulk, float Dbulk_mu0MsatMul, int N0, int N1Part, int N2, int periodic0, int periodic1, int periodic2, float cellSizeX, float cellSizeY, float cellSizeZ, CUstream* streams);
+
+
+#ifdef __cplusplus
+}
+#endif
+#endif
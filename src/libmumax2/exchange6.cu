@@ -12,6 +12,7 @@ extern "C" {
 __global__ void exchange6Kern(float* __restrict__ hx, float* __restrict__  hy, float* __restrict__  hz,
                               float* __restrict__  mx, float* __restrict__  my, float* __restrict__  mz,
                               float* __restrict__  mSat_map, float* __restrict__  Aex_map,
+                              float* __restrict__  scaleX_map, float* __restrict__  scaleY_map, float* __restrict__  scaleZ_map,
                               const float pre,
                               const int N0, const int N1, const int N2,
                               const int wrap0, const int wrap1, const int wrap2,
@@ -52,7 +53,7 @@ __global__ void exchange6Kern(float* __restrict__ hx, float* __restrict__  hy, f
         linAddr = idx * N1 * N2 + j * N2 + k;
 
         lexMul = fdivZero(getMaskUnity(Aex_map, linAddr), getMaskUnity(mSat_map, linAddr));
-        lex1Mul = avgGeomZero(lex0Mul, lexMul);
+        lex1Mul = avgGeomZero(lex0Mul, lexMul) * getMaskUnity(scaleX_map, linAddr); // link scale is stored in the lower cell
 
         mx1 = mx[linAddr];
         my1 = my[linAddr];
@@ -64,7 +65,7 @@ __global__ void exchange6Kern(float* __restrict__ hx, float* __restrict__  hy, f
         linAddr = idx * N1 * N2 + j * N2 + k;
 
         lexMul = fdivZero(getMaskUnity(Aex_map, linAddr), getMaskUnity(mSat_map, linAddr));
-        lex2Mul = avgGeomZero(lex0Mul, lexMul);
+        lex2Mul = avgGeomZero(lex0Mul, lexMul) * getMaskUnity(scaleX_map, I);
 
         mx2 = mx[linAddr];
         my2 = my[linAddr];
@@ -81,7 +82,7 @@ __global__ void exchange6Kern(float* __restrict__ hx, float* __restrict__  hy, f
         linAddr = i * N1 * N2 + j * N2 + idx;
 
         lexMul = fdivZero(getMaskUnity(Aex_map, linAddr), getMaskUnity(mSat_map, linAddr));
-        lex1Mul = avgGeomZero(lex0Mul, lexMul);
+        lex1Mul = avgGeomZero(lex0Mul, lexMul) * getMaskUnity(scaleZ_map, linAddr); // link scale is stored in the lower cell
 
         mx1 = mx[linAddr];
         my1 = my[linAddr];
@@ -93,7 +94,7 @@ __global__ void exchange6Kern(float* __restrict__ hx, float* __restrict__  hy, f
         linAddr = i * N1 * N2 + j * N2 + idx;
 
         lexMul = fdivZero(getMaskUnity(Aex_map, linAddr), getMaskUnity(mSat_map, linAddr));
-        lex2Mul = avgGeomZero(lex0Mul, lexMul);
+        lex2Mul = avgGeomZero(lex0Mul, lexMul) * getMaskUnity(scaleZ_map, I);
 
         mx2 = mx[linAddr];
         my2 = my[linAddr];
@@ -110,7 +111,7 @@ __global__ void exchange6Kern(float* __restrict__ hx, float* __restrict__  hy, f
         linAddr = i * N1 * N2 + idx * N2 + k;
 
         lexMul = fdivZero(getMaskUnity(Aex_map, linAddr), getMaskUnity(mSat_map, linAddr));
-        lex1Mul = avgGeomZero(lex0Mul, lexMul);
+        lex1Mul = avgGeomZero(lex0Mul, lexMul) * getMaskUnity(scaleY_map, linAddr); // link scale is stored in the lower cell
 
         mx1 = mx[linAddr];
         my1 = my[linAddr];
@@ -122,7 +123,7 @@ __global__ void exchange6Kern(float* __restrict__ hx, float* __restrict__  hy, f
         linAddr = i * N1 * N2 + idx * N2 + k;
 
         lexMul = fdivZero(getMaskUnity(Aex_map, linAddr), getMaskUnity(mSat_map, linAddr));
-        lex2Mul = avgGeomZero(lex0Mul, lexMul);
+        lex2Mul = avgGeomZero(lex0Mul, lexMul) * getMaskUnity(scaleY_map, I);
 
         mx2 = mx[linAddr];
         my2 = my[linAddr];
@@ -142,7 +143,7 @@ __global__ void exchange6Kern(float* __restrict__ hx, float* __restrict__  hy, f
 }
 
 
-__export__ void exchange6Async(float** hx, float** hy, float** hz, float** mx, float** my, float** mz, float** msat, float** aex, float Aex2_mu0MsatMul, int N0, int N1Part, int N2, int periodic0, int periodic1, int periodic2, float cellSizeX, float cellSizeY, float cellSizeZ, CUstream* streams)
+__export__ void exchange6Async(float** hx, float** hy, float** hz, float** mx, float** my, float** mz, float** msat, float** aex, float** scaleX, float** scaleY, float** scaleZ, float Aex2_mu0MsatMul, int N0, int N1Part, int N2, int periodic0, int periodic1, int periodic2, float cellSizeX, float cellSizeY, float cellSizeZ, CUstream* streams)
 {
 
     dim3 gridsize, blocksize;
@@ -158,7 +159,7 @@ __export__ void exchange6Async(float** hx, float** hy, float** hz, float** mx, f
     for (int dev = 0; dev < nDev; dev++)
     {
         gpu_safe(cudaSetDevice(deviceId(dev)));
-        exchange6Kern <<< gridsize, blocksize, 0, cudaStream_t(streams[dev])>>>(hx[dev], hy[dev], hz[dev], mx[dev], my[dev], mz[dev], msat[dev], aex[dev], Aex2_mu0MsatMul, N0, N1Part, N2, periodic0, periodic1, periodic2, cellx_2, celly_2, cellz_2);
+        exchange6Kern <<< gridsize, blocksize, 0, cudaStream_t(streams[dev])>>>(hx[dev], hy[dev], hz[dev], mx[dev], my[dev], mz[dev], msat[dev], aex[dev], scaleX[dev], scaleY[dev], scaleZ[dev], Aex2_mu0MsatMul, N0, N1Part, N2, periodic0, periodic1, periodic2, cellx_2, celly_2, cellz_2);
     }
 }
 
@@ -17,7 +17,8 @@ extern "C" {
 #endif
 
 
-DLLEXPORT void exchange6Async(float** hx, float** hy, float** hz, float** mx, float** my, float** mz, float** msat, float** aex, float Aex2_mu0MsatMul, int N0, int N1Part, int N2, int periodic0, int periodic1, int periodic2, float cellSizeX, float cellSizeY, float cellSizeZ, CUstream* streams);
+/// @param scaleX, scaleY, scaleZ scaling of the exchange with the next cell along each axis (NULL: 1)
+DLLEXPORT void exchange6Async(float** hx, float** hy, float** hz, float** mx, float** my, float** mz, float** msat, float** aex, float** scaleX, float** scaleY, float** scaleZ, float Aex2_mu0MsatMul, int N0, int N1Part, int N2, int periodic0, int periodic1, int periodic2, float cellSizeX, float cellSizeY, float cellSizeZ, CUstream* streams);
 
 
 #ifdef __cplusplus
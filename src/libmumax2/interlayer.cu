@@ -0,0 +1,92 @@
+#include "interlayer.h"
+
+#include "multigpu.h"
+#include <cuda.h>
+#include "gpu_conf.h"
+#include "gpu_safe.h"
+#include "common_func.h"
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+__global__ void interlayerExchangeKern(float* hx, float* hy, float* hz,
+                                       float* mx, float* my, float* mz,
+                                       float* mSat_map,
+                                       float* J1_map, float J1_mul,
+                                       float* J2_map, float J2_mul,
+                                       float* dz_map, int layerStride,
+                                       int Npart)
+{
+
+    int i = threadindex;
+
+    if (i < Npart)
+    {
+
+        int dz = (int)dz_map[i];
+        if (dz == 0)   // not coupled
+        {
+            hx[i] = 0.0f;
+            hy[i] = 0.0f;
+            hz[i] = 0.0f;
+            return;
+        }
+        int i2 = i + dz * layerStride;
+
+        // coupling constants: average of both cells
+        float J1 = J1_mul * 0.5f * (getMaskUnity(J1_map, i) + getMaskUnity(J1_map, i2));
+        float J2 = J2_mul * 0.5f * (getMaskUnity(J2_map, i) + getMaskUnity(J2_map, i2));
+
+        float m2x = mx[i2];
+        float m2y = my[i2];
+        float m2z = mz[i2];
+        float dot = mx[i] * m2x + my[i] * m2y + mz[i] * m2z;
+
+        float pre = fdivZero(J1 + 2.0f * J2 * dot, getMaskUnity(mSat_map, i));
+        hx[i] = pre * m2x;
+        hy[i] = pre * m2y;
+        hz[i] = pre * m2z;
+    }
+
+}
+
+
+
+__export__ void interlayerExchangeAsync(float** hx, float** hy, float** hz,
+                                        float** mx, float** my, float** mz,
+                                        float** msat,
+                                        float** J1_map, float J1_mu0MsatMul_t,
+                                        float** J2_map, float J2_mu0MsatMul_t,
+                                        float** dz_map, int layerStride,
+                                        CUstream* stream, int Npart)
+{
+
+    dim3 gridSize, blockSize;
+    make1dconf(Npart, &gridSize, &blockSize);
+
+    for (int dev = 0; dev < nDevice(); dev++)
+    {
+        assert(hx[dev] != NULL);
+        assert(hy[dev] != NULL);
+        assert(hz[dev] != NULL);
+        assert(mx[dev] != NULL);
+        assert(my[dev] != NULL);
+        assert(mz[dev] != NULL);
+        assert(dz_map[dev] != NULL);
+        gpu_safe(cudaSetDevice(deviceId(dev)));
+
+        interlayerExchangeKern <<< gridSize, blockSize, 0, cudaStream_t(stream[dev])>>> (
+            hx[dev], hy[dev], hz[dev],
+            mx[dev], my[dev], mz[dev],
+            msat[dev],
+            J1_map[dev], J1_mu0MsatMul_t,
+            J2_map[dev], J2_mu0MsatMul_t,
+            dz_map[dev], layerStride,
+            Npart);
+    }
+}
+
+#ifdef __cplusplus
+}
+#endif
@@ -0,0 +1,34 @@
+/**
+  * @file
+  * This file implements the interlayer (RKKY) exchange field
+  *
+  * @author Arne Vansteenkiste
+  */
+
+#ifndef _INTERLAYER_H_
+#define _INTERLAYER_H_
+
+#include <cuda.h>
+#include "cross_platform.h"
+
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/// Each cell with dz_map != 0 is coupled to the cell dz_map layers above it.
+/// @param J1_mu0MsatMul_t J1 / (Mu0 * Msat.multiplier * cell thickness), idem for J2
+/// @param layerStride number of floats in one z layer per GPU
+/// @param Npart number of floats per GPU, so total number of floats / nDevice()
+DLLEXPORT void interlayerExchangeAsync(float** hx, float** hy, float** hz,
+                                       float** mx, float** my, float** mz,
+                                       float** msat,
+                                       float** J1_map, float J1_mu0MsatMul_t,
+                                       float** J2_map, float J2_mu0MsatMul_t,
+                                       float** dz_map, int layerStride,
+                                       CUstream* stream, int Npart);
+
+#ifdef __cplusplus
+}
+#endif
+#endif
@@ -0,0 +1,104 @@
+#include "sot.h"
+
+#include "multigpu.h"
+#include <cuda.h>
+#include "gpu_conf.h"
+#include "gpu_safe.h"
+#include "common_func.h"
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+__global__ void sotKern(float* __restrict__ tx, float* __restrict__ ty, float* __restrict__ tz,
+                        float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+                        float* __restrict__ msat,
+                        float* __restrict__ jx, float* __restrict__ jy, float* __restrict__ jz,
+                        float3 jMul,
+                        float* __restrict__ theta_map,
+                        float* __restrict__ xiDL_map, float preDL,
+                        float* __restrict__ xiFL_map, float preFL,
+                        float* __restrict__ t_map,
+                        float* __restrict__ alpha_map, float alphaMul,
+                        float3 n,
+                        int Npart)
+{
+
+    int i = threadindex;
+
+    if (i < Npart)
+    {
+
+        float3 J = make_float3(jMul.x * getMaskUnity(jx, i), jMul.y * getMaskUnity(jy, i), jMul.z * getMaskUnity(jz, i));
+        float3 s = normalize(crossf(J, n)); // polarization of the spin current
+
+        // |j| theta / (msat t), 0 outside the magnet
+        float pre = fdivZero(len(J) * getMaskUnity(theta_map, i), getMaskUnity(msat, i) * getMaskUnity(t_map, i));
+        float a = preDL * getMaskUnity(xiDL_map, i) * pre;
+        float b = preFL * getMaskUnity(xiFL_map, i) * pre;
+
+        float alpha = alphaMul * getMaskUnity(alpha_map, i);
+        float alphaFac = 1.0f / (1.0f + alpha * alpha);
+
+        float3 m = make_float3(mx[i], my[i], mz[i]);
+        float3 sxm = crossf(s, m);
+        float3 mxsxm = crossf(m, sxm);
+
+        float mxsxmFac = (a + alpha * b) * alphaFac;
+        float sxmFac = (b - alpha * a) * alphaFac;
+
+        tx[i] = mxsxmFac * mxsxm.x + sxmFac * sxm.x;
+        ty[i] = mxsxmFac * mxsxm.y + sxmFac * sxm.y;
+        tz[i] = mxsxmFac * mxsxm.z + sxmFac * sxm.z;
+    }
+}
+
+
+__export__ void sotAsync(float** tx, float** ty, float** tz,
+                         float** mx, float** my, float** mz,
+                         float** msat,
+                         float** jx, float** jy, float** jz,
+                         float jxMul, float jyMul, float jzMul,
+                         float** theta_map,
+                         float** xiDL_map, float preDL,
+                         float** xiFL_map, float preFL,
+                         float** t_map,
+                         float** alpha_map, float alphaMul,
+                         float nx, float ny, float nz,
+                         CUstream* stream, int Npart)
+{
+
+    dim3 gridSize, blockSize;
+    make1dconf(Npart, &gridSize, &blockSize);
+    float3 jMul = make_float3(jxMul, jyMul, jzMul);
+    float3 n = make_float3(nx, ny, nz);
+
+    for (int dev = 0; dev < nDevice(); dev++)
+    {
+        assert(tx[dev] != NULL);
+        assert(ty[dev] != NULL);
+        assert(tz[dev] != NULL);
+        assert(mx[dev] != NULL);
+        assert(my[dev] != NULL);
+        assert(mz[dev] != NULL);
+        gpu_safe(cudaSetDevice(deviceId(dev)));
+
+        sotKern <<< gridSize, blockSize, 0, cudaStream_t(stream[dev])>>> (
+            tx[dev], ty[dev], tz[dev],
+            mx[dev], my[dev], mz[dev],
+            msat[dev],
+            jx[dev], jy[dev], jz[dev],
+            jMul,
+            theta_map[dev],
+            xiDL_map[dev], preDL,
+            xiFL_map[dev], preFL,
+            t_map[dev],
+            alpha_map[dev], alphaMul,
+            n,
+            Npart);
+    }
+}
+
+#ifdef __cplusplus
+}
+#endif
@@ -0,0 +1,42 @@
+/**
+  * @file
+  * This file implements the spin-orbit torque of a spin Hall current
+  * from a heavy-metal layer
+  *
+  * @author Arne Vansteenkiste
+  */
+
+#ifndef _SOT_H_
+#define _SOT_H_
+
+#include <cuda.h>
+#include "cross_platform.h"
+
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/// Torque (LL form of the Gilbert torque a m x (s x m) + b s x m):
+/// [(a + alpha b) m x (s x m) + (b - alpha a) s x m] / (1 + alpha²),
+/// with polarization s = unit(j x n), a = preDL xiDL theta |j| / (msat t), b = idem with FL.
+/// @param preDL gamma hbar theta_mul xiDL_mul / (2 e Mu0 msat_mul t_mul), idem for preFL
+/// @param nx, ny, nz interface normal
+/// @param Npart number of floats per GPU, so total number of floats / nDevice()
+DLLEXPORT void sotAsync(float** tx, float** ty, float** tz,
+                        float** mx, float** my, float** mz,
+                        float** msat,
+                        float** jx, float** jy, float** jz,
+                        float jxMul, float jyMul, float jzMul,
+                        float** theta_map,
+                        float** xiDL_map, float preDL,
+                        float** xiFL_map, float preFL,
+                        float** t_map,
+                        float** alpha_map, float alphaMul,
+                        float nx, float ny, float nz,
+                        CUstream* stream, int Npart);
+
+#ifdef __cplusplus
+}
+#endif
+#endif
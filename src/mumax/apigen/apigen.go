@@ -58,7 +58,7 @@ func APIGen() {
 		out, err := os.Create(file)
 		CheckErr(err, ERR_IO)
 
-		fmt.Fprintln(out, lang.Comment(), "This file is automatically generated by mumax2-apigen. DO NOT EDIT.\n")
+		fmt.Fprint(out, lang.Comment(), " This file is automatically generated by mumax2-apigen. DO NOT EDIT.\n\n")
 
 		lang.WriteHeader(out)
 
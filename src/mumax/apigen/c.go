@@ -24,7 +24,7 @@ func (x *c) comment() string {
 }
 
 func (x *c) writeHeader(out io.Writer) {
-	fmt.Fprintln(out, `
+	fmt.Fprint(out, `
 #ifndef MUMAX2_H
 #define MUMAX2_H
 
@@ -32,6 +32,7 @@ func (x *c) writeHeader(out io.Writer) {
 extern "C" {
 #endif
 
+
 `)
 }
 
@@ -24,7 +24,7 @@ func (j *Java) Comment() string {
 }
 
 func (j *Java) WriteHeader(out io.Writer) {
-	fmt.Fprintln(out, `
+	fmt.Fprint(out, `
 import java.io.*;
 
 public class Mumax2{
@@ -63,6 +63,7 @@ public class Mumax2{
 		System.exit(-2); // unreachable
 		return "bug";
 	}
+
 `)
 }
 
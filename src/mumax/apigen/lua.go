@@ -24,8 +24,7 @@ func (l *Lua) Comment() string {
 }
 
 func (p *Lua) WriteHeader(out io.Writer) {
-	fmt.Fprintln(out, `
-`)
+	fmt.Fprint(out, "\n\n")
 }
 
 func (l *Lua) WriteFooter(out io.Writer) {
@@ -25,11 +25,13 @@ func (p *Python) Comment() string {
 }
 
 func (p *Python) WriteHeader(out io.Writer) {
-	fmt.Fprintln(out, `
+	fmt.Fprint(out, `
 import os
 import json
 import sys
 import socket
+import struct
+import array
 
 
 m_sock = socket.socket(socket.AF_INET, socket.SOCK_STREAM)
@@ -63,37 +65,128 @@ def init():
 #	print 'python frontend is initialized'
 
 ## Calls a mumax2 command and returns the result as string.
+# 4D arrays (like for setarray, getarray) are transferred in binary,
+# see mumax/frontend/rpcbinary.go.
 # @note Internal use only.
 def call(command, args):
 	if (initialized == 0):
 		init()
-	m_sock.sendall(json.dumps([command, args])+'\n')
+	args = list(args)
+	frames = []
+	for i in range(len(args)):
+		if isarray(args[i]):
+			args[i], frame = encodearray(args[i])
+			frames.append(frame)
+	m_sock.sendall(json.dumps([command, args, {'binary': True}])+'\n' + ''.join(frames))
 	resp = recvall(m_sock)	     
-	return json.loads(resp)
+	ret = json.loads(resp)
+	if isinstance(ret, dict) and 'error' in ret:
+		raise mumaxError(ret['error'])
+	for i in range(len(ret)):
+		if isinstance(ret[i], dict) and 'array' in ret[i]:
+			ret[i] = recvarray(ret[i]['array'])
+	return ret
+
+## Tells whether a is a 4D list, like [comp][x][y][z].
+# @note Internal use only.
+def isarray(a):
+	for i in range(4):
+		if not isinstance(a, list) or len(a) == 0:
+			return False
+		a = a[0]
+	return True
+
+## Returns the JSON placeholder and binary frame for a 4D list.
+# @note Internal use only.
+def encodearray(a):
+	size = [len(a), len(a[0]), len(a[0][0]), len(a[0][0][0])]
+	data = array.array('f')
+	for comp in a:
+		for plane in comp:
+			for row in plane:
+				data.extend([float(v) for v in row])
+	if len(data) != size[0]*size[1]*size[2]*size[3]:
+		raise InputError(-32000, 'array is not rectangular, size ' + str(size))
+	if sys.byteorder != 'little':
+		data.byteswap()
+	return {'array': size}, '#f32' + struct.pack('<Q', 4*len(data)) + data.tostring()
+
+## Receives a binary frame and returns it as a 4D list of the size [comp, nx, ny, nz].
+# @note Internal use only.
+def recvarray(size):
+	head = recvn(m_sock, 12)
+	if head[:4] != '#f32':
+		raise MumaxIOError(-32000, 'bad array frame')
+	nbytes = struct.unpack('<Q', head[4:])[0]
+	data = array.array('f')
+	data.fromstring(recvn(m_sock, nbytes))
+	if sys.byteorder != 'little':
+		data.byteswap()
+	flat = data.tolist()
+	nc, nx, ny, nz = size
+	return [[[flat[((c*nx + x)*ny + y)*nz : ((c*nx + x)*ny + y + 1)*nz] for y in range(ny)] for x in range(nx)] for c in range(nc)]
+
+## Raised when mumax2 reports an error, e.g. a misspelled quantity name.
+# The engine keeps running, so the script may catch it and continue,
+# but a command that failed halfway may have changed part of the simulation state.
+class MumaxError(Exception):
+	def __init__(self, code, message):
+		Exception.__init__(self, message)
+		self.code = code
+		self.message = message
+
+## Raised on illegal input (mumax InputErr).
+class InputError(MumaxError):
+	pass
+
+## Raised on an I/O error in mumax2 (mumax IOErr).
+class MumaxIOError(MumaxError):
+	pass
+
+## Converts an RPC error object to the corresponding exception.
+# @note Internal use only.
+def mumaxError(err):
+	kind = {'InputErr': InputError, 'IOErr': MumaxIOError}.get(err.get('data'), MumaxError)
+	return kind(err['code'], err['message'])
 
 End='<<< End of mumax message >>>'
 
+# data received after the last message, e.g. array frames
+m_buf = ''
+
 ## Retrieving message until the EOM statement
 # @note Internal use only.
 def recvall(the_socket):
-    total_data=[];data=''
-    while True:
-            data=the_socket.recv(8192)
-            if data == '':
-                sys.exit(1)
-            if End in data:
-                total_data.append(data[:data.find(End)])
-                break
-            total_data.append(data)
-            if len(total_data)>1:
-                #check if end_of_data was split
-                last_pair=total_data[-2]+total_data[-1]
-                if End in last_pair:
-                    total_data[-2]=last_pair[:last_pair.find(End)]
-                    total_data.pop()
-                    break
-    return ''.join(total_data)
+	global m_buf
+	start = 0
+	while m_buf.find(End, start) < 0:
+		start = max(0, len(m_buf) - len(End))
+		data = the_socket.recv(8192)
+		if data == '':
+			sys.exit(1)
+		m_buf += data
+	i = m_buf.find(End, start)
+	resp = m_buf[:i]
+	m_buf = m_buf[i+len(End):]
+	return resp
+
+## Receives exactly n bytes.
+# @note Internal use only.
+def recvn(the_socket, n):
+	global m_buf
+	chunks = [m_buf]
+	have = len(m_buf)
+	while have < n:
+		data = the_socket.recv(min(1<<20, max(8192, n - have)))
+		if data == '':
+			sys.exit(1)
+		chunks.append(data)
+		have += len(data)
+	data = ''.join(chunks)
+	m_buf = data[n:]
+	return data[:n]
 		
+
 `)
 }
 
@@ -110,7 +203,7 @@ func (p *Python) WriteFunc(out io.Writer, name string, comment []string, argName
 	}()
 
 	fmt.Fprintln(out)
-	fmt.Fprintf(out, pyDocComment(comment))
+	fmt.Fprint(out, pyDocComment(comment))
 	fmt.Fprint(out, "def ", name, "(")
 
 	args := ""
@@ -28,8 +28,7 @@ func (p *Tex) Comment() string {
 }
 
 func (p *Tex) WriteHeader(out io.Writer) {
-	fmt.Fprintln(out, `
-`)
+	fmt.Fprint(out, "\n\n")
 }
 
 func (p *Tex) WriteFooter(out io.Writer) {
@@ -0,0 +1,60 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package convert
+
+import (
+	"mumax/host"
+	"testing"
+)
+
+// Field of user size 4x3x2 with component c at cell x,y,z = 1000*c + 100*z + 10*y + x.
+func testField() *Field {
+	data := host.NewArray(3, []int{2, 3, 4})
+	for c := range data.Array {
+		for i := range data.Array[c] {
+			for j := range data.Array[c][i] {
+				for k := range data.Array[c][i][j] {
+					data.Array[c][i][j][k] = float32(1000*(2-c) + 100*i + 10*j + k)
+				}
+			}
+		}
+	}
+	return &Field{Data: data, Name: "m", CellSize: [3]float64{3, 2, 1}}
+}
+
+func TestProcess(t *testing.T) {
+	f := testField()
+	f.Component(1)
+	if f.Name != "m_y" || f.Data.NComp() != 1 || f.Data.Array[0][1][2][3] != 1123 {
+		t.Error("component:", f.Name, f.Data.Array[0][1][2][3])
+	}
+
+	min, max := parseCrop("1:3,:,1:", f.Data.Size3D)
+	if min != [3]int{1, 0, 1} || max != [3]int{3, 3, 2} {
+		t.Error("parseCrop:", min, max)
+	}
+	f.Crop(min, max)
+	if size := f.Data.Size3D; size[0] != 1 || size[1] != 3 || size[2] != 2 || f.Data.Array[0][0][2][1] != 1122 {
+		t.Error("crop:", f.Data.Size3D, f.Data.Array)
+	}
+
+	f.Average(1) // over y
+	if size := f.Data.Size3D; size[1] != 1 || f.Data.Array[0][0][0][0] != 1111 || f.CellSize[1] != 6 {
+		t.Error("average:", f.Data.Array, f.CellSize)
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	*flag_format = "vtk"
+	if out := outputFile("dir/m000001.omf", 0, 1); out != "dir/m000001.vtk" {
+		t.Error(out)
+	}
+	if out := outputFile("dir/m.dump", 2, 3); out != "dir/m_000002.vtk" {
+		t.Error(out)
+	}
+}
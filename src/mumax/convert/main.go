@@ -0,0 +1,331 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package convert
+
+// This file implements the mumax2-convert command:
+//
+//	mumax2-convert [flags] file|directory ...
+//
+// It reads any format mumax2 can read (.omf, .ovf, .dump, .dumpc, .bin, .png),
+// optionally processes the data, and writes it in any output format,
+// or prints information about it. Directories are converted file by file,
+// several files in parallel. E.g.:
+//
+//	mumax2-convert -o vti -options zlib sim.out
+//	mumax2-convert -comp z -avg z -o png -options colormap=rdbu,colorbar m000100.omf
+//	mumax2-convert -info m.dump
+
+import (
+	"flag"
+	"fmt"
+	. "mumax/common"
+	"mumax/engine"
+	_ "mumax/ovf" // registers the OVF formats
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	flag_format     = flag.String("o", "", "Output format, e.g.: omf, ovf, vtk, vti, png, txt, gplot, dump, dumpc, bin")
+	flag_options    = flag.String("options", "", "Comma-separated options for the output format, e.g.: zlib")
+	flag_dir        = flag.String("dir", "", "Output directory, default: that of the input file")
+	flag_info       = flag.Bool("info", false, "Print header information and statistics")
+	flag_comp       = flag.String("comp", "", "Keep only this component: x, y, z or its number")
+	flag_crop       = flag.String("crop", "", "Keep these cells, e.g.: 0:64,:,2:3 (start:stop for x,y,z, empty means all)")
+	flag_resample   = flag.String("resample", "", "Resample to this grid size, e.g.: 128,32,1")
+	flag_resampling = flag.String("resampling", engine.NEAREST, "Resampling mode: nearest, trilinear or average")
+	flag_avg        = flag.String("avg", "", "Average over these axes, e.g.: z or xy")
+	flag_cellsize   = flag.String("cellsize", "", "Cell size for files that do not store it (.bin, .png), e.g.: 4e-9,4e-9,3e-9")
+	flag_parallel   = flag.Int("p", runtime.NumCPU(), "Number of files to convert in parallel")
+)
+
+const USAGE = `usage: mumax2-convert [flags] file|directory ...
+Processing happens in the order: -comp, -crop, -resample, -avg.
+flags:`
+
+func Main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, USAGE)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fatal(USAGE)
+	}
+	if *flag_format == "" && !*flag_info {
+		fatal("nothing to do: need -o format and/or -info")
+	}
+	if *flag_format != "" {
+		catch(func() { engine.GetOutputFormat(*flag_format) })
+	}
+	if *flag_dir != "" {
+		check(os.MkdirAll(*flag_dir, 0777))
+	}
+
+	files := inputFiles(flag.Args())
+	if len(files) == 0 {
+		fatal("no input files")
+	}
+	if *flag_format != "" {
+		checkOutputNames(files)
+	}
+
+	// convert in parallel, report all errors
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex // serializes -info output
+	status := 0
+	for i := 0; i < *flag_parallel || i == 0; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				info, err := convert(file)
+				mutex.Lock()
+				fmt.Print(info)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "mumax2-convert: "+err.Error())
+					status = 1
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, f := range files {
+		jobs <- f
+	}
+	close(jobs)
+	wg.Wait()
+	os.Exit(status)
+}
+
+// Converts all frames in the file.
+// Returns the -info output, and the error that stopped the conversion, if any.
+func convert(file string) (info string, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("%v: %v", file, e)
+		}
+	}()
+
+	fields := Read(file)
+	for i, f := range fields {
+		process(f)
+		if *flag_info {
+			if len(fields) > 1 {
+				info += fmt.Sprintf("%v frame %v:\n", file, i)
+			} else {
+				info += file + ":\n"
+			}
+			info += f.Info()
+		}
+		if *flag_format != "" {
+			out := outputFile(file, i, len(fields))
+			if abs(out) == abs(file) {
+				panic(InputErr("output would overwrite the input file, use -dir"))
+			}
+			write(f, out)
+		}
+	}
+	return info, nil
+}
+
+// Applies the processing flags.
+func process(f *Field) {
+	if *flag_cellsize != "" {
+		s := parseFloats(*flag_cellsize, "cellsize")
+		f.CellSize = [3]float64{s[Z], s[Y], s[X]}
+	}
+	if *flag_comp != "" {
+		f.Component(parseComp(*flag_comp))
+	}
+	if *flag_crop != "" {
+		min, max := parseCrop(*flag_crop, f.Data.Size3D)
+		f.Crop(min, max)
+	}
+	if *flag_resample != "" {
+		f.Resample(parseInts(*flag_resample, "resample"), *flag_resampling)
+	}
+	for _, a := range *flag_avg {
+		f.Average(parseAxis(string(a)))
+	}
+}
+
+func write(f *Field, fname string) {
+	out := OpenWRONLY(fname)
+	defer out.Close()
+	bufout := Buffer(out)
+	defer bufout.Flush()
+	var options []string
+	if *flag_options != "" {
+		options = strings.Split(*flag_options, ",")
+	}
+	engine.WriteArray(bufout, *flag_format, options, f.Data, f.Name, engine.Unit(f.Unit), f.CellSize[:], f.Time)
+}
+
+// Output file name for frame i of n in the input file.
+// Frames of a multi-frame file m.dump are numbered m_000000.vtk, m_000001.vtk, ...
+// so they do not clash with autosaved files like m000001.omf.
+func outputFile(file string, i, n int) string {
+	base := strings.TrimSuffix(file, filepath.Ext(file))
+	if n > 1 {
+		base = fmt.Sprintf("%v_%06d", base, i)
+	}
+	if *flag_dir != "" {
+		base = filepath.Join(*flag_dir, filepath.Base(base))
+	}
+	return base + "." + *flag_format
+}
+
+// Exits if two input files would be written to the same output file,
+// like m.omf and m.dump to m.vtk.
+func checkOutputNames(files []string) {
+	seen := make(map[string]string)
+	for _, f := range files {
+		out := abs(outputFile(f, 0, 1))
+		if other, ok := seen[out]; ok {
+			fatal(other, " and ", f, " would both be written to ", outputFile(f, 0, 1))
+		}
+		seen[out] = f
+	}
+}
+
+// Expands directories to the files in them that can be read.
+func inputFiles(args []string) []string {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		check(err)
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		dir, err := os.ReadDir(arg)
+		check(err)
+		var names []string
+		for _, e := range dir {
+			if !e.IsDir() && engine.CanReadFile(e.Name()) {
+				names = append(names, filepath.Join(arg, e.Name()))
+			}
+		}
+		sort.Strings(names)
+		files = append(files, names...)
+	}
+	return files
+}
+
+// Parses x, y, z or a component number.
+func parseComp(s string) int {
+	switch strings.ToLower(s) {
+	case "x":
+		return X
+	case "y":
+		return Y
+	case "z":
+		return Z
+	}
+	return Atoi(s)
+}
+
+func parseAxis(s string) int {
+	switch strings.ToLower(s) {
+	case "x":
+		return X
+	case "y":
+		return Y
+	case "z":
+		return Z
+	}
+	panic(InputErr("illegal axis: " + s + ", must be x, y or z"))
+}
+
+// Parses "x0:x1,y0:y1,z0:z1", where missing bounds mean the whole range.
+// size3D is the internal grid size.
+func parseCrop(s string, size3D []int) (min, max [3]int) {
+	ranges := strings.Split(s, ",")
+	if len(ranges) != 3 {
+		panic(InputErr("crop needs 3 ranges, e.g.: 0:64,:,2:3"))
+	}
+	for a, r := range ranges {
+		max[a] = size3D[internal(a)]
+		if r == "" || r == ":" {
+			continue
+		}
+		bounds := strings.Split(r, ":")
+		if len(bounds) != 2 {
+			panic(InputErr("crop: illegal range: " + r + ", must be start:stop"))
+		}
+		if bounds[0] != "" {
+			min[a] = Atoi(bounds[0])
+		}
+		if bounds[1] != "" {
+			max[a] = Atoi(bounds[1])
+		}
+	}
+	return
+}
+
+func parseInts(s, flag string) [3]int {
+	f := parseFloats(s, flag)
+	var i [3]int
+	for a := range f {
+		i[a] = int(f[a])
+		if float64(i[a]) != f[a] {
+			panic(InputErr(flag + ": not an integer: " + s))
+		}
+	}
+	return i
+}
+
+// Parses 3 comma-separated numbers, in user XYZ order.
+func parseFloats(s, flag string) [3]float64 {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		panic(InputErr(flag + " needs 3 comma-separated numbers, have: " + s))
+	}
+	var f [3]float64
+	for a, p := range parts {
+		f[a] = Atof64(strings.TrimSpace(p))
+	}
+	return f
+}
+
+func abs(file string) string {
+	a, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+	return a
+}
+
+// Runs f, exits on panic.
+func catch(f func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			fatal(err)
+		}
+	}()
+	f()
+}
+
+// Exits on error.
+func check(err error) {
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// Prints the message and exits.
+func fatal(msg ...interface{}) {
+	fmt.Fprintln(os.Stderr, "mumax2-convert: "+fmt.Sprint(msg...))
+	os.Exit(1)
+}
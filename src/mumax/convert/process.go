@@ -0,0 +1,121 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package convert
+
+// This file implements the operations on fields.
+// Axes and components are numbered in user order: 0=x, 1=y, 2=z,
+// while the arrays and cell sizes are stored in internal ZYX order.
+
+import (
+	"bytes"
+	"fmt"
+	. "mumax/common"
+	"mumax/engine"
+	"mumax/host"
+)
+
+// Keeps only component c.
+func (f *Field) Component(c int) {
+	ncomp := f.Data.NComp()
+	if c < 0 || c >= ncomp {
+		panic(InputErr(fmt.Sprint("no component ", c, ", have ", ncomp)))
+	}
+	comp := host.NewArray(1, f.Data.Size3D)
+	copy(comp.List, f.Data.Comp[SwapIndex(c, ncomp)])
+	f.Data = comp
+	if ncomp == 3 {
+		f.Name += "_" + string('x'+rune(c))
+	} else {
+		f.Name += fmt.Sprint("_", c)
+	}
+}
+
+// Keeps the cells from min (inclusive) to max (exclusive), in user XYZ order.
+func (f *Field) Crop(min, max [3]int) {
+	size := f.Data.Size3D
+	var size2 [3]int
+	for a := range min {
+		n := size[internal(a)]
+		if min[a] < 0 || max[a] > n || min[a] >= max[a] {
+			panic(InputErr(fmt.Sprint("crop: illegal ", axisName[a], " range ", min[a], ":", max[a], ", have ", n, " cells")))
+		}
+		size2[internal(a)] = max[a] - min[a]
+	}
+	out := host.NewArray(f.Data.NComp(), size2[:])
+	for c := range out.Array {
+		for i := range out.Array[c] {
+			for j := range out.Array[c][i] {
+				copy(out.Array[c][i][j], f.Data.Array[c][i+min[Z]][j+min[Y]][min[X]:max[X]])
+			}
+		}
+	}
+	f.Data = out
+}
+
+// Resamples to the grid size, in user XYZ order,
+// with mode engine.NEAREST, TRILINEAR or AVERAGE.
+// The cell size is scaled so that the total size stays the same.
+func (f *Field) Resample(size [3]int, mode string) {
+	size2 := []int{size[Z], size[Y], size[X]}
+	for i, n := range size2 {
+		if n < 1 {
+			panic(InputErr(fmt.Sprint("resample: illegal size ", size)))
+		}
+		f.CellSize[i] *= float64(f.Data.Size3D[i]) / float64(n)
+	}
+	f.Data = engine.ResampleMode(f.Data, size2, mode, false)
+}
+
+// Averages over the user axis, leaving one cell along it.
+func (f *Field) Average(axis int) {
+	var size [3]int
+	for a := range size {
+		size[a] = f.Data.Size3D[internal(a)]
+	}
+	size[axis] = 1
+	f.Resample(size, engine.AVERAGE)
+}
+
+// Header information and statistics.
+func (f *Field) Info() string {
+	var b bytes.Buffer
+	size, cell := f.Data.Size3D, f.CellSize
+	fmt.Fprintln(&b, "quantity:  ", f.Name)
+	fmt.Fprintln(&b, "unit:      ", f.Unit)
+	fmt.Fprintln(&b, "time:      ", f.Time)
+	fmt.Fprintln(&b, "components:", f.Data.NComp())
+	fmt.Fprintln(&b, "gridsize:  ", size[Z], size[Y], size[X])
+	fmt.Fprintln(&b, "cellsize:  ", cell[Z], cell[Y], cell[X])
+	for c := 0; c < f.Data.NComp(); c++ {
+		min, max, avg := stats(f.Data.Comp[SwapIndex(c, f.Data.NComp())])
+		fmt.Fprintf(&b, "component %v: min %v max %v average %v\n", c, min, max, avg)
+	}
+	return b.String()
+}
+
+func stats(data []float32) (min, max float32, avg float64) {
+	min, max = data[0], data[0]
+	for _, d := range data {
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+		avg += float64(d)
+	}
+	avg /= float64(len(data))
+	return
+}
+
+var axisName = [3]string{"x", "y", "z"}
+
+// Internal (ZYX) index of user axis a.
+func internal(a int) int {
+	return SwapIndex(a, 3)
+}
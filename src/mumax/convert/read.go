@@ -0,0 +1,134 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package convert
+
+// This file implements reading fields with their metadata.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	. "mumax/common"
+	"mumax/dump"
+	"mumax/engine"
+	"mumax/host"
+	"mumax/ovf"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// A field read from a file, with what is known about it.
+type Field struct {
+	Data     *host.Array
+	Name     string     // quantity name, e.g. "m"
+	Unit     string     // unit of the values, e.g. "A/m"
+	CellSize [3]float64 // internal ZYX order, like Engine.CellSize(); 0 if unknown
+	Time     float64    // simulation time, 0 if unknown
+}
+
+// Reads all frames from a file: one for most formats,
+// possibly more for .dump (single-file autosave) and .dumpc.
+func Read(fname string) []*Field {
+	switch path.Ext(fname) {
+	case ".dump":
+		return readDump(fname)
+	case ".dumpc":
+		return readDumpC(fname)
+	case ".omf", ".ovf":
+		return []*Field{readOVF(fname)}
+	}
+	// formats without metadata, like .bin and .png
+	return []*Field{{Data: engine.ReadFile(fname), Name: quantName(fname)}}
+}
+
+func readDump(fname string) []*Field {
+	in := OpenRDONLY(fname)
+	defer in.Close()
+	r := dump.NewReader(bufio.NewReader(in), dump.CRC_ENABLED)
+	var fields []*Field
+	for {
+		err := r.Read()
+		if err == io.EOF && len(fields) > 0 {
+			return fields
+		}
+		if err != nil {
+			panic(IOErr(fname + ": " + err.Error()))
+		}
+		fields = append(fields, dumpField(fname, &r.Frame))
+	}
+}
+
+func readDumpC(fname string) []*Field {
+	c, err := dump.ReadContainer(fname)
+	CheckIO(err)
+	defer c.Close()
+	if c.NumFrames() == 0 {
+		panic(IOErr(fname + ": no frames"))
+	}
+	var fields []*Field
+	for {
+		f, err := c.Next()
+		if err == io.EOF {
+			return fields
+		}
+		if err != nil {
+			panic(IOErr(fname + ": " + err.Error()))
+		}
+		fields = append(fields, dumpField(fname, f))
+	}
+}
+
+func dumpField(fname string, f *dump.Frame) *Field {
+	if f.Precission != dump.FLOAT32 {
+		panic(IOErr(fname + ": unsupported precission: " + fmt.Sprint(f.Precission)))
+	}
+	data := host.NewArray(f.Components, f.MeshSize[:])
+	copy(data.List, f.Data)
+	name := f.DataLabel
+	if name == "" {
+		name = quantName(fname)
+	}
+	return &Field{Data: data, Name: name, Unit: f.DataUnit, CellSize: f.MeshStep, Time: f.Time}
+}
+
+func readOVF(fname string) *Field {
+	data, info := ovf.ReadOMFInfo(fname)
+	f := &Field{Data: data, Name: info.Title, Unit: info.ValueUnit}
+	f.CellSize = [3]float64{float64(info.StepSize[Z]), float64(info.StepSize[Y]), float64(info.StepSize[X])}
+
+	// mumax2 writes "mumax data" as the OVF 2.0 title, labels like "m_x" tell more
+	if f.Name == "" || f.Name == "mumax data" {
+		f.Name = quantName(fname)
+		if len(info.ValueLabels) > 0 {
+			f.Name = strings.TrimSuffix(info.ValueLabels[0], "_x")
+		}
+	}
+	if len(info.ValueUnits) > 0 && info.ValueUnits[0] != "1" {
+		f.Unit = info.ValueUnits[0]
+	}
+
+	// OVF 1.0 by mumax2: "Desc: Time: 1e-9", OVF 2.0: "Desc: Total simulation time: 1e-9 s"
+	for _, key := range []string{"Time", "Total simulation time"} {
+		if t, ok := info.Desc[key]; ok {
+			if t, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(t.(string), "s")), 64); err == nil {
+				f.Time = t
+			}
+		}
+	}
+	return f
+}
+
+// Guesses the quantity name from a file name like "m000001.omf".
+func quantName(fname string) string {
+	name := strings.TrimSuffix(path.Base(fname), path.Ext(fname))
+	if trimmed := strings.TrimRight(name, "0123456789_"); trimmed != "" {
+		return trimmed
+	}
+	return name
+}
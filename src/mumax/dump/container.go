@@ -0,0 +1,374 @@
+package dump
+
+// A container holds many dump frames in one file, with an index
+// so that any frame can be found without reading the ones before it.
+//
+// Layout, all 64-bit words, little-endian like the frames:
+//	magic "#dumpc01"
+//	offset of the first index chunk
+//	frames and index chunks, in the order they were written.
+//
+// An index chunk has room for a fixed number of entries, so that adding
+// a frame only needs to write its entry and the new count.
+// When it is full, a new chunk is added after the next frame:
+//	magic "#dindex1"
+//	capacity
+//	count
+//	offset of the next index chunk, 0 if none
+//	capacity entries of: frame offset, frame size, time, crc64 of the frame,
+//	label (MAX_LABEL bytes, padded with NULs)
+//
+// The frame headers only hold the first 8 bytes of the label,
+// the full label is in the index and returned by ReadFrame.
+//
+// The count is written after the frame and its entry,
+// so a container is valid at any time, even when the writer crashes.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+const (
+	CONTAINER_MAGIC = "#dumpc01"
+	INDEX_MAGIC     = "#dindex1"
+	INDEX_CAPACITY  = 512 // entries per index chunk
+	MAX_LABEL       = 64  // bytes of the label in an index entry
+)
+
+const (
+	containerHeaderSize = 16
+	chunkHeaderSize     = 32
+	entrySize           = 32 + MAX_LABEL
+	chunkSize           = chunkHeaderSize + INDEX_CAPACITY*entrySize
+)
+
+// Index entry of a frame in a container.
+type IndexEntry struct {
+	Offset int64   // Position of the frame in the file.
+	Size   int64   // Size of the frame in bytes.
+	Time   float64 // Header.Time of the frame.
+	Label  string  // Header.DataLabel of the frame, the quantity name.
+	CRC    uint64  // Checksum of the frame, as stored at its end.
+}
+
+// A container file opened for reading with ReadContainer,
+// or for appending with OpenContainer.
+type Container struct {
+	Index  []IndexEntry // Entries of all frames, in the order they were written.
+	file   *os.File
+	chunks []int64 // offsets of the index chunks
+	next   int     // frame returned by Next()
+	reader *Reader
+}
+
+// Opens a container for reading.
+func ReadContainer(fname string) (*Container, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	c := &Container{file: f}
+	if err := c.readIndex(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%v: %v", fname, err)
+	}
+	return c, nil
+}
+
+// Opens a container for reading and appending frames.
+// The file is created if it does not exist yet.
+func OpenContainer(fname string) (*Container, error) {
+	f, err := os.OpenFile(fname, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	c := &Container{file: f}
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		err = c.init()
+	} else {
+		err = c.readIndex()
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%v: %v", fname, err)
+	}
+	return c, nil
+}
+
+func (c *Container) Close() error {
+	return c.file.Close()
+}
+
+// Number of frames.
+func (c *Container) NumFrames() int {
+	return len(c.Index)
+}
+
+// Reads frame i, checking its CRC.
+// The returned frame is reused by the next ReadFrame or Next.
+func (c *Container) ReadFrame(i int) (*Frame, error) {
+	if i < 0 || i >= len(c.Index) {
+		return nil, fmt.Errorf("dump container: no frame %v, have %v frames", i, len(c.Index))
+	}
+	e := c.Index[i]
+	in := bufio.NewReader(io.NewSectionReader(c.file, e.Offset, e.Size))
+	if c.reader == nil {
+		c.reader = NewReader(in, CRC_ENABLED)
+	}
+	c.reader.in = in
+	c.reader.crc.Reset() // in case a previous read failed halfway
+	c.reader.Bytes = 0
+	if err := c.reader.Read(); err != nil {
+		return nil, err
+	}
+	c.reader.Frame.DataLabel = e.Label // not truncated
+	return &c.reader.Frame, nil
+}
+
+// Reads the frames one after another, starting at the frame set by Seek.
+// Returns io.EOF after the last frame.
+// The returned frame is reused by the next ReadFrame or Next.
+func (c *Container) Next() (*Frame, error) {
+	if c.next >= len(c.Index) {
+		return nil, io.EOF
+	}
+	c.next++
+	return c.ReadFrame(c.next - 1)
+}
+
+// Sets the frame that will be returned by the next call to Next.
+func (c *Container) Seek(i int) {
+	c.next = i
+}
+
+// Returns the index of the last frame with time <= t,
+// or -1 if there is none.
+// Assumes the frames were written in order of time.
+func (c *Container) Find(t float64) int {
+	return sort.Search(len(c.Index), func(i int) bool { return c.Index[i].Time > t }) - 1
+}
+
+// Appends a frame with the header and data.
+// Returns an error if the label is longer than MAX_LABEL bytes.
+func (c *Container) WriteFrame(h *Header, data []float32) error {
+	if err := checkLabel(h.DataLabel); err != nil {
+		return err
+	}
+	offset, err := c.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(c.file)
+	w := NewWriter(out, CRC_ENABLED)
+	w.Header = *h
+	w.WriteHeader()
+	w.WriteData(data)
+	w.WriteHash()
+	if w.Err != nil {
+		return w.Err
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	entry := IndexEntry{offset, w.Bytes, h.Time, h.DataLabel, w.CRC}
+
+	// first frame that does not fit in the last chunk: add a new one
+	n := len(c.Index) - INDEX_CAPACITY*(len(c.chunks)-1)
+	if n == INDEX_CAPACITY {
+		chunk := offset + w.Bytes
+		if err := c.writeChunk(chunk); err != nil {
+			return err
+		}
+		if err := c.writeWord(c.chunks[len(c.chunks)-1]+24, uint64(chunk)); err != nil {
+			return err
+		}
+		c.chunks = append(c.chunks, chunk)
+		n = 0
+	}
+
+	chunk := c.chunks[len(c.chunks)-1]
+	if _, err := c.file.WriteAt(encodeEntry(&entry), chunk+chunkHeaderSize+int64(n)*entrySize); err != nil {
+		return err
+	}
+	if err := c.writeWord(chunk+16, uint64(n+1)); err != nil {
+		return err
+	}
+	c.Index = append(c.Index, entry)
+	return nil
+}
+
+// Truncates the file to size bytes, removing the frames
+// and index chunks that do not fit anymore.
+// Used to discard the output written after a checkpoint.
+func (c *Container) Truncate(size int64) error {
+	chunks := 0
+	for chunks < len(c.chunks) && c.chunks[chunks]+chunkSize <= size {
+		chunks++
+	}
+	if chunks == 0 {
+		if err := c.file.Truncate(0); err != nil {
+			return err
+		}
+		c.Index, c.chunks = nil, nil
+		return c.init()
+	}
+	c.chunks = c.chunks[:chunks]
+
+	n := 0
+	for n < len(c.Index) && n < chunks*INDEX_CAPACITY && c.Index[n].Offset+c.Index[n].Size <= size {
+		n++
+	}
+	c.Index = c.Index[:n]
+
+	// rewrite the counts, the last chunk has no next one anymore
+	for i, chunk := range c.chunks {
+		count := n - i*INDEX_CAPACITY
+		if count > INDEX_CAPACITY {
+			count = INDEX_CAPACITY
+		}
+		if count < 0 {
+			count = 0
+		}
+		if err := c.writeWord(chunk+16, uint64(count)); err != nil {
+			return err
+		}
+	}
+	if err := c.writeWord(c.chunks[chunks-1]+24, 0); err != nil {
+		return err
+	}
+	if c.next > n {
+		c.next = n
+	}
+	return c.file.Truncate(size)
+}
+
+// Writes the header and first index chunk of a new container.
+func (c *Container) init() error {
+	var buf bytes.Buffer
+	buf.WriteString(CONTAINER_MAGIC)
+	binary.Write(&buf, binary.LittleEndian, uint64(containerHeaderSize))
+	if _, err := c.file.WriteAt(buf.Bytes(), 0); err != nil {
+		return err
+	}
+	c.chunks = []int64{containerHeaderSize}
+	return c.writeChunk(containerHeaderSize)
+}
+
+// Writes an empty index chunk.
+func (c *Container) writeChunk(offset int64) error {
+	buf := make([]byte, chunkSize)
+	copy(buf, INDEX_MAGIC)
+	binary.LittleEndian.PutUint64(buf[8:], INDEX_CAPACITY)
+	_, err := c.file.WriteAt(buf, offset)
+	return err
+}
+
+func (c *Container) writeWord(offset int64, x uint64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], x)
+	_, err := c.file.WriteAt(buf[:], offset)
+	return err
+}
+
+// Reads all index chunks.
+func (c *Container) readIndex() error {
+	var header [containerHeaderSize]byte
+	if _, err := c.file.ReadAt(header[:], 0); err != nil {
+		return fmt.Errorf("not a dump container: %v", err)
+	}
+	if string(header[:8]) != CONTAINER_MAGIC {
+		return fmt.Errorf("not a dump container: bad magic number: %q", header[:8])
+	}
+	chunk := int64(binary.LittleEndian.Uint64(header[8:]))
+	for chunk != 0 {
+		var h [chunkHeaderSize]byte
+		if _, err := c.file.ReadAt(h[:], chunk); err != nil {
+			return fmt.Errorf("reading index: %v", err)
+		}
+		capacity := binary.LittleEndian.Uint64(h[8:])
+		count := binary.LittleEndian.Uint64(h[16:])
+		if string(h[:8]) != INDEX_MAGIC || count > capacity {
+			return fmt.Errorf("corrupt index at %v", chunk)
+		}
+		entries := make([]byte, count*entrySize)
+		if _, err := c.file.ReadAt(entries, chunk+chunkHeaderSize); err != nil {
+			return fmt.Errorf("reading index: %v", err)
+		}
+		for i := 0; i < int(count); i++ {
+			c.Index = append(c.Index, decodeEntry(entries[i*entrySize:]))
+		}
+		c.chunks = append(c.chunks, chunk)
+		chunk = int64(binary.LittleEndian.Uint64(h[24:]))
+	}
+	if len(c.chunks) == 0 {
+		return fmt.Errorf("no index")
+	}
+	return nil
+}
+
+func encodeEntry(e *IndexEntry) []byte {
+	buf := make([]byte, entrySize)
+	binary.LittleEndian.PutUint64(buf[0:], uint64(e.Offset))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(e.Size))
+	binary.LittleEndian.PutUint64(buf[16:], math.Float64bits(e.Time))
+	binary.LittleEndian.PutUint64(buf[24:], e.CRC)
+	copy(buf[32:], e.Label)
+	return buf
+}
+
+func decodeEntry(buf []byte) IndexEntry {
+	label := buf[32:entrySize]
+	if i := bytes.IndexByte(label, 0); i >= 0 {
+		label = label[:i]
+	}
+	return IndexEntry{
+		Offset: int64(binary.LittleEndian.Uint64(buf[0:])),
+		Size:   int64(binary.LittleEndian.Uint64(buf[8:])),
+		Time:   math.Float64frombits(binary.LittleEndian.Uint64(buf[16:])),
+		Label:  string(label),
+		CRC:    binary.LittleEndian.Uint64(buf[24:]),
+	}
+}
+
+// Writes a container with one frame to out, which does not need to be seekable.
+func WriteContainer(out io.Writer, h *Header, data []float32) error {
+	if err := checkLabel(h.DataLabel); err != nil {
+		return err
+	}
+	var frame bytes.Buffer
+	w := NewWriter(&frame, CRC_ENABLED)
+	w.Header = *h
+	w.WriteHeader()
+	w.WriteData(data)
+	w.WriteHash()
+
+	buf := make([]byte, containerHeaderSize+chunkSize)
+	copy(buf, CONTAINER_MAGIC)
+	binary.LittleEndian.PutUint64(buf[8:], containerHeaderSize)
+	chunk := buf[containerHeaderSize:]
+	copy(chunk, INDEX_MAGIC)
+	binary.LittleEndian.PutUint64(chunk[8:], INDEX_CAPACITY)
+	binary.LittleEndian.PutUint64(chunk[16:], 1)
+	entry := IndexEntry{int64(len(buf)), w.Bytes, h.Time, h.DataLabel, w.CRC}
+	copy(chunk[chunkHeaderSize:], encodeEntry(&entry))
+
+	if _, err := out.Write(buf); err != nil {
+		return err
+	}
+	_, err := out.Write(frame.Bytes())
+	return err
+}
+
+func checkLabel(label string) error {
+	if len(label) > MAX_LABEL {
+		return fmt.Errorf("dump container: label %q longer than %v bytes", label, MAX_LABEL)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package dump
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContainer(t *testing.T) {
+	fname := filepath.Join(os.TempDir(), "mumax2_test.dumpc")
+	os.Remove(fname)
+	defer os.Remove(fname)
+
+	// enough frames for 3 index chunks, written by two writers
+	N := 2*INDEX_CAPACITY + 10
+	h := Header{Components: 1, MeshSize: [3]int{1, 2, 3}, DataLabel: "m"}
+	data := make([]float32, 6)
+	var size int64 // file size after the first N-5 frames
+	for _, n := range []int{N / 2, N - 5, N} {
+		c, err := OpenContainer(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := c.NumFrames(); i < n; i++ {
+			h.Time = float64(i)
+			data[0] = float32(i)
+			if err := c.WriteFrame(&h, data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		c.Close()
+		if n == N-5 {
+			info, _ := os.Stat(fname)
+			size = info.Size()
+		}
+	}
+
+	c, err := ReadContainer(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.NumFrames() != N {
+		t.Fatal("have", c.NumFrames(), "frames, want", N)
+	}
+	for _, i := range []int{N - 1, 0, INDEX_CAPACITY, 777} {
+		f, err := c.ReadFrame(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Time != float64(i) || f.Data[0] != float32(i) || c.Index[i].Label != "m" {
+			t.Error("frame", i, ": have time", f.Time, "data", f.Data[0], "label", c.Index[i].Label)
+		}
+	}
+	if i := c.Find(100.5); i != 100 {
+		t.Error("Find(100.5): have", i)
+	}
+	c.Seek(N - 2)
+	for i := N - 2; ; i++ {
+		f, err := c.Next()
+		if err == io.EOF {
+			if i != N {
+				t.Error("Next: EOF after", i, "frames")
+			}
+			break
+		}
+		if err != nil || f.Time != float64(i) {
+			t.Fatal("Next: have", f, err)
+		}
+	}
+	c.Close()
+
+	// discard the last frames, like when resuming from a checkpoint
+	c, err = OpenContainer(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	c, err = ReadContainer(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.NumFrames() != N-5 {
+		t.Error("after Truncate: have", c.NumFrames(), "frames, want", N-5)
+	}
+	if _, err := c.ReadFrame(N - 6); err != nil {
+		t.Error(err)
+	}
+}
+
+// Labels longer than the 8 bytes of the frame header are kept in the index.
+func TestContainerLabel(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "labels.dumpc")
+	c, err := OpenContainer(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := []string{"m", "H_anis_cubic", strings.Repeat("x", MAX_LABEL)}
+	h := Header{Components: 1, MeshSize: [3]int{1, 1, 2}}
+	data := make([]float32, 2)
+	for _, l := range labels {
+		h.DataLabel = l
+		if err := c.WriteFrame(&h, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.DataLabel = strings.Repeat("x", MAX_LABEL+1)
+	if err := c.WriteFrame(&h, data); err == nil {
+		t.Error("no error for label of", MAX_LABEL+1, "bytes")
+	}
+	c.Close()
+
+	c, err = ReadContainer(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.NumFrames() != len(labels) {
+		t.Fatal("have", c.NumFrames(), "frames, want", len(labels))
+	}
+	for i, l := range labels {
+		f, err := c.ReadFrame(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Index[i].Label != l || f.DataLabel != l {
+			t.Errorf("frame %v: have label %q in index, %q in frame, want %q", i, c.Index[i].Label, f.DataLabel, l)
+		}
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"os"
+	"io"
 	"testing"
 )
 
@@ -40,3 +40,27 @@ func TestDump(t *testing.T) {
 	r.Read()
 	//r.Fprintf(os.Stdout, "%v")
 }
+
+// Each frame of a stream has its own checksum.
+func TestDumpFrames(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, CRC_ENABLED)
+	w.Components = 1
+	w.MeshSize = [3]int{1, 1, 2}
+	for f := 0; f < 3; f++ {
+		w.Time = float64(f)
+		w.WriteHeader()
+		w.WriteData([]float32{float32(f), 1})
+		w.WriteHash()
+	}
+
+	r := NewReader(&buf, CRC_ENABLED)
+	for f := 0; f < 3; f++ {
+		if err := r.Read(); err != nil || r.Time != float64(f) || r.Data[0] != float32(f) {
+			t.Fatal("frame", f, ": have", r.Time, r.Data, err)
+		}
+	}
+	if err := r.Read(); err != io.EOF {
+		t.Error("after the last frame: have", err, "want EOF")
+	}
+}
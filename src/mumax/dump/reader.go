@@ -59,9 +59,11 @@ func (r *Reader) Read() error {
 	var mycrc uint64 // checksum by this reader
 	if r.crc != nil {
 		mycrc = r.crc.Sum64()
-		r.crc.Reset() // reset for next frame
 	}
 	r.CRC = r.readUint64() // checksum from data stream. 0 means not set
+	if r.crc != nil {
+		r.crc.Reset() // reset for next frame, after reading the checksum itself
+	}
 	if r.crc != nil && r.CRC != 0 &&
 		mycrc != r.CRC &&
 		r.Err == nil {
@@ -25,8 +25,9 @@ const (
 // 	// may be repeated.
 type Writer struct {
 	Header       // Written by WriteHeader().
-	Bytes  int64 // Total number of bytes written.
-	Err    error // Stores the latest I/O error, if any.
+	Bytes  int64  // Total number of bytes written.
+	Err    error  // Stores the latest I/O error, if any.
+	CRC    uint64 // Hash written by the last WriteHash(), 0 if CRC is disabled.
 	out    io.Writer
 	crc    hash.Hash64
 }
@@ -70,7 +71,8 @@ func (w *Writer) WriteHash() {
 	if w.crc == nil {
 		w.writeUInt64(0)
 	} else {
-		w.writeUInt64(w.crc.Sum64())
+		w.CRC = w.crc.Sum64()
+		w.writeUInt64(w.CRC)
 		w.crc.Reset()
 	}
 }
@@ -14,14 +14,17 @@ package engine
 
 import (
 	"fmt"
+	"image"
 	"math"
 	. "mumax/common"
+	"mumax/geom"
 	"mumax/gpu"
 	"mumax/host"
 	"os"
 	"path"
 	"reflect"
 	"runtime"
+	"strings"
 )
 
 const (
@@ -104,7 +107,10 @@ func (a API) LoadArgs(name string, ins, deps, outs []string) {
 
 // Take one solver step
 func (a API) Step() {
-	a.Engine.Step()
+	e := a.Engine
+	if e.beginRun() && e.step.Scalar() == e._runStartStep {
+		e.Step()
+	}
 }
 
 // Takes N solver steps
@@ -122,6 +128,9 @@ func (a API) Run(duration float64) {
 func (a API) Run_Until_Smaller(quantity string, value float64) {
 	e := a.Engine
 	q := e.Quant(quantity)
+	if !e.beginRun() {
+		return
+	}
 	Log("Running until", q.Name(), "<", value, q.Unit())
 	for q.Scalar() >= value {
 		e.Step()
@@ -134,6 +143,9 @@ func (a API) Run_Until_Smaller(quantity string, value float64) {
 func (a API) Run_Until_Larger(quantity string, value float64) {
 	e := a.Engine
 	q := e.Quant(quantity)
+	if !e.beginRun() {
+		return
+	}
 	Log("Running until", q.Name(), ">", value, q.Unit())
 	for q.Scalar() <= value {
 		e.Step()
@@ -227,6 +239,19 @@ func (a API) SetScalar(quantity string, value float64) {
 	a.SetS(quantity, value)
 }
 
+// Sets how SetArray, SetMask and their _File variants resample
+// arrays that do not have the size of the grid:
+//	"nearest":   value of the nearest cell (default)
+//	"trilinear": linear interpolation, smooth instead of staircase-like
+//	"average":   average over each new cell's volume, conserves the total moment when downsampling
+// With renormalize, resampled direction fields like m are rescaled to unit length (except where they are zero).
+// Masks and fields with a unit, like B_ext, are never renormalized.
+func (a API) SetResampling(mode string, renormalize bool) {
+	checkResampleMode(mode)
+	a.Engine.resampleMode = mode
+	a.Engine.resampleNorm = renormalize
+}
+
 // Sets a space-dependent multiplier mask for the quantity.
 // The value of the quantity (set by SetValue), will be multiplied
 // by the mask value in each point of space. The mask is dimensionless
@@ -235,8 +260,8 @@ func (a API) SetMask(quantity string, mask *host.Array) {
 	q := a.Engine.Quant(quantity)
 	qArray := q.Array()
 	if !EqualSize(mask.Size3D, qArray.Size3D()) {
-		Log("Auto-resampling ", q.Name(), "from", Size(mask.Size3D), "to", Size(qArray.Size3D()))
-		mask = Resample(mask, qArray.Size3D())
+		Log("Auto-resampling ", q.Name(), "from", Size(mask.Size3D), "to", Size(qArray.Size3D()), "(", a.Engine.resampleMode, ")")
+		mask = ResampleMode(mask, qArray.Size3D(), a.Engine.resampleMode, false)
 	}
 	q.SetMask(mask)
 }
@@ -251,8 +276,8 @@ func (a API) SetArray(quantity string, field *host.Array) {
 	q := a.Engine.Quant(quantity)
 	qArray := q.Array()
 	if !EqualSize(field.Size3D, qArray.Size3D()) {
-		Log("Auto-resampling ", quantity, "from", Size(field.Size3D), "to", Size(qArray.Size3D()))
-		field = Resample(field, qArray.Size3D())
+		Log("Auto-resampling ", quantity, "from", Size(field.Size3D), "to", Size(qArray.Size3D()), "(", a.Engine.resampleMode, ")")
+		field = ResampleMode(field, qArray.Size3D(), a.Engine.resampleMode, a.Engine.resampleNorm && isDirection(q))
 	}
 	// setting a field when there is a non-1 multiplier is too confusing to allow
 	for _, m := range q.multiplier {
@@ -264,6 +289,8 @@ func (a API) SetArray(quantity string, field *host.Array) {
 }
 
 // Like SetArray but reads the array from a file.
+// The extension determines the format: .omf or .ovf (OOMMF OVF 1.0 or 2.0), .dump, .png.
+// Arrays of another size are resampled.
 func (a API) SetArray_File(quantity string, filename string) {
 	a.SetArray(quantity, ReadFile(filename))
 }
@@ -377,6 +404,19 @@ func (a API) SetCell(quant string, x, y, z int, value []float64) {
 	q.Invalidate() //!
 }
 
+// Sets the value of a space-dependent parameter like Msat, alpha or Ku,
+// or of a field like m, in all cells of a region (see the regions module).
+// Cells in other regions keep their value. E.g. for a bilayer:
+//	setregion('Msat', 1, [800e3])
+//	setregion('Msat', 2, [600e3])
+// The averages over a region can be tabulated as "m_region2",
+// the energies as "E_ex_region2", "E_region2", etc.
+func (a API) SetRegion(quantity string, region int, value []float64) {
+	checkComp(a.Engine.Quant(quantity), len(value))
+	SwapXYZ(value)
+	a.Engine.SetRegionValue(quantity, region, value)
+}
+
 // Sets scalar quantity uniform on each region
 // @param quant (string) name of the scalar quantity to set
 // @param initValues ([]float32) array containing the initial values to set. The index of each value must correpond to the concerned region.
@@ -507,6 +547,140 @@ func (a API) SetVectorQuantRandomUniformRegion(quant string, regionsToProceed []
 	q.Invalidate()
 }
 
+// ________________________________________________________________________________ geometry
+
+// Geometry is built from shapes, which are referred to by an integer handle.
+// Lengths are in meters, with the origin at the center of the grid.
+// A size <= 0 means infinitely large along that axis.
+// E.g. a disk with a hole, used as the geometry (Msat mask) and as region "disk":
+//	disk = subtract(cylinder(100e-9, 0), cylinder(20e-9, 0))
+//	setv('Msat', 800e3)
+//	setshape('Msat', invert(disk), [0])
+//	defregion('disk', disk)
+
+// Box with sides sx, sy, sz, centered on the origin.
+func (a API) Box(sx, sy, sz float64) (shape int) {
+	return a.Engine.AddShape(geom.Box(sx, sy, sz))
+}
+
+// Cylinder with its axis along z, centered on the origin.
+func (a API) Cylinder(radius, height float64) (shape int) {
+	return a.Engine.AddShape(geom.Cylinder(radius, height))
+}
+
+// Ellipsoid with semi-axes rx, ry, rz, centered on the origin.
+func (a API) Ellipsoid(rx, ry, rz float64) (shape int) {
+	return a.Engine.AddShape(geom.Ellipsoid(rx, ry, rz))
+}
+
+// Prism along z with a polygon with vertices (x[i], y[i]) as base,
+// extending from -height/2 to height/2.
+func (a API) Prism(x, y []float64, height float64) (shape int) {
+	return a.Engine.AddShape(geom.Prism(x, y, height))
+}
+
+// Prism along z with a regular polygon as base,
+// with the given number of sides and circumscribed radius.
+func (a API) RegularPrism(sides int, radius, height float64) (shape int) {
+	return a.Engine.AddShape(geom.RegularPrism(sides, radius, height))
+}
+
+// Extrusion along z of the pixels of an image with the color:
+// "dark", "light" or "#RRGGBB".
+// The image is stretched over sx x sy, or the entire grid if these are <= 0.
+func (a API) ImageShape(filename, color string, sx, sy float64) (shape int) {
+	in, err := os.Open(filename)
+	CheckIO(err)
+	defer in.Close()
+	img, _, err := image.Decode(in)
+	CheckIO(err)
+	size, cell := a.Engine.GridSize(), a.Engine.CellSize()
+	if sx <= 0 {
+		sx = float64(size[Z]) * cell[Z]
+	}
+	if sy <= 0 {
+		sy = float64(size[Y]) * cell[Y]
+	}
+	return a.Engine.AddShape(geom.Image(img, sx, sy, geom.ColorSelector(color)))
+}
+
+// Points inside shape1 or shape2.
+func (a API) Union(shape1, shape2 int) (shape int) {
+	return a.Engine.AddShape(geom.Union(a.Engine.Shape(shape1), a.Engine.Shape(shape2)))
+}
+
+// Points inside both shapes.
+func (a API) Intersect(shape1, shape2 int) (shape int) {
+	return a.Engine.AddShape(geom.Intersect(a.Engine.Shape(shape1), a.Engine.Shape(shape2)))
+}
+
+// Points inside shape1 but not inside shape2.
+func (a API) Subtract(shape1, shape2 int) (shape int) {
+	return a.Engine.AddShape(a.Engine.Shape(shape1).Sub(a.Engine.Shape(shape2)))
+}
+
+// Points outside the shape.
+func (a API) Invert(shape int) int {
+	return a.Engine.AddShape(a.Engine.Shape(shape).Invert())
+}
+
+// The shape moved over dx, dy, dz.
+func (a API) Translate(shape int, dx, dy, dz float64) int {
+	return a.Engine.AddShape(a.Engine.Shape(shape).Translate(dx, dy, dz))
+}
+
+// The shape rotated counterclockwise around the axis ("x", "y" or "z")
+// through the origin, by angle (radians).
+func (a API) Rotate(shape int, axis string, angle float64) int {
+	s := a.Engine.Shape(shape)
+	switch strings.ToLower(axis) {
+	default:
+		panic(InputErr("rotate: illegal axis: " + axis + ", must be x, y or z"))
+	case "x":
+		s = s.RotateX(angle)
+	case "y":
+		s = s.RotateY(angle)
+	case "z":
+		s = s.RotateZ(angle)
+	}
+	return a.Engine.AddShape(s)
+}
+
+// The shape scaled by sx, sy, sz along each axis, with respect to the origin.
+func (a API) Scale(shape int, sx, sy, sz float64) int {
+	return a.Engine.AddShape(a.Engine.Shape(shape).Scale(sx, sy, sz))
+}
+
+// Sets a space-dependent parameter like Msat or a field like m to value
+// in all cells inside the shape. Other cells keep their value.
+func (a API) SetShape(quantity string, shape int, value []float64) {
+	checkComp(a.Engine.Quant(quantity), len(value))
+	SwapXYZ(value)
+	a.Engine.SetShapeValue(quantity, a.Engine.Shape(shape), value)
+}
+
+// Adds the cells inside the shape to the region with the name,
+// and returns the region index. New names get a new index.
+// Loads the regions module if needed. See also SetRegion.
+func (a API) DefRegion(name string, shape int) (index int) {
+	return a.Engine.DefRegion(name, a.Engine.Shape(shape))
+}
+
+// Returns the index of the region with the name, defined by DefRegion.
+func (a API) RegionIndex(name string) int {
+	return a.Engine.RegionIndex(name)
+}
+
+// Scales the exchange coupling between the cells of two regions
+// (see the regions module) by a factor >= 0, e.g., to weaken the exchange
+// at grain boundaries, or to decouple islands with scale 0:
+//	setexchangescale(1, 2, 0)
+// With region1 == region2, the exchange inside the region is scaled.
+// The default scale is 1. Named regions can be passed with regionindex(name).
+func (a API) SetExchangeScale(region1, region2 int, scale float64) {
+	a.Engine.SetExchangeScale(region1, region2, scale)
+}
+
 // ________________________________________________________________________________ save quantities
 
 // Saves a space-dependent quantity, once. Uses the specified format and gives an automatic file name (like "m000001.png").
@@ -527,6 +701,8 @@ func (a API) SaveAs(quantity string, format string, options []string, filename s
 // E.g., for a quantity named "m", and format "txt" the generated files will be:
 //	m00000.txt m00001.txt m00002.txt...
 // See FilenameFormat() for setting the number of zeros.
+// For the "vtk" and "vti" formats, the files are also listed in m_vtk.pvd (resp. m_vti.pvd),
+// which ParaView opens as one time series.
 // Returns an integer handle that can be used to manipulate the auto-save entry.
 // E.g. remove(handle) stops auto-saving it.
 // @see filenumberfomat
@@ -540,7 +716,9 @@ func (a API) AutoSave(quantity string, format string, options []string, period f
 // m.dump, b.dump ...
 // See FilenameFormat() for setting the number of zeros.
 // This function outputs to single file by appending new data to the end of the file.
-// Therefore it is meaningfull only for mumax2's 'dump' binary format.
+// Therefore it is meaningfull only for mumax2's 'dump' binary format,
+// and for 'dumpc', which also keeps an index of the frames so that
+// they can be read in any order (see package mumax/dump).
 // Returns an integer handle that can be used to manipulate the auto-save entry.
 // E.g. remove(handle) stops auto-saving it.
 // @see filenumberfomat
@@ -550,12 +728,33 @@ func (a API) AutoSaveSingleFile(quantity string, format string, options []string
 
 // Saves these space-independent quantities, once.
 // Their values are appended to the file, on one line.
+// Space-dependent quantities can be tabulated as statistics:
+//	avg(m), min(m), max(m), std(m)  average, minimum, maximum and standard deviation of each component
+//	max(|torque|)                    of the norm
+//	min(m_z)                         of one component, also written m.z
+//	avg(m, region=2)                 only over the cells of region 2 (see the regions module)
 func (a API) Tabulate(quantities []string, filename string) {
 	a.Engine.Tabulate(quantities, filename)
 }
 
+// Sets the format of a table file written by Tabulate or AutoTabulate,
+// before its first line is written. Options:
+//	tsv          tab-separated values, with a header line starting with # (default)
+//	csv          comma-separated values, with a plain header line
+//	delimiter=;  values separated by any string, with a plain header line
+//	precision=N  N significant digits, default: as many as needed
+//	json         describe the columns (name, quantity, component, unit, description)
+//	             in filename.json
+//	flush=row    write every line to the file right away (default)
+//	flush=T      write to the file at most every T seconds (wall clock time),
+//	             and when the simulation ends
+func (a API) SetTableFormat(filename string, options []string) {
+	a.Engine.SetTableFormat(filename, options)
+}
+
 // Saves any number of space-independent quantities periodically,
 // every period (expressed in seconds).
+// Space-dependent quantities can be tabulated as statistics like avg(m), see Tabulate.
 // The values are appended to the file.
 // Returns an integer handle that can be used to manipulate the auto-save entry.
 // E.g. remove(handle) stops auto-saving it.
@@ -563,6 +762,23 @@ func (a API) AutoTabulate(quantities []string, filename string, period float64)
 	return a.Engine.AutoTabulate(quantities, filename, period)
 }
 
+// Saves a checkpoint periodically, every period (expressed in seconds of simulated time).
+// Checkpoint files appear in the output directory as:
+//	checkpoint000001.dat checkpoint000002.dat ...
+// Only the last keep files are retained, older ones are removed.
+// The simulation can be resumed from any of them with the -resume flag.
+// See Checkpoint().
+// Returns an integer handle that can be used to manipulate the auto-checkpoint entry.
+func (a API) AutoCheckpoint(period float64, keep int) (handle int) {
+	return a.Engine.AutoCheckpoint(period, keep, false)
+}
+
+// Like AutoCheckpoint(), but the period is expressed in seconds of wall-clock time.
+// Useful for cluster jobs with a walltime limit.
+func (a API) AutoCheckpoint_Walltime(period float64, keep int) (handle int) {
+	return a.Engine.AutoCheckpoint(period, keep, true)
+}
+
 // Removes the object with given handle.
 // E.g.:
 //	handle = autosave(...)
@@ -969,6 +1185,16 @@ func extractCmplxComponents(src, comp1, comp2 *host.Array, norm float64, format
 	}
 }
 
+// Saves the complete simulation state to a file in the output directory:
+// all quantities, the solver state and the state of all auto-save/tabulate
+// entries. The simulation can be resumed from it with the -resume flag:
+//	mumax2 -resume=file.py.out/checkpoint file.py
+// The input script is then replayed without any output,
+// until it reaches the run that was in progress when the checkpoint was written.
+func (a API) Checkpoint(filename string) {
+	a.Engine.Checkpoint(filename)
+}
+
 func (a API) SaveState(dst, src string) {
 	a.Engine.SaveState(dst, src)
 }
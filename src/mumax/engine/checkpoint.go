@@ -0,0 +1,285 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+// This file implements checkpointing of the full simulation state,
+// so that a crashed or preempted simulation can be resumed.
+//
+// A checkpoint holds all quantities, the solver's internal state,
+// the state of all crontabs and the size of all open tables.
+//
+// Resuming works by replaying the input script: every run
+// (Run, Steps, Run_Until_...) that was already completed when the checkpoint
+// was written is skipped, and output is suppressed, until the script reaches
+// the run that was in progress. At that point the checkpoint is restored
+// and the simulation continues where it left off.
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	. "mumax/common"
+	"mumax/gpu"
+	"mumax/host"
+	"os"
+)
+
+// Identifies the checkpoint file format.
+const CHECKPOINT_MAGIC = "#mumax2 checkpoint 1"
+
+// On-disk representation of the simulation state.
+type checkpoint struct {
+	Magic        string
+	GridSize     []int
+	Quants       []quantState
+	OutputID     int               // Engine._outputID
+	LastOutputT  float64           // Engine._lastOutputT
+	HandleCount  int               // Engine._handleCount
+	RunCount     int               // Engine._runCount
+	RunStartT    float64           // Engine._runStartT
+	RunStartStep float64           // Engine._runStartStep
+	SolverType   string            // Type name of the solver, "" if none
+	Solver       *SolverState      // nil if the solver has no internal state
+	Crontabs     map[int][]float64 // state of the stateful crontabs, indexed by handle
+	Tables       map[string]int64  // size of the open tables, indexed by file name
+}
+
+// On-disk representation of a quantity.
+type quantState struct {
+	Name       string
+	Kind       QuantKind
+	NComp      int
+	Multiplier []float64
+	Data       []float32 // array values (without multiplier), nil for VALUEs and uniform MASKs
+	UpToDate   bool      // updaters need not be idempotent (e.g. normalization), so do not needlessly re-update
+}
+
+// Writes the full simulation state to a file.
+// The file is first written under a temporary name and then moved in place,
+// so that a crash while writing does not destroy the previous checkpoint.
+func (e *Engine) Checkpoint(filename string) {
+	if e.replaying() {
+		Debug("Resuming: not overwriting checkpoint", filename)
+		return
+	}
+	filename = e.Relative(filename)
+	c := e.checkpoint()
+
+	tmp := filename + ".tmp"
+	out := OpenWRONLY(tmp)
+	bufout := Buffer(out)
+	err := gob.NewEncoder(bufout).Encode(c)
+	if err == nil {
+		err = bufout.Flush()
+	}
+	out.Close()
+	CheckIO(err)
+	CheckIO(os.Rename(tmp, filename))
+	Log("Checkpoint", filename, "at t =", e.time.multiplier[0], "s")
+}
+
+// Reads a checkpoint file written by Checkpoint().
+// It will be restored when the input script starts the run
+// that was in progress when the checkpoint was written.
+func (e *Engine) Resume(filename string) {
+	in := OpenRDONLY(filename)
+	defer in.Close()
+	c := new(checkpoint)
+	err := gob.NewDecoder(bufio.NewReader(in)).Decode(c)
+	if err != nil {
+		panic(IOErr(fmt.Sprint("reading checkpoint ", filename, ": ", err)))
+	}
+	if c.Magic != CHECKPOINT_MAGIC {
+		panic(IOErr(filename + " is not a mumax2 checkpoint file"))
+	}
+	e.resume = c
+	Log("Resuming from", filename)
+}
+
+// True while the input script is being replayed
+// up to the point where the checkpoint was written.
+func (e *Engine) replaying() bool {
+	return e.resume != nil
+}
+
+// INTERNAL: called at the beginning of each run (Run, Steps, Run_Until_..., Step).
+// Returns false if the run was already completed before the checkpoint
+// we are resuming from, in which case it should be skipped.
+// Otherwise, the run's starting time and step are set in _runStartT, _runStartStep.
+func (e *Engine) beginRun() bool {
+	e._runCount++
+	if e.replaying() {
+		if e._runCount < e.resume.RunCount {
+			Log("Resuming: skipping run", e._runCount)
+			return false
+		}
+		e.restore(e.resume)
+		e.resume = nil
+		return true
+	}
+	e._runStartT = e.time.multiplier[0]
+	e._runStartStep = e.step.multiplier[0]
+	return true
+}
+
+// Collects the full simulation state.
+func (e *Engine) checkpoint() *checkpoint {
+	c := new(checkpoint)
+	c.Magic = CHECKPOINT_MAGIC
+	c.GridSize = e.GridSize()
+
+	for _, q := range e.quantity {
+		s := quantState{Name: q.name, Kind: q.kind, NComp: q.nComp, Multiplier: q.multiplier, UpToDate: q.upToDate}
+		switch {
+		case q.cpuOnly:
+			s.Data = q.buffer.List
+		case q.kind != VALUE && !q.array.IsNil():
+			s.Data = q.array.LocalCopy().List
+		}
+		c.Quants = append(c.Quants, s)
+	}
+
+	c.OutputID = e._outputID
+	c.LastOutputT = e._lastOutputT
+	c.HandleCount = e._handleCount
+	c.RunCount = e._runCount
+	c.RunStartT = e._runStartT
+	c.RunStartStep = e._runStartStep
+
+	if e.solver != nil {
+		c.SolverType = fmt.Sprintf("%T", e.solver)
+		if s, ok := e.solver.(StatefulSolver); ok {
+			c.Solver = s.CheckpointState()
+		}
+	}
+
+	c.Crontabs = make(map[int][]float64)
+	for handle, tab := range e.crontabs {
+		if s, ok := tab.(StatefulNotifier); ok {
+			c.Crontabs[handle] = s.CheckpointState()
+		}
+	}
+
+	c.Tables = make(map[string]int64)
+	for fname, t := range e.outputTables {
+		if size := t.size(); size >= 0 {
+			c.Tables[fname] = size
+		}
+	}
+	return c
+}
+
+// Restores the simulation state from a checkpoint.
+func (e *Engine) restore(c *checkpoint) {
+	Log("Restoring checkpoint")
+	CheckSize(e.GridSize(), c.GridSize)
+
+	restored := make(map[*Quant]bool)
+	for _, s := range c.Quants {
+		if !e.HasQuant(s.Name) {
+			Debug("Resuming: ignoring", s.Name, ": not defined by the input script")
+			continue
+		}
+		q := e.Quant(s.Name)
+		q.restore(&s)
+		restored[q] = true
+	}
+	for _, q := range e.quantity {
+		if !restored[q] {
+			q.Invalidate()
+		}
+	}
+
+	e._outputID = c.OutputID
+	e._lastOutputT = c.LastOutputT
+	e._handleCount = c.HandleCount
+	if c.RunCount == e._runCount {
+		e._runStartT = c.RunStartT
+		e._runStartStep = c.RunStartStep
+	} else { // checkpoint was written before this run started
+		e._runStartT = e.time.multiplier[0]
+		e._runStartStep = e.step.multiplier[0]
+	}
+
+	solverType := ""
+	if e.solver != nil {
+		solverType = fmt.Sprintf("%T", e.solver)
+	}
+	if solverType != c.SolverType {
+		panic(InputErr(fmt.Sprint("Resuming: checkpoint was written with solver ", c.SolverType, ", but the input script uses ", solverType)))
+	}
+	if c.Solver != nil {
+		e.solver.(StatefulSolver).RestoreState(c.Solver)
+	}
+
+	for handle, state := range c.Crontabs {
+		tab, ok := e.crontabs[handle].(StatefulNotifier)
+		if !ok {
+			Warn("Resuming: crontab", handle, "not defined by the input script")
+			continue
+		}
+		tab.RestoreState(state)
+	}
+
+	for fname, size := range c.Tables {
+		e.table(fname).truncate(size) // keeps the format set by the input script
+	}
+}
+
+// Restores the quantity's value from a checkpoint.
+func (q *Quant) restore(s *quantState) {
+	if s.Kind != q.kind || s.NComp != q.nComp {
+		panic(InputErr(fmt.Sprint("Resuming: ", q.name, " is a ", s.NComp, "-component ", s.Kind, " in the checkpoint, but a ", q.nComp, "-component ", q.kind, " in the input script")))
+	}
+	copy(q.multiplier, s.Multiplier)
+	q.upToDate = s.UpToDate
+	q.bufUpToDate = false
+
+	switch {
+	case s.Data == nil:
+		if q.kind == MASK && !q.array.IsNil() {
+			q.array.MemSet(1) // uniform mask
+		}
+	case q.cpuOnly:
+		checkLen(q, len(q.buffer.List), len(s.Data))
+		copy(q.buffer.List, s.Data)
+	default:
+		if q.kind == MASK {
+			q.assureAlloc()
+		}
+		setArrayState(q.Array(), s.Data)
+	}
+}
+
+// Panics if the checkpointed data does not fit the quantity.
+func checkLen(q *Quant, have, want int) {
+	if have != want {
+		panic(InputErr(fmt.Sprint("Resuming: ", q.Name(), " has ", have, " elements, but ", want, " in the checkpoint")))
+	}
+}
+
+// Internal state of a solver, as stored in a checkpoint.
+type SolverState struct {
+	Values []float64   // Scalar state, like error histories
+	Arrays [][]float32 // Array buffers, see arrayState()
+}
+
+// Returns the contents of the array, for storing in a checkpoint.
+func arrayState(a *gpu.Array) []float32 {
+	return a.LocalCopy().List
+}
+
+// Sets the contents of the array, as returned by arrayState().
+func setArrayState(a *gpu.Array, list []float32) {
+	if len(list) != a.Len() {
+		panic(InputErr(fmt.Sprint("Resuming: array of ", a.Len(), " elements does not match ", len(list), " elements in the checkpoint")))
+	}
+	buf := host.NewArray(a.NComp(), a.Size3D())
+	copy(buf.List, list)
+	a.CopyFromHost(buf)
+}
@@ -0,0 +1,176 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+// Colormaps and color bars for PNG output.
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	. "mumax/common"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A colormap maps values between 0 and 1 to colors,
+// by linear interpolation between equidistant anchor colors.
+type Colormap []color.NRGBA
+
+// Color for value f, clipped to 0..1.
+func (c Colormap) At(f float32) color.NRGBA {
+	if f != f { // NaN
+		f = 0
+	}
+	if f < 0 {
+		f = 0
+	}
+	if f > 1 {
+		f = 1
+	}
+	x := f * float32(len(c)-1)
+	i := int(x)
+	if i >= len(c)-1 {
+		return c[len(c)-1]
+	}
+	t := x - float32(i)
+	a, b := c[i], c[i+1]
+	mix := func(a, b uint8) uint8 { return uint8(float32(a)*(1-t) + float32(b)*t + 0.5) }
+	return color.NRGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// Colormaps by name. A name followed by "_r" gives the reversed map.
+var colormaps = map[string]Colormap{
+	"grey":    hexColormap("000000", "ffffff"),
+	"viridis": hexColormap("440154", "482878", "3e4989", "31688e", "26828e", "1f9e89", "35b779", "6ece58", "b5de2b", "fde725"),
+	"plasma":  hexColormap("0d0887", "46039f", "7201a8", "9c179e", "bd3786", "d8576b", "ed7953", "fb9f3a", "fdca26", "f0f921"),
+	"inferno": hexColormap("000004", "1b0c41", "4a0c6b", "781c6d", "a52c60", "cf4446", "ed6925", "fb9b06", "f7d13d", "fcffa4"),
+	"magma":   hexColormap("000004", "180f3d", "440f76", "721f81", "9e2f7f", "cd4071", "f1605d", "fd9668", "feca8d", "fcfdbf"),
+	"rdbu":    hexColormap("67001f", "b2182b", "d6604d", "f4a582", "fddbc7", "f7f7f7", "d1e5f0", "92c5de", "4393c3", "2166ac", "053061"),
+	"bwr":     hexColormap("0000ff", "ffffff", "ff0000"),
+}
+
+// Returns the colormap by name, e.g. "viridis" or "rdbu_r".
+func GetColormap(name string) Colormap {
+	name = strings.ToLower(name)
+	if name == "gray" {
+		name = "grey"
+	}
+	reverse := strings.HasSuffix(name, "_r")
+	c, ok := colormaps[strings.TrimSuffix(name, "_r")]
+	if !ok {
+		var names []string
+		for k := range colormaps {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		panic(InputErr(fmt.Sprint("Unknown colormap: ", name, ". Options are: ", strings.Join(names, ", "), " (add _r to reverse)")))
+	}
+	if reverse {
+		r := make(Colormap, len(c))
+		for i := range c {
+			r[i] = c[len(c)-1-i]
+		}
+		c = r
+	}
+	return c
+}
+
+func hexColormap(colors ...string) Colormap {
+	c := make(Colormap, len(colors))
+	for i, hex := range colors {
+		rgb, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			panic(err)
+		}
+		c[i] = color.NRGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 255}
+	}
+	return c
+}
+
+// Returns a copy of img with a color bar to its right,
+// labeled with the min and max values.
+func addColorbar(img *image.NRGBA, c Colormap, min, max float32) *image.NRGBA {
+	const (
+		margin = 4
+		width  = 10 // of the bar
+	)
+	h := img.Bounds().Dy()
+	maxLabel := strconv.FormatFloat(float64(max), 'g', 3, 32)
+	minLabel := strconv.FormatFloat(float64(min), 'g', 3, 32)
+	labelWidth := textWidth(maxLabel)
+	if w := textWidth(minLabel); w > labelWidth {
+		labelWidth = w
+	}
+
+	w0 := img.Bounds().Dx()
+	out := image.NewNRGBA(image.Rect(0, 0, w0+margin+width+margin+labelWidth+margin, h))
+	for i := range out.Pix {
+		out.Pix[i] = 255
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w0; x++ {
+			out.Set(x, y, img.At(x, y))
+		}
+		f := float32(h-1-y) / float32(h-1)
+		for x := w0 + margin; x < w0+margin+width; x++ {
+			out.Set(x, y, c.At(f))
+		}
+	}
+	x := w0 + margin + width + margin
+	drawText(out, maxLabel, x, 0)
+	drawText(out, minLabel, x, h-FONT_HEIGHT)
+	return out
+}
+
+// Tiny bitmap font for numbers, 3x5 pixels per character.
+const FONT_HEIGHT = 5
+
+var font = map[rune][FONT_HEIGHT]string{
+	'0': {"###", "# #", "# #", "# #", "###"},
+	'1': {" # ", "## ", " # ", " # ", "###"},
+	'2': {"###", "  #", "###", "#  ", "###"},
+	'3': {"###", "  #", "###", "  #", "###"},
+	'4': {"# #", "# #", "###", "  #", "  #"},
+	'5': {"###", "#  ", "###", "  #", "###"},
+	'6': {"###", "#  ", "###", "# #", "###"},
+	'7': {"###", "  #", "  #", "  #", "  #"},
+	'8': {"###", "# #", "###", "# #", "###"},
+	'9': {"###", "# #", "###", "  #", "###"},
+	'.': {"   ", "   ", "   ", "   ", " # "},
+	'-': {"   ", "   ", "###", "   ", "   "},
+	'+': {"   ", " # ", "###", " # ", "   "},
+	'e': {"   ", "###", "## ", "#  ", "###"},
+	'N': {"# #", "###", "###", "###", "# #"},
+	'a': {"   ", "## ", " ##", "# #", "###"},
+	'I': {"###", " # ", " # ", " # ", "###"},
+	'n': {"   ", "## ", "# #", "# #", "# #"},
+	'f': {" ##", " # ", "###", " # ", " # "},
+}
+
+// Width in pixels of text drawn by drawText.
+func textWidth(text string) int {
+	return 4 * len(text)
+}
+
+// Draws text in black, with its top left corner at x, y.
+func drawText(img *image.NRGBA, text string, x, y int) {
+	black := color.NRGBA{0, 0, 0, 255}
+	for _, r := range text {
+		glyph := font[r]
+		for j, row := range glyph {
+			for i, p := range row {
+				if p == '#' {
+					img.Set(x+i, y+j, black)
+				}
+			}
+		}
+		x += 4
+	}
+}
@@ -12,6 +12,7 @@ package engine
 import (
 	"fmt"
 	. "mumax/common"
+	"mumax/geom"
 	"mumax/gpu"
 	"path"
 	"strings"
@@ -50,8 +51,17 @@ type Engine struct {
 	_outputID      int               // index for output numbering
 	_lastOutputT   float64           // time of last output ID increment
 	_handleCount   int               // used to generate unique handle IDs for various object passed out
+	_runCount      int               // number of runs started, used to resume from a checkpoint
+	_runStartT     float64           // time at which the current run started
+	_runStartStep  float64           // step at which the current run started
+	resume         *checkpoint       // checkpoint to resume from, nil if not resuming. See checkpoint.go
 	outputDir      string            // output directory
 	filenameFormat string            // Printf format string for file name numbering. Must consume one integer.
+	resampleMode   string            // how SetArray and SetMask resample arrays of another size, see ResampleMode
+	resampleNorm   bool              // renormalize resampled vectors
+	shapes         map[int]geom.Shape // geometries built by the API, indexed by handle. See geometry.go
+	regionNames    map[string]int     // region index of named regions. See geometry.go
+	exchScale      map[[2]int]float64 // exchange scaling between pairs of regions. See regions.go
 }
 
 // Initializes the global simulation engine
@@ -81,7 +91,11 @@ func (e *Engine) init() {
 	e.modules = make([]Module, 0)
 	e.crontabs = make(map[int]Notifier)
 	e.outputTables = make(map[string]*Table)
+	e.shapes = make(map[int]geom.Shape)
+	e.regionNames = make(map[string]int)
+	e.exchScale = make(map[[2]int]float64)
 	e.filenameFormat = "%06d"
+	e.resampleMode = NEAREST
 	e.timer.Start()
 }
 
@@ -225,6 +239,9 @@ func (e *Engine) NCell() int {
 // Retrieve a quantity by its name.
 // Lookup is case-independent
 func (e *Engine) Quant(name string) *Quant {
+	if isReduction(name) {
+		name = strings.Replace(name, " ", "", -1) // "avg(m, region=1)" == "avg(m,region=1)"
+	}
 	lname := strings.ToLower(name)
 	if q, ok := e.quantity[lname]; ok {
 		return q
@@ -270,7 +287,19 @@ func (e *Engine) AddDeltaQuant(in, ref string) {
 //	"q.xx" 		: xx-component of q, must be tensor
 //	"<q.x>"		: average of x-component of q.
 //  "fft(q)" 	: fft of q
+//	"max(|q|)"	: reductions avg, min, max, std, see reduction.go
+//	"q_region2"	: q in region 2, see regions.go
 func (e *Engine) addDerivedQuant(name string) {
+	// reductions
+	if isReduction(name) {
+		e.addReduction(name)
+		return
+	}
+	// per region
+	if isRegionQuant(name) {
+		e.addRegionQuant(name)
+		return
+	}
 	// fft
 	if strings.HasPrefix(name, "fft(") && strings.HasSuffix(name, ")") {
 		in := name[len("fft(") : len(name)-1]
@@ -347,6 +376,8 @@ func (e *Engine) panicNoSuchQuant(name string) {
 	msg += "]"
 	msg += "\n Names are case-independent"
 	msg += "\n Spatial averages can be taken as <name>"
+	msg += "\n Statistics can be taken as avg(name), min(name), max(name), std(name), max(|name|), avg(name, region=1)"
+	msg += "\n Averages and energies per region can be taken as name_region1"
 	msg += "\n Components can be taken as name.x, name.y, etc"
 	panic(InputErr(msg))
 }
@@ -503,8 +534,11 @@ func (e *Engine) Step() {
 
 // Takes N time steps
 func (e *Engine) Steps(N int) {
+	if !e.beginRun() {
+		return
+	}
 	Log("Running", N, "steps.")
-	for i := 0; i < N; i++ {
+	for i := int(e.step.Scalar() - e._runStartStep); i < N; i++ {
 		e.Step()
 		e.updateDash()
 	}
@@ -513,9 +547,12 @@ func (e *Engine) Steps(N int) {
 
 // Runs for a certain duration specified in seconds
 func (e *Engine) Run(duration float64) {
+	if !e.beginRun() {
+		return
+	}
 	Log("Running for", duration, "s.")
 	time := e.time
-	start := time.Scalar()
+	start := e._runStartT
 	for time.Scalar() < (start + duration) {
 		e.Step()
 		e.updateDash()
@@ -544,14 +581,26 @@ func (e *Engine) updateDash() {
 //__________________________________________________________________ output
 
 // Notifies all crontabs that a step has been taken.
+// Auto-checkpoints go last, so they see the state of the other crontabs after this step.
 func (e *Engine) notifyAll() {
+	var checkpoints []Notifier
 	for _, tab := range e.crontabs {
+		if _, ok := tab.(*AutoCheckpoint); ok {
+			checkpoints = append(checkpoints, tab)
+			continue
+		}
+		tab.Notify(e)
+	}
+	for _, tab := range checkpoints {
 		tab.Notify(e)
 	}
 }
 
 // Saves the quantity once in the specified format and file name
 func (e *Engine) SaveAs(q *Quant, format string, options []string, filename string) {
+	if e.replaying() {
+		return // already saved before the checkpoint
+	}
 	q.Update() //!!
 	checkKinds(q, MASK, FIELD)
 	out := OpenWRONLY(e.Relative(filename))
@@ -563,6 +612,9 @@ func (e *Engine) SaveAs(q *Quant, format string, options []string, filename stri
 
 // Append the quantity once in the specified format and file name
 func (e *Engine) SaveAsAppend(q *Quant, format string, options []string, filename string) {
+	if e.replaying() {
+		return // already saved before the checkpoint
+	}
 	q.Update() //!!
 	checkKinds(q, MASK, FIELD)
 	out := OpenWRAPPENDONLY(e.Relative(filename))
@@ -584,8 +636,8 @@ func (e *Engine) AutoSave(quant string, format string, options []string, period
 // Saves the quantity periodically to single file.
 func (e *Engine) AutoSaveSingleFile(quant string, format string, options []string, period float64) (handle int) {
 	checkKinds(e.Quant(quant), MASK, FIELD)
-	if format != "dump" {
-		panic(InputErr("Single File mode is only meaningfull for 'dump' output format!"))
+	if format != "dump" && format != "dumpc" {
+		panic(InputErr("Single File mode is only meaningfull for 'dump' and 'dumpc' output formats!"))
 	}
 	handle = e.NewHandle()
 	e.crontabs[handle] = &AutoSaveSingleFile{quant, format, options, period, e.time.Scalar(), 0}
@@ -595,20 +647,33 @@ func (e *Engine) AutoSaveSingleFile(quant string, format string, options []strin
 
 // See api.go
 func (e *Engine) Tabulate(quants []string, filename string) {
-	if _, ok := e.outputTables[filename]; !ok { // table not yet open
-		e.outputTables[filename] = NewTable(e.Relative(filename))
+	if e.replaying() {
+		return // already tabulated before the checkpoint
 	}
-	table := e.outputTables[filename]
+	table := e.table(filename)
 	for _, q := range quants {
 		e.Quant(q).Update() //!
 	}
 	table.Tabulate(quants)
 }
 
+// See api.go
+func (e *Engine) SetTableFormat(filename string, options []string) {
+	e.table(filename).SetFormat(options)
+}
+
+// Returns the table for the file, creates it if needed.
+func (e *Engine) table(filename string) *Table {
+	if _, ok := e.outputTables[filename]; !ok { // table not yet open
+		e.outputTables[filename] = NewTable(e.Relative(filename))
+	}
+	return e.outputTables[filename]
+}
+
 // See api.go
 func (e *Engine) AutoTabulate(quants []string, filename string, period float64) (handle int) {
 	for _, q := range quants {
-		checkKinds(e.Quant(q), MASK, VALUE)
+		tableQuant(q)
 	}
 	handle = e.NewHandle()
 	e.crontabs[handle] = &AutoTabulate{quants, filename, period, 0}
@@ -616,6 +681,26 @@ func (e *Engine) AutoTabulate(quants []string, filename string, period float64)
 	return handle
 }
 
+// See api.go
+func (e *Engine) AutoCheckpoint(period float64, keep int, walltime bool) (handle int) {
+	if period <= 0 {
+		panic(InputErr(fmt.Sprint("auto-checkpoint period should be positive: ", period)))
+	}
+	if keep < 1 {
+		panic(InputErr(fmt.Sprint("auto-checkpoint should keep at least 1 file: ", keep)))
+	}
+	start := e.time.Scalar()
+	clock := "s"
+	if walltime {
+		start = wallTime()
+		clock = "s wall-clock time"
+	}
+	handle = e.NewHandle()
+	e.crontabs[handle] = &AutoCheckpoint{period, keep, walltime, start, 0}
+	Log("Auto-checkpoint every", period, clock, "keeping", keep, "files", "(handle ", handle, ")")
+	return handle
+}
+
 // Generates an automatic file name for the quantity, given the output format.
 // E.g., "dir.out/m000007.omf"
 // see: outputDir, filenameFormat
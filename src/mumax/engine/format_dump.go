@@ -30,6 +30,7 @@ import (
 
 func init() {
 	RegisterOutputFormat(&FormatDump{})
+	RegisterOutputFormat(&FormatDumpC{})
 }
 
 type FormatDump struct{}
@@ -43,20 +44,56 @@ func (f *FormatDump) Write(out io.Writer, q *Quant, options []string) {
 		panic(InputErr("dump output format does not take options"))
 	}
 
-	data := q.Buffer().Array
-	list := q.Buffer().List
-
 	w := dump.NewWriter(out, dump.CRC_ENABLED)
-	w.Components = len(data) 
-	w.MeshSize = [3]int{len(data[0]), len(data[0][0]), len(data[0][0][0])}
-	w.TimeUnit = "s"
-	w.Time = GetEngine().Quant("t").Scalar()
-	w.MeshUnit = "m"
-	w.DataLabel = q.Name()
-	w.DataUnit = string(q.Unit())
-	sz := GetEngine().CellSize()
-	w.MeshStep = [3]float64{sz[X], sz[Y], sz[Z]} // We dont swap
+	w.Header = dumpHeader(q)
 	w.WriteHeader()
-	w.WriteData(list)
+	w.WriteData(q.Buffer().List)
 	w.WriteHash()
 }
+
+// Indexed container of dump frames, see dump.Container.
+// Saved once, it holds one frame. AutoSaveSingleFile
+// appends a frame to it each time.
+type FormatDumpC struct{}
+
+func (f *FormatDumpC) Name() string {
+	return "dumpc"
+}
+
+func (f *FormatDumpC) Write(out io.Writer, q *Quant, options []string) {
+	if len(options) > 0 {
+		panic(InputErr("dumpc output format does not take options"))
+	}
+	h := dumpHeader(q)
+	CheckIO(dump.WriteContainer(out, &h, q.Buffer().List))
+}
+
+// Appends the quantity to a container file.
+func (e *Engine) SaveToContainer(q *Quant, filename string) {
+	if e.replaying() {
+		return // already saved before the checkpoint
+	}
+	q.Update() //!!
+	checkKinds(q, MASK, FIELD)
+	c, err := dump.OpenContainer(e.Relative(filename))
+	CheckIO(err)
+	defer c.Close()
+	h := dumpHeader(q)
+	CheckIO(c.WriteFrame(&h, q.Buffer().List))
+}
+
+// Header for a dump frame of the quantity.
+func dumpHeader(q *Quant) dump.Header {
+	data := q.Buffer().Array
+	var h dump.Header
+	h.Components = len(data)
+	h.MeshSize = [3]int{len(data[0]), len(data[0][0]), len(data[0][0][0])}
+	h.TimeUnit = "s"
+	h.Time = GetEngine().Quant("t").Scalar()
+	h.MeshUnit = "m"
+	h.DataLabel = q.Name()
+	h.DataUnit = string(q.Unit())
+	sz := GetEngine().CellSize()
+	h.MeshStep = [3]float64{sz[X], sz[Y], sz[Z]} // We dont swap
+	return h
+}
@@ -33,7 +33,7 @@ func (f *FormatGPlot) Write(out io.Writer, q *Quant, options []string) {
 	}
 
 	data := q.Buffer().Array
-	gridsize := q.Array().Size3D()
+	gridsize := q.Buffer().Size3D
 	cellsize := GetEngine().CellSize()
 	ncomp := len(data)
 
@@ -11,12 +11,14 @@ package engine
 // Author: Arne Vansteenkiste
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"image/png"
 	"io"
 	"math"
 	. "mumax/common"
+	"strings"
 )
 
 func init() {
@@ -29,28 +31,289 @@ func (f *FormatPNG) Name() string {
 	return "png"
 }
 
+// Options, as "key=value" strings:
+//	axis=z      axis perpendicular to the image: x, y or z
+//	layer=N     cell index along axis to draw, default: average over axis
+//	comp=x      draw only this component of a vector quantity, as a scalar
+//	min=V max=V fixed color range for scalars, default: extrema of the layer, or of all the data
+//	colormap=C  colormap for scalars: grey, viridis, plasma, inferno, magma, rdbu, bwr (add _r to reverse)
+//	scale=N     pixels per cell
+//	arrows=N    overlay in-plane arrows every N cells (vector quantities)
+//	colorbar    add a color bar with the min and max values (scalars)
+// Without options, vector quantities are drawn with an HSL colormap
+// and scalars in grey scale, both averaged over z.
 func (f *FormatPNG) Write(out io.Writer, q *Quant, options []string) {
-	if len(options) > 0 {
-		panic(InputErr("png output format does not take options"))
+	o := parsePNGOptions(options)
+	ncomp := q.NComp()
+	if ncomp != 1 && ncomp != 3 {
+		panic(InputErrF("PNG cannot handle data with ", ncomp, " components."))
+	}
+	if o.comp >= 0 && ncomp != 3 {
+		panic(InputErr("png option comp needs a vector quantity"))
+	}
+	if o.arrows > 0 && ncomp != 3 {
+		panic(InputErr("png option arrows needs a vector quantity"))
+	}
+	scalar := ncomp == 1 || o.comp >= 0
+	if o.colorbar && !scalar {
+		panic(InputErr("png option colorbar needs a scalar quantity or comp="))
 	}
 
-	var image *image.NRGBA
-	switch q.NComp() {
-	default:
-		panic(InputErrF("PNG cannot handle data with", q.NComp(), "components."))
-	case 1:
-		min, max := Extrema(q.Buffer().List)
-		image = DrawScalars(q.Buffer().Array[0], min, max)
-	case 3:
-		image = DrawVectors(q.Buffer().Array)
+	buffer := q.Buffer()
+	slice := pngSlice(buffer.Array, o)
+	u, v := planeAxes(o.axis)
+	h, w := len(slice[0]), len(slice[0][0])
+	img := image.NewNRGBA(image.Rect(0, 0, w*o.scale, h*o.scale))
+
+	var min, max float32
+	if scalar {
+		c := 0
+		if o.comp >= 0 {
+			c = o.comp
+		}
+		if o.layer >= 0 {
+			min, max = sliceExtrema(slice[c])
+		} else {
+			min, max = Extrema(buffer.Comp[SwapIndex(c, ncomp)])
+		}
+		if o.fixedMin {
+			min = o.min
+		}
+		if o.fixedMax {
+			max = o.max
+		}
+		for i := 0; i < h; i++ {
+			for j := 0; j < w; j++ {
+				x := slice[c][i][j]
+				if o.colormap == nil {
+					fillCell(img, i, j, o.scale, GreyMap(min, max, x))
+				} else {
+					fillCell(img, i, j, o.scale, o.colormap.At((x-min)/(max-min)))
+				}
+			}
+		}
+	} else {
+		for i := 0; i < h; i++ {
+			for j := 0; j < w; j++ {
+				fillCell(img, i, j, o.scale, HSLMap(slice[0][i][j], slice[1][i][j], slice[2][i][j]))
+			}
+		}
+	}
+
+	if o.arrows > 0 {
+		drawArrows(img, slice[u], slice[v], o.arrows, o.scale)
+	}
+	if o.colorbar {
+		colormap := o.colormap
+		if colormap == nil {
+			colormap = GetColormap("grey")
+		}
+		img = addColorbar(img, colormap, min, max)
 	}
 
-	err := png.Encode(out, image)
+	err := png.Encode(out, img)
 	if err != nil {
 		panic(IOErr(err.Error()))
 	}
 }
 
+type pngOptions struct {
+	axis               int      // user axis perpendicular to the image
+	layer              int      // cell index along axis, -1: average
+	comp               int      // user component to draw as scalar, -1: all
+	min, max           float32  // color range for scalars
+	fixedMin, fixedMax bool     // min, max set by the user
+	colormap           Colormap // nil: GreyMap
+	scale              int      // pixels per cell
+	arrows             int      // cells between arrows, 0: none
+	colorbar           bool
+}
+
+func parsePNGOptions(options []string) *pngOptions {
+	o := &pngOptions{axis: Z, layer: -1, comp: -1, scale: 1}
+	for _, opt := range options {
+		key, value := opt, ""
+		if i := strings.Index(opt, "="); i >= 0 {
+			key, value = strings.TrimSpace(opt[:i]), strings.TrimSpace(opt[i+1:])
+		}
+		switch strings.ToLower(key) {
+		default:
+			panic(InputErr(fmt.Sprint("Illegal PNG option ", opt, ". Options are: axis=, layer=, comp=, min=, max=, colormap=, scale=, arrows=, colorbar")))
+		case "axis":
+			o.axis = pngAxis(key, value)
+		case "layer":
+			o.layer = Atoi(value)
+			if o.layer < 0 {
+				panic(InputErr(fmt.Sprint("png option layer=", value, ": must be >= 0")))
+			}
+		case "comp":
+			o.comp = pngAxis(key, value)
+		case "min":
+			o.min, o.fixedMin = Atof32(value), true
+		case "max":
+			o.max, o.fixedMax = Atof32(value), true
+		case "colormap":
+			o.colormap = GetColormap(value)
+		case "scale":
+			o.scale = Atoi(value)
+			if o.scale < 1 {
+				panic(InputErr(fmt.Sprint("png option scale=", value, ": must be >= 1")))
+			}
+		case "arrows":
+			o.arrows = Atoi(value)
+			if o.arrows < 1 {
+				panic(InputErr(fmt.Sprint("png option arrows=", value, ": must be >= 1")))
+			}
+		case "colorbar":
+			o.colorbar = true
+		}
+	}
+	return o
+}
+
+// Parses x, y or z to the user axis 0, 1, 2.
+func pngAxis(key, value string) int {
+	switch strings.ToLower(value) {
+	case "x":
+		return X
+	case "y":
+		return Y
+	case "z":
+		return Z
+	}
+	panic(InputErr(fmt.Sprint("png option ", key, "=", value, ": must be x, y or z")))
+}
+
+// User axes spanning the image (horizontal, vertical), when looking along axis.
+func planeAxes(axis int) (u, v int) {
+	switch axis {
+	case X:
+		return Y, Z
+	case Y:
+		return X, Z
+	}
+	return X, Y
+}
+
+// Takes a 2D slice of the data (in internal ZYX order),
+// perpendicular to the user axis o.axis: at o.layer, or averaged over the axis.
+// Returns [user component][vertical][horizontal].
+func pngSlice(arr [][][][]float32, o *pngOptions) [][][]float32 {
+	ncomp := len(arr)
+	size := [3]int{len(arr[0][0][0]), len(arr[0][0]), len(arr[0])} // user XYZ
+	u, v := planeAxes(o.axis)
+	from, to := 0, size[o.axis]
+	if o.layer >= 0 {
+		if o.layer >= size[o.axis] {
+			panic(InputErr(fmt.Sprint("png option layer=", o.layer, ": out of range, have ", size[o.axis], " layers")))
+		}
+		from, to = o.layer, o.layer+1
+	}
+
+	slice := make([][][]float32, ncomp)
+	for c := range slice {
+		data := arr[SwapIndex(c, ncomp)]
+		slice[c] = make([][]float32, size[v])
+		for i := range slice[c] {
+			slice[c][i] = make([]float32, size[u])
+			for j := range slice[c][i] {
+				var idx [3]int
+				idx[u], idx[v] = j, i
+				var sum float32
+				for k := from; k < to; k++ {
+					idx[o.axis] = k
+					sum += data[idx[Z]][idx[Y]][idx[X]]
+				}
+				slice[c][i][j] = sum / float32(to-from)
+			}
+		}
+	}
+	return slice
+}
+
+// Extrema of one component of a slice.
+func sliceExtrema(s [][]float32) (min, max float32) {
+	min, max = Extrema(s[0])
+	for _, row := range s[1:] {
+		rmin, rmax := Extrema(row)
+		if rmin < min {
+			min = rmin
+		}
+		if rmax > max {
+			max = rmax
+		}
+	}
+	return
+}
+
+// Fills the scale x scale pixels of cell i (vertical), j (horizontal).
+// The vertical axis points up.
+func fillCell(img *image.NRGBA, i, j, scale int, col color.NRGBA) {
+	h := img.Bounds().Dy()
+	for y := 0; y < scale; y++ {
+		for x := 0; x < scale; x++ {
+			img.Set(j*scale+x, h-1-(i*scale+y), col)
+		}
+	}
+}
+
+// Draws black arrows of the in-plane components (a, b) every n cells.
+// The longest arrow spans 0.9*n cells.
+func drawArrows(img *image.NRGBA, a, b [][]float32, n, scale int) {
+	var maxNorm float32
+	for i := range a {
+		for j := range a[i] {
+			if norm := fsqrt(a[i][j]*a[i][j] + b[i][j]*b[i][j]); norm > maxNorm {
+				maxNorm = norm
+			}
+		}
+	}
+	if maxNorm == 0 {
+		return
+	}
+	black := color.NRGBA{0, 0, 0, 255}
+	h := img.Bounds().Dy()
+	length := 0.9 * float32(n*scale) / maxNorm
+	for i := n / 2; i < len(a); i += n {
+		for j := n / 2; j < len(a[i]); j += n {
+			// center of the cell, in pixels, y up
+			cx := (float32(j) + 0.5) * float32(scale)
+			cy := (float32(i) + 0.5) * float32(scale)
+			dx, dy := a[i][j]*length, b[i][j]*length
+			x1, y1 := cx+dx/2, cy+dy/2
+			drawLine(img, cx-dx/2, cy-dy/2, x1, y1, h, black)
+			// arrow head: two strokes of 1/3 the length, at +-30 degrees
+			for _, angle := range []float64{5 * math.Pi / 6, -5 * math.Pi / 6} {
+				sin, cos := math.Sincos(angle)
+				hx := (dx*float32(cos) - dy*float32(sin)) / 3
+				hy := (dx*float32(sin) + dy*float32(cos)) / 3
+				drawLine(img, x1, y1, x1+hx, y1+hy, h, black)
+			}
+		}
+	}
+}
+
+// Draws a line between points with y pointing up, in an image of height h.
+func drawLine(img *image.NRGBA, x0, y0, x1, y1 float32, h int, col color.NRGBA) {
+	steps := int(math.Ceil(float64(max32(abs(x1-x0), abs(y1-y0)))))
+	if steps == 0 {
+		steps = 1
+	}
+	for s := 0; s <= steps; s++ {
+		t := float32(s) / float32(steps)
+		x := x0 + t*(x1-x0)
+		y := y0 + t*(y1-y0)
+		img.Set(int(x), h-1-int(y), col)
+	}
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 func Extrema(data []float32) (min, max float32) {
 	min = data[0]
 	max = data[0]
@@ -0,0 +1,86 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"mumax/host"
+	"testing"
+)
+
+func TestPNGSlice(t *testing.T) {
+	// value encodes the user cell index: 100*z + 10*y + x
+	in := host.NewArray(1, []int{2, 3, 4}) // internal ZYX: user z=2, y=3, x=4
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 4; k++ {
+				in.Array[0][i][j][k] = float32(100*i + 10*j + k)
+			}
+		}
+	}
+
+	s := pngSlice(in.Array, parsePNGOptions([]string{"layer=1"}))
+	if len(s[0]) != 3 || len(s[0][0]) != 4 || s[0][2][3] != 123 {
+		t.Error("z slice:", s[0])
+	}
+	s = pngSlice(in.Array, parsePNGOptions([]string{"axis=y", "layer=2"}))
+	if len(s[0]) != 2 || len(s[0][0]) != 4 || s[0][1][3] != 123 {
+		t.Error("y slice:", s[0])
+	}
+	s = pngSlice(in.Array, parsePNGOptions([]string{"axis=x", "layer=3"}))
+	if len(s[0]) != 2 || len(s[0][0]) != 3 || s[0][1][2] != 123 {
+		t.Error("x slice:", s[0])
+	}
+	s = pngSlice(in.Array, parsePNGOptions(nil))
+	if s[0][2][3] != 73 {
+		t.Error("z average:", s[0][2][3])
+	}
+}
+
+func TestColormap(t *testing.T) {
+	c := GetColormap("bwr")
+	if c.At(-1) != (color.NRGBA{0, 0, 255, 255}) || c.At(0.5) != (color.NRGBA{255, 255, 255, 255}) {
+		t.Error("bwr:", c.At(-1), c.At(0.5))
+	}
+	if r := GetColormap("bwr_r"); r.At(0) != c.At(1) {
+		t.Error("bwr_r:", r.At(0))
+	}
+}
+
+// With layer=, the automatic color range is that of the drawn layer.
+func TestPNGLayerRange(t *testing.T) {
+	e := initTestEngine(t, []int{2, 1, 2}) // user z=2, y=1, x=2
+	q := e.AddNewQuant("q", SCALAR, FIELD, Unit(""))
+	in := host.NewArray(1, []int{2, 1, 2})
+	in.Array[0][0][0][0], in.Array[0][0][0][1] = 0, 100
+	in.Array[0][1][0][0], in.Array[0][1][0][1] = 10, 20
+	q.SetField(in)
+
+	for _, test := range []struct {
+		options     []string
+		left, right uint8
+	}{
+		{[]string{"layer=1"}, 0, 255},
+		{[]string{"layer=0"}, 0, 255},
+		{nil, 12, 153}, // averages 5 and 60, range of all data 0..100
+	} {
+		var out bytes.Buffer
+		GetOutputFormat("png").Write(&out, q, test.options)
+		img, err := png.Decode(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		left := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA)
+		right := color.NRGBAModel.Convert(img.At(1, 0)).(color.NRGBA)
+		if left.R != test.left || right.R != test.right {
+			t.Error(test.options, ": have", left.R, right.R, "want", test.left, test.right)
+		}
+	}
+}
@@ -2,7 +2,7 @@
 //  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
 //  Use of this source code is governed by the GNU General Public License version 3
 //  (as published by the Free Software Foundation) that can be found in the license.txt file.
-//  Note that you are welcome to modify this code under the condition that you do not remove any 
+//  Note that you are welcome to modify this code under the condition that you do not remove any
 //  copyright notices and prominently state that you modified it, giving a relevant date.
 
 // Add support for vtk 4.2 file output
@@ -10,20 +10,36 @@
 package engine
 
 // Author: Rémy Lassalle-Balier
+//
+// VTK XML output, as StructuredGrid (format "vtk") or ImageData (format "vti").
+// Options choose the encoding of the data arrays:
+//	ascii (or text): human-readable (default)
+//	binary:          base64, inline
+//	appended:        base64, appended at the end of the file
+//	raw:             appended without base64, smallest but not valid XML
+//	zlib:            zlib compression, together with binary, appended or raw
+// E.g.: save("m", "vti", ["appended", "zlib"])
 
 import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"io/ioutil"
 	. "mumax/common"
+	"path"
 	"strings"
 	"unsafe"
 )
 
 func init() {
 	RegisterOutputFormat(&FormatVTK{})
+	RegisterOutputFormat(&FormatVTI{})
 }
 
-// vtk output format
+// vtk output format: StructuredGrid with the values on the points.
 type FormatVTK struct{}
 
 func (f *FormatVTK) Name() string {
@@ -31,171 +47,308 @@ func (f *FormatVTK) Name() string {
 }
 
 func (f *FormatVTK) Write(out io.Writer, q *Quant, options []string) {
-	dataformat := ""
-	switch len(options) {
-	case 0: // No options: default=ascii
-		dataformat = "ascii"
-	case 1:
-		dataformat = strings.ToLower(options[0])
-	default:
-		panic(InputErr(fmt.Sprint("Illegal VTK options:", options)))
-	}
-	if dataformat == "text" {
-		dataformat = "ascii"
-	}
-	dataformat = "ascii"
+	w := newVTKWriter(out, options)
+	gridsize := GetEngine().GridSize()
+	extent := fmt.Sprintf("0 %d 0 %d 0 %d", gridsize[Z]-1, gridsize[Y]-1, gridsize[X]-1)
 
-	writeVTKHeader(out, q)
-	writeVTKPoints(out, dataformat)
-	writeVTKCellData(out, q, dataformat)
-	writeVTKFooter(out)
+	w.header("StructuredGrid")
+	fmt.Fprintf(out, "\t<StructuredGrid WholeExtent=\"%s\">\n", extent)
+	fmt.Fprintf(out, "\t\t<Piece Extent=\"%s\">\n", extent)
+	fmt.Fprintln(out, "\t\t\t<Points>")
+	w.dataArray("points", 3, vtkPoints())
+	fmt.Fprintln(out, "\t\t\t</Points>")
+	w.quantData("PointData", q)
+	fmt.Fprintln(out, "\t\t</Piece>")
+	fmt.Fprintln(out, "\t</StructuredGrid>")
+	w.footer()
 }
 
-func writeVTKHeader(out io.Writer, q *Quant) {
-	gridsize := GetEngine().GridSize()
+// vti output format: ImageData with the values in the cells.
+// Smaller than vtk, as the regular mesh needs no point coordinates.
+type FormatVTI struct{}
 
-	fmt.Fprintln(out, "<?xml version=\"1.0\"?>")
-	fmt.Fprintln(out, "<VTKFile type=\"StructuredGrid\" version=\"0.1\" byte_order=\"LittleEndian\">")
-	fmt.Fprintf(out, "\t<StructuredGrid WholeExtent=\"0 %d 0 %d 0 %d\">\n", gridsize[Z]-1, gridsize[Y]-1, gridsize[X]-1)
-	fmt.Fprintf(out, "\t\t<Piece Extent=\"0 %d 0 %d 0 %d\">\n", gridsize[Z]-1, gridsize[Y]-1, gridsize[X]-1)
+func (f *FormatVTI) Name() string {
+	return "vti"
 }
 
-func writeVTKPoints(out io.Writer, dataformat string) {
-	fmt.Fprintln(out, "\t\t\t<Points>")
-	fmt.Fprintf(out, "\t\t\t\t<DataArray type=\"Float32\" Name=\"points\" NumberOfComponents=\"3\" format=\"%s\">\n", dataformat)
+func (f *FormatVTI) Write(out io.Writer, q *Quant, options []string) {
+	w := newVTKWriter(out, options)
 	gridsize := GetEngine().GridSize()
 	cellsize := GetEngine().CellSize()
-	switch dataformat {
+	extent := fmt.Sprintf("0 %d 0 %d 0 %d", gridsize[Z], gridsize[Y], gridsize[X])
+
+	w.header("ImageData")
+	fmt.Fprintf(out, "\t<ImageData WholeExtent=\"%s\" Origin=\"0 0 0\" Spacing=\"%v %v %v\">\n", extent, cellsize[Z], cellsize[Y], cellsize[X])
+	fmt.Fprintf(out, "\t\t<Piece Extent=\"%s\">\n", extent)
+	w.quantData("CellData", q)
+	fmt.Fprintln(out, "\t\t</Piece>")
+	fmt.Fprintln(out, "\t</ImageData>")
+	w.footer()
+}
+
+// Writes a VTK XML file with data arrays in the encoding chosen by the options.
+type vtkWriter struct {
+	out      io.Writer
+	format   string   // ascii, binary or appended
+	base64   bool     // base64 encoding for binary data
+	zlib     bool     // compressed binary data
+	appended [][]byte // blocks to append at the end
+	offset   int      // offset of the next appended block
+}
+
+func newVTKWriter(out io.Writer, options []string) *vtkWriter {
+	w := &vtkWriter{out: out, format: "ascii", base64: true}
+	for _, opt := range options {
+		switch strings.ToLower(opt) {
+		default:
+			panic(InputErr(fmt.Sprint("Illegal VTK option ", opt, ". Options are: ascii, binary, appended, raw, zlib")))
+		case "ascii", "text":
+			w.format = "ascii"
+		case "binary":
+			w.format = "binary"
+		case "appended":
+			w.format = "appended"
+		case "raw":
+			w.format = "appended"
+			w.base64 = false
+		case "zlib":
+			w.zlib = true
+		}
+	}
+	if w.zlib && w.format == "ascii" {
+		panic(InputErr("VTK option zlib needs binary, appended or raw"))
+	}
+	return w
+}
+
+func (w *vtkWriter) header(datatype string) {
+	compressor := ""
+	if w.zlib {
+		compressor = " compressor=\"vtkZLibDataCompressor\""
+	}
+	fmt.Fprintln(w.out, "<?xml version=\"1.0\"?>")
+	fmt.Fprintf(w.out, "<VTKFile type=\"%s\" version=\"1.0\" byte_order=\"LittleEndian\" header_type=\"UInt64\"%s>\n", datatype, compressor)
+}
+
+// Writes the appended data, if any, and closes the file.
+func (w *vtkWriter) footer() {
+	if w.format == "appended" {
+		encoding := "base64"
+		if !w.base64 {
+			encoding = "raw"
+		}
+		fmt.Fprintf(w.out, "\t<AppendedData encoding=\"%s\">\n", encoding)
+		fmt.Fprint(w.out, "\t\t_")
+		for _, b := range w.appended {
+			w.write(b)
+		}
+		fmt.Fprintln(w.out, "\n\t</AppendedData>")
+	}
+	fmt.Fprintln(w.out, "</VTKFile>")
+}
+
+// Writes the quantity's values as PointData or CellData.
+func (w *vtkWriter) quantData(element string, q *Quant) {
+	values, ncomp := vtkValues(q)
+	kind := map[int]string{1: "Scalars", 3: "Vectors", 9: "Tensors"}[ncomp]
+	fmt.Fprintf(w.out, "\t\t\t<%s %s=\"%s\">\n", element, kind, q.Name())
+	w.dataArray(q.Name(), ncomp, values)
+	fmt.Fprintf(w.out, "\t\t\t</%s>\n", element)
+}
+
+// Writes a Float32 DataArray element.
+func (w *vtkWriter) dataArray(name string, ncomp int, values []float32) {
+	fmt.Fprintf(w.out, "\t\t\t\t<DataArray type=\"Float32\" Name=\"%s\" NumberOfComponents=\"%d\" format=\"%s\"", name, ncomp, w.format)
+	switch w.format {
 	case "ascii":
-		for k := 0; k < gridsize[X]; k++ {
-			for j := 0; j < gridsize[Y]; j++ {
-				for i := 0; i < gridsize[Z]; i++ {
-					x := (float32)(i) * (float32)(cellsize[Z])
-					y := (float32)(j) * (float32)(cellsize[Y])
-					z := (float32)(k) * (float32)(cellsize[X])
-					_, err := fmt.Fprint(out, x, " ", y, " ", z, " ")
-					if err != nil {
-						panic(IOErr(err.Error()))
-					}
-					//fmt.Fprint(out, " \n")
-				}
+		fmt.Fprintln(w.out, ">")
+		for i, v := range values {
+			fmt.Fprint(w.out, v)
+			if (i+1)%ncomp == 0 {
+				fmt.Fprintln(w.out)
+			} else {
+				fmt.Fprint(w.out, " ")
 			}
 		}
+		fmt.Fprintln(w.out, "\t\t\t\t</DataArray>")
 	case "binary":
-		// Conversion form float32 [4]byte in big-endian
-		// encoding/binary is too slow
-		// Inlined for performance, terabytes of data will pass here...
-		var bytes []byte
-		for k := 0; k < gridsize[X]; k++ {
-			for j := 0; j < gridsize[Y]; j++ {
-				for i := 0; i < gridsize[Z]; i++ {
-					x := (float32)(i) * (float32)(cellsize[Z])
-					y := (float32)(j) * (float32)(cellsize[Y])
-					z := (float32)(k) * (float32)(cellsize[X])
-					bytes = (*[4]byte)(unsafe.Pointer(&x))[:]
-					out.Write(bytes)
-					bytes = (*[4]byte)(unsafe.Pointer(&y))[:]
-					out.Write(bytes)
-					bytes = (*[4]byte)(unsafe.Pointer(&z))[:]
-					out.Write(bytes)
-				}
+		fmt.Fprintln(w.out, ">")
+		for _, b := range w.encode(values) {
+			w.write(b)
+		}
+		fmt.Fprintln(w.out, "\n\t\t\t\t</DataArray>")
+	case "appended":
+		fmt.Fprintf(w.out, " offset=\"%d\"/>\n", w.offset)
+		for _, b := range w.encode(values) {
+			w.appended = append(w.appended, b)
+			w.offset += w.size(b)
+		}
+	}
+}
+
+// Returns the blocks that make up the binary data, each to be base64 encoded separately:
+// the byte count followed by the data, or, with zlib, the compression header and the compressed data.
+func (w *vtkWriter) encode(values []float32) [][]byte {
+	data := floatsAsBytes(values)
+	if !w.zlib {
+		var block bytes.Buffer
+		binary.Write(&block, binary.LittleEndian, uint64(len(data)))
+		block.Write(data)
+		return [][]byte{block.Bytes()}
+	}
+
+	const BLOCKSIZE = 1 << 20
+	var compressed bytes.Buffer
+	var sizes []uint64
+	for start := 0; start < len(data); start += BLOCKSIZE {
+		end := start + BLOCKSIZE
+		if end > len(data) {
+			end = len(data)
+		}
+		before := compressed.Len()
+		z := zlib.NewWriter(&compressed)
+		z.Write(data[start:end])
+		z.Close()
+		sizes = append(sizes, uint64(compressed.Len()-before))
+	}
+	// header: #blocks, block size, size of the last partial block (0 if none), compressed sizes
+	header := []uint64{uint64(len(sizes)), BLOCKSIZE, uint64(len(data) % BLOCKSIZE)}
+	header = append(header, sizes...)
+	var h bytes.Buffer
+	binary.Write(&h, binary.LittleEndian, header)
+	return [][]byte{h.Bytes(), compressed.Bytes()}
+}
+
+// Writes a block of binary data, base64 encoded if needed.
+func (w *vtkWriter) write(b []byte) {
+	var err error
+	if w.base64 {
+		enc := base64.NewEncoder(base64.StdEncoding, w.out)
+		_, err = enc.Write(b)
+		enc.Close()
+	} else {
+		_, err = w.out.Write(b)
+	}
+	if err != nil {
+		panic(IOErr(err.Error()))
+	}
+}
+
+// Number of bytes write(b) will write.
+func (w *vtkWriter) size(b []byte) int {
+	if w.base64 {
+		return base64.StdEncoding.EncodedLen(len(b))
+	}
+	return len(b)
+}
+
+// Returns the coordinates of the grid points, x running fastest.
+func vtkPoints() []float32 {
+	gridsize := GetEngine().GridSize()
+	cellsize := GetEngine().CellSize()
+	points := make([]float32, 0, 3*gridsize[X]*gridsize[Y]*gridsize[Z])
+	for k := 0; k < gridsize[X]; k++ {
+		for j := 0; j < gridsize[Y]; j++ {
+			for i := 0; i < gridsize[Z]; i++ {
+				x := (float32)(i) * (float32)(cellsize[Z])
+				y := (float32)(j) * (float32)(cellsize[Y])
+				z := (float32)(k) * (float32)(cellsize[X])
+				points = append(points, x, y, z)
 			}
 		}
-	default:
-		panic(InputErr("Illegal VTK data format " + dataformat + ". Options are: ascii, binary"))
 	}
-	fmt.Fprintln(out, "</DataArray>")
-	fmt.Fprintln(out, "\t\t\t</Points>")
+	return points
 }
 
-func writeVTKCellData(out io.Writer, q *Quant, dataformat string) {
-	N := q.NComp()
+// Returns the quantity's values, x running fastest, components in user order.
+// Tensors are written with all 9 components, also when symmetric.
+func vtkValues(q *Quant) (values []float32, ncomp int) {
 	data := q.Buffer().Array
+	N := q.NComp()
+
+	// internal component for each output component
+	var comp []int
 	switch N {
-	case SCALAR:
-		fmt.Fprintf(out, "\t\t\t<PointData Scalars=\"%s\">\n", q.Name())
-		fmt.Fprintf(out, "\t\t\t\t<DataArray type=\"Float32\" Name=\"%s\" NumberOfComponents=\"%d\" format=\"%s\">\n", q.Name(), SCALAR, dataformat)
-	case VECTOR:
-		fmt.Fprintf(out, "\t\t\t<PointData Vectors=\"%s\">\n", q.Name())
-		fmt.Fprintf(out, "\t\t\t\t<DataArray type=\"Float32\" Name=\"%s\" NumberOfComponents=\"%d\" format=\"%s\">\n", q.Name(), VECTOR, dataformat)
-	case SYMMTENS:
-		fmt.Fprintf(out, "\t\t\t<PointData Tensors=\"%s\">\n", q.Name())
-		fmt.Fprintf(out, "\t\t\t\t<DataArray type=\"Float32\" Name=\"%s\" NumberOfComponents=\"%d\" format=\"%s\">\n", q.Name(), TENS, dataformat)
-	case TENS:
-		fmt.Fprintf(out, "\t\t\t<PointData Tensors=\"%s\">\n", q.Name())
-		fmt.Fprintf(out, "\t\t\t\t<DataArray type=\"Float32\" Name=\"%s\" NumberOfComponents=\"%d\" format=\"%s\">\n", q.Name(), TENS, dataformat)
-	}
-	gridsize := GetEngine().GridSize()
-	switch dataformat {
-	case "ascii":
-		for i := 0; i < gridsize[X]; i++ {
-			for j := 0; j < gridsize[Y]; j++ {
-				for k := 0; k < gridsize[Z]; k++ {
-					// if symmetric tensor manage it appart to write the full 9 components
-					if N == SYMMTENS {
-						fmt.Fprint(out, data[SwapIndex(0, TENS)][i][j][k], " ")
-						fmt.Fprint(out, data[SwapIndex(1, TENS)][i][j][k], " ")
-						fmt.Fprint(out, data[SwapIndex(2, TENS)][i][j][k], " ")
-						fmt.Fprint(out, data[SwapIndex(1, TENS)][i][j][k], " ")
-						fmt.Fprint(out, data[SwapIndex(3, TENS)][i][j][k], " ")
-						fmt.Fprint(out, data[SwapIndex(4, TENS)][i][j][k], " ")
-						fmt.Fprint(out, data[SwapIndex(2, TENS)][i][j][k], " ")
-						fmt.Fprint(out, data[SwapIndex(4, TENS)][i][j][k], " ")
-						fmt.Fprint(out, data[SwapIndex(5, TENS)][i][j][k], " ")
-					} else {
-						for c := 0; c < N; c++ {
-							fmt.Fprint(out, data[SwapIndex(c, N)][i][j][k], " ")
-						}
-						//fmt.Fprint(out, "\n")
-					}
+	default:
+		panic(InputErr(fmt.Sprint("VTK output does not support ", N, " components")))
+	case SCALAR, VECTOR:
+		for c := 0; c < N; c++ {
+			comp = append(comp, SwapIndex(c, N))
+		}
+	case SYMMTENS, TENS:
+		// row by row in user axes: xx, xy, xz, yx, ...
+		// user axis a is internal axis 2-a
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				if N == SYMMTENS {
+					comp = append(comp, SymmTensorIdx[2-a][2-b])
+				} else {
+					comp = append(comp, FullTensorIdx[2-a][2-b])
 				}
 			}
 		}
-	case "binary":
-		// encoding/binary is too slow
-		// Inlined for performance, terabytes of data will pass here...
-		var bytes []byte
-		for i := 0; i < gridsize[X]; i++ {
-			for j := 0; j < gridsize[Y]; j++ {
-				for k := 0; k < gridsize[Z]; k++ {
-					// if symmetric tensor manage it appart to write the full 9 components
-					if N == SYMMTENS {
-						bytes = (*[4]byte)(unsafe.Pointer(&data[SwapIndex(0, TENS)][i][j][k]))[:]
-						out.Write(bytes)
-						bytes = (*[4]byte)(unsafe.Pointer(&data[SwapIndex(1, TENS)][i][j][k]))[:]
-						out.Write(bytes)
-						bytes = (*[4]byte)(unsafe.Pointer(&data[SwapIndex(2, TENS)][i][j][k]))[:]
-						out.Write(bytes)
-						bytes = (*[4]byte)(unsafe.Pointer(&data[SwapIndex(1, TENS)][i][j][k]))[:]
-						out.Write(bytes)
-						bytes = (*[4]byte)(unsafe.Pointer(&data[SwapIndex(3, TENS)][i][j][k]))[:]
-						out.Write(bytes)
-						bytes = (*[4]byte)(unsafe.Pointer(&data[SwapIndex(4, TENS)][i][j][k]))[:]
-						out.Write(bytes)
-						bytes = (*[4]byte)(unsafe.Pointer(&data[SwapIndex(2, TENS)][i][j][k]))[:]
-						out.Write(bytes)
-						bytes = (*[4]byte)(unsafe.Pointer(&data[SwapIndex(4, TENS)][i][j][k]))[:]
-						out.Write(bytes)
-						bytes = (*[4]byte)(unsafe.Pointer(&data[SwapIndex(5, TENS)][i][j][k]))[:]
-						out.Write(bytes)
-					} else {
-						for c := 0; c < N; c++ {
-							bytes = (*[4]byte)(unsafe.Pointer(&data[SwapIndex(c, N)][i][j][k]))[:]
-							out.Write(bytes)
-						}
-					}
+	}
+
+	// Here we loop over X,Y,Z, not Z,Y,X, because
+	// internal in C-order == external in Fortran-order
+	gridsize := GetEngine().GridSize()
+	values = make([]float32, 0, len(comp)*gridsize[X]*gridsize[Y]*gridsize[Z])
+	for i := 0; i < gridsize[X]; i++ {
+		for j := 0; j < gridsize[Y]; j++ {
+			for k := 0; k < gridsize[Z]; k++ {
+				for _, c := range comp {
+					values = append(values, data[c][i][j][k])
 				}
 			}
 		}
-	default:
-		panic(InputErr("Illegal VTK data format " + dataformat + ". Options are: ascii, binary"))
 	}
-	fmt.Fprintln(out, "</DataArray>")
-	fmt.Fprintln(out, "\t\t\t</PointData>")
+	return values, len(comp)
 }
 
-func writeVTKFooter(out io.Writer) {
-	fmt.Fprintln(out, "\t\t</Piece>")
-	fmt.Fprintln(out, "\t</StructuredGrid>")
+// The bytes of the float32s, in the machine's endianess,
+// which is little endian on all supported platforms.
+func floatsAsBytes(floats []float32) []byte {
+	if len(floats) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&floats[0])), 4*len(floats))
+}
+
+// True for the formats that get a .pvd time series index when auto-saved.
+func isVTKFormat(format string) bool {
+	return format == "vtk" || format == "vti"
+}
+
+// Adds a file saved at time t to the ParaView collection quant_format.pvd
+// in the output directory, so that ParaView opens all of them as one time series.
+// The collection is rewritten completely each time, so it is always a valid file.
+// An entry for the same file, left by a run that was resumed, is replaced.
+func (e *Engine) addToPVD(quant, format, filename string, t float64) {
+	if e.replaying() {
+		return // already added before the checkpoint
+	}
+	pvd := e.Relative(quant + "_" + format + ".pvd")
+	file := path.Base(filename)
+	entry := fmt.Sprintf("\t\t<DataSet timestep=\"%v\" part=\"0\" file=\"%s\"/>", t, file)
+
+	var entries []string
+	if old, err := ioutil.ReadFile(pvd); err == nil {
+		for _, line := range strings.Split(string(old), "\n") {
+			if strings.Contains(line, "<DataSet ") && !strings.Contains(line, "file=\""+file+"\"") {
+				entries = append(entries, line)
+			}
+		}
+	}
+	entries = append(entries, entry)
+
+	out := OpenWRONLY(pvd)
+	defer out.Close()
+	fmt.Fprintln(out, "<?xml version=\"1.0\"?>")
+	fmt.Fprintln(out, "<VTKFile type=\"Collection\" version=\"0.1\">")
+	fmt.Fprintln(out, "\t<Collection>")
+	for _, l := range entries {
+		fmt.Fprintln(out, l)
+	}
+	fmt.Fprintln(out, "\t</Collection>")
 	fmt.Fprintln(out, "</VTKFile>")
 }
@@ -0,0 +1,260 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"io/ioutil"
+	"math"
+	. "mumax/common"
+	"mumax/host"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Writes a vector field in every encoding and reads it back.
+func TestVTKEncodings(t *testing.T) {
+	size := []int{2, 3, 4} // internal ZYX: user z=2, y=3, x=4
+	e := initTestEngine(t, size)
+	q := e.AddNewQuant("q", VECTOR, FIELD, Unit("A/m"))
+	in := host.NewArray(3, size)
+	for c := range in.Comp {
+		for i := range in.Comp[c] {
+			in.Comp[c][i] = float32(1000*c+i) + 0.25
+		}
+	}
+	q.SetField(in)
+
+	// expected: x running fastest, components in user order
+	var want []float32
+	for i := 0; i < size[X]; i++ {
+		for j := 0; j < size[Y]; j++ {
+			for k := 0; k < size[Z]; k++ {
+				for c := 0; c < 3; c++ {
+					want = append(want, in.Array[SwapIndex(c, 3)][i][j][k])
+				}
+			}
+		}
+	}
+
+	options := [][]string{nil, {"ascii"}, {"binary"}, {"appended"}, {"raw"}, {"binary", "zlib"}, {"appended", "zlib"}, {"raw", "zlib"}}
+	for _, format := range []string{"vtk", "vti"} {
+		for _, opt := range options {
+			var out bytes.Buffer
+			GetOutputFormat(format).Write(&out, q, opt)
+			arrays := decodeVTK(t, out.Bytes())
+
+			if !equalFloats(arrays["q"], want) {
+				t.Error(format, opt, ": have", arrays["q"], "want", want)
+			}
+			if format == "vtk" {
+				points := arrays["points"]
+				c := float32(1e-9) // cell size
+				last := []float32{3 * c, 2 * c, 1 * c}
+				if len(points) != len(want) || !equalFloats(points[len(points)-3:], last) {
+					t.Error(format, opt, ": bad points", points)
+				}
+			}
+		}
+	}
+}
+
+// Compressed data larger than one zlib block, with a partial last block.
+func TestVTKZlibBlocks(t *testing.T) {
+	size := []int{3, 128, 256}
+	e := initTestEngine(t, size)
+	q := e.AddNewQuant("q", SCALAR, FIELD, Unit(""))
+	in := host.NewArray(1, size)
+	for i := range in.List {
+		in.List[i] = float32(i % 1000)
+	}
+	q.SetField(in)
+
+	var out bytes.Buffer
+	GetOutputFormat("vti").Write(&out, q, []string{"appended", "zlib"})
+	if !equalFloats(decodeVTK(t, out.Bytes())["q"], in.List) {
+		t.Error("multi-block zlib data does not match")
+	}
+}
+
+// The .pvd collection is rewritten on each save,
+// replacing entries left by a run that was resumed.
+func TestVTKCollection(t *testing.T) {
+	e := initTestEngine(t, []int{1, 4, 4})
+	dir := e.outputDir
+	e.addToPVD("m", "vti", dir+"/m000000.vti", 0)
+	e.addToPVD("m", "vti", dir+"/m000001.vti", 1e-9)
+
+	// resumed run: replayed saves are not added again,
+	// saves after the checkpoint replace the old entries
+	e.resume = &checkpoint{}
+	e.addToPVD("m", "vti", dir+"/m000000.vti", 0)
+	e.resume = nil
+	e.addToPVD("m", "vti", dir+"/m000001.vti", 2e-9)
+	e.addToPVD("m", "vti", dir+"/m000002.vti", 3e-9)
+
+	var pvd struct {
+		Type    string `xml:"type,attr"`
+		DataSet []struct {
+			Timestep float64 `xml:"timestep,attr"`
+			File     string  `xml:"file,attr"`
+		} `xml:"Collection>DataSet"`
+	}
+	data, err := ioutil.ReadFile(dir + "/m_vti.pvd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(data, &pvd); err != nil {
+		t.Fatal("invalid pvd:", err, "\n", string(data))
+	}
+	want := []struct {
+		t    float64
+		file string
+	}{{0, "m000000.vti"}, {2e-9, "m000001.vti"}, {3e-9, "m000002.vti"}}
+	if pvd.Type != "Collection" || len(pvd.DataSet) != len(want) {
+		t.Fatal("bad pvd:\n", string(data))
+	}
+	for i, w := range want {
+		if d := pvd.DataSet[i]; d.Timestep != w.t || d.File != w.file {
+			t.Error("entry", i, ": have", d, "want", w)
+		}
+	}
+	if _, err := os.Stat(dir + "/m_vtk.pvd"); err == nil {
+		t.Error("vtk collection should not exist")
+	}
+}
+
+var vtkDataArray = regexp.MustCompile(`<DataArray type="Float32" Name="(\w+)" NumberOfComponents="\d+" format="(\w+)"(?: offset="(\d+)"/>|>([^<]*)</DataArray>)`)
+
+// Decodes all Float32 DataArrays of a VTK XML file, indexed by name.
+func decodeVTK(t *testing.T, file []byte) map[string][]float32 {
+	compressed := bytes.Contains(file, []byte(`compressor="vtkZLibDataCompressor"`))
+
+	// appended data starts after the underscore
+	var appended []byte
+	appendedBase64 := true
+	if start := bytes.Index(file, []byte("<AppendedData")); start >= 0 {
+		appendedBase64 = bytes.Contains(file[start:], []byte(`encoding="base64"`))
+		data := file[start:]
+		data = data[bytes.IndexByte(data, '_')+1:]
+		appended = data[:bytes.LastIndex(data, []byte("\n\t</AppendedData>"))]
+	}
+
+	arrays := make(map[string][]float32)
+	for _, m := range vtkDataArray.FindAllSubmatch(file, -1) {
+		name, format := string(m[1]), string(m[2])
+		switch format {
+		default:
+			t.Fatal("unknown format", format)
+		case "ascii":
+			for _, f := range strings.Fields(string(m[4])) {
+				v, err := strconv.ParseFloat(f, 32)
+				if err != nil {
+					t.Fatal(err)
+				}
+				arrays[name] = append(arrays[name], float32(v))
+			}
+		case "binary":
+			arrays[name] = decodeVTKBlock(t, bytes.TrimSpace(m[4]), true, compressed)
+		case "appended":
+			offset, _ := strconv.Atoi(string(m[3]))
+			arrays[name] = decodeVTKBlock(t, appended[offset:], appendedBase64, compressed)
+		}
+	}
+	return arrays
+}
+
+// Decodes binary data starting at the beginning of data:
+// a byte count followed by the values, or, when compressed,
+// the zlib header followed by the compressed blocks.
+// Base64-encoded data has the header and data encoded separately when compressed.
+func decodeVTKBlock(t *testing.T, data []byte, b64, compressed bool) []float32 {
+	// peek returns the first n bytes of the (encoded) block starting at data
+	peek := func(n int) []byte {
+		if !b64 {
+			return data[:n]
+		}
+		dec, err := base64.StdEncoding.DecodeString(string(data[:4*((n+2)/3)]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dec[:n]
+	}
+	// next returns the complete block of n bytes and advances past it
+	next := func(n int) []byte {
+		b := peek(n)
+		if b64 {
+			data = data[base64.StdEncoding.EncodedLen(n):]
+		} else {
+			data = data[n:]
+		}
+		return b
+	}
+	u64 := func(b []byte) int { return int(binary.LittleEndian.Uint64(b)) }
+
+	var raw []byte
+	if !compressed {
+		n := u64(peek(8))
+		raw = next(8 + n)[8:]
+	} else {
+		nblocks := u64(peek(8))
+		header := next(8 * (3 + nblocks))
+		blocksize, last := u64(header[8:]), u64(header[16:])
+		var sizes []int
+		total := 0
+		for b := 0; b < nblocks; b++ {
+			sizes = append(sizes, u64(header[8*(3+b):]))
+			total += sizes[b]
+		}
+		blocks := next(total)
+		for b, s := range sizes {
+			z, err := zlib.NewReader(bytes.NewReader(blocks[:s]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec, err := ioutil.ReadAll(z)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := blocksize
+			if b == nblocks-1 && last != 0 {
+				want = last
+			}
+			if len(dec) != want {
+				t.Error("zlib block", b, "has", len(dec), "bytes, header says", want)
+			}
+			raw = append(raw, dec...)
+			blocks = blocks[s:]
+		}
+	}
+
+	values := make([]float32, len(raw)/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:]))
+	}
+	return values
+}
+
+func equalFloats(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
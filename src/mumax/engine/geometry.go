@@ -0,0 +1,95 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+// This file implements the geometry set-up.
+// Shapes (see package mumax/geom) built through the API are stored by handle,
+// and can be rasterized onto any MASK or FIELD quantity,
+// or onto regionDefinition as a named region.
+
+import (
+	"fmt"
+	. "mumax/common"
+	"mumax/geom"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Stores the shape, returns a handle to refer to it.
+func (e *Engine) AddShape(s geom.Shape) (handle int) {
+	handle = e.NewHandle()
+	e.shapes[handle] = s
+	return handle
+}
+
+// Returns the shape with the handle.
+func (e *Engine) Shape(handle int) geom.Shape {
+	s, ok := e.shapes[handle]
+	if !ok {
+		panic(InputErr(fmt.Sprint("no shape with handle ", handle)))
+	}
+	return s
+}
+
+// Tells for each cell, in the order of host.Array.List,
+// whether its center lies inside the shape.
+func (e *Engine) Raster(s geom.Shape) []bool {
+	size, cell := e.GridSize(), e.CellSize()
+	return s.Raster([3]int{size[Z], size[Y], size[X]}, [3]float64{cell[Z], cell[Y], cell[X]})
+}
+
+// Sets a MASK or FIELD quantity to value (internal order) inside the shape,
+// leaving the other cells unchanged.
+func (e *Engine) SetShapeValue(quantity string, s geom.Shape, value []float64) {
+	e.setCells(e.Quant(quantity), e.Raster(s), value, "shape")
+}
+
+// Assigns the cells inside the shape to the named region.
+// A new name gets the first index not yet used in regionDefinition.
+// Loads the regions module if needed. Returns the region index.
+func (e *Engine) DefRegion(name string, s geom.Shape) (index int) {
+	if !e.HasQuant(REGIONS) {
+		e.LoadModule("regions")
+	}
+	index, ok := e.regionNames[strings.ToLower(name)]
+	if !ok {
+		index = 1 // 0 means no region
+		for _, r := range e.regionIndex() {
+			if r >= index {
+				index = r + 1
+			}
+		}
+		for _, r := range e.regionNames {
+			if r >= index {
+				index = r + 1
+			}
+		}
+		e.regionNames[strings.ToLower(name)] = index
+		Log("Region", name, "has index", index)
+	}
+	e.setCells(e.regionQuant(), e.Raster(s), []float64{float64(index)}, "region "+name)
+	return index
+}
+
+// Returns the index of a region, given by name or number.
+// Names are case-independent.
+func (e *Engine) RegionIndex(name string) int {
+	if index, ok := e.regionNames[strings.ToLower(name)]; ok {
+		return index
+	}
+	if index, err := strconv.Atoi(name); err == nil && index >= 0 {
+		return index
+	}
+	var names []string
+	for n := range e.regionNames {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	panic(InputErr(fmt.Sprint("no region named ", name, ", have: ", names)))
+}
@@ -9,7 +9,37 @@ package engine
 
 // Author: Arne Vansteenkiste
 
-import ()
+import (
+	"mumax/gpu"
+	"mumax/host"
+	"testing"
+)
 
 func init() {
+	gpu.InitDebugGPUs()
+}
+
+// Resets the global engine to a fresh one with the given internal grid size
+// and 1nm cells, writing output to a temporary directory.
+func initTestEngine(t *testing.T, size3D []int) *Engine {
+	engine = Engine{}
+	Init()
+	e := GetEngine()
+	e.SetGridSize(size3D)
+	e.SetCellSize([]float64{1e-9, 1e-9, 1e-9})
+	e.SetOutputDirectory(t.TempDir())
+	return e
+}
+
+// Adds the regionDefinition mask with regions 0, 1, 2
+// in bands of 7 cells, and returns the region of each cell.
+func addTestRegions(e *Engine) *host.Array {
+	regions := e.AddNewQuant(REGIONS, SCALAR, MASK, Unit(""))
+	def := host.NewArray(1, e.GridSize())
+	for i := range def.List {
+		def.List[i] = float32((i / 7) % 3)
+	}
+	regions.SetMask(def)
+	regions.SetValue([]float64{1})
+	return def
 }
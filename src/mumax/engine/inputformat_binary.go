@@ -0,0 +1,36 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+// Implements input in the "bin" format, see format_binary.go.
+
+import (
+	"bufio"
+	. "mumax/common"
+	"mumax/host"
+)
+
+func init() {
+	RegisterInputFormat(".bin", ReadBinary)
+}
+
+// Reads a file written by the "bin" output format.
+func ReadBinary(fname string) *host.Array {
+	defer func() {
+		// tell which file is broken
+		if err := recover(); err != nil {
+			if e, ok := err.(IOErr); ok {
+				err = IOErr(fname + ": " + string(e))
+			}
+			panic(err)
+		}
+	}()
+	in := OpenRDONLY(fname)
+	defer in.Close()
+	return host.ReadBinary(bufio.NewReader(in))
+}
@@ -0,0 +1,91 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2012  Arne Vansteenkiste, Ben Van de Wiele and Mykola Dvornik.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+// Implements input in the binary dump and dumpc formats, see format_dump.go.
+// Arrays of another size than the quantity are resampled by SetArray/SetMask.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	. "mumax/common"
+	"mumax/dump"
+	"mumax/host"
+	"os"
+)
+
+func init() {
+	RegisterInputFormat(".dump", ReadDump)
+	RegisterInputFormat(".dumpc", ReadDumpC)
+}
+
+// Reads a dump file. A file with several frames,
+// like one written in single-file mode, yields the last frame.
+// An incomplete last frame, left by a run that was killed while writing it,
+// is skipped with a warning.
+func ReadDump(fname string) *host.Array {
+	in, err := os.Open(fname)
+	CheckIO(err)
+	defer in.Close()
+
+	r := dump.NewReader(bufio.NewReader(in), dump.CRC_ENABLED)
+	var array *host.Array
+	for {
+		start := r.Bytes
+		err := r.Read()
+		if err == io.EOF && r.Bytes == start && array != nil {
+			return array
+		}
+		if truncated(err) && array != nil {
+			Warn(fname, "ends with an incomplete frame, using the last complete one")
+			return array
+		}
+		if err != nil {
+			panic(IOErr(fname + ": " + err.Error()))
+		}
+		if r.Precission != dump.FLOAT32 {
+			panic(IOErr(fname + ": unsupported precission: " + fmt.Sprint(r.Precission)))
+		}
+		array = host.NewArray(r.Components, r.MeshSize[:])
+		copy(array.List, r.Data)
+	}
+}
+
+// Reads the last frame of a dumpc container.
+// Frames that were cut off, e.g. when the file was truncated,
+// are skipped with a warning.
+func ReadDumpC(fname string) *host.Array {
+	c, err := dump.ReadContainer(fname)
+	CheckIO(err)
+	defer c.Close()
+	if c.NumFrames() == 0 {
+		panic(IOErr(fname + ": no frames"))
+	}
+	last := c.NumFrames() - 1
+	for i := last; ; i-- {
+		f, err := c.ReadFrame(i)
+		if truncated(err) && i > 0 {
+			continue
+		}
+		if err != nil {
+			panic(IOErr(fname + ": " + err.Error()))
+		}
+		if i != last {
+			Warn(fname, "ends with", last-i, "incomplete frame(s), using the last complete one")
+		}
+		array := host.NewArray(f.Components, f.MeshSize[:])
+		copy(array.List, f.Data)
+		return array
+	}
+}
+
+// Tells whether a read error means the data was cut off.
+func truncated(err error) bool {
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,157 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+import (
+	"bufio"
+	. "mumax/common"
+	"mumax/dump"
+	"mumax/host"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Writes frames of 3-component data of the given internal size,
+// in dump or dumpc format depending on the extension.
+// Frame f has the values 100*f + index.
+// Returns the size of one frame in bytes.
+func writeTestDump(t *testing.T, fname string, size []int, frames int) int64 {
+	h := dump.Header{Components: 3, MeshSize: [3]int{size[X], size[Y], size[Z]}, MeshStep: [3]float64{1e-9, 1e-9, 1e-9}, DataLabel: "m"}
+	data := make([]float32, 3*Prod(size))
+	frame := func(f int) []float32 {
+		h.Time = float64(f) * 1e-12
+		for i := range data {
+			data[i] = float32(100*f + i)
+		}
+		return data
+	}
+
+	if filepath.Ext(fname) == ".dumpc" {
+		c, err := dump.OpenContainer(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		for f := 0; f < frames; f++ {
+			if err := c.WriteFrame(&h, frame(f)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return c.Index[0].Size
+	}
+
+	out, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	buf := bufio.NewWriter(out)
+	w := dump.NewWriter(buf, dump.CRC_ENABLED)
+	var size1 int64
+	for f := 0; f < frames; f++ {
+		w.Header = h
+		w.WriteHeader()
+		w.WriteData(frame(f))
+		w.WriteHash()
+		if f == 0 {
+			size1 = w.Bytes
+		}
+	}
+	if w.Err != nil {
+		t.Fatal(w.Err)
+	}
+	if err := buf.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return size1
+}
+
+// Checks that a holds frame f, as written by writeTestDump.
+func checkTestFrame(t *testing.T, msg string, a *host.Array, size []int, f int) {
+	if !EqualSize(a.Size3D, size) || a.NComp() != 3 {
+		t.Error(msg, ": have size", a.NComp(), a.Size3D, "want", 3, size)
+		return
+	}
+	for i, v := range a.List {
+		if v != float32(100*f+i) {
+			t.Error(msg, ": have", a.List, "want frame", f)
+			return
+		}
+	}
+}
+
+// Returns the IOErr raised by f, if any.
+func catchIOErr(f func()) (err IOErr) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = e.(IOErr)
+		}
+	}()
+	f()
+	return ""
+}
+
+func TestReadDump(t *testing.T) {
+	size := []int{1, 2, 4}
+	for _, ext := range []string{".dump", ".dumpc"} {
+		dir := t.TempDir()
+
+		// the last of several frames
+		fname := filepath.Join(dir, "m"+ext)
+		frameSize := writeTestDump(t, fname, size, 3)
+		checkTestFrame(t, ext, ReadFile(fname), size, 2)
+
+		// a cut-off last frame is skipped,
+		// also when it ends in the header or right after a header field
+		full, _ := os.ReadFile(fname)
+		for _, cut := range []int64{1, 8, frameSize / 2, frameSize - 8, frameSize - 1} {
+			truncated := filepath.Join(dir, "truncated"+ext)
+			if err := os.WriteFile(truncated, full[:int64(len(full))-cut], 0666); err != nil {
+				t.Fatal(err)
+			}
+			checkTestFrame(t, ext+" truncated", ReadFile(truncated), size, 1)
+		}
+
+		// nothing complete to return
+		single := filepath.Join(dir, "single"+ext)
+		writeTestDump(t, single, size, 1)
+		data, _ := os.ReadFile(single)
+		if err := os.WriteFile(single, data[:len(data)-10], 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := catchIOErr(func() { ReadFile(single) }); err == "" {
+			t.Error(ext, ": no error for truncated single frame")
+		}
+	}
+}
+
+// Dump files of another size are resampled when loaded.
+func TestReadDumpResample(t *testing.T) {
+	fileSize := []int{1, 2, 4}
+	e := initTestEngine(t, []int{1, 4, 8})
+	e.AddNewQuant("q", VECTOR, FIELD, Unit(""))
+	for _, ext := range []string{".dump", ".dumpc"} {
+		fname := filepath.Join(t.TempDir(), "m"+ext)
+		writeTestDump(t, fname, fileSize, 2)
+		API{e}.SetArray_File("q", fname)
+
+		// nearest neighbor: each file cell becomes 2x2 cells
+		have := e.Quant("q").Buffer()
+		in := ReadFile(fname)
+		for c := range have.Array {
+			for j := range have.Array[c][0] {
+				for k := range have.Array[c][0][j] {
+					if have.Array[c][0][j][k] != in.Array[c][0][j/2][k/2] {
+						t.Fatal(ext, ": have", have.Array, "from", in.Array)
+					}
+				}
+			}
+		}
+	}
+}
@@ -14,3 +14,11 @@ import ()
 type Notifier interface {
 	Notify(e *Engine) // Notifies the crontab that a step has been taken, so it can take action if needed
 }
+
+// Notifiers that keep track of their progress (e.g., the number of times they have saved)
+// implement this so their state can be saved in a checkpoint.
+type StatefulNotifier interface {
+	Notifier
+	CheckpointState() []float64   // Returns the internal state
+	RestoreState(state []float64) // Restores the state returned by CheckpointState()
+}
@@ -0,0 +1,69 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+import (
+	"fmt"
+	. "mumax/common"
+	"os"
+)
+
+// Saves a checkpoint periodically, in simulated or wall-clock time.
+// Only the last few checkpoint files are kept.
+type AutoCheckpoint struct {
+	period   float64 // How often to save, in seconds
+	keep     int     // Number of checkpoint files to keep
+	walltime bool    // Period is in wall-clock time instead of simulated time
+	start    float64 // Starting point, simulated time or wall-clock time
+	count    int     // Number of times it has been saved
+}
+
+// Called by the eninge
+func (a *AutoCheckpoint) Notify(e *Engine) {
+	t := e.time.Scalar() - a.start
+	if a.walltime {
+		t = wallTime() - a.start
+	}
+	if t-float64(a.count)*a.period >= a.period {
+		// count first, so the checkpoint itself contains the updated count
+		a.count++
+		e.Checkpoint(a.filename(a.count))
+		if old := a.count - a.keep; old > 0 {
+			err := os.Remove(e.Relative(a.filename(old)))
+			if err != nil && !os.IsNotExist(err) {
+				Warn(err)
+			}
+		}
+	}
+}
+
+// File name of the n'th checkpoint, e.g. "checkpoint000007.dat".
+func (a *AutoCheckpoint) filename(n int) string {
+	return "checkpoint" + fmt.Sprintf(GetEngine().filenameFormat, n) + ".dat"
+}
+
+// Returns {start, count}, see StatefulNotifier.
+func (a *AutoCheckpoint) CheckpointState() []float64 {
+	return []float64{a.start, float64(a.count)}
+}
+
+// See StatefulNotifier.
+// With a wall-clock period, the clock restarts at the moment of resuming.
+func (a *AutoCheckpoint) RestoreState(state []float64) {
+	a.count = int(state[1])
+	if a.walltime {
+		a.start = wallTime() - float64(a.count)*a.period
+	} else {
+		a.start = state[0]
+	}
+}
+
+// Wall-clock time in seconds.
+func wallTime() float64 {
+	return float64(Nanoseconds()) / 1e9
+}
@@ -25,7 +25,21 @@ type AutoSave struct {
 func (a *AutoSave) Notify(e *Engine) {
 	t := e.time.Scalar() - a.start
 	if t-float64(a.count)*a.period >= a.period {
-		e.SaveAs(e.Quant(a.quant), a.format, a.options, e.AutoFilename(a.quant, a.format))
+		fname := e.AutoFilename(a.quant, a.format)
+		e.SaveAs(e.Quant(a.quant), a.format, a.options, fname)
+		if isVTKFormat(a.format) {
+			e.addToPVD(a.quant, a.format, fname, e.time.Scalar())
+		}
 		a.count++
 	}
 }
+
+// Returns {start, count}, see StatefulNotifier.
+func (a *AutoSave) CheckpointState() []float64 {
+	return []float64{a.start, float64(a.count)}
+}
+
+// See StatefulNotifier.
+func (a *AutoSave) RestoreState(state []float64) {
+	a.start, a.count = state[0], int(state[1])
+}
@@ -9,7 +9,11 @@ package engine
 
 // Auhtor: Arne Vansteenkiste
 
-import ()
+import (
+	. "mumax/common"
+	"mumax/dump"
+	"os"
+)
 
 // Saves a field (scalar field, vector field, etc) periodically.
 type AutoSaveSingleFile struct {
@@ -25,7 +29,42 @@ type AutoSaveSingleFile struct {
 func (a *AutoSaveSingleFile) Notify(e *Engine) {
 	t := e.time.Scalar() - a.start
 	if t-float64(a.count)*a.period >= a.period {
-		e.SaveAsAppend(e.Quant(a.quant), a.format, a.options, e.AutoFilenameSingleFile(a.quant, a.format))
+		fname := e.AutoFilenameSingleFile(a.quant, a.format)
+		if a.format == "dumpc" {
+			e.SaveToContainer(e.Quant(a.quant), fname)
+		} else {
+			e.SaveAsAppend(e.Quant(a.quant), a.format, a.options, fname)
+		}
 		a.count++
 	}
 }
+
+// Returns {start, count, file size}, see StatefulNotifier.
+func (a *AutoSaveSingleFile) CheckpointState() []float64 {
+	e := GetEngine()
+	size := fileSize(e.AutoFilenameSingleFile(a.quant, a.format))
+	return []float64{a.start, float64(a.count), float64(size)}
+}
+
+// See StatefulNotifier.
+// Output appended after the checkpoint was taken is discarded.
+func (a *AutoSaveSingleFile) RestoreState(state []float64) {
+	a.start, a.count = state[0], int(state[1])
+	fname := GetEngine().AutoFilenameSingleFile(a.quant, a.format)
+	if !FileExists(fname) {
+		return
+	}
+	size := int64(state[2])
+	if size < 0 {
+		size = 0 // nothing was saved yet when the checkpoint was taken
+	}
+	if a.format == "dumpc" {
+		// also remove the index entries of the discarded frames
+		c, err := dump.OpenContainer(fname)
+		CheckIO(err)
+		defer c.Close()
+		CheckIO(c.Truncate(size))
+	} else {
+		CheckIO(os.Truncate(fname, size))
+	}
+}
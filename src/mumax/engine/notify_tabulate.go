@@ -26,3 +26,13 @@ func (a *AutoTabulate) Notify(e *Engine) {
 		a.count++
 	}
 }
+
+// Returns {count}, see StatefulNotifier.
+func (a *AutoTabulate) CheckpointState() []float64 {
+	return []float64{float64(a.count)}
+}
+
+// See StatefulNotifier.
+func (a *AutoTabulate) RestoreState(state []float64) {
+	a.count = int(state[0])
+}
@@ -0,0 +1,56 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+// Output of arrays that do not belong to a simulation,
+// for post-processing tools like mumax2-convert.
+
+import (
+	"io"
+	"mumax/host"
+	"path"
+	"sync"
+)
+
+var offlineMutex sync.Mutex
+
+// Writes an array, e.g. read from a file, in a registered output format,
+// as if it were the quantity name with the given unit at time t.
+// cellSize is in internal (ZYX) order, like Engine.CellSize().
+//
+// The output formats take the grid size, cell size and time from the global engine,
+// so WriteArray replaces the engine's state and must not be used during a simulation.
+// Concurrent calls are serialized.
+func WriteArray(out io.Writer, format string, options []string, data *host.Array, name string, unit Unit, cellSize []float64, t float64) {
+	f := GetOutputFormat(format)
+
+	offlineMutex.Lock()
+	defer offlineMutex.Unlock()
+
+	engine = Engine{}
+	e := &engine
+	e.init()
+	e.size3D = e.size3D_[:]
+	copy(e.size3D, data.Size3D)
+	e.cellSize = e.cellSize_[:]
+	copy(e.cellSize, cellSize)
+	e.time.SetScalar(t)
+
+	// not Quant.init, that needs the GPU
+	q := &Quant{name: name, nComp: data.NComp(), kind: FIELD, unit: unit, cpuOnly: true, buffer: data}
+	q.multiplier = ones(q.nComp)
+	q.initChildrenParents()
+
+	f.Write(out, q, options)
+}
+
+// Tells whether ReadFile can read the file, judging by its extension.
+func CanReadFile(fname string) bool {
+	_, ok := inputFormats[path.Ext(fname)]
+	return ok
+}
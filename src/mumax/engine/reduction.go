@@ -0,0 +1,217 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+// This file implements reduction quantities like "max(|torque|)",
+// which are added on-demand by addDerivedQuant so that spatial
+// statistics of FIELD and MASK quantities can be tabulated.
+//
+// Syntax:
+//	"avg(q)", "min(q)", "max(q)", "std(q)" : per component
+//	"max(|q|)", ...                         : of the norm, for vectors
+//	"avg(q, region=2)", ...                 : only over cells in region 2, see the regions module
+//	"avg(q, region=top)", ...               : only over cells in the region named top, see geometry.go
+// q may also be a component, like "m.z" or "m_z".
+//
+// Averages, minima and maxima over the whole grid use the GPU reductors.
+// The standard deviation, norms other than max(|q|), and region restrictions
+// are computed on the host, in double precision.
+
+import (
+	"fmt"
+	"math"
+	. "mumax/common"
+	"regexp"
+	"strings"
+)
+
+var reductionRegexp = regexp.MustCompile(`^(avg|min|max|std)\((.+)\)$`)
+
+// Tells whether name is a reduction like "avg(m)".
+func isReduction(name string) bool {
+	return reductionRegexp.MatchString(strings.ToLower(name))
+}
+
+// Adds the reduction quantity name, like "avg(m)", see above.
+func (e *Engine) addReduction(name string) {
+	match := reductionRegexp.FindStringSubmatch(strings.ToLower(name))
+	op, args := match[1], strings.Split(match[2], ",")
+
+	operand := args[0]
+	norm := false
+	if len(operand) > 2 && strings.HasPrefix(operand, "|") && strings.HasSuffix(operand, "|") {
+		norm = true
+		operand = operand[1 : len(operand)-1]
+	}
+	in := e.Quant(e.componentName(operand))
+	if in.kind == VALUE {
+		panic(InputErr(fmt.Sprint(in.Name(), " is not space-dependent, can not take ", op, "()")))
+	}
+
+	region := -1
+	for _, arg := range args[1:] {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[0] != "region" {
+			panic(InputErr(fmt.Sprint("illegal argument ", arg, " in ", name, ", only region=N is allowed")))
+		}
+		e.regionQuant() // check that regions are defined
+		region = e.RegionIndex(kv[1])
+	}
+
+	nComp := in.nComp
+	if norm {
+		nComp = 1
+	}
+	out := e.AddNewQuant(name, nComp, VALUE, in.unit, op+" of "+in.desc)
+
+	gpuOK := region < 0 && op != "std" && (!norm || (op == "max" && in.kind == FIELD && (in.nComp == 1 || in.nComp == 3)))
+	if gpuOK {
+		red := NewReduceUpdater(in, out)
+		switch {
+		case norm && in.nComp == 3:
+			out.updater = (*MaxNormUpdater)(red)
+		case norm:
+			out.updater = (*MaxAbsUpdater)(red)
+		case op == "avg":
+			out.updater = (*AverageUpdater)(red)
+		default:
+			out.updater = &ExtremumUpdater{red, op == "max"}
+		}
+		return
+	}
+
+	u := &HostReduceUpdater{in: in, out: out, op: op, norm: norm, region: region}
+	e.Depends(name, in.Name())
+	if region >= 0 {
+		u.regions = e.regionQuant()
+		e.Depends(name, u.regions.Name())
+	}
+	out.updater = u
+}
+
+// Resolves "m_z" to the component "m.z", if there is no quantity "m_z".
+func (e *Engine) componentName(name string) string {
+	if e.HasQuant(name) {
+		return name
+	}
+	if i := strings.LastIndex(name, "_"); i > 0 && e.HasQuant(name[:i]) {
+		switch name[i+1:] {
+		case "x", "y", "z", "xx", "yy", "zz", "xy", "xz", "yz", "yx", "zx", "zy":
+			return name[:i] + "." + name[i+1:]
+		}
+	}
+	return name
+}
+
+// ________________________________________________________________________________ min/max
+
+// Updates the minimum or maximum of each component.
+type ExtremumUpdater struct {
+	*ReduceUpdater
+	max bool
+}
+
+func (u *ExtremumUpdater) Update() {
+	for c := 0; c < u.in.nComp; c++ {
+		mul := u.in.multiplier[c]
+		if !u.in.IsSpaceDependent() {
+			u.out.SetComponent(c, mul)
+			continue
+		}
+		comp := u.in.Array().Component(c)
+		// a negative multiplier turns the maximum into the minimum
+		var value float32
+		if u.max == (mul >= 0) {
+			value = u.reduce.Max(comp)
+		} else {
+			value = u.reduce.Min(comp)
+		}
+		u.out.SetComponent(c, float64(value)*mul)
+	}
+}
+
+// ________________________________________________________________________________ host
+
+// Computes a reduction on the host buffer of the input.
+type HostReduceUpdater struct {
+	in, out *Quant
+	op      string // avg, min, max or std
+	norm    bool   // reduce the norm instead of each component
+	regions *Quant // regionDefinition, nil if not restricted to a region
+	region  int
+}
+
+func (u *HostReduceUpdater) Update() {
+	buffer := u.in.Buffer()
+	var regions []float32
+	if u.regions != nil {
+		regions = u.regions.Buffer().List
+	}
+
+	// values per cell: each component, or the norm
+	nValues := buffer.NComp()
+	if u.norm {
+		nValues = 1
+	}
+	value := func(c, i int) float64 {
+		if !u.norm {
+			return float64(buffer.Comp[c][i])
+		}
+		var norm2 float64
+		for _, comp := range buffer.Comp {
+			norm2 += float64(comp[i]) * float64(comp[i])
+		}
+		return math.Sqrt(norm2)
+	}
+	inRegion := func(i int) bool {
+		return regions == nil || int(regions[i]+0.5) == u.region
+	}
+
+	N := len(buffer.Comp[0])
+	for c := 0; c < nValues; c++ {
+		var sum, min, max float64
+		min, max = math.Inf(1), math.Inf(-1)
+		count := 0
+		for i := 0; i < N; i++ {
+			if !inRegion(i) {
+				continue
+			}
+			v := value(c, i)
+			sum += v
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+			count++
+		}
+		if count == 0 {
+			u.out.SetComponent(c, 0) // empty region, NaN would be fatal
+			continue
+		}
+		avg := sum / float64(count)
+
+		var result float64
+		switch u.op {
+		case "avg":
+			result = avg
+		case "min":
+			result = min
+		case "max":
+			result = max
+		case "std":
+			// second pass, more accurate than <v²>-<v>²
+			var sum2 float64
+			for i := 0; i < N; i++ {
+				if inRegion(i) {
+					d := value(c, i) - avg
+					sum2 += d * d
+				}
+			}
+			result = math.Sqrt(sum2 / float64(count))
+		}
+		u.out.SetComponent(c, result)
+	}
+}
@@ -0,0 +1,156 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+import (
+	"math"
+	. "mumax/common"
+	"mumax/host"
+	"testing"
+)
+
+// Checks reductions, on the GPU and on the host, against values computed here.
+func TestReduction(t *testing.T) {
+	size := []int{2, 4, 8}
+	e := initTestEngine(t, size)
+	N := Prod(size)
+
+	// vector field with positive and negative values
+	q := e.AddNewQuant("q", VECTOR, FIELD, Unit("T"))
+	qIn := host.NewArray(3, size)
+	for c := range qIn.Comp {
+		for i := range qIn.Comp[c] {
+			qIn.Comp[c][i] = float32(float64(c+1) * math.Sin(float64(i*(c+1)+c)))
+		}
+	}
+	q.SetField(qIn)
+
+	// scalar field
+	s := e.AddNewQuant("s", SCALAR, FIELD, Unit(""))
+	sIn := host.NewArray(1, size)
+	for i := range sIn.List {
+		sIn.List[i] = float32(math.Cos(float64(i))) - 0.3
+	}
+	s.SetField(sIn)
+
+	// mask with negative multipliers
+	k := e.AddNewQuant("k", VECTOR, MASK, Unit(""))
+	kIn := host.NewArray(3, size)
+	for c := range kIn.Comp {
+		for i := range kIn.Comp[c] {
+			kIn.Comp[c][i] = float32(i%5) - float32(c)
+		}
+	}
+	k.SetMask(kIn)
+	k.SetValue([]float64{-2, 1, -0.5})
+	kMul := k.Multiplier()
+
+	regIn := addTestRegions(e)
+	e.regionNames["top"] = 2
+
+	all := func(i int) bool { return true }
+	region := func(r int) func(int) bool {
+		return func(i int) bool { return int(regIn.List[i]) == r }
+	}
+
+	// per-component statistics
+	stats := func(data []float32, mul float64, inside func(int) bool) (avg, min, max, std float64) {
+		min, max = math.Inf(1), math.Inf(-1)
+		count := 0
+		for i := range data {
+			if inside(i) {
+				v := float64(data[i]) * mul
+				avg += v
+				min = math.Min(min, v)
+				max = math.Max(max, v)
+				count++
+			}
+		}
+		avg /= float64(count)
+		for i := range data {
+			if inside(i) {
+				d := float64(data[i])*mul - avg
+				std += d * d
+			}
+		}
+		std = math.Sqrt(std / float64(count))
+		return
+	}
+	norm := func(i int) float64 {
+		var n2 float64
+		for c := range qIn.Comp {
+			n2 += float64(qIn.Comp[c][i]) * float64(qIn.Comp[c][i])
+		}
+		return math.Sqrt(n2)
+	}
+	norms := make([]float32, N)
+	for i := range norms {
+		norms[i] = float32(norm(i))
+	}
+
+	check := func(name string, want ...float64) {
+		have := e.Quant(name)
+		have.Update()
+		if len(have.Multiplier()) != len(want) {
+			t.Error(name, ": have", have.Multiplier(), "want", want)
+			return
+		}
+		for c := range want {
+			if math.Abs(have.Multiplier()[c]-want[c]) > 1e-5*math.Max(1, math.Abs(want[c])) {
+				t.Error(name, ": have", have.Multiplier(), "want", want)
+				return
+			}
+		}
+	}
+
+	for _, r := range []struct {
+		name   string
+		inside func(int) bool
+	}{{"", all}, {", region=1", region(1)}, {", region=top", region(2)}} {
+		var avg, min, max, std [3]float64
+		for c := range qIn.Comp {
+			avg[c], min[c], max[c], std[c] = stats(qIn.Comp[c], 1, r.inside)
+		}
+		check("avg(q"+r.name+")", avg[:]...)
+		check("min(q"+r.name+")", min[:]...)
+		check("max(q"+r.name+")", max[:]...)
+		check("std(q"+r.name+")", std[:]...)
+
+		// component operands: m.z and m_z, user z is internal component X
+		check("avg(q.z"+r.name+")", avg[X])
+		check("max(q_z"+r.name+")", max[X])
+		check("std(q_y"+r.name+")", std[Y])
+
+		// norms: max(|q|) uses the GPU, min(|q|) the host
+		_, nmin, nmax, _ := stats(norms, 1, r.inside)
+		check("max(|q|"+r.name+")", nmax)
+		check("min(|q|"+r.name+")", nmin)
+
+		// max(|s|) of a scalar
+		abs := make([]float32, N)
+		for i, v := range sIn.List {
+			abs[i] = float32(math.Abs(float64(v)))
+		}
+		_, _, smax, _ := stats(abs, 1, r.inside)
+		check("max(|s|"+r.name+")", smax)
+
+		// mask: negative multipliers swap minimum and maximum
+		for c := range kIn.Comp {
+			avg[c], min[c], max[c], std[c] = stats(kIn.Comp[c], kMul[c], r.inside)
+		}
+		check("avg(k"+r.name+")", avg[:]...)
+		check("min(k"+r.name+")", min[:]...)
+		check("max(k"+r.name+")", max[:]...)
+	}
+
+	// the average tracks changes of the input
+	qIn.Comp[X][0] += 64
+	q.SetField(qIn)
+	avgZ, _, _, _ := stats(qIn.Comp[X], 1, all)
+	check("avg(q.z)", avgZ)
+}
@@ -0,0 +1,250 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+// This file implements engine support for the regions defined by
+// the "regionDefinition" mask of the regions module.
+// Each cell belongs to the region given by the (rounded) mask value.
+//
+// Per-region quantities are added on-demand by addDerivedQuant:
+//	"m_region2"      : average of a FIELD or MASK over region 2, like avg(m, region=2)
+//	"E_ex_region2"   : a VALUE that is a sum over the cells (like an energy),
+//	                   restricted to region 2. Its updater must implement RegionSummer.
+//
+// The exchange between pairs of regions can be scaled with SetExchangeScale.
+
+import (
+	"fmt"
+	"math"
+	. "mumax/common"
+	"mumax/host"
+	"regexp"
+	"strconv"
+)
+
+const REGIONS = "regionDefinition"
+
+var regionQuantRegexp = regexp.MustCompile(`^(.+)_region([0-9]+)$`)
+
+// Implemented by updaters of VALUE quantities that are sums over all cells,
+// like energies, so that the sum can also be taken over only one region.
+type RegionSummer interface {
+	// Returns an updater that writes the sum over the region to out.
+	RegionUpdater(out *Quant, region int) Updater
+}
+
+// The regionDefinition quantity, panics if the regions module is not loaded.
+func (e *Engine) regionQuant() *Quant {
+	if !e.HasQuant(REGIONS) {
+		panic(InputErr("regions are not defined, load('regions') first"))
+	}
+	return e.Quant(REGIONS)
+}
+
+// Region index of each cell, in the same order as host.Array.List.
+func (e *Engine) regionIndex() []int {
+	def := e.regionQuant().Buffer().List
+	index := make([]int, len(def))
+	for i, r := range def {
+		index[i] = int(r + 0.5)
+	}
+	return index
+}
+
+// Tells whether name is a per-region quantity like "m_region2".
+func isRegionQuant(name string) bool {
+	return regionQuantRegexp.MatchString(name)
+}
+
+// Adds the per-region quantity name, like "m_region2" or "E_ex_region2".
+func (e *Engine) addRegionQuant(name string) {
+	match := regionQuantRegexp.FindStringSubmatch(name)
+	in := e.Quant(match[1])
+	region, _ := strconv.Atoi(match[2])
+	regions := e.regionQuant()
+
+	switch in.kind {
+	case FIELD, MASK:
+		out := e.AddNewQuant(name, in.nComp, VALUE, in.unit, fmt.Sprint("average of ", in.desc, " over region ", region))
+		out.updater = &HostReduceUpdater{in: in, out: out, op: "avg", regions: regions, region: region}
+		e.Depends(name, in.Name(), regions.Name())
+	case VALUE:
+		if !regionSummable(in) {
+			panic(InputErr(fmt.Sprint(in.Name(), " can not be computed per region, only space-dependent quantities and energies can")))
+		}
+		summer := in.updater.(RegionSummer)
+		out := e.AddNewQuant(name, in.nComp, VALUE, in.unit, fmt.Sprint(in.desc, " in region ", region))
+		out.updater = summer.RegionUpdater(out, region)
+		e.Depends(name, regions.Name())
+	default:
+		panic(Bug(fmt.Sprint("unknown kind ", in.kind)))
+	}
+}
+
+// Tells whether the VALUE q can be computed per region.
+func regionSummable(q *Quant) bool {
+	if sum, ok := q.updater.(*SumUpdater); ok {
+		for _, p := range sum.parents {
+			if p.kind != VALUE || !regionSummable(p) {
+				return false
+			}
+		}
+		return true
+	}
+	_, ok := q.updater.(RegionSummer)
+	return ok
+}
+
+// Sets the value of a MASK or FIELD quantity in all cells of the region,
+// leaving the other cells unchanged. The value is in internal (ZYX) order.
+func (e *Engine) SetRegionValue(quantity string, region int, value []float64) {
+	index := e.regionIndex()
+	inside := make([]bool, len(index))
+	for i, r := range index {
+		inside[i] = r == region
+	}
+	e.setCells(e.Quant(quantity), inside, value, fmt.Sprint("region ", region))
+}
+
+// Sets the value of a MASK or FIELD quantity in the cells where inside is true.
+// A MASK keeps its multiplier, so the value is stored as value/multiplier in the mask.
+// Only a multiplier of zero is replaced, by the largest value.
+// where describes the cells, for the warning when there are none.
+func (e *Engine) setCells(q *Quant, inside []bool, value []float64, where string) {
+	checkKinds(q, MASK, FIELD)
+	checkComp(q, len(value))
+
+	data := host.NewArray(q.nComp, q.Buffer().Size3D)
+	copy(data.List, q.Buffer().List)
+	found := false
+	for i, in := range inside {
+		if in {
+			found = true
+			for c := range data.Comp {
+				data.Comp[c][i] = float32(value[c])
+			}
+		}
+	}
+	if !found {
+		Warn(where, " contains no cells, ", q.Name(), " not set")
+		return
+	}
+
+	if q.kind == FIELD {
+		for _, m := range q.multiplier {
+			if m != 1 {
+				panic(InputErr(fmt.Sprint(q.Name(), " has a multiplier, can not set it per cell")))
+			}
+		}
+		q.SetField(data)
+		return
+	}
+
+	for c, comp := range data.Comp {
+		if q.multiplier[c] == 0 {
+			q.multiplier[c] = maxAbs(comp)
+			if q.multiplier[c] == 0 {
+				q.multiplier[c] = 1
+			}
+		}
+		mul := float32(q.multiplier[c])
+		for i := range comp {
+			comp[i] /= mul
+		}
+	}
+	q.SetMask(data)
+	q.Verify()
+}
+
+func maxAbs(list []float32) float64 {
+	max := 0.
+	for _, v := range list {
+		max = math.Max(max, math.Abs(float64(v)))
+	}
+	return max
+}
+
+// ________________________________________________________________________________ exchange scaling
+
+// Name of the exchange6 mask that holds the exchange scaling of each link between cells.
+const EXCHANGE_SCALE = "Aex_scale"
+
+// Scales the exchange between cells of regions r1 and r2 (in either order) by scale:
+// 0 decouples them, 1 (the default) is the normal exchange.
+// With r1 == r2, the exchange inside a region is scaled.
+func (e *Engine) SetExchangeScale(r1, r2 int, scale float64) {
+	if scale < 0 {
+		panic(InputErr(fmt.Sprint("exchange scale should be >= 0, have: ", scale)))
+	}
+	regions := e.regionQuant()
+	e.exchScale[regionPair(r1, r2)] = scale
+	if e.HasQuant(EXCHANGE_SCALE) {
+		e.Depends(EXCHANGE_SCALE, regions.Name())
+		e.Quant(EXCHANGE_SCALE).Invalidate()
+	}
+}
+
+// The exchange scaling between regions r1 and r2, 1 if not set.
+func (e *Engine) ExchangeScale(r1, r2 int) float64 {
+	if scale, ok := e.exchScale[regionPair(r1, r2)]; ok {
+		return scale
+	}
+	return 1
+}
+
+// Tells whether the exchange is scaled between any pair of regions.
+func (e *Engine) HasExchangeScale() bool {
+	return len(e.exchScale) != 0
+}
+
+// Key of the exchange scaling table, independent of the order of r1, r2.
+func regionPair(r1, r2 int) [2]int {
+	if r1 > r2 {
+		r1, r2 = r2, r1
+	}
+	return [2]int{r1, r2}
+}
+
+// ________________________________________________________________________________ region sums
+
+// Region version of SDotUpdater, computed on the host.
+type regionSDotUpdater struct {
+	sum              *Quant
+	parent1, parent2 *Quant
+	scaling          float64
+	region           int
+}
+
+func (u *SDotUpdater) RegionUpdater(out *Quant, region int) Updater {
+	GetEngine().Depends(out.Name(), u.parent1.Name(), u.parent2.Name())
+	return &regionSDotUpdater{out, u.parent1, u.parent2, u.scaling, region}
+}
+
+func (u *regionSDotUpdater) Update() {
+	index := GetEngine().regionIndex()
+	b1, b2 := u.parent1.Buffer(), u.parent2.Buffer()
+	sum := 0.
+	for c := range b1.Comp {
+		comp1, comp2 := b1.Comp[c], b2.Comp[c]
+		for i, r := range index {
+			if r == u.region {
+				sum += float64(comp1[i]) * float64(comp2[i])
+			}
+		}
+	}
+	u.sum.multiplier[0] = sum * u.scaling
+}
+
+// The region sum of a sum of VALUEs is the sum of the region sums of the terms.
+func (u *SumUpdater) RegionUpdater(out *Quant, region int) Updater {
+	regionSum := NewSumUpdater(out).(*SumUpdater)
+	for p, parent := range u.parents {
+		regionSum.MAddParent(fmt.Sprint(parent.Name(), "_region", region), u.weight[p])
+	}
+	return regionSum
+}
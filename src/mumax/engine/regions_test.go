@@ -0,0 +1,120 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+import (
+	"fmt"
+	"math"
+	. "mumax/common"
+	"mumax/host"
+	"testing"
+)
+
+// setregion on a MASK keeps the multiplier and rescales the mask values.
+func TestSetRegionValue(t *testing.T) {
+	e := initTestEngine(t, []int{1, 4, 8})
+	def := addTestRegions(e)
+
+	// checks the values of q per region
+	check := func(q *Quant, wantMul []float64, want map[int][]float64) {
+		buffer := q.Buffer()
+		for c, m := range wantMul {
+			if q.Multiplier()[c] != m {
+				t.Error(q.Name(), ": multiplier", q.Multiplier(), "want", wantMul)
+			}
+		}
+		for i, r := range def.List {
+			for c := range buffer.Comp {
+				if have := float64(buffer.Comp[c][i]); math.Abs(have-want[int(r)][c]) > 1e-6 {
+					t.Error(q.Name(), ": cell", i, "in region", r, "component", c, "have", have, "want", want[int(r)][c])
+					return
+				}
+			}
+		}
+	}
+
+	// uniform mask: the multiplier stays, the mask becomes 2 in region 1
+	ku := e.AddNewQuant("Ku", SCALAR, MASK, Unit("J/m3"))
+	ku.SetValue([]float64{500})
+	e.SetRegionValue("Ku", 1, []float64{1000})
+	check(ku, []float64{500}, map[int][]float64{0: {500}, 1: {1000}, 2: {500}})
+
+	// a mask with a spatial profile keeps it outside the region
+	profile := host.NewArray(1, e.GridSize())
+	for i := range profile.List {
+		profile.List[i] = 0.5
+	}
+	ku.SetMask(profile)
+	e.SetRegionValue("Ku", 2, []float64{-250})
+	check(ku, []float64{500}, map[int][]float64{0: {250}, 1: {250}, 2: {-250}})
+
+	// a zero multiplier is replaced by the largest value, per component
+	k := e.AddNewQuant("k", VECTOR, MASK, Unit(""))
+	e.SetRegionValue("k", 0, []float64{3, 0, -4})
+	check(k, []float64{3, 1, 4}, map[int][]float64{0: {3, 0, -4}, 1: {0, 0, 0}, 2: {0, 0, 0}})
+	e.SetRegionValue("k", 2, []float64{6, 1, 2})
+	check(k, []float64{3, 1, 4}, map[int][]float64{0: {3, 0, -4}, 1: {0, 0, 0}, 2: {6, 1, 2}})
+
+	// a FIELD is set directly
+	q := e.AddNewQuant("q", VECTOR, FIELD, Unit(""))
+	q.SetValue([]float64{1, 2, 3})
+	e.SetRegionValue("q", 1, []float64{-1, 0, 1})
+	check(q, []float64{1, 1, 1}, map[int][]float64{0: {1, 2, 3}, 1: {-1, 0, 1}, 2: {1, 2, 3}})
+}
+
+// q_regionN is the average of q over region N, like avg(q, region=N),
+// and follows changes of q and of the regions.
+func TestRegionAverage(t *testing.T) {
+	e := initTestEngine(t, []int{2, 4, 4})
+	def := addTestRegions(e)
+
+	q := e.AddNewQuant("q", VECTOR, FIELD, Unit(""))
+	in := host.NewArray(3, e.GridSize())
+	for c := range in.Comp {
+		for i := range in.Comp[c] {
+			in.Comp[c][i] = float32(math.Cos(float64(i + 10*c)))
+		}
+	}
+	q.SetField(in)
+
+	check := func() {
+		for r := 0; r < 3; r++ {
+			var want [3]float64
+			count := 0
+			for i, d := range def.List {
+				if int(d) == r {
+					for c := range in.Comp {
+						want[c] += float64(in.Comp[c][i])
+					}
+					count++
+				}
+			}
+			region := e.Quant(fmt.Sprint("q_region", r))
+			avg := e.Quant(fmt.Sprint("avg(q, region=", r, ")"))
+			region.Update()
+			avg.Update()
+			for c := range want {
+				want[c] /= float64(count)
+				if math.Abs(region.Multiplier()[c]-want[c]) > 1e-6 || region.Multiplier()[c] != avg.Multiplier()[c] {
+					t.Error("region", r, ": have", region.Multiplier(), avg.Multiplier(), "want", want)
+					break
+				}
+			}
+		}
+	}
+	check()
+
+	// change the input and the regions
+	in.Comp[Z][0] = 100
+	q.SetField(in)
+	for i := range def.List {
+		def.List[i] = float32(i % 3)
+	}
+	e.Quant(REGIONS).SetMask(def)
+	check()
+}
@@ -14,6 +14,7 @@ package engine
 // Author: Arne Vansteenkiste
 
 import (
+	"math"
 	. "mumax/common"
 	"mumax/host"
 )
@@ -41,6 +42,136 @@ func Resample(in *host.Array, size2 []int) *host.Array {
 	return out
 }
 
+// Resampling modes, see ResampleMode.
+const (
+	NEAREST   = "nearest"   // value of the nearest cell
+	TRILINEAR = "trilinear" // linear interpolation between cell centers
+	AVERAGE   = "average"   // average over the overlapping volume, conserves the total
+)
+
+// Returns a new host array of size size2, re-sized from the input array
+// with the mode NEAREST, TRILINEAR or AVERAGE.
+// With renormalize, vectors are rescaled to unit length, except where they are zero.
+func ResampleMode(in *host.Array, size2 []int, mode string, renormalize bool) *host.Array {
+	Assert(len(size2) == 3)
+	checkResampleMode(mode)
+	out := in
+	switch mode {
+	case NEAREST:
+		out = Resample(in, size2)
+	case TRILINEAR, AVERAGE:
+		// the interpolation is separable: resample one axis at a time
+		for axis := range size2 {
+			out = resampleAxis(out, axis, resampleWeights(out.Size3D[axis], size2[axis], mode))
+		}
+	}
+	if renormalize && out.NComp() == 3 {
+		normalize(out)
+	}
+	return out
+}
+
+// Tells whether q is a field of directions, like m:
+// a dimensionless vector FIELD, which may be renormalized after resampling.
+func isDirection(q *Quant) bool {
+	return q.kind == FIELD && q.nComp == 3 && q.unit == ""
+}
+
+func checkResampleMode(mode string) {
+	if mode != NEAREST && mode != TRILINEAR && mode != AVERAGE {
+		panic(InputErr("Unknown resampling mode: " + mode + ". Options are: " + NEAREST + ", " + TRILINEAR + ", " + AVERAGE))
+	}
+}
+
+// Contribution of a cell to an interpolated value.
+type resampleWeight struct {
+	index  int
+	weight float32
+}
+
+// For each of the n2 new cells along an axis, the old cells
+// (of n1) that contribute to it, and with which weight.
+func resampleWeights(n1, n2 int, mode string) [][]resampleWeight {
+	w := make([][]resampleWeight, n2)
+	scale := float64(n1) / float64(n2) // new cell size in units of old cells
+	for i := range w {
+		switch mode {
+		case TRILINEAR:
+			// position of the new cell center between the old ones
+			x := (float64(i)+0.5)*scale - 0.5
+			if x < 0 {
+				x = 0
+			}
+			if x > float64(n1-1) {
+				x = float64(n1 - 1)
+			}
+			i0 := int(x)
+			f := x - float64(i0)
+			w[i] = append(w[i], resampleWeight{i0, float32(1 - f)})
+			if f > 0 {
+				w[i] = append(w[i], resampleWeight{i0 + 1, float32(f)})
+			}
+		case AVERAGE:
+			// old cells overlapping with [start, end)
+			start, end := float64(i)*scale, float64(i+1)*scale
+			for j := int(start); j < n1 && float64(j) < end; j++ {
+				overlap := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+				if overlap > 0 {
+					w[i] = append(w[i], resampleWeight{j, float32(overlap / scale)})
+				}
+			}
+		}
+	}
+	return w
+}
+
+// Resamples the array along one axis, using the weights for each new cell.
+func resampleAxis(in *host.Array, axis int, weights [][]resampleWeight) *host.Array {
+	size2 := []int{in.Size3D[X], in.Size3D[Y], in.Size3D[Z]}
+	size2[axis] = len(weights)
+	out := host.NewArray(in.NComp(), size2)
+	out_a := out.Array
+	in_a := in.Array
+	for c := range out_a {
+		for i := range out_a[c] {
+			for j := range out_a[c][i] {
+				for k := range out_a[c][i][j] {
+					var sum float32
+					switch axis {
+					case X:
+						for _, w := range weights[i] {
+							sum += w.weight * in_a[c][w.index][j][k]
+						}
+					case Y:
+						for _, w := range weights[j] {
+							sum += w.weight * in_a[c][i][w.index][k]
+						}
+					case Z:
+						for _, w := range weights[k] {
+							sum += w.weight * in_a[c][i][j][w.index]
+						}
+					}
+					out_a[c][i][j][k] = sum
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Rescales the vectors of a 3-component array to unit length, except zero vectors.
+func normalize(a *host.Array) {
+	x, y, z := a.Comp[X], a.Comp[Y], a.Comp[Z]
+	for i := range x {
+		norm := float32(math.Sqrt(float64(x[i]*x[i] + y[i]*y[i] + z[i]*z[i])))
+		if norm != 0 {
+			x[i] /= norm
+			y[i] /= norm
+			z[i] /= norm
+		}
+	}
+}
+
 // input is assumed vector field
 //func subsample4(data *tensor.T4, small *tensor.T4, f int) {
 //	bigsize := data.Size()
@@ -0,0 +1,84 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+import (
+	"math"
+	. "mumax/common"
+	"mumax/host"
+	"testing"
+)
+
+func TestResampleMode(t *testing.T) {
+	// linear in the last (x) index
+	in := host.NewArray(1, []int{1, 2, 6})
+	for j := 0; j < 2; j++ {
+		for k := 0; k < 6; k++ {
+			in.Array[0][0][j][k] = float32(k)
+		}
+	}
+
+	// averaging conserves the total, also for a non-integer ratio
+	avg := ResampleMode(in, []int{1, 1, 4}, AVERAGE, false)
+	var sum float64
+	for _, v := range avg.List {
+		sum += float64(v)
+	}
+	if math.Abs(sum*(2*6)/(1*4)-2*15) > 1e-4 {
+		t.Error("average does not conserve the total:", avg.List)
+	}
+
+	// trilinear reproduces a linear function between the outermost cell centers
+	lin := ResampleMode(in, []int{1, 2, 12}, TRILINEAR, false)
+	for k := 1; k < 11; k++ {
+		want := (float64(k)+0.5)/2 - 0.5
+		if got := float64(lin.Array[0][0][1][k]); math.Abs(got-want) > 1e-5 {
+			t.Error("trilinear: cell", k, "have", got, "want", want)
+		}
+	}
+
+	// renormalized vectors have unit length
+	v := host.NewArray(3, []int{1, 1, 2})
+	v.Array[X][0][0][0], v.Array[Z][0][0][1] = 1, 1
+	v = ResampleMode(v, []int{1, 1, 3}, TRILINEAR, true)
+	x, z := v.Array[X][0][0][1], v.Array[Z][0][0][1]
+	if math.Abs(float64(x*x+z*z)-1) > 1e-5 {
+		t.Error("renormalize: have", x, z)
+	}
+}
+
+// Only direction fields like m are renormalized by SetArray, not masks or fields with a unit.
+func TestResampleRenormalizeKinds(t *testing.T) {
+	e := initTestEngine(t, []int{1, 2, 4})
+	e.AddNewQuant("m", VECTOR, FIELD, Unit(""))
+	e.AddNewQuant("B_ext", VECTOR, FIELD, Unit("T"))
+	e.AddNewQuant("k", VECTOR, MASK, Unit(""))
+	api := API{e}
+	api.SetResampling(NEAREST, true)
+
+	in := host.NewArray(3, []int{1, 1, 2})
+	for i := range in.Comp[X] {
+		in.Comp[X][i] = 2 // length 2
+	}
+	api.SetArray("m", in)
+	api.SetArray("B_ext", in)
+	api.SetMask("k", in)
+
+	for name, want := range map[string]float32{"m": 1, "B_ext": 2, "k": 2} {
+		q := e.Quant(name)
+		if name == "k" {
+			q.SetValue([]float64{1, 1, 1})
+		}
+		for _, v := range q.Buffer().Comp[X] {
+			if v != want {
+				t.Error(name, ": have", q.Buffer().Comp[X], "want", want)
+				break
+			}
+		}
+	}
+}
@@ -327,6 +327,62 @@ func (s *BDFAM12) Dependencies() (children, parents []string) {
 	return
 }
 
+// Saves the convergence estimates, error/step history and buffers, see StatefulSolver.
+// Values: alpha, newDt, then for each equation: history length, errors, steps.
+// Arrays: ybuffer, y0buffer, y1buffer, dy0buffer, dybuffer for each equation.
+func (s *BDFAM12) CheckpointState() *SolverState {
+	state := new(SolverState)
+	state.Values = append(state.Values, s.alpha...)
+	state.Values = append(state.Values, s.newDt...)
+	for i := range s.err_list {
+		state.Values = append(state.Values, float64(s.err_list[i].Len()))
+		for el := s.err_list[i].Front(); el != nil; el = el.Next() {
+			state.Values = append(state.Values, el.Value.(float64))
+		}
+		for el := s.steps_list[i].Front(); el != nil; el = el.Next() {
+			state.Values = append(state.Values, el.Value.(float64))
+		}
+	}
+	for i := range s.ybuffer {
+		for _, buf := range s.buffers(i) {
+			state.Arrays = append(state.Arrays, arrayState(buf))
+		}
+	}
+	return state
+}
+
+// See StatefulSolver.
+func (s *BDFAM12) RestoreState(state *SolverState) {
+	v := state.Values
+	N := len(s.alpha)
+	copy(s.alpha, v[:N])
+	copy(s.newDt, v[N:2*N])
+	v = v[2*N:]
+	for i := range s.err_list {
+		n := int(v[0])
+		s.err_list[i].Init()
+		s.steps_list[i].Init()
+		for j := 0; j < n; j++ {
+			s.err_list[i].PushBack(v[1+j])
+			s.steps_list[i].PushBack(v[1+n+j])
+		}
+		v = v[1+2*n:]
+	}
+
+	a := state.Arrays
+	for i := range s.ybuffer {
+		for _, buf := range s.buffers(i) {
+			setArrayState(buf, a[0])
+			a = a[1:]
+		}
+	}
+}
+
+// The buffers for equation i.
+func (s *BDFAM12) buffers(i int) []*gpu.Array {
+	return []*gpu.Array{s.ybuffer[i], s.y0buffer[i], s.y1buffer[i], s.dy0buffer[i], s.dybuffer[i]}
+}
+
 // Register this module
 func init() {
 	RegisterModule("solver/am12", "Adaptive Adams-Moulton 1+2 solver", LoadBDFAM12)
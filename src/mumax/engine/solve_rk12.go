@@ -82,6 +82,24 @@ func (s *RK12Solver) Dependencies() (children, parents []string) {
 	return
 }
 
+// Saves the initial value and derivative buffers, see StatefulSolver.
+func (s *RK12Solver) CheckpointState() *SolverState {
+	state := new(SolverState)
+	for i := range s.y0buffer {
+		state.Arrays = append(state.Arrays, arrayState(s.y0buffer[i]), arrayState(s.dybuffer[i]))
+	}
+	return state
+}
+
+// See StatefulSolver.
+func (s *RK12Solver) RestoreState(state *SolverState) {
+	Assert(len(state.Arrays) == 2*len(s.y0buffer))
+	for i := range s.y0buffer {
+		setArrayState(s.y0buffer[i], state.Arrays[2*i])
+		setArrayState(s.dybuffer[i], state.Arrays[2*i+1])
+	}
+}
+
 // Register this module
 func init() {
 	RegisterModule("solver/rk12", "Adaptive Heun solver (Runge-Kutta 1+2)", LoadRK12)
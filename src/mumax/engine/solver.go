@@ -15,3 +15,11 @@ type Solver interface {
 	Step()                                      // Takes one time step
 	Dependencies() (children, parents []string) // Reports dependencies apart from the normal input/output. E.g.: time, dt,maxerror
 }
+
+// Solvers that keep internal state between time steps (e.g., error history)
+// implement this so their state can be saved in a checkpoint.
+type StatefulSolver interface {
+	Solver
+	CheckpointState() *SolverState   // Returns the internal state
+	RestoreState(state *SolverState) // Restores the state returned by CheckpointState()
+}
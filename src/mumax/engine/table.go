@@ -10,62 +10,243 @@ package engine
 // Author: Arne Vansteenkiste
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
-	"io"
 	. "mumax/common"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Table refers to an open data table 
 // to which space-independent output is appended during the simulation.
 type Table struct {
-	out   io.WriteCloser
-	fname string
+	file        *os.File
+	out         *bufio.Writer
+	fname       string
+	delimiter   string        // between values
+	trailing    bool          // also write the delimiter after the last value (tsv)
+	precision   int           // significant digits, -1: as many as needed
+	sidecar     bool          // describe the columns in fname.json
+	flushPeriod time.Duration // 0: flush every row
+	lastFlush   time.Time
 }
 
 // New table that will write in the file.
 func NewTable(fname string) *Table {
 	t := new(Table)
 	t.fname = fname
+	t.delimiter = "\t"
+	t.trailing = true
+	t.precision = -1
 	return t
 }
 
+// Sets the table format from options, see API.SetTableFormat.
+func (t *Table) SetFormat(options []string) {
+	if t.out != nil {
+		panic(InputErr("table " + t.fname + " already written to, set its format before the first tabulate"))
+	}
+	for _, opt := range options {
+		key, value := opt, ""
+		if i := strings.Index(opt, "="); i >= 0 {
+			key, value = opt[:i], opt[i+1:]
+		}
+		switch strings.ToLower(key) {
+		default:
+			panic(InputErr(fmt.Sprint("Illegal table option ", opt, ". Options are: tsv, csv, delimiter=, precision=, json, flush=")))
+		case "tsv":
+			t.delimiter, t.trailing = "\t", true
+		case "csv":
+			t.delimiter, t.trailing = ",", false
+		case "delimiter":
+			if value == "" {
+				panic(InputErr("table option delimiter= needs a value"))
+			}
+			t.delimiter, t.trailing = value, false
+		case "precision":
+			t.precision = Atoi(value)
+			if t.precision < 1 {
+				panic(InputErr(fmt.Sprint("table option precision=", value, ": must be >= 1")))
+			}
+		case "json":
+			t.sidecar = true
+		case "flush":
+			if strings.ToLower(value) == "row" {
+				t.flushPeriod = 0
+			} else {
+				period := Atof64(value)
+				if period < 0 {
+					panic(InputErr(fmt.Sprint("table option flush=", value, ": must be row or a period >= 0 in seconds")))
+				}
+				t.flushPeriod = time.Duration(period * float64(time.Second))
+			}
+		}
+	}
+}
+
 // Append the quantities value to the table.
 func (t *Table) Tabulate(quants []string) {
 	if t.out == nil {
-		t.out = OpenWRONLY(t.fname)
-		writeTableHeader(t.out, quants)
+		t.open(OpenWRONLY(t.fname))
+		t.writeHeader(quants)
+		if t.sidecar {
+			t.writeSidecar(quants)
+		}
 	}
 	e := GetEngine()
+	first := true
 	for _, q := range quants {
 		quant := e.Quant(q)
 		quant.Update() //!!
 		v := quant.multiplier
 		n := len(v)
 		for i := n - 1; i >= 0; i-- { // Swap XYZ -> ZYX
-			fmt.Fprint(t.out, v[i], "\t")
+			if !first && !t.trailing {
+				t.out.WriteString(t.delimiter)
+			}
+			first = false
+			if t.precision > 0 {
+				t.out.WriteString(strconv.FormatFloat(v[i], 'g', t.precision, 64))
+			} else {
+				fmt.Fprint(t.out, v[i])
+			}
+			if t.trailing {
+				t.out.WriteString(t.delimiter)
+			}
 		}
 	}
-	fmt.Fprintln(t.out)
+	t.out.WriteString("\n")
+	if t.flushPeriod == 0 || time.Since(t.lastFlush) >= t.flushPeriod {
+		t.flush()
+	}
 }
 
+// Flushes and closes the file. Does not panic,
+// so that it can be used while cleaning up after an error.
 func (t *Table) Close() {
-	t.out.Close()
+	if t.out == nil {
+		return
+	}
+	t.out.Flush()
+	t.file.Close()
 }
 
-func writeTableHeader(out io.Writer, quants []string) {
-	e := GetEngine()
-	fmt.Fprint(out, "#")
+func (t *Table) open(f *os.File) {
+	t.file = f
+	t.out = bufio.NewWriter(f)
+	t.lastFlush = time.Now()
+}
+
+func (t *Table) flush() {
+	CheckIO(t.out.Flush())
+	t.lastFlush = time.Now()
+}
+
+// Current size of the table file in bytes,
+// -1 if nothing has been written yet.
+func (t *Table) size() int64 {
+	if t.out == nil {
+		return -1
+	}
+	t.flush()
+	return fileSize(t.fname)
+}
+
+// Re-opens the table for appending, after first truncating it to size.
+// Used when resuming from a checkpoint, to discard the output written
+// after the checkpoint was taken.
+func (t *Table) truncate(size int64) {
+	out := OpenWRAPPENDONLY(t.fname)
+	CheckIO(out.Truncate(size))
+	t.open(out)
+}
+
+// Size of the file in bytes, -1 if it does not exist.
+func fileSize(fname string) int64 {
+	info, err := os.Stat(fname)
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
+// A column of a table, as described in the JSON sidecar file.
+type tableColumn struct {
+	Name        string `json:"name"`      // column header, e.g. "<m>_x"
+	Quantity    string `json:"quantity"`  // e.g. "<m>"
+	Component   string `json:"component"` // "x", "y", "z", or "" for a scalar
+	Unit        string `json:"unit"`
+	Description string `json:"description"`
+}
+
+func tableColumns(quants []string) []tableColumn {
+	var cols []tableColumn
 	for _, q := range quants {
-		quant := e.Quant(q)
-		checkKinds(quant, VALUE, MASK)
+		quant := tableQuant(q)
 		n := quant.NComp()
 		for i := n - 1; i >= 0; i-- {
 			comp := ""
 			if n > 1 {
-				comp = "_" + string('z'-i)
+				comp = string(rune('z' - i))
+			}
+			name := quant.Name()
+			if comp != "" {
+				name += "_" + comp
 			}
-			fmt.Fprint(out, quant.Name()+comp, " (", quant.Unit(), ")\t")
+			cols = append(cols, tableColumn{name, quant.Name(), comp, string(quant.Unit()), quant.desc})
 		}
 	}
-	fmt.Fprintln(out)
+	return cols
+}
+
+// The tab-separated header starts with a #, like the default mumax table,
+// other formats have a plain header line as expected by spreadsheets.
+func (t *Table) writeHeader(quants []string) {
+	if t.trailing {
+		t.out.WriteString("#")
+	}
+	for i, c := range tableColumns(quants) {
+		if i > 0 && !t.trailing {
+			t.out.WriteString(t.delimiter)
+		}
+		fmt.Fprint(t.out, c.Name, " (", c.Unit, ")")
+		if t.trailing {
+			t.out.WriteString(t.delimiter)
+		}
+	}
+	t.out.WriteString("\n")
+}
+
+// Writes fname.json, describing the table's layout and columns.
+func (t *Table) writeSidecar(quants []string) {
+	desc := struct {
+		File      string        `json:"file"`
+		Delimiter string        `json:"delimiter"`
+		Header    string        `json:"header"` // prefix of the header line
+		Columns   []tableColumn `json:"columns"`
+	}{path.Base(t.fname), t.delimiter, "", tableColumns(quants)}
+	if t.trailing {
+		desc.Header = "#"
+	}
+	out := OpenWRONLY(t.fname + ".json")
+	defer out.Close()
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false) // quantity names like <m>
+	enc.SetIndent("", "\t")
+	CheckIO(enc.Encode(&desc))
+}
+
+// Returns the quantity to be tabulated,
+// panics if it is space-dependent.
+func tableQuant(name string) *Quant {
+	q := GetEngine().Quant(name)
+	if q.kind == FIELD {
+		panic(InputErr(fmt.Sprint(q.Name(), " is space-dependent, tabulate a statistic like avg(", name, "), max(|", name, "|) or std(", name, ") instead")))
+	}
+	checkKinds(q, VALUE, MASK)
+	return q
 }
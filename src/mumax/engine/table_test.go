@@ -0,0 +1,36 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTableFormat(t *testing.T) {
+	initTestEngine(t, []int{1, 4, 4})
+	GetEngine().time.SetScalar(1.0 / 3)
+	dir := t.TempDir()
+
+	tsv := NewTable(filepath.Join(dir, "a.txt"))
+	tsv.Tabulate([]string{"t", "step"})
+	tsv.Close()
+	if out, _ := os.ReadFile(tsv.fname); string(out) != "#t (s)\tstep ()\t\n0.3333333333333333\t0\t\n" {
+		t.Errorf("tsv: %q", out)
+	}
+
+	csv := NewTable(filepath.Join(dir, "a.csv"))
+	csv.SetFormat([]string{"csv", "precision=3", "flush=10"})
+	csv.Tabulate([]string{"t", "step"})
+	csv.Tabulate([]string{"t", "step"})
+	csv.Close()
+	if out, _ := os.ReadFile(csv.fname); string(out) != "t (s),step ()\n0.333,0\n0.333,0\n" {
+		t.Errorf("csv: %q", out)
+	}
+}
@@ -13,7 +13,6 @@ package engine
 // Author: Arne Vansteenkiste
 
 import (
-	cu "cuda/driver"
 	"fmt"
 	"io"
 	. "mumax/common"
@@ -52,7 +51,7 @@ func initCUDA() {
 	Debug("Initializing CUDA")
 	runtime.LockOSThread()
 	Debug("Locked OS Thread")
-	cu.Init(0)
+	gpu.InitCUDA()
 }
 
 // Write LaTeX documentation for the module.
@@ -62,6 +62,9 @@ func (c *Client) Init(inputfile string, outputDir string) {
 
 	engine.Init()
 	engine.GetEngine().SetOutputDirectory(outputDir)
+	if *flag_resume != "" {
+		engine.GetEngine().Resume(*flag_resume)
+	}
 	c.api = engine.API{engine.GetEngine()}
 }
 
@@ -82,6 +85,11 @@ func (c *Client) Run() {
 		Debug("Done.")
 	}()
 
+	if command, _ := commandForFile(c.inputFile); command == IN_PROCESS {
+		runScript(c.inputFile, c.api)
+		return
+	}
+
 	c.logWait = make(chan int)
 
 	command, waiter := c.startSubcommand()
@@ -201,6 +209,10 @@ func (c *Client) startSubcommand() (command string, waiter chan (int)) {
 	return
 }
 
+// Pseudo-command returned by commandForFile for files
+// that are interpreted by mumax itself (see script.go).
+const IN_PROCESS = ""
+
 // given a file name (e.g. file.py)
 // this returns a command to run the file (e.g. python file.py, java File)
 func commandForFile(file string) (command string, args []string) {
@@ -215,6 +227,8 @@ func commandForFile(file string) (command string, args []string) {
 		panic(InputErr("Cannot handle files with extension " + path.Ext(file)))
 	case ".py":
 		return "python", []string{file}
+	case ".mx2":
+		return IN_PROCESS, []string{file}
 		//case ".java":
 		//	return GetExecDir() + "javaint", []string{file}
 		//case ".class":
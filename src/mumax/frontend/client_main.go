@@ -11,10 +11,10 @@ package frontend
 // Arne Vansteenkiste
 
 import (
-	cu "cuda/driver"
 	"fmt"
 	"io"
 	. "mumax/common"
+	"mumax/gpu"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -25,12 +25,16 @@ func clientMain() {
 	//defer fmt.Println(RESET)
 
 	if !*flag_silent {
-		fmt.Println(WELCOME)
+		fmt.Print(WELCOME)
 	}
 
 	infile := inputFile()
 	outdir := GetOutputDir(infile)
 
+	if *flag_resume != "" && *flag_force {
+		panic(InputErr("-resume can not be combined with -f, it would remove the existing output"))
+	}
+
 	initOutputDir(outdir)
 	initLogger(outdir)
 
@@ -45,7 +49,7 @@ func clientMain() {
 	runtime.LockOSThread()
 	Debug("Locked OS Thread")
 
-	cu.Init(0)
+	gpu.InitCUDA()
 
 	initMultiGPU()
 
@@ -0,0 +1,44 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build !cpu
+// +build !cpu
+
+package frontend
+
+// This file implements the CUDA-specific parts of the frontend.
+
+import (
+	cu "cuda/driver"
+	. "mumax/common"
+)
+
+// Context flags for the CUDA driver, as set by the -sched flag.
+func parseCuFlags() uint {
+	cudaflag := *flag_sched
+	var flag uint
+
+	switch cudaflag {
+	default:
+		panic(InputErr("Expecting auto,spin,yield or sync: " + cudaflag))
+	case "auto":
+		flag |= cu.CTX_SCHED_AUTO
+	case "spin":
+		flag |= cu.CTX_SCHED_SPIN
+	case "yield":
+		flag |= cu.CTX_SCHED_YIELD
+	case "sync":
+		flag |= cu.CTX_BLOCKING_SYNC
+	}
+	return flag
+}
+
+// Whether a panic value is an error returned by CUDA.
+func isCUDAError(err interface{}) bool {
+	_, ok := err.(cu.Result)
+	return ok
+}
@@ -0,0 +1,34 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package frontend
+
+// This file replaces the CUDA-specific parts of the frontend
+// for the CPU backend.
+
+import (
+	. "mumax/common"
+)
+
+// The -sched flag is still checked, but has no effect.
+func parseCuFlags() uint {
+	cudaflag := *flag_sched
+	switch cudaflag {
+	default:
+		panic(InputErr("Expecting auto,spin,yield or sync: " + cudaflag))
+	case "auto", "spin", "yield", "sync":
+	}
+	return 0
+}
+
+// There are no CUDA errors with the CPU backend.
+func isCUDAError(err interface{}) bool {
+	return false
+}
@@ -11,9 +11,9 @@ package frontend
 
 import (
 	"bufio"
-	cu "cuda/driver"
 	"fmt"
 	. "mumax/common"
+	"mumax/gpu"
 	"os"
 	"runtime"
 )
@@ -21,7 +21,7 @@ import (
 // run the input files given on the command line
 func engineMain() {
 	if !*flag_silent {
-		fmt.Println(WELCOME)
+		fmt.Print(WELCOME)
 	}
 
 	outdir := "."
@@ -51,7 +51,7 @@ func initCUDA() {
 	Debug("Initializing CUDA")
 	runtime.LockOSThread()
 	Debug("Locked OS Thread")
-	cu.Init(0)
+	gpu.InitCUDA()
 }
 
 // Do not start interpreter subprocess but wait for commands on Stdin.
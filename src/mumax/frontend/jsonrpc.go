@@ -28,8 +28,14 @@ import (
 // Protocol:
 // 	Call: ["methodname", [arg1, arg2, ...]]
 // 	Response: [return_value1, return_value2, ...]
+// 	Error response: {"error": {"code": -32000, "message": "...", "data": "InputErr"}}
+// A call may have a third element with options, see rpcbinary.go:
+// 	Call: ["methodname", [arg1, arg2, ...], {"binary": true}]
+// Error responses are sent for InputErr and IOErr, with the same error object as jsonrpc2.go.
+// The engine stays alive, so an interactive user can recover from, e.g., a typo.
+// Other panics (Bug, ...) still crash mumax.
 type jsonRPC struct {
-	in    io.Reader
+	in    io.Reader // input not yet read by the decoder, see readArrays
 	out   io.Writer
 	flush bufio.Writer
 
@@ -67,12 +73,28 @@ func (j *jsonRPC) Run() {
 		CheckErr(err, ERR_IO)
 
 		if array, ok := (*v).([]interface{}); ok {
-			Assert(len(array) == 2)
-			ret := j.Call(array[0].(string), array[1].([]interface{}))
-			convertOutput(ret)
-			//j.Encode(ret)
-			jsonc.Encode(ret)
+			Assert(len(array) == 2 || len(array) == 3)
+			args := array[1].([]interface{})
+			binary := len(array) == 3 && binaryOption(array[2])
+			if binary {
+				j.readArrays(args)
+			}
+			ret, rpcErr := j.TryCall(array[0].(string), args)
+			var frames []*host.Array
+			if rpcErr != nil {
+				jsonc.Encode(map[string]*rpc2Error{"error": rpcErr})
+			} else {
+				if binary {
+					frames = arrayReturnValues(ret)
+				}
+				convertOutput(ret)
+				//j.Encode(ret)
+				jsonc.Encode(ret)
+			}
 			j.flush.WriteString(wbuf.String() + "<<< End of mumax message >>>")
+			for _, f := range frames {
+				writeFrame(&j.flush, f)
+			}
 			j.flush.Flush()
 			// 
 			// wbuf now has JSON cPRC call
@@ -83,6 +105,15 @@ func (j *jsonRPC) Run() {
 	}
 }
 
+// Reads the frames for the array placeholders in args.
+// The decoder may already have buffered (part of) them,
+// so it continues after the frames with a new decoder.
+func (j *jsonRPC) readArrays(args []interface{}) {
+	j.in = io.MultiReader(j.Decoder.Buffered(), j.in)
+	readArrayArgs(j.in, args)
+	j.Decoder = json.NewDecoder(j.in)
+}
+
 // Calls the function specified by funcName with the given arguments and returns the return values.
 func (j *jsonRPC) Call(funcName string, args []interface{}) []interface{} {
 
@@ -102,6 +133,9 @@ func (j *jsonRPC) Call(funcName string, args []interface{}) []interface{} {
 	if !ok {
 		panic(InputErr(fmt.Sprint("rpc: no such method:", funcName)))
 	}
+	if len(args) != f.Type().NumIn() {
+		panic(InputErr(fmt.Sprint("rpc: ", funcName, " needs ", f.Type().NumIn(), " arguments, have ", len(args))))
+	}
 
 	// call
 	// convert []interface{} to []reflect.Value  
@@ -123,10 +157,31 @@ func (j *jsonRPC) Call(funcName string, args []interface{}) []interface{} {
 	return ret
 }
 
+// Like Call, but InputErr and IOErr are returned as an error object instead of panicking.
+// Other panics are passed on.
+func (j *jsonRPC) TryCall(funcName string, args []interface{}) (ret []interface{}, rpcErr *rpc2Error) {
+	defer func() {
+		err := recover()
+		switch err.(type) {
+		case nil:
+			return
+		case InputErr, IOErr:
+			Err(err)
+			rpcErr = panicToError(err)
+		default:
+			panic(err)
+		}
+	}()
+	return j.Call(funcName, args), nil
+}
+
 // Convert v to the specified type.
 // JSON returns all numbers as float64's even when, e.g., ints are needed,
 // hence such conversion. Also, convert to host.Array etc.
 func convertArg(v interface{}, typ reflect.Type) reflect.Value {
+	if v != nil && reflect.TypeOf(v).AssignableTo(typ) {
+		return reflect.ValueOf(v) // no conversion needed, e.g. *host.Array passed in-process
+	}
 	switch typ.Kind() {
 	case reflect.Int:
 		if float64(int(v.(float64))) != v.(float64) {
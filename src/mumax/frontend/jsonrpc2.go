@@ -0,0 +1,281 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package frontend
+
+// This file implements a standard JSON-RPC 2.0 server (http://www.jsonrpc.org/specification),
+// so that any language with a JSON library can drive mumax.
+// Requests and responses are JSON objects, typically one per line:
+// 	--> {"jsonrpc": "2.0", "method": "getscalar", "params": ["t"], "id": 1}
+// 	<-- {"jsonrpc": "2.0", "result": 0, "id": 1}
+// Only positional parameters are supported. Batches and notifications are supported.
+// Method names are the API methods in lower case, like for the python client.
+// Additionally, "rpc.methods" lists all methods and "rpc.shutdown" stops the server.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	. "mumax/common"
+	"reflect"
+	"sort"
+)
+
+// JSON-RPC 2.0 error codes.
+// -32768 to -32000 are reserved by the specification,
+// -32000 to -32099 are for implementation-defined errors.
+const (
+	RPC_PARSE_ERROR      = -32700 // Invalid JSON
+	RPC_INVALID_REQUEST  = -32600 // JSON is not a valid request object
+	RPC_METHOD_NOT_FOUND = -32601 // No such method
+	RPC_INVALID_PARAMS   = -32602 // Wrong number or type of parameters
+	RPC_INTERNAL_ERROR   = -32603 // Unspecified panic
+	RPC_INPUT_ERROR      = -32000 // mumax InputErr: illegal input
+	RPC_IO_ERROR         = -32001 // mumax IOErr: I/O error
+	RPC_BUG              = -32002 // mumax Bug
+)
+
+// A JSON-RPC 2.0 request.
+type rpc2Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"` // nil for notifications
+}
+
+// A JSON-RPC 2.0 response.
+type rpc2Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"` // "null" is not omitted
+	Error   *rpc2Error      `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// A JSON-RPC 2.0 error object.
+type rpc2Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"` // mumax error type: "InputErr", "IOErr", "Bug" or "panic"
+}
+
+// Implements error
+func (e *rpc2Error) Error() string {
+	return fmt.Sprint("rpc error ", e.Code, ": ", e.Message)
+}
+
+var json_null = json.RawMessage("null")
+
+// A JSON-RPC 2.0 server for the public methods of a receiver (typically engine.API).
+type jsonRPC2 struct {
+	method   map[string]reflect.Value // methods that can be called
+	shutdown bool                     // set by "rpc.shutdown"
+}
+
+// Sets up the server. All public methods of the receiver are made accessible.
+func (j *jsonRPC2) Init(receiver interface{}) {
+	j.method = make(map[string]reflect.Value)
+	AddMethods(j.method, receiver)
+}
+
+// Serves one client: reads requests from in and writes the responses to out,
+// until EOF, a parse error, a write error or "rpc.shutdown".
+// A client that disconnects does not bring the server down.
+func (j *jsonRPC2) Serve(in io.Reader, out io.Writer) {
+	dec := json.NewDecoder(in)
+	enc := json.NewEncoder(out)
+	for !j.shutdown {
+		var msg json.RawMessage
+		err := dec.Decode(&msg)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			// the stream can not be resynchronized after invalid JSON
+			enc.Encode(errorResponse(json_null, &rpc2Error{RPC_PARSE_ERROR, "parse error: " + err.Error(), ""}))
+			return
+		}
+		if resp := j.handle(msg); resp != nil {
+			if err := enc.Encode(resp); err != nil {
+				Warn("rpc: writing response:", err)
+				return
+			}
+		}
+	}
+}
+
+// Handles a single request or a batch.
+// Returns the response, or nil if there is nothing to respond (notifications).
+func (j *jsonRPC2) handle(msg json.RawMessage) interface{} {
+	msg = bytes.TrimSpace(msg)
+	if len(msg) == 0 || msg[0] != '[' {
+		if resp := j.handleOne(msg); resp != nil {
+			return resp
+		}
+		return nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(msg, &batch); err != nil || len(batch) == 0 {
+		return errorResponse(json_null, &rpc2Error{RPC_INVALID_REQUEST, "invalid batch", ""})
+	}
+	var resp []*rpc2Response
+	for _, m := range batch {
+		if r := j.handleOne(m); r != nil {
+			resp = append(resp, r)
+		}
+	}
+	if len(resp) == 0 {
+		return nil // all notifications
+	}
+	return resp
+}
+
+// Handles a single request object.
+// Returns nil for notifications.
+func (j *jsonRPC2) handleOne(msg json.RawMessage) *rpc2Response {
+	var req rpc2Request
+	if err := json.Unmarshal(msg, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(json_null, &rpc2Error{RPC_INVALID_REQUEST, "invalid request: " + string(msg), ""})
+	}
+
+	result, rpcErr := j.call(req.Method, req.Params)
+
+	if req.ID == nil {
+		return nil // notification: no response, even on error
+	}
+	if rpcErr != nil {
+		return errorResponse(req.ID, rpcErr)
+	}
+	res, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, &rpc2Error{RPC_INTERNAL_ERROR, "encoding result: " + err.Error(), ""})
+	}
+	return &rpc2Response{JSONRPC: "2.0", Result: res, ID: req.ID}
+}
+
+func errorResponse(id json.RawMessage, err *rpc2Error) *rpc2Response {
+	return &rpc2Response{JSONRPC: "2.0", Error: err, ID: id}
+}
+
+// Calls the method with JSON-encoded positional parameters.
+// Returns nil for no return value, the value itself for one return value,
+// or an array for multiple return values.
+// Panics are turned into an error object.
+func (j *jsonRPC2) call(funcName string, params json.RawMessage) (result interface{}, rpcErr *rpc2Error) {
+	switch funcName {
+	case "rpc.methods":
+		return j.methodNames(), nil
+	case "rpc.shutdown":
+		j.shutdown = true
+		return nil, nil
+	}
+
+	f, ok := j.method[funcName]
+	if !ok {
+		return nil, &rpc2Error{RPC_METHOD_NOT_FOUND, "no such method: " + funcName, ""}
+	}
+
+	args, rpcErr := parseParams(params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	Debug("rpc2.Call", funcName, ShortPrint(args))
+
+	argvals, rpcErr := convertArgs(f.Type(), args)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			Err(fmt.Sprint("error calling ", funcName, ShortPrint(args), ": ", err))
+			rpcErr = panicToError(err)
+		}
+	}()
+
+	retVals := f.Call(argvals)
+	ret := make([]interface{}, len(retVals))
+	for i := range retVals {
+		ret[i] = retVals[i].Interface()
+	}
+	convertOutput(ret)
+
+	switch len(ret) {
+	case 0:
+		return nil, nil
+	case 1:
+		return ret[0], nil
+	}
+	return ret, nil
+}
+
+// Decodes positional parameters. Absent params means no parameters.
+func parseParams(params json.RawMessage) ([]interface{}, *rpc2Error) {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || string(params) == "null" {
+		return nil, nil
+	}
+	if params[0] != '[' {
+		return nil, &rpc2Error{RPC_INVALID_PARAMS, "only positional (array) parameters are supported", ""}
+	}
+	var args []interface{}
+	if err := json.Unmarshal(params, &args); err != nil {
+		return nil, &rpc2Error{RPC_INVALID_PARAMS, err.Error(), ""}
+	}
+	return args, nil
+}
+
+// Converts JSON arguments to the types expected by the function,
+// see convertArg().
+func convertArgs(ftype reflect.Type, args []interface{}) (argvals []reflect.Value, rpcErr *rpc2Error) {
+	if len(args) != ftype.NumIn() {
+		return nil, &rpc2Error{RPC_INVALID_PARAMS, fmt.Sprint("need ", ftype.NumIn(), " parameters, have ", len(args)), ""}
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			rpcErr = &rpc2Error{RPC_INVALID_PARAMS, fmt.Sprint(err), ""}
+		}
+	}()
+
+	argvals = make([]reflect.Value, len(args))
+	for i := range args {
+		want := ftype.In(i)
+		if args[i] == nil {
+			panic(fmt.Sprint("parameter ", i, ": need ", want, ", have null"))
+		}
+		argvals[i] = convertArg(args[i], want)
+		if !argvals[i].Type().AssignableTo(want) {
+			panic(fmt.Sprint("parameter ", i, ": need ", want, ", have ", argvals[i].Type()))
+		}
+	}
+	return argvals, nil
+}
+
+// Turns a recovered mumax panic into an error object.
+func panicToError(err interface{}) *rpc2Error {
+	switch e := err.(type) {
+	case InputErr:
+		return &rpc2Error{RPC_INPUT_ERROR, string(e), "InputErr"}
+	case IOErr:
+		return &rpc2Error{RPC_IO_ERROR, string(e), "IOErr"}
+	case Bug:
+		return &rpc2Error{RPC_BUG, string(e), "Bug"}
+	}
+	return &rpc2Error{RPC_INTERNAL_ERROR, fmt.Sprint(err), "panic"}
+}
+
+// Sorted list of all callable methods.
+func (j *jsonRPC2) methodNames() []string {
+	names := make([]string, 0, len(j.method))
+	for name := range j.method {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -8,10 +8,10 @@
 package frontend
 
 import (
-	cu "cuda/driver"
 	"flag"
 	"fmt"
 	. "mumax/common"
+	"mumax/engine"
 	"mumax/gpu"
 	"os"
 	"runtime"
@@ -36,6 +36,8 @@ var (
 	flag_version   *bool   = flag.Bool("v", false, "Print version info and exit")
 	flag_test      *bool   = flag.Bool("test", false, "Test CUDA and exit")
 	flag_dontrun   *bool   = flag.Bool("dr", false, "Don't run mumax if the output directory exists")
+	flag_resume    *string = flag.String("resume", "", "Resume from a checkpoint file written by checkpoint()")
+	flag_serve     *string = flag.String("serve", "", "Serve the API over JSON-RPC 2.0 instead of running an input file. E.g.: serve=localhost:2011, serve=unix:/tmp/mumax.sock")
 	//flag_timeout   *string = flag.String("timeout", "", "Set a maximum run time. Units s,h,d are recognized.")
 	flag_gpus  *string = flag.String("gpu", "0", "Which GPUs to use. gpu=0, gpu=0:3, gpu=1,2,3, gpu=all")
 	flag_sched *string = flag.String("sched", "auto", "CUDA scheduling: auto|spin|yield|sync")
@@ -78,7 +80,7 @@ func Main() {
 	}
 
 	if *flag_version {
-		fmt.Println(WELCOME)
+		fmt.Print(WELCOME)
 		fmt.Println("Go", runtime.Version())
 		return
 	}
@@ -93,6 +95,11 @@ func Main() {
 		gpu.SetDefaultFFT(*flag_fft)
 	}
 
+	if *flag_serve != "" {
+		serveMain()
+		return
+	}
+
 	// else...
 	clientMain()
 }
@@ -136,25 +143,6 @@ func initMultiGPU() {
 	gpu.InitMultiGPU(gpus, cuFlags)
 }
 
-func parseCuFlags() uint {
-	cudaflag := *flag_sched
-	var flag uint
-
-	switch cudaflag {
-	default:
-		panic(InputErr("Expecting auto,spin,yield or sync: " + cudaflag))
-	case "auto":
-		flag |= cu.CTX_SCHED_AUTO
-	case "spin":
-		flag |= cu.CTX_SCHED_SPIN
-	case "yield":
-		flag |= cu.CTX_SCHED_YIELD
-	case "sync":
-		flag |= cu.CTX_BLOCKING_SYNC
-	}
-	return flag
-}
-
 // return the input file. "" means none
 func inputFile() string {
 	// check if there is just one input file given on the command line
@@ -171,6 +159,9 @@ func inputFile() string {
 func cleanup() {
 	Debug("cleanup")
 
+	// flush and close output tables
+	engine.GetEngine().Close()
+
 	// write memory profile
 	if *flag_memprof != "" {
 		f, err := os.Create(*flag_memprof)
@@ -200,6 +191,11 @@ func crashreport(err interface{}) {
 	status := 0
 	switch err.(type) {
 	default:
+		if isCUDAError(err) {
+			Err("cuda error:", err, "\n", getCrashStack())
+			status = ERR_CUDA
+			break
+		}
 		Err("panic:", err, "\n", getCrashStack())
 		Log(SENDMAIL)
 		status = ERR_PANIC
@@ -219,12 +215,6 @@ func crashreport(err interface{}) {
 			Debug(getCrashStack())
 		}
 		status = ERR_IO
-	case cu.Result:
-		Err("cuda error:", err, "\n", getCrashStack())
-		if *flag_debug {
-			Debug(getCrashStack())
-		}
-		status = ERR_CUDA
 	}
 	Debug("Exiting with status", status, ErrString[status])
 	os.Exit(status)
@@ -10,8 +10,178 @@ package frontend
 // Author: Arne Vansteenkiste
 
 import (
+	"bufio"
+	"bytes"
+	"io"
+	. "mumax/common"
+	"mumax/host"
+	"strings"
 	"testing"
 )
 
 func TestRPC(t *testing.T) {
 }
+
+// receiver for TestJSONRPC2
+type rpc2TestAPI struct{}
+
+func (rpc2TestAPI) Add(a, b int) int        { return a + b }
+func (rpc2TestAPI) Pair() (string, float64) { return "x", 1.5 }
+func (rpc2TestAPI) Nothing()                {}
+func (rpc2TestAPI) BadInput(name string)    { panic(InputErr("no such quantity: " + name)) }
+func (rpc2TestAPI) Broken()                 { panic(Bug("broken")) }
+func (rpc2TestAPI) Double(a *host.Array) *host.Array {
+	for i := range a.List {
+		a.List[i] *= 2
+	}
+	return a
+}
+
+func TestJSONRPC2(t *testing.T) {
+	requests := []string{
+		`{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}`,
+		`{"jsonrpc": "2.0", "method": "pair", "id": "a"}`,
+		`{"jsonrpc": "2.0", "method": "nothing", "params": [], "id": 3}`,
+		`{"jsonrpc": "2.0", "method": "nothing"}`,
+		`{"jsonrpc": "2.0", "method": "badinput", "params": ["mm"], "id": 4}`,
+		`{"jsonrpc": "2.0", "method": "broken", "id": 5}`,
+		`{"jsonrpc": "2.0", "method": "foo", "id": 6}`,
+		`{"jsonrpc": "2.0", "method": "add", "params": [1], "id": 7}`,
+		`{"jsonrpc": "2.0", "method": "add", "params": [1, "x"], "id": 8}`,
+		`{"jsonrpc": "2.0", "method": "add", "params": {"a": 1, "b": 2}, "id": 9}`,
+		`{"method": "add", "params": [1, 2], "id": 10}`,
+		`[{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 11}, {"jsonrpc": "2.0", "method": "nothing"}, 1]`,
+		`[{"jsonrpc": "2.0", "method": "nothing"}]`,
+		`{"jsonrpc": "2.0", "method": "rpc.shutdown", "id": 12}`,
+		`{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 13}`,
+	}
+	want := []string{
+		`{"jsonrpc":"2.0","result":3,"id":1}`,
+		`{"jsonrpc":"2.0","result":["x",1.5],"id":"a"}`,
+		`{"jsonrpc":"2.0","result":null,"id":3}`,
+		`{"jsonrpc":"2.0","error":{"code":-32000,"message":"no such quantity: mm","data":"InputErr"},"id":4}`,
+		`{"jsonrpc":"2.0","error":{"code":-32002,"message":"broken","data":"Bug"},"id":5}`,
+		`{"jsonrpc":"2.0","error":{"code":-32601,"message":"no such method: foo"},"id":6}`,
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"need 2 parameters, have 1"},"id":7}`,
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"interface conversion: interface {} is string, not float64"},"id":8}`,
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"only positional (array) parameters are supported"},"id":9}`,
+		`{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request: {\"method\": \"add\", \"params\": [1, 2], \"id\": 10}"},"id":null}`,
+		`[{"jsonrpc":"2.0","result":3,"id":11},{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request: 1"},"id":null}]`,
+		`{"jsonrpc":"2.0","result":null,"id":12}`,
+	}
+
+	var server jsonRPC2
+	server.Init(rpc2TestAPI{})
+	in := strings.NewReader(strings.Join(requests, "\n"))
+	out := new(bytes.Buffer)
+	server.Serve(in, out)
+
+	have := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(have) != len(want) {
+		t.Fatal("have", len(have), "responses, want", len(want), ":\n", out.String())
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Error("\nhave:", have[i], "\nwant:", want[i])
+		}
+	}
+}
+
+// Writer for a client that has gone away.
+type brokenPipe struct{ writes int }
+
+func (b *brokenPipe) Write(p []byte) (int, error) {
+	b.writes++
+	return 0, io.ErrClosedPipe
+}
+
+// A client disconnecting mid-response should end Serve, not crash the server.
+func TestJSONRPC2Disconnect(t *testing.T) {
+	requests := `{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}
+{"jsonrpc": "2.0", "method": "add", "params": [3, 4], "id": 2}`
+
+	var server jsonRPC2
+	server.Init(rpc2TestAPI{})
+	out := new(brokenPipe)
+	server.Serve(strings.NewReader(requests), out)
+
+	if out.writes != 1 {
+		t.Error("have", out.writes, "writes after a write error, want 1")
+	}
+	if server.shutdown {
+		t.Error("disconnect should not shut down the server")
+	}
+}
+
+// InputErr and IOErr should become error responses, not crashes.
+func TestRPCError(t *testing.T) {
+	requests := `["add", [1, 2]]
+["badinput", ["mm"]]
+["add", [1]]
+["foo", []]
+["add", [3, 4]]`
+	EOM := "<<< End of mumax message >>>"
+	want := []string{
+		`[3]`,
+		`{"error":{"code":-32000,"message":"no such quantity: mm","data":"InputErr"}}`,
+		`{"error":{"code":-32000,"message":"rpc: add needs 2 arguments, have 1","data":"InputErr"}}`,
+		`{"error":{"code":-32000,"message":"rpc: no such method:foo","data":"InputErr"}}`,
+		`[7]`,
+	}
+
+	var rpc jsonRPC
+	out := new(bytes.Buffer)
+	rpc.Init(strings.NewReader(requests), out, *bufio.NewWriter(out), rpc2TestAPI{})
+	rpc.Run()
+
+	have := strings.Split(strings.TrimSuffix(out.String(), EOM), EOM)
+	if len(have) != len(want) {
+		t.Fatal("have", len(have), "responses, want", len(want), ":\n", out.String())
+	}
+	for i := range want {
+		if strings.TrimSpace(have[i]) != want[i] {
+			t.Error("\nhave:", have[i], "\nwant:", want[i])
+		}
+	}
+}
+
+// Arrays sent and returned as binary frames.
+func TestRPCBinary(t *testing.T) {
+	// user array [c][x][y][z] of size 2x3x2x1, value 10*c + 3*x + y
+	user := host.NewArray(2, []int{3, 2, 1})
+	for c := range user.Array {
+		for x := range user.Array[c] {
+			for y := range user.Array[c][x] {
+				user.Array[c][x][y][0] = float32(10*c + 3*x + y)
+			}
+		}
+	}
+	in := new(bytes.Buffer)
+	in.WriteString(`["double", [{"array": [2, 3, 2, 1]}], {"binary": true}]` + "\n")
+	writeFrame(in, user)
+	in.WriteString(`["add", [1, 2]]` + "\n")
+
+	var rpc jsonRPC
+	out := new(bytes.Buffer)
+	rpc.Init(in, out, *bufio.NewWriter(out), rpc2TestAPI{})
+	rpc.Run()
+
+	EOM := "<<< End of mumax message >>>"
+	resp := strings.SplitN(out.String(), EOM, 2)
+	if strings.TrimSpace(resp[0]) != `[{"array":[2,3,2,1]}]` {
+		t.Fatal("response:", resp[0])
+	}
+	frame := strings.NewReader(resp[1])
+	have := readFrame(frame, [4]int{2, 3, 2, 1}) // internal order
+	back := convertXYZ(have)
+	for i := range user.List {
+		if back.List[i] != 2*user.List[i] {
+			t.Fatal("have", back.List, "want 2 *", user.List)
+		}
+	}
+	rest := make([]byte, frame.Len())
+	frame.Read(rest)
+	if strings.TrimSpace(strings.TrimSuffix(string(rest), EOM)) != "[3]" {
+		t.Error("next call:", string(rest))
+	}
+}
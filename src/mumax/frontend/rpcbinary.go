@@ -0,0 +1,143 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package frontend
+
+// This file implements binary array transfer for the RPC of jsonrpc.go,
+// which is much faster and lossless compared to nested JSON lists.
+//
+// A client enables it per call by adding an options object to the call:
+// 	["setarray", ["m", {"array": [3, 64, 32, 1]}], {"binary": true}]
+// Array arguments are then given as a placeholder {"array": [ncomp, nx, ny, nz]},
+// and their data follows the call as frames, in argument order.
+// Array return values are replaced in the same way by placeholders in the
+// JSON response, and their frames follow the end-of-message marker.
+//
+// Frame layout:
+//	magic "#f32" (4 bytes)
+//	data size in bytes (uint64, little-endian)
+//	data: float32 values, little-endian, in user order:
+//	      index [c][x][y][z], with z varying fastest (like the JSON lists)
+// Whitespace (like the newline ending the JSON call) may precede a frame.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	. "mumax/common"
+	"mumax/host"
+)
+
+const FRAME_MAGIC = "#f32"
+
+// Key of the JSON object that stands for an array with frame.
+const ARRAY_KEY = "array"
+
+// Tells whether the call options ask for binary arrays.
+func binaryOption(options interface{}) bool {
+	opts, ok := options.(map[string]interface{})
+	if !ok {
+		panic(IOErr(fmt.Sprint("rpc: call options should be an object, have: ", ShortPrint(options))))
+	}
+	binary, _ := opts["binary"].(bool)
+	return binary
+}
+
+// Replaces the array placeholders in args by arrays read from frames in in.
+func readArrayArgs(in io.Reader, args []interface{}) {
+	for i, a := range args {
+		if size, ok := arrayPlaceholder(a); ok {
+			args[i] = readFrame(in, size)
+		}
+	}
+}
+
+// Replaces array return values by placeholders,
+// returns the arrays (in user order) to be sent as frames.
+func arrayReturnValues(ret []interface{}) (frames []*host.Array) {
+	for i, r := range ret {
+		if arr, ok := r.(*host.Array); ok {
+			user := convertXYZ(arr)
+			size := user.Size3D
+			ret[i] = map[string][]int{ARRAY_KEY: {user.NComp(), size[0], size[1], size[2]}}
+			frames = append(frames, user)
+		}
+	}
+	return
+}
+
+// Returns the size {ncomp, nx, ny, nz} if v is an array placeholder.
+func arrayPlaceholder(v interface{}) (size [4]int, ok bool) {
+	obj, isObj := v.(map[string]interface{})
+	if !isObj {
+		return
+	}
+	list, isList := obj[ARRAY_KEY].([]interface{})
+	if !isList || len(list) != 4 {
+		panic(IOErr(fmt.Sprint("rpc: illegal array placeholder: ", ShortPrint(v))))
+	}
+	for i := range size {
+		n, _ := list[i].(float64)
+		size[i] = int(n)
+		if size[i] < 1 || float64(size[i]) != n {
+			panic(IOErr(fmt.Sprint("rpc: illegal array size: ", ShortPrint(v))))
+		}
+	}
+	return size, true
+}
+
+// Reads a frame with an array of size {ncomp, nx, ny, nz}, in user order,
+// and returns it in internal order.
+func readFrame(in io.Reader, size [4]int) *host.Array {
+	// skip whitespace up to the magic number
+	var b [8]byte
+	for {
+		_, err := io.ReadFull(in, b[:1])
+		CheckIO(err)
+		if b[0] == FRAME_MAGIC[0] {
+			break
+		}
+		if b[0] != ' ' && b[0] != '\n' && b[0] != '\r' && b[0] != '\t' {
+			panic(IOErr(fmt.Sprintf("rpc: expected array frame, have byte %q", b[0])))
+		}
+	}
+	_, err := io.ReadFull(in, b[:len(FRAME_MAGIC)-1])
+	CheckIO(err)
+	if string(b[:len(FRAME_MAGIC)-1]) != FRAME_MAGIC[1:] {
+		panic(IOErr("rpc: bad array frame magic number"))
+	}
+
+	_, err = io.ReadFull(in, b[:])
+	CheckIO(err)
+	arr := host.NewArray(size[0], size[1:])
+	nbytes := binary.LittleEndian.Uint64(b[:])
+	if nbytes != uint64(4*len(arr.List)) {
+		panic(IOErr(fmt.Sprint("rpc: array frame has ", nbytes, " bytes, need ", 4*len(arr.List), " for size ", size)))
+	}
+
+	buf := make([]byte, 4*len(arr.List))
+	_, err = io.ReadFull(in, buf)
+	CheckIO(err)
+	for i := range arr.List {
+		arr.List[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return convertXYZ(arr)
+}
+
+// Writes the array (already in user order) as a frame.
+func writeFrame(out io.Writer, arr *host.Array) {
+	buf := make([]byte, len(FRAME_MAGIC)+8+4*len(arr.List))
+	copy(buf, FRAME_MAGIC)
+	binary.LittleEndian.PutUint64(buf[len(FRAME_MAGIC):], uint64(4*len(arr.List)))
+	data := buf[len(FRAME_MAGIC)+8:]
+	for i, v := range arr.List {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	_, err := out.Write(buf)
+	CheckIO(err)
+}
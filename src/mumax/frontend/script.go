@@ -0,0 +1,442 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package frontend
+
+// This file implements a small scripting language for .mx2 input files.
+// Unlike python input files, they are interpreted in-process, calling the API
+// directly, so no python installation is needed. E.g.:
+//
+//	# Standard Problem 4
+//	Nx = 128
+//	setgridsize(Nx, 32, 1)
+//	setcellsize(500e-9/Nx, 125e-9/32, 3e-9)
+//	load("micromagnetism")
+//	setv("Msat", 800e3)
+//	setv("m", [1, 1, 0])
+//	run(1e-9)
+//	print("t =", gets("t"))
+//
+// A script is a sequence of statements, one per line or separated by ';'.
+// A statement is a function call or an assignment "name = expression".
+// Expressions are numbers, "strings" or 'strings', true/false, [lists],
+// variables, function calls and arithmetic (+ - * / and parentheses) on numbers.
+// The functions are the API methods, like for python, plus print().
+// The whole file is parsed before it is executed, so that syntax errors
+// and misspelled function names do not appear in the middle of a long simulation.
+
+import (
+	"fmt"
+	"io"
+	. "mumax/common"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/scanner"
+)
+
+// Parses and runs an .mx2 file, calling the methods of receiver (typically engine.API).
+func runScript(file string, receiver interface{}) {
+	in := OpenRDONLY(file)
+	defer in.Close()
+	s := newScript(receiver)
+	stmts := s.parse(in, file)
+	Debug("Running", file)
+	s.exec(stmts)
+}
+
+// Interpreter state.
+type script struct {
+	method  map[string]reflect.Value // API methods that can be called
+	vars    map[string]interface{}   // values of the variables
+	scan    scanner.Scanner
+	tok     rune // current token
+	nesting int  // depth of () and [], newlines are ignored inside
+}
+
+func newScript(receiver interface{}) *script {
+	s := new(script)
+	s.method = make(map[string]reflect.Value)
+	AddMethods(s.method, receiver)
+	s.vars = make(map[string]interface{})
+	return s
+}
+
+// Runs the parsed statements.
+func (s *script) exec(stmts []*scriptStmt) {
+	for _, st := range stmts {
+		s.execStmt(st)
+	}
+}
+
+// Runs one statement. Errors are reported with the script position.
+func (s *script) execStmt(st *scriptStmt) {
+	defer func() {
+		if err := recover(); err != nil {
+			Err(fmt.Sprint(st.pos, ": error executing statement"))
+			panic(err)
+		}
+	}()
+	v := st.expr.eval(s)
+	if st.assign != "" {
+		s.vars[st.assign] = v
+	}
+}
+
+// A statement: expression or assignment.
+type scriptStmt struct {
+	pos    scanner.Position
+	assign string // name of the assigned variable, "" if none
+	expr   scriptExpr
+}
+
+// An expression that can be evaluated.
+type scriptExpr interface {
+	eval(s *script) interface{}
+}
+
+// Literal value: float64, string or bool.
+type scriptLit struct {
+	val interface{}
+}
+
+func (e *scriptLit) eval(s *script) interface{} { return e.val }
+
+// Variable.
+type scriptVar struct {
+	name string
+}
+
+func (e *scriptVar) eval(s *script) interface{} { return s.vars[e.name] }
+
+// List, evaluates to []interface{}.
+type scriptList struct {
+	elem []scriptExpr
+}
+
+func (e *scriptList) eval(s *script) interface{} {
+	list := make([]interface{}, len(e.elem))
+	for i := range e.elem {
+		list[i] = e.elem[i].eval(s)
+	}
+	return list
+}
+
+// Arithmetic on numbers. Unary minus has x == nil.
+type scriptOp struct {
+	pos  scanner.Position
+	op   rune
+	x, y scriptExpr
+}
+
+func (e *scriptOp) eval(s *script) interface{} {
+	y := e.number(e.y.eval(s))
+	if e.x == nil {
+		return -y
+	}
+	x := e.number(e.x.eval(s))
+	switch e.op {
+	case '+':
+		return x + y
+	case '-':
+		return x - y
+	case '*':
+		return x * y
+	case '/':
+		return x / y
+	}
+	panic(Bug(fmt.Sprint("script: unknown operator ", string(e.op))))
+}
+
+func (e *scriptOp) number(v interface{}) float64 {
+	f, ok := v.(float64)
+	if !ok {
+		errorAt(e.pos, "%v needs numbers, have %v", string(e.op), ShortPrint(v))
+	}
+	return f
+}
+
+// Function call.
+type scriptCall struct {
+	pos  scanner.Position
+	name string
+	args []scriptExpr
+}
+
+// Returns nil for no return value, the value itself for one return value,
+// or a list for multiple return values.
+func (e *scriptCall) eval(s *script) interface{} {
+	args := make([]interface{}, len(e.args))
+	for i := range e.args {
+		args[i] = e.args[i].eval(s)
+	}
+
+	if e.name == "print" {
+		strs := make([]string, len(args))
+		for i := range args {
+			strs[i] = fmt.Sprint(args[i])
+		}
+		Log(strings.Join(strs, " "))
+		return nil
+	}
+
+	Debug("script.Call", e.name, ShortPrint(args))
+	f := s.method[e.name]
+	argvals, err := convertArgs(f.Type(), args)
+	if err != nil {
+		errorAt(e.pos, "%v: %v", e.name, err.Message)
+	}
+	retVals := f.Call(argvals)
+
+	switch len(retVals) {
+	case 0:
+		return nil
+	case 1:
+		return scriptValue(retVals[0])
+	}
+	ret := make([]interface{}, len(retVals))
+	for i := range retVals {
+		ret[i] = scriptValue(retVals[i])
+	}
+	return ret
+}
+
+// Converts a return value for use in the script:
+// all numbers become float64, like in JSON. Other values are left alone,
+// so that, e.g., a *host.Array can be passed back without conversion.
+func scriptValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return v.Interface()
+}
+
+// Parses the script. Panics with an InputErr on syntax errors,
+// unknown functions, wrong number of arguments and undefined variables.
+func (s *script) parse(in io.Reader, filename string) []*scriptStmt {
+	s.scan.Init(in)
+	s.scan.Filename = filename
+	s.scan.Mode = scanner.ScanIdents | scanner.ScanFloats | scanner.ScanStrings
+	s.scan.Whitespace = 1<<' ' | 1<<'\t' | 1<<'\r'
+	s.scan.Error = func(sc *scanner.Scanner, msg string) {
+		panic(InputErr(fmt.Sprint(sc.Pos(), ": ", msg)))
+	}
+	defined := make(map[string]bool)
+
+	var stmts []*scriptStmt
+	s.next()
+	for s.tok != scanner.EOF {
+		if s.tok == '\n' || s.tok == ';' {
+			s.next()
+			continue
+		}
+		st := &scriptStmt{pos: s.scan.Position}
+		if s.tok != scanner.Ident {
+			s.errorf("expected function call or assignment, have %v", s.tokText())
+		}
+		name := s.scan.TokenText()
+		s.next()
+		switch s.tok {
+		default:
+			s.errorf("expected = or ( after %v, have %v", name, s.tokText())
+		case '(':
+			st.expr = s.parseCall(name, st.pos, defined)
+		case '=':
+			s.next()
+			st.assign = name
+			st.expr = s.parseExpr(defined)
+			defined[name] = true
+		}
+		if s.tok != '\n' && s.tok != ';' && s.tok != scanner.EOF {
+			s.errorf("unexpected %v at end of statement", s.tokText())
+		}
+		stmts = append(stmts, st)
+	}
+	return stmts
+}
+
+// Advances to the next token.
+// Skips comments, and newlines inside parentheses or brackets.
+func (s *script) next() {
+	for {
+		s.tok = s.scan.Scan()
+		switch s.tok {
+		case '#':
+			for c := s.scan.Peek(); c != '\n' && c != scanner.EOF; c = s.scan.Peek() {
+				s.scan.Next()
+			}
+			continue
+		case '\n':
+			if s.nesting > 0 {
+				continue
+			}
+		case '(', '[':
+			s.nesting++
+		case ')', ']':
+			s.nesting--
+		}
+		return
+	}
+}
+
+// Panics with an InputErr at the current position.
+func (s *script) errorf(format string, args ...interface{}) {
+	errorAt(s.scan.Position, format, args...)
+}
+
+// Panics with an InputErr at the given position.
+func errorAt(pos scanner.Position, format string, args ...interface{}) {
+	panic(InputErr(fmt.Sprint(pos, ": ", fmt.Sprintf(format, args...))))
+}
+
+// Consumes the expected token.
+func (s *script) expect(tok rune) {
+	if s.tok != tok {
+		s.errorf("expected %v, have %v", scanner.TokenString(tok), s.tokText())
+	}
+	s.next()
+}
+
+// Current token, for error messages.
+func (s *script) tokText() string {
+	switch s.tok {
+	case scanner.EOF:
+		return "end of file"
+	case '\n':
+		return "newline"
+	}
+	return s.scan.TokenText()
+}
+
+// expression: term {(+|-) term}
+func (s *script) parseExpr(defined map[string]bool) scriptExpr {
+	x := s.parseTerm(defined)
+	for s.tok == '+' || s.tok == '-' {
+		op := &scriptOp{pos: s.scan.Position, op: s.tok, x: x}
+		s.next()
+		op.y = s.parseTerm(defined)
+		x = op
+	}
+	return x
+}
+
+// term: factor {(*|/) factor}
+func (s *script) parseTerm(defined map[string]bool) scriptExpr {
+	x := s.parseFactor(defined)
+	for s.tok == '*' || s.tok == '/' {
+		op := &scriptOp{pos: s.scan.Position, op: s.tok, x: x}
+		s.next()
+		op.y = s.parseFactor(defined)
+		x = op
+	}
+	return x
+}
+
+// factor: number | string | true | false | variable | call | list | -factor | (expression)
+func (s *script) parseFactor(defined map[string]bool) scriptExpr {
+	pos := s.scan.Position
+	text := s.scan.TokenText()
+	switch s.tok {
+	case scanner.Float, scanner.Int:
+		s.next()
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			s.errorf("%v", err)
+		}
+		return &scriptLit{v}
+	case scanner.String:
+		s.next()
+		v, err := strconv.Unquote(text)
+		if err != nil {
+			s.errorf("%v", err)
+		}
+		return &scriptLit{v}
+	case '\'':
+		return &scriptLit{s.scanQuoted()}
+	case '-':
+		s.next()
+		return &scriptOp{pos: pos, op: '-', y: s.parseFactor(defined)}
+	case '(':
+		s.next()
+		x := s.parseExpr(defined)
+		s.expect(')')
+		return x
+	case '[':
+		s.next()
+		list := new(scriptList)
+		for s.tok != ']' {
+			list.elem = append(list.elem, s.parseExpr(defined))
+			if s.tok != ']' {
+				s.expect(',')
+			}
+		}
+		s.next()
+		return list
+	case scanner.Ident:
+		s.next()
+		switch text {
+		case "true", "True":
+			return &scriptLit{true}
+		case "false", "False":
+			return &scriptLit{false}
+		}
+		if s.tok == '(' {
+			return s.parseCall(text, pos, defined)
+		}
+		if !defined[text] {
+			errorAt(pos, "undefined: %v", text)
+		}
+		return &scriptVar{text}
+	}
+	s.errorf("unexpected %v", s.tokText())
+	return nil //silence 6g
+}
+
+// call: name(expression, ...)
+func (s *script) parseCall(name string, pos scanner.Position, defined map[string]bool) scriptExpr {
+	s.expect('(')
+	call := &scriptCall{pos: pos, name: strings.ToLower(name)}
+	for s.tok != ')' {
+		call.args = append(call.args, s.parseExpr(defined))
+		if s.tok != ')' {
+			s.expect(',')
+		}
+	}
+	s.next()
+
+	if call.name == "print" {
+		return call
+	}
+	f, ok := s.method[call.name]
+	if !ok {
+		errorAt(pos, "no such function: %v", name)
+	}
+	if len(call.args) != f.Type().NumIn() {
+		errorAt(pos, "%v needs %v arguments, have %v", name, f.Type().NumIn(), len(call.args))
+	}
+	return call
+}
+
+// Scans a single-quoted string, the opening quote being the current token.
+// Backslash escapes are not supported.
+func (s *script) scanQuoted() string {
+	pos := s.scan.Position
+	var str []rune
+	for c := s.scan.Next(); c != '\''; c = s.scan.Next() {
+		if c == '\n' || c == scanner.EOF {
+			errorAt(pos, "string literal not terminated")
+		}
+		str = append(str, c)
+	}
+	s.next()
+	return string(str)
+}
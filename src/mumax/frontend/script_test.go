@@ -0,0 +1,75 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package frontend
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// receiver for TestScript
+type scriptTestAPI struct{ got []interface{} }
+
+func (a *scriptTestAPI) Add(x, y int) int           { return x + y }
+func (a *scriptTestAPI) Record(x float64, s string) { a.got = append(a.got, x, s) }
+func (a *scriptTestAPI) RecordList(list []float64)  { a.got = append(a.got, list) }
+func (a *scriptTestAPI) RecordBool(b bool)          { a.got = append(a.got, b) }
+func (a *scriptTestAPI) Pair() (string, float64)    { return "x", 1.5 }
+
+func TestScript(t *testing.T) {
+	src := `
+# comment
+n = add(1, 2)   # 3
+record(-n * (2 + 1) / 2, 'single') ; record(1e-9, "double")
+recordlist([n,
+            4,
+            -5])
+Record(add(n, 1) - 0.5, "upper case")
+recordbool(True)
+p = pair()
+`
+	api := new(scriptTestAPI)
+	s := newScript(api)
+	s.exec(s.parse(strings.NewReader(src), "test.mx2"))
+
+	want := []interface{}{-4.5, "single", 1e-9, "double", []float64{3, 4, -5}, 3.5, "upper case", true}
+	if have, want := fmt.Sprint(api.got), fmt.Sprint(want); have != want {
+		t.Error("have:", have, "want:", want)
+	}
+	if p := fmt.Sprint(s.vars["p"]); p != "[x 1.5]" {
+		t.Error("pair:", p)
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	bad := map[string]string{
+		"add(1)":               "test.mx2:1:1: add needs 2 arguments, have 1",
+		"foo()":                "test.mx2:1:1: no such function: foo",
+		"add(x, 1)":            "test.mx2:1:5: undefined: x",
+		"x = 1 +\n":            "test.mx2:1:8: unexpected newline",
+		"add(1, 2) 3":          "test.mx2:1:11: unexpected 3 at end of statement",
+		"1 + 2":                "test.mx2:1:1: expected function call or assignment, have 1",
+		"x = 'abc":             "test.mx2:1:5: string literal not terminated",
+		"x = add(1, 'a')":      "test.mx2:1:5: add: interface conversion: interface {} is string, not float64",
+		"x = 'a' * 2":          "test.mx2:1:9: * needs numbers, have a",
+		"recordlist([1, 'a'])": "test.mx2:1:1: recordlist: Error parsing json array: [1 a]\ncause: interface conversion: interface {} is string, not float64",
+	}
+	for src, want := range bad {
+		func() {
+			defer func() {
+				err := recover()
+				if have := fmt.Sprint(err); have != want {
+					t.Error(src, "\nhave:", have, "\nwant:", want)
+				}
+			}()
+			s := newScript(new(scriptTestAPI))
+			s.exec(s.parse(strings.NewReader(src), "test.mx2"))
+		}()
+	}
+}
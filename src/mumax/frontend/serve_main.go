@@ -0,0 +1,75 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package frontend
+
+// This file implements the -serve mode: instead of running an input file,
+// mumax serves its API over JSON-RPC 2.0 (see jsonrpc2.go).
+// Clients are served one after another, all acting on the same engine,
+// so a client can disconnect and another one can pick up the simulation.
+
+import (
+	"fmt"
+	. "mumax/common"
+	"mumax/engine"
+	"net"
+	"strings"
+)
+
+func serveMain() {
+	if !*flag_silent {
+		fmt.Print(WELCOME)
+	}
+
+	outdir := "."
+	if *flag_outputdir != "" {
+		outdir = *flag_outputdir
+	}
+	initOutputDir(outdir)
+	initLogger(outdir)
+	LogFile(WELCOME)
+
+	initCUDA()
+	initMultiGPU()
+
+	engine.Init()
+	engine.GetEngine().SetOutputDirectory(outdir)
+	if *flag_resume != "" {
+		engine.GetEngine().Resume(*flag_resume)
+	}
+
+	var server jsonRPC2
+	server.Init(engine.API{Engine: engine.GetEngine()})
+
+	network, addr := parseServeAddr(*flag_serve)
+	listener, err := net.Listen(network, addr)
+	CheckIO(err)
+	defer listener.Close()
+	Log("Serving JSON-RPC 2.0 on", network, listener.Addr())
+
+	for !server.shutdown {
+		conn, err := listener.Accept()
+		CheckIO(err)
+		Log("Client connected:", conn.RemoteAddr())
+		server.Serve(conn, conn)
+		conn.Close()
+		Log("Client disconnected")
+	}
+	Log("Shutting down")
+}
+
+// Splits the -serve flag into network and address:
+//
+//	"unix:/tmp/mumax.sock" -> "unix", "/tmp/mumax.sock"
+//	"localhost:2011"       -> "tcp", "localhost:2011"
+//	":2011"                -> "tcp", ":2011"
+func parseServeAddr(flag string) (network, addr string) {
+	if strings.HasPrefix(flag, "unix:") {
+		return "unix", flag[len("unix:"):]
+	}
+	return "tcp", flag
+}
@@ -0,0 +1,70 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package geom
+
+// This file implements shapes extruded from images.
+
+import (
+	"image"
+	"image/color"
+	"math"
+	. "mumax/common"
+	"strconv"
+	"strings"
+)
+
+// Extrusion along z of the pixels of the image for which inside returns true.
+// The image is stretched over sx × sy in the xy plane, centered on the origin,
+// with its top row at +y.
+func Image(img image.Image, sx, sy float64, inside func(color.Color) bool) Shape {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	pixels := make([]bool, w*h)
+	for j := 0; j < h; j++ {
+		for i := 0; i < w; i++ {
+			pixels[j*w+i] = inside(img.At(bounds.Min.X+i, bounds.Min.Y+j))
+		}
+	}
+	return func(x, y, z float64) bool {
+		i := int(math.Floor((x/sx + 0.5) * float64(w)))
+		j := int(math.Floor((0.5 - y/sy) * float64(h)))
+		if i < 0 || i >= w || j < 0 || j >= h {
+			return false
+		}
+		return pixels[j*w+i]
+	}
+}
+
+// Returns a function that selects pixels by color:
+//
+//	"dark"    : darker than 50% grey (the default for empty strings)
+//	"light"   : the other pixels
+//	"#RRGGBB" : exactly this color
+func ColorSelector(spec string) func(color.Color) bool {
+	dark := func(c color.Color) bool {
+		r, g, b, _ := c.RGBA()
+		return r+g+b < (0xFFFF*3)/2
+	}
+	switch spec = strings.ToLower(spec); {
+	case spec == "" || spec == "dark":
+		return dark
+	case spec == "light":
+		return func(c color.Color) bool { return !dark(c) }
+	case len(spec) == 7 && spec[0] == '#':
+		rgb, err := strconv.ParseUint(spec[1:], 16, 32)
+		if err != nil {
+			break
+		}
+		want := color.NRGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0xFF}
+		return func(c color.Color) bool {
+			have := color.NRGBAModel.Convert(c).(color.NRGBA)
+			return have.R == want.R && have.G == want.G && have.B == want.B
+		}
+	}
+	panic(InputErr("illegal color " + spec + ", must be dark, light or #RRGGBB"))
+}
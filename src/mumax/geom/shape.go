@@ -0,0 +1,210 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+// Package geom describes geometries as shapes that can be combined
+// (constructive solid geometry) and rasterized onto the simulation grid.
+// Coordinates are in meters, in user XYZ order,
+// with the origin at the center of the grid.
+package geom
+
+import (
+	"math"
+	. "mumax/common"
+)
+
+// A shape tells whether the point (x, y, z) lies inside it.
+type Shape func(x, y, z float64) bool
+
+// Sizes <= 0 mean infinitely large along that axis,
+// e.g. Cylinder(r, 0) is infinitely long.
+
+// Infinitely large shape, contains every point.
+func Universe() Shape {
+	return func(x, y, z float64) bool { return true }
+}
+
+// Box with sides sx, sy, sz, centered on the origin.
+func Box(sx, sy, sz float64) Shape {
+	return func(x, y, z float64) bool {
+		return inSlab(x, sx) && inSlab(y, sy) && inSlab(z, sz)
+	}
+}
+
+// Cylinder with its axis along z, centered on the origin.
+func Cylinder(radius, height float64) Shape {
+	return func(x, y, z float64) bool {
+		return ellipseTerm(x, radius)+ellipseTerm(y, radius) <= 1 && inSlab(z, height)
+	}
+}
+
+// Ellipsoid with semi-axes rx, ry, rz, centered on the origin.
+// E.g. Ellipsoid(rx, ry, 0) is an elliptical cylinder, for thin films.
+func Ellipsoid(rx, ry, rz float64) Shape {
+	return func(x, y, z float64) bool {
+		return ellipseTerm(x, rx)+ellipseTerm(y, ry)+ellipseTerm(z, rz) <= 1
+	}
+}
+
+// Prism with the polygon with vertices (xs[i], ys[i]) as base,
+// extending along z from -height/2 to height/2.
+// The polygon may be concave or self-intersecting (even-odd rule).
+func Prism(xs, ys []float64, height float64) Shape {
+	if len(xs) != len(ys) || len(xs) < 3 {
+		panic(InputErr("prism needs at least 3 vertices, with as many x as y coordinates"))
+	}
+	return func(x, y, z float64) bool {
+		return inSlab(z, height) && inPolygon(xs, ys, x, y)
+	}
+}
+
+// Prism with a regular polygon as base,
+// with the given number of sides and circumscribed radius.
+// The first vertex lies on the x axis.
+func RegularPrism(sides int, radius, height float64) Shape {
+	if sides < 3 {
+		panic(InputErr("polygon needs at least 3 sides"))
+	}
+	xs, ys := make([]float64, sides), make([]float64, sides)
+	for i := range xs {
+		angle := 2 * math.Pi * float64(i) / float64(sides)
+		xs[i], ys[i] = radius*math.Cos(angle), radius*math.Sin(angle)
+	}
+	return Prism(xs, ys, height)
+}
+
+// Union of the shapes: points inside any of them.
+func Union(shapes ...Shape) Shape {
+	return func(x, y, z float64) bool {
+		for _, s := range shapes {
+			if s(x, y, z) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Intersection of the shapes: points inside all of them.
+func Intersect(shapes ...Shape) Shape {
+	return func(x, y, z float64) bool {
+		for _, s := range shapes {
+			if !s(x, y, z) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Points inside s but not inside b.
+func (s Shape) Sub(b Shape) Shape {
+	return func(x, y, z float64) bool {
+		return s(x, y, z) && !b(x, y, z)
+	}
+}
+
+// Points outside s.
+func (s Shape) Invert() Shape {
+	return func(x, y, z float64) bool {
+		return !s(x, y, z)
+	}
+}
+
+// Shape moved over (dx, dy, dz).
+func (s Shape) Translate(dx, dy, dz float64) Shape {
+	return func(x, y, z float64) bool {
+		return s(x-dx, y-dy, z-dz)
+	}
+}
+
+// Shape scaled by sx, sy, sz along each axis, with respect to the origin.
+func (s Shape) Scale(sx, sy, sz float64) Shape {
+	return func(x, y, z float64) bool {
+		return s(x/sx, y/sy, z/sz)
+	}
+}
+
+// Shape rotated counterclockwise by angle (radians) around the x axis.
+func (s Shape) RotateX(angle float64) Shape {
+	sin, cos := math.Sincos(angle)
+	return func(x, y, z float64) bool {
+		return s(x, cos*y+sin*z, -sin*y+cos*z)
+	}
+}
+
+// Shape rotated counterclockwise by angle (radians) around the y axis.
+func (s Shape) RotateY(angle float64) Shape {
+	sin, cos := math.Sincos(angle)
+	return func(x, y, z float64) bool {
+		return s(cos*x-sin*z, y, sin*x+cos*z)
+	}
+}
+
+// Shape rotated counterclockwise by angle (radians) around the z axis.
+func (s Shape) RotateZ(angle float64) Shape {
+	sin, cos := math.Sincos(angle)
+	return func(x, y, z float64) bool {
+		return s(cos*x+sin*y, -sin*x+cos*y, z)
+	}
+}
+
+// Tells for each cell of a grid with n cells of size c (user XYZ order),
+// centered on the origin, whether its center lies inside the shape.
+// The cells are ordered with x varying fastest, then y, then z,
+// which is the order of host.Array.List.
+func (s Shape) Raster(n [3]int, c [3]float64) []bool {
+	inside := make([]bool, n[0]*n[1]*n[2])
+	i := 0
+	for iz := 0; iz < n[2]; iz++ {
+		z := center(iz, n[2], c[2])
+		for iy := 0; iy < n[1]; iy++ {
+			y := center(iy, n[1], c[1])
+			for ix := 0; ix < n[0]; ix++ {
+				inside[i] = s(center(ix, n[0], c[0]), y, z)
+				i++
+			}
+		}
+	}
+	return inside
+}
+
+// Coordinate of the center of cell i of n cells of size c, centered on the origin.
+func center(i, n int, c float64) float64 {
+	return (float64(i) - float64(n)/2 + 0.5) * c
+}
+
+// Tells whether -size/2 <= x <= size/2.
+// A size <= 0 means infinitely large.
+func inSlab(x, size float64) bool {
+	return size <= 0 || math.Abs(x) <= size/2
+}
+
+// Contribution (x/radius)² of one axis to the ellipse equation,
+// 0 for a radius <= 0 (infinitely large).
+func ellipseTerm(x, radius float64) float64 {
+	if radius <= 0 {
+		return 0
+	}
+	return sqr(x / radius)
+}
+
+// Even-odd rule point-in-polygon test.
+func inPolygon(xs, ys []float64, x, y float64) bool {
+	inside := false
+	j := len(xs) - 1
+	for i := range xs {
+		if (ys[i] > y) != (ys[j] > y) && x < (xs[j]-xs[i])*(y-ys[i])/(ys[j]-ys[i])+xs[i] {
+			inside = !inside
+		}
+		j = i
+	}
+	return inside
+}
+
+func sqr(x float64) float64 {
+	return x * x
+}
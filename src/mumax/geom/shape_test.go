@@ -0,0 +1,79 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package geom
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func count(s Shape) int {
+	n := 0
+	for _, in := range s.Raster([3]int{8, 8, 2}, [3]float64{1, 1, 1}) {
+		if in {
+			n++
+		}
+	}
+	return n
+}
+
+func TestShapes(t *testing.T) {
+	tests := []struct {
+		name string
+		s    Shape
+		want int
+	}{
+		{"universe", Universe(), 128},
+		{"box", Box(4, 2, 0), 16},
+		{"cylinder", Cylinder(2, 1), 24},
+		{"ellipsoid", Ellipsoid(4, 4, 1), 64},
+		{"ellipse", Ellipsoid(4, 4, 0), 104},
+		{"slab", Ellipsoid(0, 2, 0), 64},
+		{"infinite cylinder", Cylinder(0, 1), 128},
+		{"prism", Prism([]float64{0, 4, 0}, []float64{0, 0, 4}, 0), 12},
+		{"union", Union(Box(4, 2, 0), Box(2, 4, 0)), 24},
+		{"intersect", Intersect(Box(4, 2, 0), Box(2, 4, 0)), 8},
+		{"sub", Box(4, 4, 0).Sub(Box(2, 2, 0)), 24},
+		{"invert", Box(4, 4, 0).Invert(), 96},
+		{"translate", Box(4, 2, 0).Translate(3, 0, 0), 12},
+		{"scale", Box(4, 2, 0).Scale(2, 1, 1), 32},
+		{"rotate", Box(4, 2, 0).RotateZ(math.Pi / 2), 16},
+		{"square", RegularPrism(4, math.Sqrt2, 0).RotateZ(math.Pi / 4), 8},
+	}
+	for _, test := range tests {
+		if n := count(test.s); n != test.want {
+			t.Error(test.name, ": have", n, "cells, want", test.want)
+		}
+	}
+
+	// rotations are counterclockwise
+	s := Box(2, 0.5, 0.5).Translate(1, 0, 0)
+	if !s.RotateZ(math.Pi/2)(0, 1, 0) || !s.RotateY(math.Pi/2)(0, 0, -1) || !s.Translate(0, 1, 0).RotateX(math.Pi/2)(1, 0, 1) {
+		t.Error("rotation")
+	}
+}
+
+func TestImage(t *testing.T) {
+	// 2x2 image: top row black, white; bottom row red, black
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.Black)
+	img.Set(1, 0, color.White)
+	img.Set(0, 1, color.NRGBA{0xFF, 0, 0, 0xFF})
+	img.Set(1, 1, color.Black)
+
+	dark := Image(img, 4, 4, ColorSelector("dark"))
+	if !dark(-1, 1, 0) || dark(1, 1, 0) || !dark(1, -1, 0) || dark(3, 0, 0) {
+		t.Error("dark")
+	}
+	red := Image(img, 4, 4, ColorSelector("#FF0000"))
+	if !red(-1, -1, 5) || red(-1, 1, 0) {
+		t.Error("red")
+	}
+}
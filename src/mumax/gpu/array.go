@@ -5,6 +5,9 @@
 //  Note that you are welcome to modify this code under the condition that you do not remove any 
 //  copyright notices and prominently state that you modified it, giving a relevant date.
 
+//go:build !cpu
+// +build !cpu
+
 package gpu
 
 // This file implements 3-dimensional arrays of N-vectors distributed over multiple GPUs.
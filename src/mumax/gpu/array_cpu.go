@@ -0,0 +1,345 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// This file implements 3-dimensional arrays of N-vectors stored in host memory,
+// for use without CUDA. The memory layout is identical to the GPU version
+// with one single device.
+
+import (
+	"fmt"
+	. "mumax/common"
+	"mumax/host"
+	"unsafe"
+)
+
+// A MuMax Array represents a 3-dimensional array of N-vectors.
+//
+// For the CPU backend the storage is a plain float32 list,
+// component-major (X0 X1 ... Y0 Y1 ... Z0 Z1 ...).
+type Array struct {
+	list      []float32 // Underlying storage, nil when not allocated.
+	pointer   []uintptr // Address of list[0] (or 0), mimics the GPU pointers.
+	_size     [4]int    // INTERNAL {components, size0, size1, size2}
+	size4D    []int     // {components, size0, size1, size2}
+	size3D    []int     // {size0, size1, size2}
+	_partSize [3]int    // INTERNAL
+	partSize  []int     // size of the parts of the array on each device.
+	partLen4D int       // total number of floats per device
+	partLen3D int       // total number of floats per device for one component
+	Stream              // stream for general use with this array
+	Comp      []Array   // X,Y,Z components as arrays
+}
+
+// Initializes the array to hold a field with the number of components and given size.
+//
+//	Init(3, 1000) // gives an array of 1000 3-vectors
+//	Init(1, 1000) // gives an array of 1000 scalars
+//	Init(6, 1000) // gives an array of 1000 6-vectors or symmetric tensors
+//
+// Storage is allocated only if alloc == true.
+func (a *Array) Init(components int, size3D []int, alloc bool) {
+	a.initSize(components, size3D)
+
+	a.list = nil
+	a.pointer = make([]uintptr, NDevice())
+	a.Stream = NewStream()
+	if alloc {
+		a.Alloc()
+	}
+
+	// initialize component arrays
+	a.initComp()
+}
+
+// initialize component arrays
+func (a *Array) initComp() {
+	a.Comp = make([]Array, a.NComp())
+	for c := range a.Comp {
+		a.Comp[c].initSize(1, a.Size3D())
+		a.Comp[c].pointer = make([]uintptr, NDevice())
+		a.Comp[c].Stream = NewStream()
+		a.Comp[c].Comp = nil
+	}
+	a.initCompPtrs()
+}
+
+// a = other
+// (accessible from packages where Array is not assignable)
+func (a *Array) Assign(other *Array) {
+	a.list = other.list
+	a.pointer = other.pointer
+	a._size = other._size
+	a.size4D = other.size4D
+	a.size3D = other.size3D
+	a._partSize = other._partSize
+	a.partSize = other.partSize
+	a.partLen4D = other.partLen4D
+	a.partLen3D = other.partLen3D
+	a.Stream = other.Stream
+	a.Comp = other.Comp
+}
+
+// Lets the storage of an already initialized, but not allocated array (shared)
+// point to an allocated array (original) possibly with an offset.
+func (shared *Array) PointTo(original *Array, offset int) {
+	Assert(shared.Len()+offset <= original.Len())
+	shared.setList(original.list[offset : offset+shared.Len()])
+	shared.initCompPtrs()
+}
+
+// Parameters for Array.Init()
+const (
+	DO_ALLOC   = true
+	DONT_ALLOC = false
+)
+
+// INTERNAL: sets the storage and the corresponding pointer value.
+func (a *Array) setList(list []float32) {
+	a.list = list
+	if len(list) == 0 {
+		a.list = nil
+		a.pointer[0] = 0
+		return
+	}
+	a.pointer[0] = uintptr(unsafe.Pointer(&list[0]))
+}
+
+// INTERNAL
+// initialize the storage of the component arrays.
+// called after the storage has been changed.
+func (a *Array) initCompPtrs() {
+	for c := range a.Comp {
+		if a.list == nil {
+			a.Comp[c].setList(nil)
+			continue
+		}
+		start := c * a.partLen3D
+		a.Comp[c].setList(a.list[start : start+a.partLen3D])
+	}
+}
+
+// INTERNAL
+// initialize the sizes
+func (a *Array) initSize(components int, size3D []int) {
+	Ndev := len(getDevices())
+	Assert(components > 0)
+	Assert(len(size3D) == 3)
+	length3D := Prod(size3D)
+	Assert(length3D > 0)
+	a.partLen4D = components * length3D / Ndev
+	a.partLen3D = length3D / Ndev
+
+	a._size[0] = components
+	for i := range size3D {
+		a._size[i+1] = size3D[i]
+	}
+	a.size4D = a._size[:]
+	a.size3D = a._size[1:]
+	a._partSize[X] = a.size3D[X]
+	a._partSize[Y] = a.size3D[Y] / Ndev
+	a._partSize[Z] = a.size3D[Z]
+	a.partSize = a._partSize[:]
+}
+
+// Returns an array which holds a field with the number of components and given size.
+func NewArray(components int, size3D []int) *Array {
+	t := new(Array)
+	t.Init(components, size3D, DO_ALLOC)
+	return t
+}
+
+// Returns an array without underlying storage.
+// This is used for space-independent quantities. These pass
+// a multiplier value and a null pointer.
+// See: Alloc()
+func NilArray(components int, size3D []int) *Array {
+	t := new(Array)
+	t.Init(components, size3D, DONT_ALLOC)
+	return t
+}
+
+// If the array has no underlying storage yet (e.g., it was
+// created by NilArray()), allocate that storage.
+func (a *Array) Alloc() {
+	Assert(a.list == nil)
+	a.setList(make([]float32, a.partLen4D))
+	a.initCompPtrs() // need to update the component pointers
+}
+
+// Frees the underlying storage and sets the size to zero.
+func (v *Array) Free() {
+	v.Stream.Destroy()
+	v.Stream = nil
+
+	if v.pointer != nil {
+		v.setList(nil)
+	}
+
+	for i := range v._size {
+		v._size[i] = 0
+	}
+	v.initCompPtrs() // also set component storage to nil
+	for c := range v.Comp {
+		v.Comp[c].Stream.Destroy()
+	}
+}
+
+// Address of the storage, one per device.
+func (a *Array) DevicePtr() []uintptr {
+	return a.pointer
+}
+
+// Total number of elements
+func (a *Array) Len() int {
+	return a._size[0] * a._size[1] * a._size[2] * a._size[3]
+}
+
+// Total number of elements per device
+func (a *Array) PartLen4D() int {
+	return a.partLen4D
+}
+
+// Number of elements per component per device
+func (a *Array) PartLen3D() int {
+	return a.partLen3D
+}
+
+// Number of components (1: scalar, 3: vector, ...).
+func (a *Array) NComp() int {
+	return a._size[0]
+}
+
+// Gets the i'th component as an array.
+// E.g.: Component(0) is the x-component.
+func (a *Array) Component(i int) *Array {
+	if a._size[0] == 1 { // 1-component
+		return a
+	}
+	return &(a.Comp[i])
+}
+
+// Array of pointers to parts, one per device.
+func (a *Array) Pointers() []uintptr {
+	return a.pointer
+}
+
+// The underlying storage (nil if not allocated).
+// Only available with the CPU backend.
+func (a *Array) List() []float32 {
+	return a.list
+}
+
+// True if the array has no underlying storage.
+// E.g., when created by NilArray()
+func (a *Array) IsNil() bool {
+	return a.list == nil
+}
+
+// Size of the vector field.
+func (a *Array) Size3D() []int {
+	return a.size3D
+}
+
+// Number of components + size of the vector field.
+func (a *Array) Size4D() []int {
+	return a.size4D
+}
+
+// Size of each part per device
+func (a *Array) PartSize() []int {
+	return a.partSize
+}
+
+// check if comp, x, y, z is inside the array's bounds.
+// panic if not.
+func (a *Array) checkBounds(comp, x, y, z int) {
+	if comp < 0 || comp >= a.NComp() ||
+		x < 0 || x >= a.size3D[X] ||
+		y < 0 || y >= a.size3D[Y] ||
+		z < 0 || z >= a.size3D[Z] {
+		panic(InputErr(fmt.Sprint("gpu.Array index out of range. ",
+			"component:", comp, " index:", z, y, x,
+			" array size: ", a.NComp(), " components x ", a.size3D[Z], a.size3D[Y], a.size3D[X])))
+	}
+}
+
+// Get a single value
+func (b *Array) Get(comp, x, y, z int) float32 {
+	b.checkBounds(comp, x, y, z)
+	_, index := b.Comp[comp].indexOf(x, y, z)
+	return b.Comp[comp].list[index]
+}
+
+// Set a single value
+func (b *Array) Set(comp, x, y, z int, value float32) {
+	b.checkBounds(comp, x, y, z)
+	_, index := b.Comp[comp].indexOf(x, y, z)
+	b.Comp[comp].list[index] = value
+}
+
+func (a *Array) indexOf(x, y, z int) (device, index int) {
+	N1 := a.partSize[Y]
+	N2 := a.partSize[Z]
+	index = x*N1*N2 + y*N2 + z
+	return
+}
+
+// Copy from array to array.
+func (dst *Array) CopyFromDevice(src *Array) {
+	CheckSize(dst.size4D, src.size4D)
+	copy(dst.list, src.list)
+}
+
+// Copy from host array to array.
+func (dst *Array) CopyFromHost(src *host.Array) {
+	CheckSize(dst.size4D, src.Size4D)
+	copy(dst.list, src.List)
+}
+
+// Copy from array to host array.
+func (src *Array) CopyToHost(dst *host.Array) {
+	CheckSize(dst.Size4D, src.size4D)
+	copy(dst.List, src.list)
+}
+
+// DEBUG: Make a freshly allocated copy on the host.
+func (src *Array) LocalCopy() *host.Array {
+	dst := host.NewArray(src.NComp(), src.Size3D())
+	src.CopyToHost(dst)
+	return dst
+}
+
+// DEBUG: copy of parts as stored on each device
+func (src *Array) RawCopy() [][]float32 {
+	cpy := make([]float32, src.NComp()*src.partLen3D)
+	copy(cpy, src.list)
+	return [][]float32{cpy}
+}
+
+// Makes all elements zero.
+func (a *Array) Zero() {
+	a.MemSet(0)
+}
+
+func (a *Array) MemSet(num float32) {
+	for i := range a.list {
+		a.list[i] = num
+	}
+}
+
+// Error message.
+const MSG_ARRAY_SIZE_MISMATCH = "array size mismatch"
+
+// Human-readable string.
+func (a *Array) String() string {
+	return fmt.Sprint("gpu.Array{pointers=", a.pointer, "size=", a.size4D, "}")
+}
@@ -0,0 +1,163 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of brillouin.cu
+
+import (
+	"math"
+	. "mumax/common"
+)
+
+func BrillouinAsync(msat0 *Array, msat0T0 *Array, T *Array, Tc *Array, S *Array, msat0Mul float64, msat0T0Mul float64, TcMul float64, SMul float64, stream Stream) {
+
+	// Bookkeeping
+	CheckSize(msat0.Size3D(), T.Size3D())
+
+	msat0Msk := msat0.Comp[X].list
+	msat0T0Msk := msat0T0.Comp[X].list
+	temp := T.Comp[X].list
+	TcMsk := Tc.Comp[X].list
+	SMsk := S.Comp[X].list
+	ms0Mul, ms0T0Mul, tcMul, sMul := float32(msat0Mul), float32(msat0T0Mul), float32(TcMul), float32(SMul)
+
+	for i := 0; i < msat0.partLen3D; i++ {
+		Temp := temp[i]
+		ms0T0 := ms0T0Mul * maskUnity(msat0T0Msk, i)
+		if ms0T0 == 0 {
+			msat0Msk[i] = 0
+			continue
+		}
+		if Temp == 0 {
+			msat0Msk[i] = ms0T0 / ms0Mul
+			continue
+		}
+		tc := tcMul * maskUnity(TcMsk, i)
+		if Temp > tc {
+			msat0Msk[i] = 0
+			continue
+		}
+		s := sMul * maskUnity(SMsk, i)
+		J0 := 3 * tc / (s * (s + 1))
+		pre := s * s * J0 / Temp
+		dT := tc - Temp
+		lowLimit, hiLimit := float32(0.1), float32(1.1)
+		if dT < 0.25 {
+			lowLimit, hiLimit = -0.1, 0.5
+		}
+		model := func(n float32) float32 { return brillouin(s, pre*n) - n }
+		ms := findrootRidders(model, lowLimit, hiLimit)
+		msat0Msk[i] = ms0T0 * abs32(ms) / ms0Mul
+	}
+}
+
+// Numerical constants of common_func.h
+const (
+	zeroThreshold = 1.0e-32 // the zero threshold
+	rootEps       = 1.0e-8  // the target numerical accuracy of iterative methods
+	linRange      = 2.0e-1  // defines the region of linearity
+)
+
+// Finds the root of f between xa and xb with Ridders' method.
+func findrootRidders(f func(x float32) float32, xa, xb float32) float32 {
+	ya := f(xa)
+	if abs32(ya) < zeroThreshold {
+		return xa
+	}
+	yb := f(xb)
+	if abs32(yb) < zeroThreshold {
+		return xb
+	}
+
+	y1, x1 := ya, xa
+	y2, x2 := yb, xb
+
+	x := float32(1e10)
+	tx := x
+	teps := x
+
+	for iter := 0; teps > rootEps && iter < 1000; iter++ {
+		x3 := 0.5 * (x2 + x1)
+		y3 := f(x3)
+		dy := y3*y3 - y1*y2
+		if dy == 0 {
+			x = x3
+			break
+		}
+		dx := (x3 - x1) * signf(y1-y2) * y3 / sqrtf(dy)
+		x = x3 + dx
+		y := f(x)
+
+		if signbit(y) != signbit(y3) {
+			y2, x2 = y3, x3
+		}
+		if !(signbit(y) == signbit(y1) || x2 == x3) {
+			y2, x2 = y1, x1
+		}
+		y1, x1 = y, x
+
+		teps = abs32((x - tx) / (tx + x))
+		tx = x
+	}
+	return x
+}
+
+func signbit(x float32) bool {
+	return math.Signbit(float64(x))
+}
+
+func signf(x float32) float32 {
+	if x == 0 {
+		return 0
+	}
+	if signbit(x) {
+		return -1
+	}
+	return 1
+}
+
+func cothf(x float32) float32 {
+	return 1 / float32(math.Tanh(float64(x)))
+}
+
+func powf(x, y float32) float32 {
+	return float32(math.Pow(float64(x), float64(y)))
+}
+
+// Brillouin function B_J(x).
+func brillouin(J, x float32) float32 {
+	lpre := 1 / (2 * J)
+	gpre := (2*J + 1) * lpre
+	lim := linRange / gpre
+	if abs32(x) < lim {
+		return ((gpre*gpre - lpre*lpre) * x / 3) +
+			((powf(lpre, 4) - powf(gpre, 4)) * x * x * x / 45) +
+			(0.5 * (powf(gpre, 6) - powf(lpre, 6)) * x * x * x * x * x / 945) +
+			((powf(gpre, 8) - powf(lpre, 8)) * x * x * x * x * x * x * x / 4725)
+	}
+	return gpre*cothf(gpre*x) - lpre*cothf(lpre*x)
+}
+
+// Derivative of the Brillouin function dB_J/dx.
+func dBrillouindx(J, x float32) float32 {
+	lpre := 1 / (2 * J)
+	gpre := (2*J + 1) * lpre
+	gpre2 := gpre * gpre
+	lpre2 := lpre * lpre
+	lim := linRange / gpre
+	if abs32(x) < lim {
+		return (gpre2-lpre2)/3 +
+			((powf(lpre, 4) - powf(gpre, 4)) * x * x / 15) +
+			(0.5 * (powf(gpre, 6) - powf(lpre, 6)) * x * x * x * x / 189) +
+			((powf(gpre, 8) - powf(lpre, 8)) * x * x * x * x * x * x / 675)
+	}
+	return (gpre2 - lpre2) + lpre2*cothf(lpre*x)*cothf(lpre*x) - gpre2*cothf(gpre*x)*cothf(gpre*x)
+}
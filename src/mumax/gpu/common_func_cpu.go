@@ -0,0 +1,106 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of the helper functions in common_func.h.
+
+import (
+	"math"
+)
+
+// 3-vector of float32, like CUDA's float3.
+type float3 struct {
+	x, y, z float32
+}
+
+// Dot product.
+func dotf(a, b float3) float32 {
+	return a.x*b.x + a.y*b.y + a.z*b.z
+}
+
+// Cross product, with the same operand order as common_func.h.
+func crossf(a, b float3) float3 {
+	return float3{
+		-a.y*b.z + a.z*b.y,
+		-a.z*b.x + a.x*b.z,
+		-a.x*b.y + a.y*b.x}
+}
+
+// Vector length.
+func lenf(a float3) float32 {
+	return sqrtf(dotf(a, a))
+}
+
+// Normalized vector, or zero vector if a has length 0.
+func normalize(a float3) float3 {
+	var veclen float32
+	if l := lenf(a); l != 0 {
+		veclen = 1 / l
+	}
+	return float3{a.x * veclen, a.y * veclen, a.z * veclen}
+}
+
+// INTERNAL: neighbor index for a finite-difference stencil:
+// wraps around if periodic, else clamps to the edge.
+func wrapClamp(idx, N int, periodic bool) int {
+	if periodic {
+		return mod(idx, N)
+	}
+	if idx < 0 {
+		return 0
+	}
+	if idx > N-1 {
+		return N - 1
+	}
+	return idx
+}
+
+// Python-like modulus
+func mod(a, b int) int {
+	return (a%b + b) % b
+}
+
+// Returns 1 for a NULL mask, the mask value otherwise.
+func maskUnity(mask []float32, i int) float32 {
+	if mask == nil {
+		return 1
+	}
+	return mask[i]
+}
+
+// Returns 0 for a NULL mask, the mask value otherwise.
+func maskZero(mask []float32, i int) float32 {
+	if mask == nil {
+		return 0
+	}
+	return mask[i]
+}
+
+// a/b, or 0 if b is 0.
+func fdivZero(a, b float32) float32 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// Harmonic mean 2ab/(a+b), or 0 if a+b is 0.
+func avgGeomZero(a, b float32) float32 {
+	a_b := a + b
+	if a_b == 0 {
+		return 0
+	}
+	return 2 * a * b / a_b
+}
+
+func sqrtf(x float32) float32 {
+	return float32(math.Sqrt(float64(x)))
+}
@@ -13,6 +13,7 @@ package gpu
 
 import (
 	"fmt"
+	"math"
 	"testing"
 	// 	. "mumax/common"
 )
@@ -33,7 +34,7 @@ func TestFFT(test *testing.T) {
 		fftSize[0] = N0
 	}
 
-	fft := NewFFTPlan5(dataSize, fftSize)
+	fft := NewDefaultFFT(dataSize, fftSize)
 	defer fft.Free()
 
 	in := NewArray(nComp, dataSize)
@@ -73,6 +74,16 @@ func TestFFT(test *testing.T) {
 	fmt.Println("")
 	fmt.Println("INV: ", in.LocalCopy().Array)
 
+	// forward + inverse should give back the input, times the FFT size
+	norm := float32(FFTNormLogic(fftSize))
+	inv := in.LocalCopy()
+	for i := range inv.List {
+		if math.Abs(float64(inv.List[i]-norm*inh.List[i])) > 1e-3*float64(norm) {
+			test.Error("FFT round trip: got", inv.List[i]/norm, "want", inh.List[i], "at", i)
+			break
+		}
+	}
+
 	/*   fmt.Println("")
 	fmt.Println("FW->BW: ", in.LocalCopy().Array)*/
 	// 	PrintTimers()
@@ -86,17 +97,19 @@ func BenchmarkFFT(b *testing.B) {
 	N0, N1, N2 := 1, N, N
 	dataSize := []int{N0, N1, N2}
 	fftSize := []int{N0, N1, N2}
-	fft := NewFFTPlan1(dataSize, fftSize)
+	fft := NewDefaultFFT(dataSize, fftSize)
 	defer fft.Free()
 
 	in := NewArray(nComp, dataSize)
 	defer in.Free()
+	out := NewArray(nComp, FFTOutputSize(fftSize))
+	defer out.Free()
 
 	// warmup
-	fft.Forward(in, nil)
+	fft.Forward(in, out)
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
-		fft.Forward(in, nil)
+		fft.Forward(in, out)
 	}
 
 }
@@ -0,0 +1,234 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go replacement of fftplanX.go for the CPU backend.
+// The output has the same layout as cufft's in-place R2C transform
+// in native compatibility mode: the real input is not padded and the
+// last dimension of the output holds N2/2+1 interleaved complex numbers.
+// Like cufft, the transforms are not normalized.
+
+import (
+	"math"
+	. "mumax/common"
+)
+
+// Register this FFT plan
+func init() {
+	fftPlans["X"] = NewFFTPlanX
+}
+
+type FFTPlanX struct {
+	//sizes
+	dataSize   [3]int // Size of the (non-zero) input data block
+	logicSize  [3]int // Transform size including zero-padding. >= dataSize
+	outputSize [3]int // Size of the output data
+	buffer     Array  // An array for zero-padding the data
+
+	plan    [3]*fft1D    // 1D transforms along each dimension
+	scratch []complex128 // full complex transform
+	Stream
+}
+
+func (fft *FFTPlanX) init(dataSize, logicSize []int) {
+	Assert(len(dataSize) == 3)
+	Assert(len(logicSize) == 3)
+	const nComp = 1
+
+	fft.buffer.Init(nComp, logicSize, DONT_ALLOC)
+
+	outputSize := FFTOutputSize(logicSize)
+	for i := range fft.dataSize {
+		fft.dataSize[i] = dataSize[i]
+		fft.logicSize[i] = logicSize[i]
+		fft.outputSize[i] = outputSize[i]
+		fft.plan[i] = newFFT1D(logicSize[i])
+	}
+	fft.scratch = make([]complex128, Prod(logicSize))
+
+	fft.Stream = NewStream()
+}
+
+func NewFFTPlanX(dataSize, logicSize []int) FFTInterface {
+	fft := new(FFTPlanX)
+	fft.init(dataSize, logicSize)
+	return fft
+}
+
+func (fft *FFTPlanX) Free() {
+	for i := range fft.dataSize {
+		fft.dataSize[i] = 0
+		fft.logicSize[i] = 0
+	}
+	fft.scratch = nil
+}
+
+func (fft *FFTPlanX) Forward(in, out *Array) {
+	AssertMsg(in.size4D[0] == 1, "1")
+	AssertMsg(out.size4D[0] == 1, "2")
+	CheckSize(in.size3D, fft.dataSize[:])
+	CheckSize(out.size3D, fft.outputSize[:])
+
+	buf := &fft.buffer
+	buf.PointTo(out, 0)
+
+	buf.Zero()
+
+	CopyPad3D(buf, in)
+
+	// real to complex
+	data := buf.list
+	c := fft.scratch
+	for i := range c {
+		c[i] = complex(float64(data[i]), 0)
+	}
+
+	fft.transform(c, false)
+
+	// store non-redundant half
+	N0, N1, N2 := fft.logicSize[0], fft.logicSize[1], fft.logicSize[2]
+	o := out.list
+	M2 := N2/2 + 1
+	for i := 0; i < N0; i++ {
+		for j := 0; j < N1; j++ {
+			for k := 0; k < M2; k++ {
+				v := c[(i*N1+j)*N2+k]
+				I := 2 * ((i*N1+j)*M2 + k)
+				o[I] = float32(real(v))
+				o[I+1] = float32(imag(v))
+			}
+		}
+	}
+}
+
+func (fft *FFTPlanX) Inverse(in, out *Array) {
+	N0, N1, N2 := fft.logicSize[0], fft.logicSize[1], fft.logicSize[2]
+	M2 := N2/2 + 1
+
+	// restore the full spectrum from its hermitian symmetry
+	d := in.list
+	c := fft.scratch
+	for i := 0; i < N0; i++ {
+		for j := 0; j < N1; j++ {
+			for k := 0; k < N2; k++ {
+				if k < M2 {
+					I := 2 * ((i*N1+j)*M2 + k)
+					c[(i*N1+j)*N2+k] = complex(float64(d[I]), float64(d[I+1]))
+				} else {
+					i2, j2, k2 := mod(-i, N0), mod(-j, N1), N2-k
+					I := 2 * ((i2*N1+j2)*M2 + k2)
+					c[(i*N1+j)*N2+k] = complex(float64(d[I]), -float64(d[I+1]))
+				}
+			}
+		}
+	}
+
+	fft.transform(c, true)
+
+	// complex to real, in place
+	for i := range c {
+		d[i] = float32(real(c[i]))
+	}
+
+	buf := &fft.buffer
+	buf.PointTo(in, 0)
+
+	CopyUnPad3D(out, buf)
+}
+
+// INTERNAL: in-place 3D transform of data, stored as N0 x N1 x N2.
+func (fft *FFTPlanX) transform(data []complex128, inverse bool) {
+	N := fft.logicSize
+	stride := [3]int{N[1] * N[2], N[2], 1}
+	for dim := 0; dim < 3; dim++ {
+		n := N[dim]
+		if n == 1 {
+			continue
+		}
+		line := make([]complex128, n)
+		res := make([]complex128, n)
+		for start := range data {
+			// skip elements which are not at the start of a line along dim
+			if (start/stride[dim])%n != 0 {
+				continue
+			}
+			for x := 0; x < n; x++ {
+				line[x] = data[start+x*stride[dim]]
+			}
+			fft.plan[dim].exec(res, line, inverse)
+			for x := 0; x < n; x++ {
+				data[start+x*stride[dim]] = res[x]
+			}
+		}
+	}
+}
+
+// 1D complex FFT of arbitrary length.
+// Even lengths use radix-2 decimation in time,
+// remaining odd factors use a direct DFT.
+type fft1D struct {
+	n       int
+	twiddle []complex128 // exp(-2 pi i m/n)
+	tmp     []complex128
+}
+
+func newFFT1D(n int) *fft1D {
+	p := &fft1D{n: n, twiddle: make([]complex128, n), tmp: make([]complex128, n)}
+	for m := range p.twiddle {
+		phase := -2 * math.Pi * float64(m) / float64(n)
+		p.twiddle[m] = complex(math.Cos(phase), math.Sin(phase))
+	}
+	return p
+}
+
+// dst = FFT(src), unnormalized.
+// The inverse is computed as conj(FFT(conj(src))).
+func (p *fft1D) exec(dst, src []complex128, inverse bool) {
+	if inverse {
+		for i, v := range src {
+			p.tmp[i] = complex(real(v), -imag(v))
+		}
+		p.rec(dst, p.tmp, 1, p.n, 1)
+		for i, v := range dst {
+			dst[i] = complex(real(v), -imag(v))
+		}
+		return
+	}
+	p.rec(dst, src, 1, p.n, 1)
+}
+
+// Transforms n elements of src, spaced by stride, into dst.
+// s = p.n / n is the twiddle factor step.
+func (p *fft1D) rec(dst, src []complex128, stride, n, s int) {
+	if n == 1 {
+		dst[0] = src[0]
+		return
+	}
+	if n%2 == 0 {
+		half := n / 2
+		p.rec(dst[:half], src, 2*stride, half, 2*s)
+		p.rec(dst[half:], src[stride:], 2*stride, half, 2*s)
+		for k := 0; k < half; k++ {
+			e := dst[k]
+			t := p.twiddle[k*s] * dst[half+k]
+			dst[k] = e + t
+			dst[half+k] = e - t
+		}
+		return
+	}
+	for k := 0; k < n; k++ {
+		var sum complex128
+		for j := 0; j < n; j++ {
+			sum += src[j*stride] * p.twiddle[((j*k)%n)*s]
+		}
+		dst[k] = sum
+	}
+}
@@ -10,8 +10,6 @@ package gpu
 // Author: Arne Vansteenkiste
 
 import (
-	cu "cuda/driver"
-	"flag"
 	. "mumax/common"
 )
 
@@ -19,9 +17,8 @@ import (
 const BIG = 16 * 128 * 512
 
 func init() {
-	flag.Parse()
 	InitLogger("test.log")
-	cu.Init()
+	InitCUDA()
 	//InitAllGPUs(0)
 	//println("		*****  u s i n g    1    g p u  *******  ")
 	//InitMultiGPU([]int{0}, 0)
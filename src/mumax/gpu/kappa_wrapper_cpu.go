@@ -0,0 +1,51 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of kappa.cu
+
+import (
+	. "mumax/common"
+)
+
+func KappaAsync(kappa *Array, msat0 *Array, msat0T0 *Array, T *Array, Tc *Array, S *Array, n *Array, msat0Mul float64, msat0T0Mul float64, TcMul float64, SMul float64, stream Stream) {
+
+	// Bookkeeping
+	CheckSize(kappa.Size3D(), T.Size3D())
+
+	k := kappa.Comp[X].list
+	msat0Msk := msat0.Comp[X].list
+	msat0T0Msk := msat0T0.Comp[X].list
+	temp := T.Comp[X].list
+	TcMsk := Tc.Comp[X].list
+	SMsk := S.Comp[X].list
+	nMsk := n.Comp[X].list
+	ms0Mul, ms0T0Mul, tcMul, sMul := float32(msat0Mul), float32(msat0T0Mul), float32(TcMul), float32(SMul)
+
+	for i := 0; i < kappa.partLen3D; i++ {
+		ms0T0 := ms0T0Mul * maskUnity(msat0T0Msk, i)
+		Temp := temp[i]
+		if ms0T0 == 0 || Temp == 0 {
+			k[i] = 0
+			continue
+		}
+		s := sMul * maskUnity(SMsk, i)
+		tc := tcMul * maskUnity(TcMsk, i)
+		ms0 := ms0Mul * maskUnity(msat0Msk, i)
+		J0 := 3 * tc / (s * (s + 1)) // in h^2 units
+		N := maskUnity(nMsk, i)
+		mul := ms0T0 * ms0T0 / (s * s * J0 * N)
+		me := ms0 / ms0T0
+		b := s * s * J0 / Temp
+		f := b * dBrillouindx(s, me*b)
+		k[i] = mul * (f / (1 - f))
+	}
+}
@@ -0,0 +1,230 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Magnetostatic kernel, pure-Go version of kernelInit.go,
+// pointKernel.cu and rotorKernel.cu
+// Author: Ben Van de Wiele
+
+import (
+	. "mumax/common"
+	"mumax/host"
+)
+
+func InitRotorKernel(size []int, cellsize []float64, periodic []int, accuracy int, kern *host.Array) {
+	Debug("Calculating demag kernel", "size:", size, "cellsize:", cellsize, "accuracy:", accuracy, "periodic:", periodic)
+	Start("kern_r")
+
+	Assert(len(kern.Array) == 9) // TODO: should be able to change to 6
+	CheckSize(kern.Size3D, size)
+
+	qd_W_10 := make([][]float32, NDevice())
+	qd_P_10 := make([][]float32, NDevice())
+	Initialize_Gauss_quadrature(qd_W_10, qd_P_10, cellsize)
+
+	buffer := NewArray(1, size)
+	defer buffer.Free()
+
+	for comp := 0; comp < 9; comp++ {
+		buffer.Zero()
+		InitRotorKernelElement(buffer, comp, periodic, cellsize, qd_P_10, qd_W_10)
+		buffer.CopyToHost(kern.Component(comp))
+	}
+
+	Stop("kern_r")
+}
+
+func InitPointKernel(size []int, cellsize []float64, periodic []int, kern *host.Array) {
+	Debug("Calculating demag kernel", "size:", size, "cellsize:", cellsize, "periodic:", periodic)
+	Start("kern_p")
+
+	Assert(len(kern.Array) == 3) // TODO: should be able to change to 6
+	CheckSize(kern.Size3D, size)
+
+	qd_W_10 := make([][]float32, NDevice())
+	qd_P_10 := make([][]float32, NDevice())
+	Initialize_Gauss_quadrature(qd_W_10, qd_P_10, cellsize)
+
+	buffer := NewArray(1, size)
+	defer buffer.Free()
+
+	for comp := 0; comp < 3; comp++ {
+		buffer.Zero()
+		InitPointKernelElement(buffer, comp, periodic, cellsize, qd_P_10, qd_W_10)
+		buffer.CopyToHost(kern.Component(comp))
+	}
+
+	Stop("kern_p")
+}
+
+func Initialize_Gauss_quadrature(qd_W_10, qd_P_10 [][]float32, cellSize []float64) {
+
+	// initialize standard order 10 Gauss quadrature points and weights _____________________________
+	std_qd_P_10 := make([]float64, 10)
+	std_qd_P_10[0] = -0.97390652851717197
+	std_qd_P_10[1] = -0.86506336668898498
+	std_qd_P_10[2] = -0.67940956829902399
+	std_qd_P_10[3] = -0.43339539412924699
+	std_qd_P_10[4] = -0.14887433898163099
+	std_qd_P_10[5] = -std_qd_P_10[4]
+	std_qd_P_10[6] = -std_qd_P_10[3]
+	std_qd_P_10[7] = -std_qd_P_10[2]
+	std_qd_P_10[8] = -std_qd_P_10[1]
+	std_qd_P_10[9] = -std_qd_P_10[0]
+	host_qd_W_10 := make([]float32, 10)
+	host_qd_W_10[0] = 0.066671344308687999
+	host_qd_W_10[9] = 0.066671344308687999
+	host_qd_W_10[1] = 0.149451349150581
+	host_qd_W_10[8] = 0.149451349150581
+	host_qd_W_10[2] = 0.21908636251598201
+	host_qd_W_10[7] = 0.21908636251598201
+	host_qd_W_10[3] = 0.26926671930999602
+	host_qd_W_10[6] = 0.26926671930999602
+	host_qd_W_10[4] = 0.29552422471475298
+	host_qd_W_10[5] = 0.29552422471475298
+	// ______________________________________________________________________________________________
+
+	// Map the standard Gauss quadrature points to the used integration boundaries __________________
+	host_qd_P_10 := make([]float32, 30)
+	get_Quad_Points(host_qd_P_10, std_qd_P_10, 10, -0.5*cellSize[0], 0.5*cellSize[0], 0)
+	get_Quad_Points(host_qd_P_10, std_qd_P_10, 10, -0.5*cellSize[1], 0.5*cellSize[1], 1)
+	get_Quad_Points(host_qd_P_10, std_qd_P_10, 10, -0.5*cellSize[2], 0.5*cellSize[2], 2)
+	// ______________________________________________________________________________________________
+
+	for i := range qd_W_10 {
+		qd_W_10[i] = host_qd_W_10
+		qd_P_10[i] = host_qd_P_10
+	}
+}
+
+func get_Quad_Points(gaussQP []float32, stdGaussQP []float64, qOrder int, a, b float64, cnt int) {
+
+	A := (b - a) / 2.0 // coefficients for transformation x'= Ax+B
+	B := (a + b) / 2.0 // where x' is the new integration parameter
+	for i := 0; i < qOrder; i++ {
+		gaussQP[cnt*10+i] = float32(A*stdGaussQP[i] + B)
+	}
+
+}
+
+func InitRotorKernelElement(buffer *Array, comp int, periodic []int, cellSize []float64, qd_P_10, qd_W_10 [][]float32) {
+	initKernelElement(buffer, periodic, cellSize, func(N [3]int, a, b, c int, per [3]int, cell [3]float32) float32 {
+		return rotorKernelElement(N, comp, a, b, c, per, cell, qd_P_10[0], qd_W_10[0])
+	})
+}
+
+func InitPointKernelElement(buffer *Array, comp int, periodic []int, cellSize []float64, qd_P_10, qd_W_10 [][]float32) {
+	initKernelElement(buffer, periodic, cellSize, func(N [3]int, a, b, c int, per [3]int, cell [3]float32) float32 {
+		return pointKernelElement(N, comp, a, b, c, per, cell, qd_P_10[0], qd_W_10[0])
+	})
+}
+
+// INTERNAL: Fills the kernel buffer using the symmetries of the kernel,
+// like initPointKernelElementKern and initRotorKernelElementKern.
+func initKernelElement(buffer *Array, periodic []int, cellSize []float64, element func(N [3]int, a, b, c int, per [3]int, cell [3]float32) float32) {
+	data := buffer.list
+	N := [3]int{buffer.size3D[0], buffer.size3D[1], buffer.size3D[2]}
+	N0, N1, N2 := N[0], N[1], N[2]
+	N1part := buffer.partSize[1]
+	N12 := N1part * N2
+	per := [3]int{periodic[0], periodic[1], periodic[2]}
+	cell := [3]float32{float32(cellSize[0]), float32(cellSize[1]), float32(cellSize[2])}
+
+	for j := 0; j < N1part; j++ {
+		j2 := j
+		if j2 == N1/2 {
+			continue
+		}
+		if j2 > N1/2 {
+			j2 -= N1
+		}
+		for k := 0; k < N2/2; k++ {
+			for i := 0; i < (N0+1)/2; i++ { // this also works in the 2D case
+				data[i*N12+j*N2+k] = element(N, i, j2, k, per, cell)
+				if i > 0 {
+					data[(N0-i)*N12+j*N2+k] = element(N, -i, j2, k, per, cell)
+				}
+				if k > 0 {
+					data[i*N12+j*N2+N2-k] = element(N, i, j2, -k, per, cell)
+				}
+				if i > 0 && k > 0 {
+					data[(N0-i)*N12+j*N2+N2-k] = element(N, -i, j2, -k, per, cell)
+				}
+			}
+		}
+	}
+}
+
+// Prefactor 1/(4 pi) of the kernel elements.
+const kernelPrefactor = 1.0 / 4.0 / 3.14159265
+
+func pointKernelElement(N [3]int, comp int, a, b, c int, per [3]int, cell [3]float32, qd_P_10, qd_W_10 []float32) float32 {
+	return kernelPrefactor * dipoleIntegral(N, comp, a, b, c, per, cell, qd_P_10, qd_W_10)
+}
+
+func rotorKernelElement(N [3]int, comp int, a, b, c int, per [3]int, cell [3]float32, qd_P_10, qd_W_10 []float32) float32 {
+	switch comp {
+	default:
+		return 0
+	case 3, 7, 5:
+		return kernelPrefactor * dipoleIntegral(N, (comp-3)%3, a, b, c, per, cell, qd_P_10, qd_W_10)
+	case 6, 4, 8:
+		return -kernelPrefactor * dipoleIntegral(N, (comp-3)%3, a, b, c, per, cell, qd_P_10, qd_W_10)
+	}
+}
+
+// INTERNAL: Integrates r_comp / r^3 over the cell at (a, b, c),
+// summed over the periodic images.
+func dipoleIntegral(N [3]int, comp int, a, b, c int, per [3]int, cell [3]float32, qd_P_10, qd_W_10 []float32) float32 {
+	var result float32
+	qd_P_10_X := qd_P_10[X*10:]
+	qd_P_10_Y := qd_P_10[Y*10:]
+	qd_P_10_Z := qd_P_10[Z*10:]
+	cellX, cellY, cellZ := cell[0], cell[1], cell[2]
+	const cutoff = 10000 // square of the cutoff where the interaction is computed for dipole in the center in stead of magnetized volume
+
+	for cnta := -per[0]; cnta <= per[0]; cnta++ {
+		for cntb := -per[1]; cntb <= per[1]; cntb++ {
+			for cntc := -per[2]; cntc <= per[2]; cntc++ {
+				i := a + cnta*N[0]/2
+				j := b + cntb*N[1]/2
+				k := c + cntc*N[2]/2
+				r2_int := i*i + j*j + k*k
+
+				if r2_int < cutoff {
+					for cnt1 := 0; cnt1 < 10; cnt1++ {
+						x := float32(i)*cellX + qd_P_10_X[cnt1]
+						for cnt2 := 0; cnt2 < 10; cnt2++ {
+							y := float32(j)*cellY + qd_P_10_Y[cnt2]
+							for cnt3 := 0; cnt3 < 10; cnt3++ {
+								z := float32(k)*cellZ + qd_P_10_Z[cnt3]
+								r := [3]float32{x, y, z}
+								result += cellX * cellY * cellZ / 8 *
+									qd_W_10[cnt1] * qd_W_10[cnt2] * qd_W_10[cnt3] *
+									(r[comp] * powf(x*x+y*y+z*z, -1.5))
+							}
+						}
+					}
+				} else {
+					r2 := (float32(i)*cellX)*(float32(i)*cellX) + (float32(j)*cellY)*(float32(j)*cellY) + (float32(k)*cellZ)*(float32(k)*cellZ)
+					// NOTE: like the CUDA version, this uses cellX for all components.
+					ijk := [3]int{i, j, k}
+					result += cellX * cellY * cellZ *
+						((float32(ijk[comp]) * cellX) * powf(r2, -1.5))
+				}
+				if r2_int == 0 {
+					result = 0
+				}
+			}
+		}
+	}
+	return result
+}
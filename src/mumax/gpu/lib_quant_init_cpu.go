@@ -0,0 +1,133 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+// Author: Rémy
+
+package gpu
+
+// Pure-Go version of uniform.cu, vortex.cu and random.cu
+
+import (
+	"math"
+	"math/rand"
+	. "mumax/common"
+)
+
+// Seed of the random generator, like random.cu.
+const regionRandomSeed = 123
+
+// Region index stored in the regions array at i.
+func regionIndex(regions []float32, i int) int {
+	return int(math.RoundToEven(float64(regions[i])))
+}
+
+// Initialise scalar quantity with uniform value in each region
+func InitScalarQuantUniformRegion(initValues []float32, S, regions *Array) {
+	s, r := S.list, regions.list
+	for i := 0; i < regions.PartLen3D(); i++ {
+		regionIndex := regionIndex(r, i)
+		if regionIndex < len(initValues) {
+			s[i] = initValues[regionIndex]
+		}
+	}
+}
+
+// Initialise scalar quantity with uniform value in each region
+func InitVectorQuantUniformRegion(S, regions *Array, initValuesX, initValuesY, initValuesZ []float32) {
+	// user X,Y,Z are internal Z,Y,X
+	Sx, Sy, Sz := S.Comp[Z].list, S.Comp[Y].list, S.Comp[X].list
+	r := regions.list
+	for i := 0; i < regions.PartLen3D(); i++ {
+		regionIndex := regionIndex(r, i)
+		if regionIndex < len(initValuesX) && regionIndex > 0 {
+			Sx[i] = initValuesX[regionIndex]
+			Sy[i] = initValuesY[regionIndex]
+			Sz[i] = initValuesZ[regionIndex]
+		} else {
+			Sx[i] = 0
+			Sy[i] = 0
+			Sz[i] = 1
+		}
+	}
+}
+
+// Initialise scalar quantity with uniform value in each region
+func InitVectorQuantVortexRegion(S, regions *Array, regionsToProceed []bool, center, axis, cellsize []float32, polarity, chirality int, maxRadius float32) {
+	// user X,Y,Z are internal Z,Y,X
+	Sx, Sy, Sz := S.Comp[Z].list, S.Comp[Y].list, S.Comp[X].list
+	r := regions.list
+	Nx, NyPart := regions.PartSize()[2], regions.PartSize()[1]
+	pol, chir := float32(polarity), float32(chirality)
+	axisX, axisY, axisZ := axis[0], axis[1], axis[2]
+
+	for i := 0; i < regions.PartLen3D(); i++ {
+		regionIndex := regionIndex(r, i)
+		if !(regionIndex < len(regionsToProceed) && regionIndex > 0 && regionsToProceed[regionIndex]) {
+			continue
+		}
+		v1X := center[0] - (float32(i%Nx)+0.5)*cellsize[0]
+		v1Y := center[1] - (float32((i/Nx)%NyPart)+0.5)*cellsize[1]
+		v1Z := center[2] - (float32(i/(Nx*NyPart))+0.5)*cellsize[2]
+		vScalaru := v1X*axisX + v1Y*axisY + v1Z*axisZ
+		v1X -= axisX * vScalaru
+		v1Y -= axisY * vScalaru
+		v1Z -= axisZ * vScalaru
+		d := sqrtf(v1X*v1X + v1Y*v1Y + v1Z*v1Z)
+		if !(maxRadius == 0 || d <= maxRadius) {
+			continue
+		}
+		if d < 5e-8 {
+			gauss := float32(math.Exp(-1.0 * float64(d) * float64(d) / 25.0e-18))
+			Sx[i] = axisX*pol*gauss - (1-gauss)*chir*(axisY*v1Z-axisZ*v1Y)/d
+			Sy[i] = axisY*pol*gauss - (1-gauss)*chir*(axisZ*v1X-axisX*v1Z)/d
+			Sz[i] = axisZ*pol*gauss - (1-gauss)*chir*(axisX*v1Y-axisY*v1X)/d
+		} else {
+			Sx[i] = -chir * (axisY*v1Z - axisZ*v1Y) / d
+			Sy[i] = -chir * (axisZ*v1X - axisX*v1Z) / d
+			Sz[i] = -chir * (axisX*v1Y - axisY*v1X) / d
+		}
+	}
+}
+
+// Initialise scalar quantity with random uniform value in each region
+func InitScalarQuantRandomUniformRegion(S, regions *Array, regionsToProceed []bool, max, min float32) {
+	Assert(max != min)
+	if max < min {
+		max, min = min, max
+	}
+	rng := rand.New(rand.NewSource(regionRandomSeed))
+	s, r := S.list, regions.list
+	for i := 0; i < regions.PartLen3D(); i++ {
+		regionIndex := regionIndex(r, i)
+		if regionIndex < len(regionsToProceed) && regionIndex > 0 && regionsToProceed[regionIndex] {
+			s[i] = min + (max-min)*rng.Float32()
+		}
+	}
+}
+
+// Initialise vector quantity with random uniform value in each region
+func InitVectorQuantRandomUniformRegion(S, regions *Array, regionsToProceed []bool) {
+	// user X,Y,Z are internal Z,Y,X
+	Sx, Sy, Sz := S.Comp[Z].list, S.Comp[Y].list, S.Comp[X].list
+	rng := rand.New(rand.NewSource(regionRandomSeed))
+	r := regions.list
+	for i := 0; i < regions.PartLen3D(); i++ {
+		regionIndex := regionIndex(r, i)
+		if regionIndex < len(regionsToProceed) && regionIndex > 0 && regionsToProceed[regionIndex] {
+			Sx[i] = 2*rng.Float32() - 1
+			Sy[i] = 2*rng.Float32() - 1
+			Sz[i] = 2*rng.Float32() - 1
+			norm := sqrtf(Sx[i]*Sx[i] + Sy[i]*Sy[i] + Sz[i]*Sz[i])
+			Sx[i] /= norm
+			Sy[i] /= norm
+			Sz[i] /= norm
+		}
+	}
+}
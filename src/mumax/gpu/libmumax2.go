@@ -5,6 +5,9 @@
 //  Note that you are welcome to modify this code under the condition that you do not remove any
 //  copyright notices and prominently state that you modified it, giving a relevant date.
 
+//go:build !cpu
+// +build !cpu
+
 // This file wraps the core functions of libmultigpu.so.
 // Functions added by add-on modules are wrapped elsewhere.
 // Author: Arne Vansteenkiste, Ben Van de Wiele
@@ -863,12 +866,77 @@ func UniaxialAnisotropyAsync(h, m *Array, KuMask, MsatMask *Array, Ku2_Mu0MSat f
 		(C.int)(h.partLen3D))
 }
 
+// Computes the cubic anisotropy field, stores in h.
+// K1_Mu0MSat, K2_Mu0MSat: K multiplier / (Mu0 * Msat multiplier).
+// c1, c2: the first two (orthogonal, unit) cubic axes.
+func CubicAnisotropyAsync(h, m *Array, K1Mask *Array, K1_Mu0MSat float64, K2Mask *Array, K2_Mu0MSat float64, MsatMask *Array, c1Mask *Array, c1Mul []float64, c2Mask *Array, c2Mul []float64, stream Stream) {
+	C.cubicAnisotropyAsync(
+		(**C.float)(unsafe.Pointer(&(h.Comp[X].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(h.Comp[Y].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(h.Comp[Z].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[X].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[Y].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[Z].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(K1Mask.pointer[0]))),
+		(C.float)(K1_Mu0MSat),
+		(**C.float)(unsafe.Pointer(&(K2Mask.pointer[0]))),
+		(C.float)(K2_Mu0MSat),
+		(**C.float)(unsafe.Pointer(&(MsatMask.pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(c1Mask.Comp[X].pointer[0]))),
+		(C.float)(c1Mul[X]),
+		(**C.float)(unsafe.Pointer(&(c1Mask.Comp[Y].pointer[0]))),
+		(C.float)(c1Mul[Y]),
+		(**C.float)(unsafe.Pointer(&(c1Mask.Comp[Z].pointer[0]))),
+		(C.float)(c1Mul[Z]),
+		(**C.float)(unsafe.Pointer(&(c2Mask.Comp[X].pointer[0]))),
+		(C.float)(c2Mul[X]),
+		(**C.float)(unsafe.Pointer(&(c2Mask.Comp[Y].pointer[0]))),
+		(C.float)(c2Mul[Y]),
+		(**C.float)(unsafe.Pointer(&(c2Mask.Comp[Z].pointer[0]))),
+		(C.float)(c2Mul[Z]),
+		(*C.CUstream)(unsafe.Pointer(&(stream[0]))),
+		(C.int)(h.partLen3D))
+}
+
 // 6-neighbor exchange field.
 // Aex2_mu0Msatmul: 2 * Aex / Mu0 * Msat.multiplier
-func Exchange6Async(h, m, msat, aex *Array, Aex2_mu0Msatmul float64, cellSize []float64, periodic []int, stream Stream) {
+// scale: 3-component mask, component c scales the exchange with the next cell along axis c (nil mask: 1).
+func Exchange6Async(h, m, msat, aex, scale *Array, Aex2_mu0Msatmul float64, cellSize []float64, periodic []int, stream Stream) {
 	//void exchange6Async(float** hx, float** hy, float** hz, float** mx, float** my, float** mz, float Aex, int N0, int N1Part, int N2, int periodic0, int periodic1, int periodic2, float cellSizeX, float cellSizeY, float cellSizeZ, CUstream* streams);
 	CheckSize(h.Size3D(), m.Size3D())
+	Assert(scale.NComp() == 3)
 	C.exchange6Async(
+		(**C.float)(unsafe.Pointer(&(h.Comp[X].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(h.Comp[Y].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(h.Comp[Z].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[X].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[Y].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[Z].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(msat.pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(aex.pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(scale.Comp[X].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(scale.Comp[Y].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(scale.Comp[Z].pointer[0]))),
+		(C.float)(Aex2_mu0Msatmul),
+		(C.int)(h.PartSize()[X]),
+		(C.int)(h.PartSize()[Y]),
+		(C.int)(h.PartSize()[Z]),
+		(C.int)(periodic[X]),
+		(C.int)(periodic[Y]),
+		(C.int)(periodic[Z]),
+		(C.float)(cellSize[X]),
+		(C.float)(cellSize[Y]),
+		(C.float)(cellSize[Z]),
+		(*C.CUstream)(unsafe.Pointer(&(stream[0]))))
+}
+
+// Dzyaloshinskii-Moriya field of the interfacial (dind) and bulk (dbulk) DMI,
+// with the boundary conditions of exchange + DMI at the edges and next to cells with Msat = 0.
+// Aex2_mu0Msatmul: 2 * Aex / Mu0 * Msat.multiplier, as for Exchange6Async.
+// Dind_mu0Msatmul, Dbulk_mu0Msatmul: D / Mu0 * Msat.multiplier.
+func DMIAsync(h, m, msat, aex *Array, Aex2_mu0Msatmul float64, dind *Array, Dind_mu0Msatmul float64, dbulk *Array, Dbulk_mu0Msatmul float64, cellSize []float64, periodic []int, stream Stream) {
+	CheckSize(h.Size3D(), m.Size3D())
+	C.dmiAsync(
 		(**C.float)(unsafe.Pointer(&(h.Comp[X].pointer[0]))),
 		(**C.float)(unsafe.Pointer(&(h.Comp[Y].pointer[0]))),
 		(**C.float)(unsafe.Pointer(&(h.Comp[Z].pointer[0]))),
@@ -878,6 +946,10 @@ func Exchange6Async(h, m, msat, aex *Array, Aex2_mu0Msatmul float64, cellSize []
 		(**C.float)(unsafe.Pointer(&(msat.pointer[0]))),
 		(**C.float)(unsafe.Pointer(&(aex.pointer[0]))),
 		(C.float)(Aex2_mu0Msatmul),
+		(**C.float)(unsafe.Pointer(&(dind.pointer[0]))),
+		(C.float)(Dind_mu0Msatmul),
+		(**C.float)(unsafe.Pointer(&(dbulk.pointer[0]))),
+		(C.float)(Dbulk_mu0Msatmul),
 		(C.int)(h.PartSize()[X]),
 		(C.int)(h.PartSize()[Y]),
 		(C.int)(h.PartSize()[Z]),
@@ -890,6 +962,68 @@ func Exchange6Async(h, m, msat, aex *Array, Aex2_mu0Msatmul float64, cellSize []
 		(*C.CUstream)(unsafe.Pointer(&(stream[0]))))
 }
 
+// Interlayer exchange field: each cell with dz != 0 is coupled
+// to the cell dz layers above it (along internal X = user z).
+// J1_mu0MsatMul_t, J2_mu0MsatMul_t: J / (Mu0 * Msat.multiplier * cell thickness).
+func InterlayerExchangeAsync(h, m, msat, J1 *Array, J1_mu0MsatMul_t float64, J2 *Array, J2_mu0MsatMul_t float64, dz *Array, stream Stream) {
+	CheckSize(h.Size3D(), m.Size3D())
+	CheckSize(h.Size3D(), dz.Size3D())
+	C.interlayerExchangeAsync(
+		(**C.float)(unsafe.Pointer(&(h.Comp[X].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(h.Comp[Y].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(h.Comp[Z].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[X].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[Y].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[Z].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(msat.pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(J1.pointer[0]))),
+		(C.float)(J1_mu0MsatMul_t),
+		(**C.float)(unsafe.Pointer(&(J2.pointer[0]))),
+		(C.float)(J2_mu0MsatMul_t),
+		(**C.float)(unsafe.Pointer(&(dz.pointer[0]))),
+		(C.int)(h.PartSize()[Y]*h.PartSize()[Z]),
+		(*C.CUstream)(unsafe.Pointer(&(stream[0]))),
+		(C.int)(h.partLen3D))
+}
+
+// Spin-orbit torque of a spin Hall current j in a heavy-metal layer
+// with interface normal n, polarized along s = j x n.
+// LL form of the Gilbert torque a m x (s x m) + b s x m:
+//	[(a + alpha b) m x (s x m) + (b - alpha a) s x m] / (1 + alpha²)
+// with a = preDL * xiDL * theta * |j| / (msat * t), idem for b with preFL and xiFL,
+// the masks times their multipliers folded into preDL and preFL.
+func SOTAsync(torque, m, msat, j *Array, jMul []float64, theta, xiDL *Array, preDL float64, xiFL *Array, preFL float64, t, alpha *Array, alphaMul float64, n []float64, stream Stream) {
+	CheckSize(torque.Size3D(), m.Size3D())
+	Assert(j.NComp() == 3)
+	C.sotAsync(
+		(**C.float)(unsafe.Pointer(&(torque.Comp[X].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(torque.Comp[Y].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(torque.Comp[Z].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[X].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[Y].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(m.Comp[Z].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(msat.pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(j.Comp[X].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(j.Comp[Y].pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(j.Comp[Z].pointer[0]))),
+		(C.float)(jMul[X]),
+		(C.float)(jMul[Y]),
+		(C.float)(jMul[Z]),
+		(**C.float)(unsafe.Pointer(&(theta.pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(xiDL.pointer[0]))),
+		(C.float)(preDL),
+		(**C.float)(unsafe.Pointer(&(xiFL.pointer[0]))),
+		(C.float)(preFL),
+		(**C.float)(unsafe.Pointer(&(t.pointer[0]))),
+		(**C.float)(unsafe.Pointer(&(alpha.pointer[0]))),
+		(C.float)(alphaMul),
+		(C.float)(n[X]),
+		(C.float)(n[Y]),
+		(C.float)(n[Z]),
+		(*C.CUstream)(unsafe.Pointer(&(stream[0]))),
+		(C.int)(torque.partLen3D))
+}
+
 // Calculates the electrical current density j.
 // Efield: electrical field
 // r, rmul: electrical resistivity (scalar) and multiplier
@@ -0,0 +1,1068 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+// This file implements the core functions of libmumax2 in pure Go,
+// for use without CUDA. Each function mirrors the corresponding
+// CUDA kernel in libmumax2/, including its treatment of NULL masks.
+
+package gpu
+
+import (
+	"math"
+	. "mumax/common"
+)
+
+// Adds 2 arrays: dst = a + b
+func Add(dst, a, b *Array) {
+	CheckSize(dst.size4D, a.size4D)
+	d, A, B := dst.list, a.list, b.list
+	for i := 0; i < dst.partLen4D; i++ {
+		d[i] = A[i] + B[i]
+	}
+}
+
+// Multiply 2 arrays: dst = a * b
+func Mul(dst, a, b *Array) {
+	CheckSize(dst.size4D, a.size4D)
+	d, A, B := dst.list, a.list, b.list
+	for i := 0; i < dst.partLen4D; i++ {
+		d[i] = A[i] * B[i]
+	}
+}
+
+// Divide 2 arrays: dst = a / b; _if_ b = 0 _then_ dst = 0
+func Div(dst, a, b *Array) {
+	CheckSize(dst.size4D, a.size4D)
+	d, A, B := dst.list, a.list, b.list
+	for i := 0; i < dst.partLen4D; i++ {
+		d[i] = fdivZero(A[i], maskUnity(B, i))
+	}
+}
+
+// Divide and Multiply by the array raised to the Power : dst = pow(c, p) * a / b; _if_ b = 0 _then_ dst = a, _if_c = 0 _then_ dst = 0
+func DivMulPow(dst, a, b, c *Array, p float64) {
+	CheckSize(dst.size4D, a.size4D)
+	d, A, B, C := dst.list, a.list, b.list, c.list
+	for i := 0; i < dst.partLen4D; i++ {
+		val := fdivZero(A[i], maskUnity(B, i))
+		cc := maskUnity(C, i)
+		if cc != 0 {
+			cc = float32(math.Pow(float64(cc), float64(float32(p))))
+		}
+		d[i] = val * cc
+	}
+}
+
+// Synchronous Dot product: C = AiBi
+func Dot(dst, a, b *Array) {
+	CheckSize(dst.size3D, a.size3D)
+	d := dst.Comp[X].list
+	ax, ay, az := a.Comp[X].list, a.Comp[Y].list, a.Comp[Z].list
+	bx, by, bz := b.Comp[X].list, b.Comp[Y].list, b.Comp[Z].list
+	for i := 0; i < dst.partLen3D; i++ {
+		d[i] = ax[i]*bx[i] + ay[i]*by[i] + az[i]*bz[i]
+	}
+}
+
+// Synchronous Singed Dot product: C = sign(BC) * (AB)
+func DotSign(dst, a, b, c *Array) {
+	CheckSize(dst.size3D, a.size3D)
+	d := dst.Comp[X].list
+	ax, ay, az := a.Comp[X].list, a.Comp[Y].list, a.Comp[Z].list
+	bx, by, bz := b.Comp[X].list, b.Comp[Y].list, b.Comp[Z].list
+	cx, cy, cz := c.Comp[X].list, c.Comp[Y].list, c.Comp[Z].list
+	for i := 0; i < dst.partLen3D; i++ {
+		dotP := ax[i]*bx[i] + ay[i]*by[i] + az[i]*bz[i]
+		sign := -(bx[i]*cx[i] + by[i]*cy[i] + bz[i]*cz[i]) // !!!
+		d[i] = float32(math.Copysign(float64(dotP), float64(sign)))
+	}
+}
+
+// Asynchronous multiply-add: a += mulB*b
+// b may contain NULL pointers, implemented as all 1's.
+func MAdd1Async(a, b *Array, mulB float32, stream Stream) {
+	A, B := a.list, b.list
+	for i := 0; i < a.partLen4D; i++ {
+		A[i] += mulB * maskUnity(B, i)
+	}
+}
+
+// Asynchronous multiply-add: a += mulB*b + mulC*c
+// b,c may contain NULL pointers, implemented as all 1's.
+func MAdd2Async(a, b *Array, mulB float32, c *Array, mulC float32, stream Stream) {
+	A, B, C := a.list, b.list, c.list
+	for i := 0; i < a.partLen4D; i++ {
+		A[i] += mulB*maskUnity(B, i) + mulC*maskUnity(C, i)
+	}
+}
+
+// 3-vector multiply-add: dst_i = a_i + mulB_i*b_i
+// b may contain NULL pointers, implemented as all 1's.
+func VecMadd(dst, a, b *Array, mulB []float64) {
+	for c := 0; c < 3; c++ {
+		d, A, B := dst.Comp[c].list, a.Comp[c].list, b.Comp[c].list
+		mul := float32(mulB[c])
+		for i := 0; i < dst.partLen3D; i++ {
+			d[i] = A[i] + mul*maskUnity(B, i)
+		}
+	}
+}
+
+func Madd(dst, a, b *Array, mulB float64) {
+	d, A, B := dst.list, a.list, b.list
+	mul := float32(mulB)
+	for i := 0; i < dst.partLen4D; i++ {
+		d[i] = A[i] + mul*maskUnity(B, i)
+	}
+}
+
+// Multiply-add: dst = a + mul* (b + c)
+// b may NOT contain NULL pointers!
+func AddMadd(dst, a, b, c *Array, mul float32) {
+	d, A, B, C := dst.list, a.list, b.list, c.list
+	for i := 0; i < dst.partLen4D; i++ {
+		d[i] = A[i] + mul*(B[i]+C[i])
+	}
+}
+
+// Complex multiply add.
+// dst and src contain complex numbers (interleaved format)
+// kern contains real numbers
+//
+//	dst[i] += scale * kern[i] * src[i]
+func CMaddAsync(dst *Array, scale complex64, kern, src *Array, stream Stream) {
+	CheckSize(dst.Size3D(), src.Size3D())
+	AssertMsg(dst.Len() == src.Len(), "src-dst")
+	AssertMsg(dst.Len() == 2*kern.Len(), "dst-kern")
+	a, b := real(scale), imag(scale)
+	d, k, s := dst.list, kern.list, src.list
+	for i := 0; i < kern.PartLen3D(); i++ {
+		e := 2 * i
+		Sa, Sb := s[e], s[e+1]
+		d[e] += k[i] * (a*Sa - b*Sb)
+		d[e+1] += k[i] * (b*Sa + a*Sb)
+	}
+}
+
+// dst[i] = a[i]*mulA + b[i]*mulB
+func LinearCombination2Async(dst *Array, a *Array, mulA float32, b *Array, mulB float32, stream Stream) {
+	dstlen := dst.Len()
+	Assert(dstlen == a.Len() && dstlen == b.Len())
+	d, A, B := dst.list, a.list, b.list
+	for i := 0; i < dst.partLen4D; i++ {
+		d[i] = mulA*A[i] + mulB*B[i]
+	}
+}
+
+// dst[i] = a[i]*mulA + b[i]*mulB + c[i]*mulC
+func LinearCombination3Async(dst *Array, a *Array, mulA float32, b *Array, mulB float32, c *Array, mulC float32, stream Stream) {
+	dstlen := dst.Len()
+	Assert(dstlen == a.Len() && dstlen == b.Len() && dstlen == c.Len())
+	d, A, B, C := dst.list, a.list, b.list, c.list
+	for i := 0; i < dst.partLen4D; i++ {
+		d[i] = mulA*A[i] + mulB*B[i] + mulC*C[i]
+	}
+}
+
+// dst[i] = a[i]*mulA + b[i]*mulB + c[i]*mulC
+func LinearCombination3(dst *Array, a *Array, mulA float32, b *Array, mulB float32, c *Array, mulC float32) {
+	LinearCombination3Async(dst, a, mulA, b, mulB, c, mulC, dst.Stream)
+}
+
+// Calculates:
+//
+//	τ = (m x h) - α m  x (m x h)
+//
+// If h = H/Msat, then τ = 1/gamma*dm/dt
+func Torque(τ, m, h, αMap *Array, αMul float32) {
+	tx, ty, tz := τ.Comp[X].list, τ.Comp[Y].list, τ.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	hx, hy, hz := h.Comp[X].list, h.Comp[Y].list, h.Comp[Z].list
+	αList := αMap.list
+	for i := 0; i < m.partLen3D; i++ {
+		alpha := αMul * maskUnity(αList, i)
+		Mx, My, Mz := mx[i], my[i], mz[i]
+		Hx, Hy, Hz := hx[i], hy[i], hz[i]
+
+		_mxHx := My*Hz - Hy*Mz
+		_mxHy := -Mx*Hz + Hx*Mz
+		_mxHz := Mx*Hy - Hx*My
+
+		_mxmxHx := -My*_mxHz + _mxHy*Mz
+		_mxmxHy := +Mx*_mxHz - _mxHx*Mz
+		_mxmxHz := -Mx*_mxHy + _mxHx*My
+
+		gilb := 1 / (1 + alpha*alpha)
+		tx[i] = gilb * (_mxHx + _mxmxHx*alpha)
+		ty[i] = gilb * (_mxHy + _mxmxHy*alpha)
+		tz[i] = gilb * (_mxHz + _mxmxHz*alpha)
+	}
+}
+
+// Normalize
+func Normalize(m, normMap *Array) {
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	normList := normMap.list
+	for i := 0; i < m.partLen3D; i++ {
+		var scale float32
+		if maskUnity(normList, i) != 0 {
+			scale = 1
+		}
+		Mnorm := sqrtf(mx[i]*mx[i] + my[i]*my[i] + mz[i]*mz[i])
+		if Mnorm != 0 {
+			scale /= Mnorm
+		} else {
+			scale = 0
+		}
+		mx[i] *= scale
+		my[i] *= scale
+		mz[i] *= scale
+	}
+}
+
+// Decompose vector to unit vector and length
+func Decompose(Mf *Array, m *Array, msat *Array, msatMul float32) {
+	Mx, My, Mz := Mf.Comp[X].list, Mf.Comp[Y].list, Mf.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	ms := msat.list
+	for i := 0; i < m.partLen3D; i++ {
+		Ms := sqrtf(Mx[i]*Mx[i] + My[i]*My[i] + Mz[i]*Mz[i])
+		if Ms == 0 {
+			mx[i], my[i], mz[i], ms[i] = 0, 0, 0, 0
+			continue
+		}
+		mx[i] = Mx[i] / Ms
+		my[i] = My[i] / Ms
+		mz[i] = Mz[i] / Ms
+		ms[i] = Ms
+	}
+}
+
+// INTERNAL: Emulates the block-wise reductions of reduce.cu.
+// Block b reduces elements [b*2*threadsPerBlock, (b+1)*2*threadsPerBlock) of N,
+// starting from init, and stores its result in out[b].
+func partialReduce(out *Array, blocks, threadsPerBlock, N int, init float32, f func(i int) float32, reduce func(a, b float32) float32) {
+	o := out.list
+	chunk := 2 * threadsPerBlock
+	for b := 0; b < blocks; b++ {
+		acc := init
+		for i := b * chunk; i < (b+1)*chunk && i < N; i++ {
+			acc = reduce(acc, f(i))
+		}
+		o[b] = acc
+	}
+}
+
+func sum(a, b float32) float32 { return a + b }
+
+func max32(a, b float32) float32 {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+func min32(a, b float32) float32 {
+	if b < a {
+		return b
+	}
+	return a
+}
+
+func abs32(a float32) float32 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// Partial sums (see reduce.h)
+func PartialSum(in, out *Array, blocks, threadsPerBlock, N int) {
+	l := in.list
+	partialReduce(out, blocks, threadsPerBlock, N, 0, func(i int) float32 { return l[i] }, sum)
+}
+
+// Partial dot products (see reduce.h)
+func PartialSDot(in1, in2, out *Array, blocks, threadsPerBlock, N int) {
+	a, b := in1.list, in2.list
+	partialReduce(out, blocks, threadsPerBlock, N, 0, func(i int) float32 { return a[i] * b[i] }, sum)
+}
+
+// Partial maxima (see reduce.h)
+func PartialMax(in, out *Array, blocks, threadsPerBlock, N int) {
+	l := in.list
+	partialReduce(out, blocks, threadsPerBlock, N, float32(math.Inf(-1)), func(i int) float32 { return l[i] }, max32)
+}
+
+// Partial minima (see reduce.h)
+func PartialMin(in, out *Array, blocks, threadsPerBlock, N int) {
+	l := in.list
+	partialReduce(out, blocks, threadsPerBlock, N, float32(math.Inf(1)), func(i int) float32 { return l[i] }, min32)
+}
+
+// Partial maxima of absolute values (see reduce.h)
+func PartialMaxAbs(in, out *Array, blocks, threadsPerBlock, N int) {
+	l := in.list
+	partialReduce(out, blocks, threadsPerBlock, N, 0, func(i int) float32 { return abs32(l[i]) }, max32)
+}
+
+// Partial maximum difference between arrays (see reduce.h)
+func PartialMaxDiff(a, b, out *Array, blocks, threadsPerBlock, N int) {
+	A, B := a.list, b.list
+	partialReduce(out, blocks, threadsPerBlock, N, 0, func(i int) float32 { return abs32(A[i] - B[i]) }, max32)
+}
+
+// Partial maximum difference between arrays (see reduce.h)
+func PartialMaxSum(a, b, out *Array, blocks, threadsPerBlock, N int) {
+	A, B := a.list, b.list
+	partialReduce(out, blocks, threadsPerBlock, N, 0, func(i int) float32 { return abs32(A[i] + B[i]) }, max32)
+}
+
+// Partial maximum of Euclidian norm squared (see reduce.h)
+func PartialMaxNorm3Sq(x, y, z, out *Array, blocks, threadsPerBlock, N int) {
+	X, Y, Z := x.list, y.list, z.list
+	partialReduce(out, blocks, threadsPerBlock, N, 0, func(i int) float32 { return X[i]*X[i] + Y[i]*Y[i] + Z[i]*Z[i] }, max32)
+}
+
+// Partial maximum of Euclidian norm squared of difference between two 3-vector arrays(see reduce.h)
+func PartialMaxNorm3SqDiff(x1, y1, z1, x2, y2, z2, out *Array, blocks, threadsPerBlock, N int) {
+	X1, Y1, Z1 := x1.list, y1.list, z1.list
+	X2, Y2, Z2 := x2.list, y2.list, z2.list
+	partialReduce(out, blocks, threadsPerBlock, N, 0, func(i int) float32 {
+		dx, dy, dz := X1[i]-X2[i], Y1[i]-Y2[i], Z1[i]-Z2[i]
+		return dx*dx + dy*dy + dz*dz
+	}, max32)
+}
+
+// Copy from src to dst, which have different size3D[Z].
+// If dst is smaller, the src input is cropped to the right size.
+// If dst is larger, the src input is padded with zeros to the right size.
+func CopyPadZ(dst, src *Array) {
+	CopyPadZAsync(dst, src, dst.Stream)
+}
+
+func CopyPadZAsync(dst, src *Array, stream Stream) {
+	Assert(
+		dst.size4D[0] == src.size4D[0] &&
+			dst.size3D[0] == src.size3D[0] &&
+			dst.size3D[1] == src.size3D[1])
+
+	D2 := dst.size3D[2]
+	S0 := src.size4D[0] * src.size3D[0] // NComp * Size0
+	S1 := src.partSize[1]
+	S2 := src.size3D[2]
+	d, s := dst.list, src.list
+	for i := 0; i < S0; i++ {
+		for j := 0; j < S1; j++ {
+			for k := 0; k < D2; k++ {
+				if k < S2 {
+					d[i*S1*D2+j*D2+k] = s[i*S1*S2+j*S2+k]
+				} else {
+					d[i*S1*D2+j*D2+k] = 0
+				}
+			}
+		}
+	}
+}
+
+// Padding of a 3D matrix.
+// Copy from src to dst, which have different size3D.
+// If dst is smaller, the src input is cropped to the right size.
+// If dst is larger, the remaining elements of dst are left untouched.
+func CopyPad3D(dst, src *Array) {
+	CopyPad3DAsync(dst, src)
+}
+
+func CopyPad3DAsync(dst, src *Array) {
+	Assert(dst.size4D[0] == src.size4D[0])
+	copyPad3D(dst, src, src.size3D)
+}
+
+func CopyUnPad3D(dst, src *Array) {
+	Assert(dst.size4D[0] == src.size4D[0])
+	copyPad3D(dst, src, dst.size3D)
+}
+
+// INTERNAL: copies the region [0,N0)x[0,N1)x[0,N2) of each component from src to dst.
+func copyPad3D(dst, src *Array, N []int) {
+	Ncomp := dst.size4D[0]
+	D0, D1, D2 := dst.size3D[0], dst.size3D[1], dst.size3D[2]
+	S0, S1, S2 := src.size3D[0], src.size3D[1], src.size3D[2]
+	for c := 0; c < Ncomp; c++ {
+		d := dst.list[c*D0*D1*D2:]
+		s := src.list[c*S0*S1*S2:]
+		for i := 0; i < N[0]; i++ {
+			for j := 0; j < N[1]; j++ {
+				for k := 0; k < N[2]; k++ {
+					d[i*D1*D2+j*D2+k] = s[i*S1*S2+j*S2+k]
+				}
+			}
+		}
+	}
+}
+
+// Insert from src into a block in dst
+// E.g.:
+// 2x2 src, block = 1, 2x6 dst:
+// [ 0 0  S1 S2  0 0 ]
+// [ 0 0  S3 S4  0 0 ]
+func InsertBlockZ(dst, src *Array, block int) {
+	InsertBlockZAsync(dst, src, block, dst.Stream)
+}
+
+func InsertBlockZAsync(dst, src *Array, block int, stream Stream) {
+	D2 := dst.size3D[2]
+	S0 := src.size4D[0] * src.size3D[0] // NComp * Size0
+	S1 := src.partSize[1]
+	S2 := src.size3D[2]
+	d, s := dst.list, src.list
+	for i := 0; i < S0; i++ {
+		for j := 0; j < S1; j++ {
+			for k := 0; k < S2; k++ {
+				d[i*S1*D2+j*D2+block*S2+k] = s[i*S1*S2+j*S2+k]
+			}
+		}
+	}
+}
+
+func ZeroArrayAsync(A *Array, stream Stream) {
+	l := A.list
+	for i := 0; i < A.PartLen4D(); i++ {
+		l[i] = 0
+	}
+}
+
+// Extract from src a block to dst
+// E.g.:
+// 2x2 dst, block = 1, 2x6 src:
+// [ 0 0  D1 D2  0 0 ]
+// [ 0 0  D3 D4  0 0 ]
+func ExtractBlockZ(dst, src *Array, block int) {
+	D0 := dst.size4D[0] * dst.size3D[0] // NComp * Size0
+	D1 := dst.partSize[1]
+	D2 := dst.size3D[2]
+	S2 := src.size3D[2]
+	d, s := dst.list, src.list
+	for i := 0; i < D0; i++ {
+		for j := 0; j < D1; j++ {
+			for k := 0; k < D2; k++ {
+				d[i*D1*D2+j*D2+k] = s[i*D1*S2+j*S2+block*D2+k]
+			}
+		}
+	}
+}
+
+// INTERNAL: Transposes N0 matrices of N2xN1 complex numbers.
+// The input rows have length N1, the output rows have length N2out >= N2.
+// Only input columns < N1in are used.
+//
+//	out[x][a][b] = in[x][b][a], a < N1in, b < N2
+func transposeComplex(out, in []float32, N0, N1, N2, N1in, N2out int) {
+	for x := 0; x < N0; x++ {
+		for a := 0; a < N1in; a++ {
+			for b := 0; b < N2; b++ {
+				o := 2 * (x*N1in*N2out + a*N2out + b)
+				I := 2 * (x*N1*N2 + b*N1 + a)
+				out[o] = in[I]
+				out[o+1] = in[I+1]
+			}
+		}
+	}
+}
+
+// Transpose parts on each device individually.
+func TransposeComplexYZPart(out, in *Array) {
+	TransposeComplexYZPartAsync(out, in, out.Stream)
+}
+
+func TransposeComplexYZPartAsync(out, in *Array, stream Stream) {
+	Assert(
+		out.size4D[0] == in.size4D[0] &&
+			out.size3D[0] == in.size3D[0] &&
+			out.size3D[1]*out.size3D[2] == in.size3D[2]*in.size3D[1])
+
+	N1 := in.size3D[2] / 2 // number of complex
+	N2 := in.partSize[1]
+	transposeComplex(out.list, in.list, in.size4D[0]*in.size3D[0], N1, N2, N1, N2)
+}
+
+func TransposeComplexYZSingleGPUFWAsync(out, in *Array, stream Stream) {
+	N1 := in.size3D[2] / 2 // number of complex
+	N2 := in.partSize[1]
+	transposeComplex(out.list, in.list, in.size4D[0]*in.size3D[0], N1, N2, N1, out.size3D[2]/2)
+}
+
+func TransposeComplexYZSingleGPUINVAsync(out, in *Array, stream Stream) {
+	N1 := in.size3D[2] / 2 // number of complex
+	N2 := in.partSize[1]
+	N1in := out.size3D[1]
+	transposeComplex(out.list, in.list, in.size4D[0]*in.size3D[0], N1, N2, N1in, N2)
+}
+
+// this function has only different input for x- and y components
+func TransposeComplexYZPart_inv(out, in *Array) {
+	Assert(
+		out.size4D[0] == in.size4D[0] &&
+			out.size3D[0] == in.size3D[0] &&
+			out.size3D[1]*out.size3D[2] == in.size3D[2]*in.size3D[1])
+
+	N1 := in.partSize[1]
+	N2 := in.size3D[2] / 2 // number of complex
+	transposeComplex(out.list, in.list, in.size4D[0]*in.size3D[0], N1, N2, N1, N2)
+}
+
+// Point-wise 3D micromagnetic kernel multiplication in Fourier space.
+// Overwrites M (in Fourier space, of course) with the result:
+//
+//	|Mx|   |Kxx Kxy Kxz|   |Mx|
+//	|My| = |Kxy Kyy Kyz| * |My|
+//	|Mz|   |Kxz Kyz Kzz|   |Mz|
+//
+// The kernel is symmetric.
+func KernelMulMicromag3DAsync(fftMx, fftMy, fftMz, fftKxx, fftKyy, fftKzz, fftKyz, fftKxz, fftKxy *Array, stream Stream) {
+	Assert(fftMx.size4D[0] == 1 &&
+		fftKxx.size4D[0] == 1 &&
+		fftMx.Len() == 2*fftKxx.Len())
+
+	mx, my, mz := fftMx.list, fftMy.list, fftMz.list
+	kxx, kyy, kzz := fftKxx.list, fftKyy.list, fftKzz.list
+	kyz, kxz, kxy := fftKyz.list, fftKxz.list, fftKxy.list
+	for i := 0; i < fftMx.partLen3D/2; i++ {
+		e := 2 * i
+		reMx, imMx := mx[e], mx[e+1]
+		reMy, imMy := my[e], my[e+1]
+		reMz, imMz := mz[e], mz[e+1]
+		Kxx, Kyy, Kzz := kxx[i], kyy[i], kzz[i]
+		Kyz, Kxz, Kxy := kyz[i], kxz[i], kxy[i]
+		mx[e] = reMx*Kxx + reMy*Kxy + reMz*Kxz
+		mx[e+1] = imMx*Kxx + imMy*Kxy + imMz*Kxz
+		my[e] = reMx*Kxy + reMy*Kyy + reMz*Kyz
+		my[e+1] = imMx*Kxy + imMy*Kyy + imMz*Kyz
+		mz[e] = reMx*Kxz + reMy*Kyz + reMz*Kzz
+		mz[e+1] = imMx*Kxz + imMy*Kyz + imMz*Kzz
+	}
+}
+
+// Tile sizes of kernelmul_micromag2.cu.
+const (
+	kernMulBlockK = 32
+	kernMulBlockJ = 8
+)
+
+// INTERNAL: Emulates the tiled kernel loads of kernelmul_micromag2.cu:
+// returns the tile column that thread (Bk, k) multiplies with,
+// for the lower and mirrored upper half of the row.
+func kernMulKmax(N2, Bk int) int {
+	if N2/4-(Bk+1)*kernMulBlockK/2 >= 0 {
+		return kernMulBlockK / 2
+	}
+	return (Bk+1)*kernMulBlockK/2 - N2/4
+}
+
+// Point-wise 3D micromagnetic kernel multiplication in Fourier space.
+// Output is saved in out (in Fourier space, of course) with the result, can be in-place or out-of-place:
+//
+//	|outx|   |Kxx Kxy Kxz|   |Mx|
+//	|outy| = |Kxy Kyy Kyz| * |My|
+//	|outz|   |Kxz Kyz Kzz|   |Mz|
+//
+// The kernel is symmetric.
+func KernelMulMicromag3D2Async(fftMx, fftMy, fftMz, fftKxx, fftKyy, fftKzz, fftKyz, fftKxz, fftKxy, outx, outy, outz *Array, stream Stream) {
+	Assert(fftMx.size4D[0] == 1 &&
+		fftKxx.size4D[0] == 1)
+
+	N0, N1, N2 := fftMx.partSize[0], fftMx.partSize[1], fftMx.partSize[2]
+	mx, my, mz := fftMx.list, fftMy.list, fftMz.list
+	ox, oy, oz := outx.list, outy.list, outz.list
+	kern := [6][]float32{fftKxx.list, fftKxy.list, fftKxz.list, fftKyy.list, fftKyz.list, fftKzz.list}
+	const (
+		XX = iota
+		XY
+		XZ
+		YY
+		YZ
+		ZZ
+	)
+
+	var tile [6][kernMulBlockJ][kernMulBlockK/2 + 1]float32
+	N2K := N2 / 2
+	gridK := (N2/2-1)/kernMulBlockK + 1
+	gridJ := (N1-1)/kernMulBlockJ + 1
+
+	for Bk := 0; Bk < gridK; Bk++ {
+		for Bj := 0; Bj < gridJ; Bj++ {
+			kmax := kernMulKmax(N2, Bk)
+			for i := 0; i < N0/2+1; i++ {
+				// load tile
+				for j := 0; j < kernMulBlockJ; j++ {
+					for k := 0; k < kernMulBlockK/2+1; k++ {
+						K, J := Bk*kernMulBlockK+k, Bj*kernMulBlockJ+j
+						index := i*N1*N2K + J*N2K + Bk*kernMulBlockK/2 + k
+						if J < N1 && K < N2/2+1 && index < len(kern[XX]) {
+							for c := range tile {
+								tile[c][j][k] = kern[c][index]
+							}
+						}
+					}
+				}
+				// multiply
+				for j := 0; j < kernMulBlockJ; j++ {
+					for k := 0; k < kernMulBlockK; k++ {
+						K, J := Bk*kernMulBlockK+k, Bj*kernMulBlockJ+j
+						if !(J < N1 && K < N2/2) {
+							continue
+						}
+						t := func(c, kk int) float32 { return tile[c][j][kk] }
+						kk := k / 2
+						ki := kmax - k/2
+
+						index := i*N1*N2 + J*N2 + K
+						Mx, My, Mz := mx[index], my[index], mz[index]
+						ox[index] = t(XX, kk)*Mx + t(XY, kk)*My + t(XZ, kk)*Mz
+						oy[index] = t(XY, kk)*Mx + t(YY, kk)*My + t(YZ, kk)*Mz
+						oz[index] = t(XZ, kk)*Mx + t(YZ, kk)*My + t(ZZ, kk)*Mz
+
+						index = i*N1*N2 + J*N2 + N2 - Bk*kernMulBlockK - 2*kmax + k
+						Mx, My, Mz = mx[index], my[index], mz[index]
+						ox[index] = t(XX, ki)*Mx - t(XY, ki)*My + t(XZ, ki)*Mz
+						oy[index] = -t(XY, ki)*Mx + t(YY, ki)*My - t(YZ, ki)*Mz
+						oz[index] = t(XZ, ki)*Mx - t(YZ, ki)*My + t(ZZ, ki)*Mz
+
+						if i != 0 && i != (N0/2+1) {
+							index = (N0-i)*N1*N2 + J*N2 + K
+							Mx, My, Mz = mx[index], my[index], mz[index]
+							ox[index] = t(XX, kk)*Mx - t(XY, kk)*My - t(XZ, kk)*Mz
+							oy[index] = -t(XY, kk)*Mx + t(YY, kk)*My + t(YZ, kk)*Mz
+							oz[index] = -t(XZ, kk)*Mx + t(YZ, kk)*My + t(ZZ, kk)*Mz
+
+							index = (N0-i)*N1*N2 + J*N2 + N2 - Bk*kernMulBlockK - 2*kmax + k
+							Mx, My, Mz = mx[index], my[index], mz[index]
+							ox[index] = t(XX, ki)*Mx + t(XY, ki)*My - t(XZ, ki)*Mz
+							oy[index] = t(XY, ki)*Mx + t(YY, ki)*My - t(YZ, ki)*Mz
+							oz[index] = -t(XZ, ki)*Mx - t(YZ, ki)*My + t(ZZ, ki)*Mz
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// Point-wise 2D micromagnetic kernel multiplication in Fourier space.
+// Output is saved in out (in Fourier space, of course) with the result, can be in-place or out-of-place:
+//
+//	|outx|   |Kxx  0   0 |   |Mx|
+//	|outy| = | 0  Kyy Kyz| * |My|
+//	|outz|   | 0  Kyz Kzz|   |Mz|
+//
+// The kernel is symmetric.
+func KernelMulMicromag2D2Async(fftMx, fftMy, fftMz, fftKxx, fftKyy, fftKzz, fftKyz, outx, outy, outz *Array, stream Stream) {
+	Assert(fftMx.size4D[0] == 1 &&
+		fftKxx.size4D[0] == 1 &&
+		fftKxx.size3D[0] == 1)
+
+	N1, N2 := fftMx.partSize[1], fftMx.partSize[2]
+	mx, my, mz := fftMx.list, fftMy.list, fftMz.list
+	ox, oy, oz := outx.list, outy.list, outz.list
+	kern := [4][]float32{fftKxx.list, fftKyy.list, fftKyz.list, fftKzz.list}
+	const (
+		XX = iota
+		YY
+		YZ
+		ZZ
+	)
+
+	var tile [4][kernMulBlockJ][kernMulBlockK/2 + 1]float32
+	N2K := N2 / 2
+	gridK := (N2/2-1)/kernMulBlockK + 1
+	gridJ := (N1-1)/kernMulBlockJ + 1
+
+	for Bk := 0; Bk < gridK; Bk++ {
+		for Bj := 0; Bj < gridJ; Bj++ {
+			kmax := kernMulKmax(N2, Bk)
+			for j := 0; j < kernMulBlockJ; j++ {
+				for k := 0; k < kernMulBlockK/2+1; k++ {
+					K, J := Bk*kernMulBlockK+k, Bj*kernMulBlockJ+j
+					index := J*N2K + Bk*kernMulBlockK/2 + k
+					if J < N1 && K < N2/2+1 && index < len(kern[XX]) {
+						for c := range tile {
+							tile[c][j][k] = kern[c][index]
+						}
+					}
+				}
+			}
+			for j := 0; j < kernMulBlockJ; j++ {
+				for k := 0; k < kernMulBlockK; k++ {
+					K, J := Bk*kernMulBlockK+k, Bj*kernMulBlockJ+j
+					if !(J < N1 && K < N2/2) {
+						continue
+					}
+					kk := k / 2
+					ki := kmax - k/2
+
+					index := J*N2 + K
+					My, Mz := my[index], mz[index]
+					ox[index] = tile[XX][j][kk] * mx[index]
+					oy[index] = tile[YY][j][kk]*My + tile[YZ][j][kk]*Mz
+					oz[index] = tile[YZ][j][kk]*My + tile[ZZ][j][kk]*Mz
+
+					index = J*N2 + N2 - Bk*kernMulBlockK - 2*kmax + k
+					My, Mz = my[index], mz[index]
+					ox[index] = tile[XX][j][ki] * mx[index]
+					oy[index] = tile[YY][j][ki]*My - tile[YZ][j][ki]*Mz
+					oz[index] = -tile[YZ][j][ki]*My + tile[ZZ][j][ki]*Mz
+				}
+			}
+		}
+	}
+}
+
+// Computes the uniaxial anisotropy field, stores in h.
+func UniaxialAnisotropyAsync(h, m *Array, KuMask, MsatMask *Array, Ku2_Mu0MSat float64, anisUMask *Array, anisUMul []float64, stream Stream) {
+	hx, hy, hz := h.Comp[X].list, h.Comp[Y].list, h.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	ku, msat := KuMask.list, MsatMask.list
+	ux, uy, uz := anisUMask.Comp[X].list, anisUMask.Comp[Y].list, anisUMask.Comp[Z].list
+	mul := float32(Ku2_Mu0MSat)
+	umulx, umuly, umulz := float32(anisUMul[X]), float32(anisUMul[Y]), float32(anisUMul[Z])
+
+	for i := 0; i < h.partLen3D; i++ {
+		mSat_mask := maskUnity(msat, i)
+		if mSat_mask == 0 {
+			mSat_mask = 1 // do not divide by zero
+		}
+		Ku2_Mu0Msat := (mul / mSat_mask) * maskUnity(ku, i)
+		Ux := umulx * maskUnity(ux, i)
+		Uy := umuly * maskUnity(uy, i)
+		Uz := umulz * maskUnity(uz, i)
+		mu := mx[i]*Ux + my[i]*Uy + mz[i]*Uz
+		hx[i] = Ku2_Mu0Msat * mu * Ux
+		hy[i] = Ku2_Mu0Msat * mu * Uy
+		hz[i] = Ku2_Mu0Msat * mu * Uz
+	}
+}
+
+// Computes the cubic anisotropy field, stores in h.
+// K1_Mu0MSat, K2_Mu0MSat: K multiplier / (Mu0 * Msat multiplier).
+// c1, c2: the first two (orthogonal, unit) cubic axes.
+func CubicAnisotropyAsync(h, m *Array, K1Mask *Array, K1_Mu0MSat float64, K2Mask *Array, K2_Mu0MSat float64, MsatMask *Array, c1Mask *Array, c1Mul []float64, c2Mask *Array, c2Mul []float64, stream Stream) {
+	hx, hy, hz := h.Comp[X].list, h.Comp[Y].list, h.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	k1, k2, msat := K1Mask.list, K2Mask.list, MsatMask.list
+	c1x, c1y, c1z := c1Mask.Comp[X].list, c1Mask.Comp[Y].list, c1Mask.Comp[Z].list
+	c2x, c2y, c2z := c2Mask.Comp[X].list, c2Mask.Comp[Y].list, c2Mask.Comp[Z].list
+	k1mul, k2mul := float32(K1_Mu0MSat), float32(K2_Mu0MSat)
+
+	for i := 0; i < h.partLen3D; i++ {
+		mSat_mask := maskUnity(msat, i)
+		if mSat_mask == 0 {
+			mSat_mask = 1 // do not divide by zero
+		}
+		K1_2_Mu0Msat := 2 * (k1mul / mSat_mask) * maskUnity(k1, i)
+		K2_2_Mu0Msat := 2 * (k2mul / mSat_mask) * maskUnity(k2, i)
+
+		C1x := float32(c1Mul[X]) * maskUnity(c1x, i)
+		C1y := float32(c1Mul[Y]) * maskUnity(c1y, i)
+		C1z := float32(c1Mul[Z]) * maskUnity(c1z, i)
+		C2x := float32(c2Mul[X]) * maskUnity(c2x, i)
+		C2y := float32(c2Mul[Y]) * maskUnity(c2y, i)
+		C2z := float32(c2Mul[Z]) * maskUnity(c2z, i)
+		// the sign of c3 does not matter, it only enters as a3 * c3 or a3²
+		C3x := C1y*C2z - C1z*C2y
+		C3y := C1z*C2x - C1x*C2z
+		C3z := C1x*C2y - C1y*C2x
+
+		a1 := mx[i]*C1x + my[i]*C1y + mz[i]*C1z
+		a2 := mx[i]*C2x + my[i]*C2y + mz[i]*C2z
+		a3 := mx[i]*C3x + my[i]*C3y + mz[i]*C3z
+		a1a1, a2a2, a3a3 := a1*a1, a2*a2, a3*a3
+
+		h1 := -K1_2_Mu0Msat*a1*(a2a2+a3a3) - K2_2_Mu0Msat*a1*a2a2*a3a3
+		h2 := -K1_2_Mu0Msat*a2*(a1a1+a3a3) - K2_2_Mu0Msat*a2*a1a1*a3a3
+		h3 := -K1_2_Mu0Msat*a3*(a1a1+a2a2) - K2_2_Mu0Msat*a3*a1a1*a2a2
+
+		hx[i] = h1*C1x + h2*C2x + h3*C3x
+		hy[i] = h1*C1y + h2*C2y + h3*C3y
+		hz[i] = h1*C1z + h2*C2z + h3*C3z
+	}
+}
+
+// 6-neighbor exchange field.
+// Aex2_mu0Msatmul: 2 * Aex / Mu0 * Msat.multiplier
+// scale: 3-component mask, component c scales the exchange with the next cell along axis c (nil mask: 1).
+func Exchange6Async(h, m, msat, aex, scale *Array, Aex2_mu0Msatmul float64, cellSize []float64, periodic []int, stream Stream) {
+	CheckSize(h.Size3D(), m.Size3D())
+	Assert(scale.NComp() == 3)
+	hx, hy, hz := h.Comp[X].list, h.Comp[Y].list, h.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	mSat_map, Aex_map := msat.list, aex.list
+	pre := float32(Aex2_mu0Msatmul)
+	N0, N1, N2 := h.PartSize()[X], h.PartSize()[Y], h.PartSize()[Z]
+	var cell_2 [3]float32
+	for c := range cell_2 {
+		cell_2[c] = float32(1 / (float64(float32(cellSize[c])) * float64(float32(cellSize[c]))))
+	}
+
+	lex := func(I int) float32 {
+		return fdivZero(maskUnity(Aex_map, I), maskUnity(mSat_map, I))
+	}
+
+	for i := 0; i < N0; i++ {
+		for j := 0; j < N1; j++ {
+			for k := 0; k < N2; k++ {
+				I := i*N1*N2 + j*N2 + k
+				lex0 := lex(I)
+				mx0, my0, mz0 := mx[I], my[I], mz[I]
+				var Hx, Hy, Hz float32
+
+				// order of the axes as in exchange6.cu: X, Z, Y
+				for _, c := range [3]int{X, Z, Y} {
+					n := [3]int{N0, N1, N2}[c]
+					idx := [3]int{i, j, k}
+					stride := [3]int{N1 * N2, N2, 1}[c]
+					here := idx[c]
+
+					i1 := wrapClamp(here-1, n, periodic[c] != 0)
+					i2 := wrapClamp(here+1, n, periodic[c] != 0)
+					addr1 := I + (i1-here)*stride
+					addr2 := I + (i2-here)*stride
+					// the scale of a link is stored in its lower cell
+					lex1 := avgGeomZero(lex0, lex(addr1)) * maskUnity(scale.Comp[c].list, addr1)
+					lex2 := avgGeomZero(lex0, lex(addr2)) * maskUnity(scale.Comp[c].list, I)
+
+					Hx += pre * cell_2[c] * (lex1*(mx[addr1]-mx0) + lex2*(mx[addr2]-mx0))
+					Hy += pre * cell_2[c] * (lex1*(my[addr1]-my0) + lex2*(my[addr2]-my0))
+					Hz += pre * cell_2[c] * (lex1*(mz[addr1]-mz0) + lex2*(mz[addr2]-mz0))
+				}
+				hx[I] = Hx
+				hy[I] = Hy
+				hz[I] = Hz
+			}
+		}
+	}
+}
+
+// Dzyaloshinskii-Moriya field of the interfacial (dind) and bulk (dbulk) DMI,
+// with the boundary conditions of exchange + DMI at the edges and next to cells with Msat = 0.
+// Aex2_mu0Msatmul: 2 * Aex / Mu0 * Msat.multiplier, as for Exchange6Async.
+// Dind_mu0Msatmul, Dbulk_mu0Msatmul: D / Mu0 * Msat.multiplier.
+func DMIAsync(h, m, msat, aex *Array, Aex2_mu0Msatmul float64, dind *Array, Dind_mu0Msatmul float64, dbulk *Array, Dbulk_mu0Msatmul float64, cellSize []float64, periodic []int, stream Stream) {
+	CheckSize(h.Size3D(), m.Size3D())
+	hx, hy, hz := h.Comp[X].list, h.Comp[Y].list, h.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	mSat_map := msat.list
+	N0, N1, N2 := h.PartSize()[X], h.PartSize()[Y], h.PartSize()[Z]
+
+	// parameter / Msat in cell I
+	perMsat := func(param *Array, mul float64, I int) float32 {
+		return float32(mul) * fdivZero(maskUnity(param.list, I), maskUnity(mSat_map, I))
+	}
+
+	for i := 0; i < N0; i++ {
+		for j := 0; j < N1; j++ {
+			for k := 0; k < N2; k++ {
+				I := i*N1*N2 + j*N2 + k
+				m0 := float3{mx[I], my[I], mz[I]}
+				lex0 := perMsat(aex, Aex2_mu0Msatmul, I)
+				dind0 := perMsat(dind, Dind_mu0Msatmul, I)
+				dbulk0 := perMsat(dbulk, Dbulk_mu0Msatmul, I)
+				var H float3
+
+				for c := 0; c < 3; c++ {
+					n := [3]int{N0, N1, N2}[c]
+					idx := [3]int{i, j, k}
+					stride := [3]int{N1 * N2, N2, 1}[c]
+					cell := float32(cellSize[c])
+
+					for _, side := range [2]int{-1, 1} {
+						here := idx[c]
+						there := here + side
+						missing := false
+						if there < 0 || there >= n {
+							if periodic[c] != 0 {
+								there = mod(there, n)
+							} else {
+								missing = true
+							}
+						}
+						addr := I + (there-here)*stride
+
+						var u, m1 float3
+						if !missing && maskUnity(mSat_map, addr) == 0 {
+							missing = true
+						}
+						if missing {
+							u = dmiVector(c, dind0, dbulk0)
+							// ghost cell from the boundary condition 2A dm/dn = -u x m,
+							// exchange6 treats it as equal to m0: add the difference
+							um := crossf(u, m0)
+							s := float32(side) * cell
+							m1 = float3{m0.x - s*fdivZero(um.x, lex0), m0.y - s*fdivZero(um.y, lex0), m0.z - s*fdivZero(um.z, lex0)}
+							ex := lex0 / (cell * cell)
+							H.x += ex * (m1.x - m0.x)
+							H.y += ex * (m1.y - m0.y)
+							H.z += ex * (m1.z - m0.z)
+						} else {
+							u = dmiVector(c, avgGeomZero(dind0, perMsat(dind, Dind_mu0Msatmul, addr)), avgGeomZero(dbulk0, perMsat(dbulk, Dbulk_mu0Msatmul, addr)))
+							m1 = float3{mx[addr], my[addr], mz[addr]}
+						}
+
+						um1 := crossf(u, m1)
+						s := float32(side) / cell
+						H.x += s * um1.x
+						H.y += s * um1.y
+						H.z += s * um1.z
+					}
+				}
+				hx[I] = H.x
+				hy[I] = H.y
+				hz[I] = H.z
+			}
+		}
+	}
+}
+
+// DMI vector u for neighbors along internal axis c (X = user z),
+// in internal component order, as in dmi.cu.
+// The energy density is sum_c u_c . (m x dm/dc), with
+// u = Dind (z x e_c) - Dbulk e_c in user coordinates.
+func dmiVector(c int, dind, dbulk float32) float3 {
+	switch c {
+	case X:
+		return float3{-dbulk, 0, 0}
+	case Y:
+		return float3{0, -dbulk, -dind}
+	default:
+		return float3{0, dind, -dbulk}
+	}
+}
+
+// Interlayer exchange field: each cell with dz != 0 is coupled
+// to the cell dz layers above it (along internal X = user z).
+// J1_mu0MsatMul_t, J2_mu0MsatMul_t: J / (Mu0 * Msat.multiplier * cell thickness).
+func InterlayerExchangeAsync(h, m, msat, J1 *Array, J1_mu0MsatMul_t float64, J2 *Array, J2_mu0MsatMul_t float64, dz *Array, stream Stream) {
+	CheckSize(h.Size3D(), m.Size3D())
+	CheckSize(h.Size3D(), dz.Size3D())
+	hx, hy, hz := h.Comp[X].list, h.Comp[Y].list, h.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	mSat_map, J1_map, J2_map, dz_map := msat.list, J1.list, J2.list, dz.list
+	J1_mul, J2_mul := float32(J1_mu0MsatMul_t), float32(J2_mu0MsatMul_t)
+	layerStride := h.PartSize()[Y] * h.PartSize()[Z]
+
+	for i := 0; i < h.partLen3D; i++ {
+		dz := int(dz_map[i])
+		if dz == 0 { // not coupled
+			hx[i], hy[i], hz[i] = 0, 0, 0
+			continue
+		}
+		i2 := i + dz*layerStride
+
+		// coupling constants: average of both cells
+		J1 := J1_mul * 0.5 * (maskUnity(J1_map, i) + maskUnity(J1_map, i2))
+		J2 := J2_mul * 0.5 * (maskUnity(J2_map, i) + maskUnity(J2_map, i2))
+
+		m2x, m2y, m2z := mx[i2], my[i2], mz[i2]
+		dot := mx[i]*m2x + my[i]*m2y + mz[i]*m2z
+
+		pre := fdivZero(J1+2*J2*dot, maskUnity(mSat_map, i))
+		hx[i] = pre * m2x
+		hy[i] = pre * m2y
+		hz[i] = pre * m2z
+	}
+}
+
+// Spin-orbit torque of a spin Hall current j in a heavy-metal layer
+// with interface normal n, polarized along s = j x n.
+// LL form of the Gilbert torque a m x (s x m) + b s x m:
+//	[(a + alpha b) m x (s x m) + (b - alpha a) s x m] / (1 + alpha²)
+// with a = preDL * xiDL * theta * |j| / (msat * t), idem for b with preFL and xiFL,
+// the masks times their multipliers folded into preDL and preFL.
+func SOTAsync(torque, m, msat, j *Array, jMul []float64, theta, xiDL *Array, preDL float64, xiFL *Array, preFL float64, t, alpha *Array, alphaMul float64, n []float64, stream Stream) {
+	CheckSize(torque.Size3D(), m.Size3D())
+	Assert(j.NComp() == 3)
+	tx, ty, tz := torque.Comp[X].list, torque.Comp[Y].list, torque.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	jx, jy, jz := j.Comp[X].list, j.Comp[Y].list, j.Comp[Z].list
+	jM := float3{float32(jMul[X]), float32(jMul[Y]), float32(jMul[Z])}
+	N := float3{float32(n[X]), float32(n[Y]), float32(n[Z])}
+	pDL, pFL, aMul := float32(preDL), float32(preFL), float32(alphaMul)
+
+	for i := 0; i < torque.partLen3D; i++ {
+		J := float3{jM.x * maskUnity(jx, i), jM.y * maskUnity(jy, i), jM.z * maskUnity(jz, i)}
+		s := normalize(crossf(J, N)) // polarization of the spin current
+
+		// |j| theta / (msat t), 0 outside the magnet
+		pre := fdivZero(lenf(J)*maskUnity(theta.list, i), maskUnity(msat.list, i)*maskUnity(t.list, i))
+		a := pDL * maskUnity(xiDL.list, i) * pre
+		b := pFL * maskUnity(xiFL.list, i) * pre
+
+		alph := aMul * maskUnity(alpha.list, i)
+		alphaFac := 1 / (1 + alph*alph)
+
+		M := float3{mx[i], my[i], mz[i]}
+		sxm := crossf(s, M)
+		mxsxm := crossf(M, sxm)
+
+		mxsxmFac := (a + alph*b) * alphaFac
+		sxmFac := (b - alph*a) * alphaFac
+
+		tx[i] = mxsxmFac*mxsxm.x + sxmFac*sxm.x
+		ty[i] = mxsxmFac*mxsxm.y + sxmFac*sxm.y
+		tz[i] = mxsxmFac*mxsxm.z + sxmFac*sxm.z
+	}
+}
+
+// Calculates the electrical current density j.
+// Efield: electrical field
+// r, rmul: electrical resistivity (scalar) and multiplier
+func CurrentDensityAsync(j, Efield, r *Array, rmul float64, periodic []int, stream Stream) {
+	CheckSize(j.Size3D(), Efield.Size3D())
+	CheckSize(j.Size3D(), r.Size3D())
+	rMul := float32(rmul)
+	rmap := r.list
+	for c := 0; c < 3; c++ {
+		J, E := j.Comp[c].list, Efield.Comp[c].list
+		for i := 0; i < j.partLen3D; i++ {
+			J[i] = E[i] / (rmap[i] * rMul)
+		}
+	}
+}
+
+// Time derivative of electrical charge density.
+func DiffRhoAsync(drho, j *Array, cellsize []float64, periodic []int, stream Stream) {
+	N := j.PartSize()
+	d := drho.list
+	for i := 0; i < N[X]; i++ {
+		for jj := 0; jj < N[Y]; jj++ {
+			for k := 0; k < N[Z]; k++ {
+				I := i*N[Y]*N[Z] + jj*N[Z] + k
+				idx := [3]int{i, jj, k}
+				var Div float32
+				for _, c := range [3]int{X, Z, Y} {
+					J := j.Comp[c].list
+					stride := [3]int{N[Y] * N[Z], N[Z], 1}[c]
+					var j0, j2 float32
+					if idx[c]-1 >= 0 {
+						j0 = J[I-stride]
+					} else if periodic[c] != 0 {
+						j0 = J[I+(N[c]-1)*stride]
+					}
+					if idx[c]+1 < N[c] {
+						j2 = J[I+stride]
+					} else if periodic[c] != 0 {
+						j2 = J[I-idx[c]*stride]
+					}
+					Div += (j0 - j2) / (2 * float32(cellsize[c]))
+				}
+				d[I] = Div
+			}
+		}
+	}
+}
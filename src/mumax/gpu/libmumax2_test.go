@@ -8,6 +8,7 @@
 package gpu
 
 import (
+	"math"
 	"math/rand"
 	"testing"
 )
@@ -94,6 +95,331 @@ func TestMadd(test *testing.T) {
 	}
 }
 
+// Cubic anisotropy energy density, host reference for TestCubicAnisotropy.
+func cubicEnergy(m, c1, c2 [3]float64, K1, K2 float64) float64 {
+	c3 := [3]float64{c1[1]*c2[2] - c1[2]*c2[1], c1[2]*c2[0] - c1[0]*c2[2], c1[0]*c2[1] - c1[1]*c2[0]}
+	a1 := m[0]*c1[0] + m[1]*c1[1] + m[2]*c1[2]
+	a2 := m[0]*c2[0] + m[1]*c2[1] + m[2]*c2[2]
+	a3 := m[0]*c3[0] + m[1]*c3[1] + m[2]*c3[2]
+	return K1*(a1*a1*a2*a2+a2*a2*a3*a3+a3*a3*a1*a1) + K2*a1*a1*a2*a2*a3*a3
+}
+
+// The cubic anisotropy field should be -dE/dm / (Mu0 Msat),
+// compared to a numerical derivative of the energy density.
+func TestCubicAnisotropy(test *testing.T) {
+	// fail test on panic, do not crash
+	defer func() {
+		if err := recover(); err != nil {
+			test.Error(err)
+		}
+	}()
+
+	const K1, K2, Msat = 4.8e4, -1e4, 1.7e6
+	const mu0 = 4 * math.Pi * 1e-7
+	// axes rotated away from x, y, z
+	s, c := math.Sincos(0.3)
+	c1 := []float64{c, s, 0}
+	c2 := []float64{-s * c, c * c, s}
+
+	for _, size := range sizes() {
+		h := NewArray(3, size)
+		defer h.Free()
+		m := NewArray(3, size)
+		defer m.Free()
+		k1 := NewArray(1, size)
+		defer k1.Free()
+		mh, k1h := m.LocalCopy(), k1.LocalCopy()
+		for i := range mh.Comp[0] {
+			x, y, z := rand.Float64()-0.5, rand.Float64()-0.5, rand.Float64()-0.5
+			norm := math.Sqrt(x*x + y*y + z*z)
+			mh.Comp[0][i], mh.Comp[1][i], mh.Comp[2][i] = float32(x/norm), float32(y/norm), float32(z/norm)
+			k1h.List[i] = rand.Float32()
+		}
+		m.CopyFromHost(mh)
+		k1.CopyFromHost(k1h)
+		nil1, nil3 := NilArray(1, size), NilArray(3, size)
+
+		CubicAnisotropyAsync(h, m, k1, K1/(mu0*Msat), nil1, K2/(mu0*Msat), nil1, nil3, c1, nil3, c2, h.Stream)
+		h.Stream.Sync()
+
+		hh := h.LocalCopy()
+		C1, C2 := [3]float64{c1[0], c1[1], c1[2]}, [3]float64{c2[0], c2[1], c2[2]}
+		for i := range hh.Comp[0] {
+			M := [3]float64{float64(mh.Comp[0][i]), float64(mh.Comp[1][i]), float64(mh.Comp[2][i])}
+			k1 := K1 * float64(k1h.List[i])
+			for comp := 0; comp < 3; comp++ {
+				const delta = 1e-6
+				Mp, Mm := M, M
+				Mp[comp] += delta
+				Mm[comp] -= delta
+				dE := (cubicEnergy(Mp, C1, C2, k1, K2) - cubicEnergy(Mm, C1, C2, k1, K2)) / (2 * delta)
+				want := -dE / (mu0 * Msat)
+				have := float64(hh.Comp[comp][i])
+				if math.Abs(have-want) > 1e-4*(2*K1/(mu0*Msat)) {
+					if !test.Failed() {
+						test.Error("cell", i, "comp", comp, ":", have, "!=", want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// For a spin spiral along x, the DMI field is parallel to m:
+// -2 D sin(kc) / (Mu0 Msat c) m for a cycloid (interfacial DMI),
+// +2 D sin(kc) / (Mu0 Msat c) m for a helix (bulk DMI).
+func TestDMISpiral(test *testing.T) {
+	// fail test on panic, do not crash
+	defer func() {
+		if err := recover(); err != nil {
+			test.Error(err)
+		}
+	}()
+
+	const N, cell = 64, 2e-9
+	const D, Aex, Msat = 3e-3, 1.5e-11, 1e6
+	const mu0 = 4 * math.Pi * 1e-7
+	size := []int{1, 1, N}     // internal order: user x along the last axis
+	periodic := []int{1, 1, 1} // no boundaries
+	cellSize := []float64{cell, cell, cell}
+	k := 2 * math.Pi * 3 / (N * cell) // 3 periods in the box
+	want := 2 * D * math.Sin(k*cell) / (mu0 * Msat * cell)
+
+	h := NewArray(3, size)
+	defer h.Free()
+	m := NewArray(3, size)
+	defer m.Free()
+	nil1 := NilArray(1, size)
+
+	for _, bulk := range []bool{false, true} {
+		// internal components: 0 = user z, 2 = user x
+		mh := m.LocalCopy()
+		for i := range mh.Comp[0] {
+			s, c := math.Sincos(k * cell * float64(i))
+			if bulk {
+				mh.Comp[2][i], mh.Comp[1][i], mh.Comp[0][i] = 0, float32(c), float32(s) // m = (0, cos, sin)
+			} else {
+				mh.Comp[2][i], mh.Comp[1][i], mh.Comp[0][i] = float32(s), 0, float32(c) // m = (sin, 0, cos)
+			}
+		}
+		m.CopyFromHost(mh)
+
+		dind, dbulk, sign := D/(mu0*Msat), 0., -1.
+		if bulk {
+			dind, dbulk, sign = 0, D/(mu0*Msat), 1
+		}
+		DMIAsync(h, m, nil1, nil1, 2*Aex/(mu0*Msat), nil1, dind, nil1, dbulk, cellSize, periodic, h.Stream)
+		h.Stream.Sync()
+
+		hh := h.LocalCopy()
+		for c := range hh.Comp {
+			for i := range hh.Comp[c] {
+				expect := sign * want * float64(mh.Comp[c][i])
+				if math.Abs(float64(hh.Comp[c][i])-expect) > 1e-5*want {
+					if !test.Failed() {
+						test.Error("bulk:", bulk, "cell", i, "comp", c, ":", hh.Comp[c][i], "!=", expect)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Two layers coupled by bilinear and biquadratic interlayer exchange:
+// H1 = (J1 + 2 J2 m1·m2) / (Mu0 Msat t) m2, and vice versa.
+func TestInterlayerExchange(test *testing.T) {
+	// fail test on panic, do not crash
+	defer func() {
+		if err := recover(); err != nil {
+			test.Error(err)
+		}
+	}()
+
+	const J1, J2 = -2, 0.5 // already divided by Mu0 Msat t
+	size := []int{3, 2 * NDevice(), 4}
+	layer := size[1] * size[2]
+
+	h := NewArray(3, size)
+	defer h.Free()
+	m := NewArray(3, size)
+	defer m.Free()
+	dz := NewArray(1, size)
+	defer dz.Free()
+	nil1 := NilArray(1, size)
+
+	// couple layer 0 to layer 2, layer 1 uncoupled
+	mh, dzh := m.LocalCopy(), dz.LocalCopy()
+	for i := range mh.Comp[0] {
+		mh.Comp[0][i], mh.Comp[1][i], mh.Comp[2][i] = 0.6, 0, 0.8
+		if i >= 2*layer {
+			mh.Comp[0][i], mh.Comp[1][i], mh.Comp[2][i] = 0, 1, 0
+		}
+		switch i / layer {
+		case 0:
+			dzh.List[i] = 2
+		case 2:
+			dzh.List[i] = -2
+		}
+	}
+	m.CopyFromHost(mh)
+	dz.CopyFromHost(dzh)
+
+	// m2 ⊥ m1: biquadratic term vanishes, then m2 = m1
+	for _, parallel := range []bool{false, true} {
+		dot := float32(0)
+		if parallel {
+			dot = 1
+			for i := 2 * layer; i < 3*layer; i++ {
+				mh.Comp[0][i], mh.Comp[1][i], mh.Comp[2][i] = 0.6, 0, 0.8
+			}
+			m.CopyFromHost(mh)
+		}
+
+		InterlayerExchangeAsync(h, m, nil1, nil1, J1, nil1, J2, dz, h.Stream)
+		h.Stream.Sync()
+
+		hh := h.LocalCopy()
+		for c := range hh.Comp {
+			for i := range hh.Comp[c] {
+				var want float32
+				switch i / layer {
+				case 0:
+					want = (J1 + 2*J2*dot) * mh.Comp[c][i+2*layer]
+				case 2:
+					want = (J1 + 2*J2*dot) * mh.Comp[c][i-2*layer]
+				}
+				if !veryclose(hh.Comp[c][i], want) {
+					if !test.Failed() {
+						test.Error("cell", i, "comp", c, ":", hh.Comp[c][i], "!=", want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestExchangeScale(test *testing.T) {
+	// fail test on panic, do not crash
+	defer func() {
+		if err := recover(); err != nil {
+			test.Error(err)
+		}
+	}()
+
+	// a row of 4 cells along the last axis, the two halves uniform
+	size := []int{1, NDevice(), 4}
+	h := NewArray(3, size)
+	defer h.Free()
+	m := NewArray(3, size)
+	defer m.Free()
+	scale := NewArray(3, size)
+	defer scale.Free()
+	nil1 := NilArray(1, size)
+
+	mh := m.LocalCopy()
+	for i := range mh.Comp[0] {
+		mh.Comp[0][i], mh.Comp[1][i], mh.Comp[2][i] = 1, 0, 0
+		if i%4 >= 2 {
+			mh.Comp[0][i], mh.Comp[1][i], mh.Comp[2][i] = 0, 1, 0
+		}
+	}
+	m.CopyFromHost(mh)
+
+	// scale the link between cells 1 and 2
+	for _, s := range []float32{1, 0.5, 0} {
+		sh := scale.LocalCopy()
+		for c := range sh.Comp {
+			for i := range sh.Comp[c] {
+				sh.Comp[c][i] = 1
+				if c == 2 && i%4 == 1 {
+					sh.Comp[c][i] = s
+				}
+			}
+		}
+		scale.CopyFromHost(sh)
+
+		Exchange6Async(h, m, nil1, nil1, scale, 1, []float64{1, 1, 1}, []int{0, 0, 0}, h.Stream)
+		h.Stream.Sync()
+
+		hh := h.LocalCopy()
+		for c := range hh.Comp {
+			for i := range hh.Comp[c] {
+				var want float32
+				switch i % 4 {
+				case 1:
+					want = s * (mh.Comp[c][i+1] - mh.Comp[c][i])
+				case 2:
+					want = s * (mh.Comp[c][i-1] - mh.Comp[c][i])
+				}
+				if !veryclose(hh.Comp[c][i], want) {
+					if !test.Failed() {
+						test.Error("scale", s, "cell", i, "comp", c, ":", hh.Comp[c][i], "!=", want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSOT(test *testing.T) {
+	// fail test on panic, do not crash
+	defer func() {
+		if err := recover(); err != nil {
+			test.Error(err)
+		}
+	}()
+
+	size := []int{1, NDevice(), 2}
+	torque := NewArray(3, size)
+	defer torque.Free()
+	m := NewArray(3, size)
+	defer m.Free()
+	nil1 := NilArray(1, size)
+	nil3 := NilArray(3, size)
+
+	// in user order (x, y, z): m, current along x, normal along z
+	M := [3]float64{0.6, 0, 0.8}
+	j := [3]float64{2, 0, 0}
+	n := [3]float64{0, 0, 1}
+	const preDL, preFL, alpha = 1, 0.5, 0.1
+
+	cross := func(a, b [3]float64) [3]float64 {
+		return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+	}
+	s := cross(j, n)
+	s[1] /= 2 // unit length: s = (0, -1, 0)
+	sxm := cross(s, M)
+	mxsxm := cross(M, sxm)
+	a, b := preDL*2., preFL*2. // |j| = 2
+	var want [3]float64
+	for c := range want {
+		want[c] = ((a+alpha*b)*mxsxm[c] + (b-alpha*a)*sxm[c]) / (1 + alpha*alpha)
+	}
+
+	// internal order is z, y, x
+	mh := m.LocalCopy()
+	for c := range mh.Comp {
+		for i := range mh.Comp[c] {
+			mh.Comp[c][i] = float32(M[2-c])
+		}
+	}
+	m.CopyFromHost(mh)
+
+	SOTAsync(torque, m, nil1, nil3, []float64{j[2], j[1], j[0]}, nil1, nil1, preDL, nil1, preFL, nil1, nil1, alpha, []float64{n[2], n[1], n[0]}, torque.Stream)
+	torque.Stream.Sync()
+
+	th := torque.LocalCopy()
+	for c := range th.Comp {
+		for i := range th.Comp[c] {
+			if !close(th.Comp[c][i], float32(want[2-c])) {
+				if !test.Failed() {
+					test.Error("cell", i, "comp", c, ":", th.Comp[c][i], "!=", want[2-c])
+				}
+			}
+		}
+	}
+}
+
 // based on math/all_test.go from Go release.r60, copyright the Go authors.
 func tolerance(a, b, e float32) bool {
 	d := a - b
@@ -0,0 +1,58 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of long_field.cu
+
+import (
+	. "mumax/common"
+)
+
+func LongFieldAsync(hlf *Array, m *Array, msat *Array, msat0 *Array, msat0T0 *Array, kappa *Array, Tc *Array, Ts *Array, kappaMul float64, msatMul float64, msat0Mul float64, msat0T0Mul float64, TcMul float64, TsMul float64, stream Stream) {
+
+	// Bookkeeping
+	CheckSize(hlf.Size3D(), m.Size3D())
+
+	hx, hy, hz := hlf.Comp[X].list, hlf.Comp[Y].list, hlf.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	msatMsk, msat0Msk, msat0T0Msk := msat.Comp[X].list, msat0.Comp[X].list, msat0T0.Comp[X].list
+	kappaMsk, TcMsk, TsMsk := kappa.Comp[X].list, Tc.Comp[X].list, Ts.Comp[X].list
+
+	for I := 0; I < hlf.partLen3D; I++ {
+		Ms0T0 := maskUnity(msat0T0Msk, I) * float32(msat0T0Mul)
+		Ms0 := maskUnity(msat0Msk, I) * float32(msat0Mul)
+		k := maskUnity(kappaMsk, I) * float32(kappaMul)
+		tc := maskUnity(TcMsk, I) * float32(TcMul)
+		ts := maskUnity(TsMsk, I) * float32(TsMul)
+
+		if Ms0T0 == 0 || k == 0 || ts == tc {
+			hx[I], hy[I], hz[I] = 0, 0, 0
+			continue
+		}
+		k = 1 / k
+
+		Ms := maskUnity(msatMsk, I) * float32(msatMul)
+		var ratio, mult float32
+		if ts < tc {
+			ratio = Ms / Ms0
+			mult = 1 - ratio*ratio
+		} else {
+			ratio = Ms / Ms0T0
+			mult = -2 * (1 + 0.6*ratio*ratio*tc/(ts-tc)) // 2.0 is to account kappa = 0.5 / kappa
+		}
+		if mult != 0 {
+			mult = k * Ms * mult
+		}
+		hx[I] = mult * mx[I]
+		hy[I] = mult * my[I]
+		hz[I] = mult * mz[I]
+	}
+}
@@ -5,6 +5,9 @@
 //  Note that you are welcome to modify this code under the condition that you do not remove any
 //  copyright notices and prominently state that you modified it, giving a relevant date.
 
+//go:build !cpu
+// +build !cpu
+
 package gpu
 
 // This file implements GPU selection for multi-device operation.
@@ -194,6 +197,12 @@ func InitDebugGPUs() {
 	InitMultiGPU(use, 0)
 }
 
+// Initializes the CUDA driver.
+// Must be called before any other CUDA function.
+func InitCUDA() {
+	cu.Init(0)
+}
+
 // Assures Context ctx[id] is currently active. Switches contexts only when necessary.
 func setDevice(deviceId int) {
 	// debug: test if device is supposed to be used
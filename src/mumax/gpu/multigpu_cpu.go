@@ -0,0 +1,104 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// This file implements device selection for the CPU backend.
+// The host acts as one single "device", so multi-GPU
+// splitting is never used.
+
+import (
+	"fmt"
+	. "mumax/common"
+)
+
+// INTERNAL: List of device ids in use. For the CPU backend this is always {0}.
+var _useDevice []int = nil
+
+// INTERNAL: Device properties, chosen to mimic a typical GPU
+// so that the reduction buffers have the same layout.
+var (
+	maxThreadsPerBlock int
+	maxBlockDim        [3]int
+	maxGridDim         [3]int
+)
+
+// Sets a list of devices to use.
+// The CPU backend has only one device, so exactly one ID must be given.
+// The flags are ignored.
+func InitMultiGPU(devices []int, flags uint) {
+	Debug("InitMultiGPU (cpu)", devices, flags)
+	Assert(len(devices) > 0)
+	Assert(_useDevice == nil) // should not yet be initialized
+
+	if len(devices) != 1 {
+		panic(InputErr(fmt.Sprint("CPU backend supports only one device, got ", devices)))
+	}
+	for _, n := range devices {
+		if n != 0 {
+			panic(InputErr(MSG_BADDEVICEID + fmt.Sprint(n)))
+		}
+	}
+	_useDevice = []int{0}
+
+	maxThreadsPerBlock = 512
+	maxBlockDim = [3]int{512, 512, 64}
+	maxGridDim = [3]int{65535, 65535, 1}
+	Log("device 0 ( CPU backend )")
+
+	STREAM0 = NewStream()
+}
+
+// Like InitMultiGPU(), but uses all available devices:
+// only the host.
+func InitAllGPUs(flags uint) {
+	InitMultiGPU([]int{0}, flags)
+}
+
+// Use device list suitable for debugging.
+func InitDebugGPUs() {
+	InitMultiGPU([]int{0}, 0)
+}
+
+// Initializes the CUDA driver. A no-op for the CPU backend.
+func InitCUDA() {}
+
+// Checks if the device is in use. There is nothing to switch on the CPU.
+func setDevice(deviceId int) {
+	if deviceId != 0 || _useDevice == nil {
+		panic(Bug(fmt.Sprint("Invalid device Id", deviceId, "should be in", _useDevice)))
+	}
+}
+
+func SetDeviceForIndex(index int) {
+	setDevice(_useDevice[index])
+}
+
+// Returns the list of usable devices.
+func getDevices() []int {
+	if _useDevice == nil {
+		panic(Bug(MSG_DEVICEUNINITIATED))
+	}
+	return _useDevice
+}
+
+// Returns the number of used devices (always 1).
+func NDevice() int {
+	return len(_useDevice)
+}
+
+// Error message
+const (
+	MSG_BADDEVICEID       = "Invalid device ID: "
+	MSG_DEVICEUNINITIATED = "Device list not initiated"
+)
+
+// Stream 0 on each device
+var STREAM0 Stream
@@ -0,0 +1,28 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of Qinter.cu
+// Author: Mykola Dvornik
+
+import (
+	. "mumax/common"
+)
+
+func Qinter_async(Qi *Array, Ti *Array, Tj *Array, Gij *Array, GijMul []float64, stream Stream) {
+	Q := Qi.Comp[X].list
+	Tii, Tjj := Ti.Comp[X].list, Tj.Comp[X].list
+	GijMsk := Gij.Comp[X].list
+	mul := float32(GijMul[0])
+	for i := 0; i < Qi.partLen3D; i++ {
+		Q[i] = mul * maskUnity(GijMsk, i) * (Tjj[i] - Tii[i])
+	}
+}
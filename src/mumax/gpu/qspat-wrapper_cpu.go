@@ -0,0 +1,66 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of Qspat.cu
+// Author: Mykola Dvornik
+
+import (
+	. "mumax/common"
+)
+
+func Qspat_async(Q *Array, T *Array, k *Array, kMul []float64, cs []float64, pbc []int) {
+	q, t := Q.Comp[X].list, T.Comp[X].list
+	kMsk := k.Comp[X].list
+	mul := float32(kMul[0])
+
+	N := Q.PartSize()
+	var mstep [3]float32
+	for c := range mstep {
+		mstep[c] = 1 / (float32(cs[c]) * float32(cs[c]))
+	}
+
+	// Second derivative along one axis. At non-periodic edges the
+	// 3-point stencil is shifted inwards by one cell.
+	ddT := func(idx [3]int, c int) float32 {
+		n := N[c]
+		if n <= 3 {
+			return 0
+		}
+		center := idx[c]
+		b1, x, f1 := center-1, center, center+1
+		if pbc[c] == 0 {
+			if center == 0 {
+				b1, x, f1 = center, center+1, center+2
+			}
+			if center == n-1 {
+				b1, x, f1 = center-2, center-1, center
+			}
+		}
+		b1, f1 = mod(b1, n), mod(f1, n)
+		at := func(a int) float32 {
+			idx[c] = a
+			return t[idx[X]*N[Y]*N[Z]+idx[Y]*N[Z]+idx[Z]]
+		}
+		return (at(b1) + at(f1)) - 2*at(x)
+	}
+
+	for i := 0; i < N[X]; i++ {
+		for j := 0; j < N[Y]; j++ {
+			for kk := 0; kk < N[Z]; kk++ {
+				x0 := i*N[Y]*N[Z] + j*N[Z] + kk
+				idx := [3]int{i, j, kk}
+				dd := mstep[X]*ddT(idx, X) + mstep[Y]*ddT(idx, Y) + mstep[Z]*ddT(idx, Z)
+				q[x0] = mul * maskUnity(kMsk, x0) * dd
+			}
+		}
+	}
+}
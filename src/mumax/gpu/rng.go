@@ -0,0 +1,50 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build !cpu
+// +build !cpu
+
+package gpu
+
+// This file implements random number generation on the GPUs.
+
+import (
+	"cuda/curand"
+)
+
+// Random number generator, one per device.
+type RNG []curand.Generator
+
+// Creates a new random number generator on each device.
+func NewRNG() RNG {
+	r := make(RNG, NDevice())
+	for dev := range r {
+		SetDeviceForIndex(dev)
+		r[dev] = curand.CreateGenerator(curand.PSEUDO_DEFAULT)
+	}
+	return r
+}
+
+// Seeds the generators. Device i gets seed+i.
+func (r RNG) SetSeed(seed int64) {
+	for dev := range r {
+		SetDeviceForIndex(dev)
+		r[dev].SetSeed(seed + int64(dev))
+	}
+}
+
+// Fills the array with standard normal noise.
+// CURAND does not provide an out-of-the-box way to do this in parallel over the GPUs,
+// so each device fills its own part.
+func (r RNG) GenerateNormal(a *Array) {
+	devPointers := a.Pointers()
+	N := int64(a.PartLen4D())
+	for dev := range r {
+		SetDeviceForIndex(dev)
+		r[dev].GenerateNormal(uintptr(devPointers[dev]), N, 0, 1)
+	}
+}
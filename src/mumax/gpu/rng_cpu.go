@@ -0,0 +1,44 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// This file implements random number generation for the CPU backend.
+
+import (
+	"math/rand"
+)
+
+// Random number generator, one per device.
+type RNG []*rand.Rand
+
+// Creates a new random number generator on each device.
+func NewRNG() RNG {
+	r := make(RNG, NDevice())
+	for dev := range r {
+		r[dev] = rand.New(rand.NewSource(0))
+	}
+	return r
+}
+
+// Seeds the generators. Device i gets seed+i.
+func (r RNG) SetSeed(seed int64) {
+	for dev := range r {
+		r[dev].Seed(seed + int64(dev))
+	}
+}
+
+// Fills the array with standard normal noise.
+func (r RNG) GenerateNormal(a *Array) {
+	list := a.list
+	for i := range list {
+		list[i] = float32(r[0].NormFloat64())
+	}
+}
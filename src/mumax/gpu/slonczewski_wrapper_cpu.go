@@ -0,0 +1,89 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of slonczewski_torque.cu
+
+import (
+	. "mumax/common"
+)
+
+func LLSlon(stt *Array, m *Array, msat *Array, p *Array, j *Array, alpha *Array, t_fl *Array, pol *Array, lambda *Array, epsilon_prime *Array, pMul []float64, jMul []float64, beta_prime float32, pre_field float32, worldSize []float64, alphaMul []float64, t_flMul []float64, lambdaMul []float64) {
+
+	// Bookkeeping
+	CheckSize(p.Size3D(), m.Size3D())
+	Assert(j.NComp() == 3)
+	Assert(msat.NComp() == 1)
+	Assert(alpha.NComp() == 1)
+
+	sttx, stty, sttz := stt.Comp[X].list, stt.Comp[Y].list, stt.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	px, py, pz := p.Comp[X].list, p.Comp[Y].list, p.Comp[Z].list
+	jx, jy, jz := j.Comp[X].list, j.Comp[Y].list, j.Comp[Z].list
+	msatMsk, alphaMsk, t_flMsk := msat.Comp[X].list, alpha.Comp[X].list, t_fl.Comp[X].list
+	polMsk, lambdaMsk, epsilonPrimeMsk := pol.Comp[X].list, lambda.Comp[X].list, epsilon_prime.Comp[X].list
+
+	pM := float3{float32(pMul[X]), float32(pMul[Y]), float32(pMul[Z])}
+	jM := float3{float32(jMul[X]), float32(jMul[Y]), float32(jMul[Z])}
+	aMul, tMul, lMul := float32(alphaMul[X]), float32(t_flMul[X]), float32(lambdaMul[X])
+
+	for I := 0; I < m.PartLen3D(); I++ {
+		Ms := maskUnity(msatMsk, I)
+		J := float3{maskUnity(jx, I) * jM.x, maskUnity(jy, I) * jM.y, maskUnity(jz, I) * jM.z}
+		nJn := lenf(J)
+		free_layer_thickness := maskUnity(t_flMsk, I) * tMul
+
+		if nJn == 0 || Ms == 0 || free_layer_thickness == 0 {
+			sttx[I], stty[I], sttz[I] = 0, 0, 0
+			continue
+		}
+
+		free_layer_thickness = 1 / free_layer_thickness
+		Ms = 1 / Ms
+		preX, preY := beta_prime*Ms, pre_field*Ms
+
+		M := float3{mx[I], my[I], mz[I]}
+		P := normalize(float3{pM.x * maskUnity(px, I), pM.y * maskUnity(py, I), pM.z * maskUnity(pz, I)})
+
+		pxm := crossf(P, M)
+		mxpxm := crossf(M, pxm)
+		pdotm := dotf(P, M)
+
+		J = normalize(J)
+		Jdir := J.x + J.y + J.z
+		Jsign := Jdir / abs32(Jdir)
+		nJn *= Jsign
+
+		preX *= nJn * free_layer_thickness
+		preY *= nJn * free_layer_thickness
+
+		// take into account spatial profile of scattering control parameter
+		l := lMul * maskUnity(lambdaMsk, I)
+		lambda2 := l * l
+		epsilon := lambda2 / ((lambda2 + 1) + (lambda2-1)*pdotm)
+		preX *= epsilon
+
+		a := aMul * maskUnity(alphaMsk, I)
+		alphaFac := 1 / (1 + a*a)
+
+		// take into account spatial profile of polarization efficiency
+		// and of the secondary spin transfer term
+		preX *= maskUnity(polMsk, I)
+		preY *= maskUnity(epsilonPrimeMsk, I)
+
+		mxpxmFac := (preX - a*preY) * alphaFac
+		pxmFac := (preY - a*preX) * alphaFac
+
+		sttx[I] = mxpxmFac*mxpxm.x + pxmFac*pxm.x
+		stty[I] = mxpxmFac*mxpxm.y + pxmFac*pxm.y
+		sttz[I] = mxpxmFac*mxpxm.z + pxmFac*pxm.z
+	}
+}
@@ -0,0 +1,34 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// This file implements streams for the CPU backend.
+// All CPU "kernels" run synchronously, so streams are
+// only kept for API compatibility.
+
+// One placeholder per device.
+type Stream []int
+
+// Creates a new stream.
+func NewStream() Stream {
+	return make(Stream, NDevice())
+}
+
+// Destroys the stream.
+func (s Stream) Destroy() {}
+
+// Synchronizes with the stream. Always immediately returns.
+func (s Stream) Sync() {}
+
+// Returns true if all work has completed, which is always the case.
+func (s Stream) Ready() (ready bool) {
+	return true
+}
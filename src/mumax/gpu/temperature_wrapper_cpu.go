@@ -0,0 +1,58 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of temperature.cu
+
+import (
+	. "mumax/common"
+)
+
+func ScaleNoise(noise, alphaMask *Array,
+	tempMask *Array, alphaKB2tempMul float32,
+	mSatMask *Array, mu0VgammaDtMsatMul float32) {
+	CheckSize(noise.Size4D(), alphaMask.Size4D())
+	n, alpha, temp, msat := noise.list, alphaMask.list, tempMask.list, mSatMask.list
+	for i := 0; i < noise.PartLen3D(); i++ {
+		mSatMul := maskUnity(msat, i)
+		if mSatMul != 0 {
+			n[i] *= sqrtf((maskUnity(alpha, i) * maskUnity(temp, i) * alphaKB2tempMul) / (mu0VgammaDtMsatMul * mSatMul))
+		} else {
+			n[i] = 0
+		}
+	}
+}
+
+func ScaleNoiseAniz(h, mu, T, msat, msat0T0 *Array,
+	muMul []float64,
+	KB2tempMul_mu0VgammaDtMsatMul float64) {
+	CheckSize(h.Size3D(), mu.Size3D())
+	hx, hy, hz := h.Comp[X].list, h.Comp[Y].list, h.Comp[Z].list
+	mu_xx, mu_yy, mu_zz := mu.Comp[X].list, mu.Comp[Y].list, mu.Comp[Z].list
+	muMul_xx, muMul_yy, muMul_zz := float32(muMul[X]), float32(muMul[Y]), float32(muMul[Z])
+	temp, ms, ms0T0 := T.list, msat.list, msat0T0.list
+	mul := float32(KB2tempMul_mu0VgammaDtMsatMul)
+
+	for i := 0; i < h.PartLen3D(); i++ {
+		if maskUnity(ms0T0, i) == 0 {
+			hx[i], hy[i], hz[i] = 0, 0, 0
+			continue
+		}
+		// as in temperature.cu, the x component is not multiplied by H.x
+		muHx := sqrtf(muMul_xx * maskUnity(mu_xx, i))
+		muHy := sqrtf(muMul_yy*maskUnity(mu_yy, i)) * hy[i]
+		muHz := sqrtf(muMul_zz*maskUnity(mu_zz, i)) * hz[i]
+		pre := sqrtf((maskUnity(temp, i) * mul) / maskUnity(ms, i))
+		hx[i] = pre * muHx
+		hy[i] = pre * muHy
+		hz[i] = pre * muHz
+	}
+}
@@ -0,0 +1,41 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of llbar-local00nc.cu
+// Author: Mykola Dvornik
+
+import (
+	. "mumax/common"
+)
+
+func LLBarLocal00NC(t *Array, h *Array, msat0T0 *Array, lambda *Array, lambdaMul []float64) {
+
+	// Bookkeeping
+	CheckSize(h.Size3D(), t.Size3D())
+	Assert(h.NComp() == 3)
+
+	tx, ty, tz := t.Comp[X].list, t.Comp[Y].list, t.Comp[Z].list
+	hx, hy, hz := h.Comp[X].list, h.Comp[Y].list, h.Comp[Z].list
+	msat0T0Msk := msat0T0.Comp[X].list
+	lxx, lyy, lzz := lambda.Comp[X].list, lambda.Comp[Y].list, lambda.Comp[Z].list
+	lMulxx, lMulyy, lMulzz := float32(lambdaMul[X]), float32(lambdaMul[Y]), float32(lambdaMul[Z])
+
+	for x0 := 0; x0 < t.partLen3D; x0++ {
+		if maskUnity(msat0T0Msk, x0) == 0 {
+			tx[x0], ty[x0], tz[x0] = 0, 0, 0
+			continue
+		}
+		tx[x0] = lMulxx * maskUnity(lxx, x0) * hx[x0]
+		ty[x0] = lMulyy * maskUnity(lyy, x0) * hy[x0]
+		tz[x0] = lMulzz * maskUnity(lzz, x0) * hz[x0]
+	}
+}
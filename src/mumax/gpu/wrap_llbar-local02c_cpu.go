@@ -0,0 +1,53 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of llbar-local02c.cu
+// Author: Mykola Dvornik
+
+import (
+	. "mumax/common"
+)
+
+func LLBarLocal02C(t *Array, m *Array, h *Array, msat0T0 *Array, mu *Array, muMul []float64) {
+
+	// Bookkeeping
+	CheckSize(h.Size3D(), m.Size3D())
+	CheckSize(h.Size3D(), t.Size3D())
+	Assert(h.NComp() == 3)
+
+	tx, ty, tz := t.Comp[X].list, t.Comp[Y].list, t.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	hx, hy, hz := h.Comp[X].list, h.Comp[Y].list, h.Comp[Z].list
+	msat0T0Msk := msat0T0.Comp[X].list
+	muxx, muyy, muzz := mu.Comp[X].list, mu.Comp[Y].list, mu.Comp[Z].list
+	muMulxx, muMulyy, muMulzz := float32(muMul[X]), float32(muMul[Y]), float32(muMul[Z])
+
+	for x0 := 0; x0 < t.partLen3D; x0++ {
+		if maskUnity(msat0T0Msk, x0) == 0 {
+			tx[x0], ty[x0], tz[x0] = 0, 0, 0
+			continue
+		}
+		M := float3{mx[x0], my[x0], mz[x0]}
+		H := float3{hx[x0], hy[x0], hz[x0]}
+
+		mxH := crossf(M, H)
+		mu_mxH := float3{
+			muMulxx * maskUnity(muxx, x0) * mxH.x,
+			muMulyy * maskUnity(muyy, x0) * mxH.y,
+			muMulzz * maskUnity(muzz, x0) * mxH.z}
+
+		_mxmu_mxH := crossf(mu_mxH, M)
+		tx[x0] = _mxmu_mxH.x
+		ty[x0] = _mxmu_mxH.y
+		tz[x0] = _mxmu_mxH.z
+	}
+}
@@ -0,0 +1,74 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of llbar-nonlocal00nc.cu
+// Author: Mykola Dvornik
+
+import (
+	. "mumax/common"
+)
+
+func LLBarNonlocal00NC(t *Array, h *Array, msat0T0 *Array, lambda_e *Array, lambda_eMul []float64, cellsizeX float32, cellsizeY float32, cellsizeZ float32, pbc []int) {
+
+	// Bookkeeping
+	CheckSize(t.Size3D(), h.Size3D())
+	Assert(h.NComp() == 3)
+
+	T := [3][]float32{t.Comp[X].list, t.Comp[Y].list, t.Comp[Z].list}
+	H := [3][]float32{h.Comp[X].list, h.Comp[Y].list, h.Comp[Z].list}
+	msat0T0Msk := msat0T0.Comp[X].list
+	le := [3][]float32{lambda_e.Comp[X].list, lambda_e.Comp[Y].list, lambda_e.Comp[Z].list}
+	leMul := [3]float32{float32(lambda_eMul[X]), float32(lambda_eMul[Y]), float32(lambda_eMul[Z])}
+
+	cs := [3]float32{cellsizeX, cellsizeY, cellsizeZ}
+	var cell_2 [3]float32
+	for c := range cs {
+		cell_2[c] = float32(1 / (float64(cs[c]) * float64(cs[c])))
+	}
+
+	N0, N1, N2 := t.PartSize()[X], t.PartSize()[Y], t.PartSize()[Z]
+
+	for i := 0; i < N0; i++ {
+		for j := 0; j < N1; j++ {
+			for k := 0; k < N2; k++ {
+				I := i*N1*N2 + j*N2 + k
+
+				if maskUnity(msat0T0Msk, I) == 0 {
+					for c := range T {
+						T[c][I] = 0
+					}
+					continue
+				}
+
+				// neighbors in the order X, Z, Y, like the CUDA kernel
+				neighbors := [6]int{
+					wrapClamp(i-1, N0, pbc[X] != 0)*N1*N2 + j*N2 + k,
+					wrapClamp(i+1, N0, pbc[X] != 0)*N1*N2 + j*N2 + k,
+					i*N1*N2 + j*N2 + wrapClamp(k-1, N2, pbc[Z] != 0),
+					i*N1*N2 + j*N2 + wrapClamp(k+1, N2, pbc[Z] != 0),
+					i*N1*N2 + wrapClamp(j-1, N1, pbc[Y] != 0)*N2 + k,
+					i*N1*N2 + wrapClamp(j+1, N1, pbc[Y] != 0)*N2 + k}
+
+				for c := range T {
+					le0 := leMul[c] * maskUnity(le[c], I)
+					H0 := H[c][I]
+					var R float32
+					for _, n := range neighbors {
+						l := avgGeomZero(le0, leMul[c]*maskUnity(le[c], n))
+						R += cell_2[c] * l * (H[c][n] - H0)
+					}
+					T[c][I] = -R
+				}
+			}
+		}
+	}
+}
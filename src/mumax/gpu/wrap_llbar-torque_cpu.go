@@ -0,0 +1,42 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of llbar-torque.cu
+// Author: Mykola Dvornik
+
+import (
+	. "mumax/common"
+)
+
+func LLBarTorqueAsync(t *Array, M *Array, h *Array, msat0T0 *Array) {
+
+	// Bookkeeping
+	CheckSize(h.Size3D(), M.Size3D())
+
+	Assert(h.NComp() == 3)
+
+	tx, ty, tz := t.Comp[X].list, t.Comp[Y].list, t.Comp[Z].list
+	Mx, My, Mz := M.Comp[X].list, M.Comp[Y].list, M.Comp[Z].list
+	hx, hy, hz := h.Comp[X].list, h.Comp[Y].list, h.Comp[Z].list
+	msat0T0Msk := msat0T0.Comp[X].list
+
+	for x0 := 0; x0 < t.partLen3D; x0++ {
+		if maskUnity(msat0T0Msk, x0) == 0 {
+			tx[x0], ty[x0], tz[x0] = 0, 0, 0
+			continue
+		}
+		_MxH := crossf(float3{hx[x0], hy[x0], hz[x0]}, float3{Mx[x0], My[x0], Mz[x0]})
+		tx[x0] = _MxH.x
+		ty[x0] = _MxH.y
+		tz[x0] = _MxH.z
+	}
+}
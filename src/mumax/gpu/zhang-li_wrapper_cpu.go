@@ -0,0 +1,109 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build cpu
+// +build cpu
+
+package gpu
+
+// Pure-Go version of zhang-li_torque.cu
+
+import (
+	. "mumax/common"
+)
+
+func LLZhangLi(stt *Array, m *Array, j *Array, msat *Array, pol *Array, ee *Array, alpha *Array, jMul []float64, pred float32, eeMul float64, alphaMul float64, cellsizeX float32, cellsizeY float32, cellsizeZ float32, pbc []int) {
+
+	// Bookkeeping
+	CheckSize(j.Size3D(), m.Size3D())
+	CheckSize(msat.Size3D(), m.Size3D())
+
+	Assert(j.NComp() == 3)
+	Assert(msat.NComp() == 1)
+
+	sttx, stty, sttz := stt.Comp[X].list, stt.Comp[Y].list, stt.Comp[Z].list
+	mx, my, mz := m.Comp[X].list, m.Comp[Y].list, m.Comp[Z].list
+	jx, jy, jz := j.Comp[X].list, j.Comp[Y].list, j.Comp[Z].list
+	msatMsk, polMsk := msat.Comp[X].list, pol.Comp[X].list
+	eeMsk, alphaMsk := ee.Comp[X].list, alpha.Comp[X].list
+
+	N0, N1, N2 := m.PartSize()[X], m.PartSize()[Y], m.PartSize()[Z]
+	mstep := float3{1 / (12 * cellsizeX), 1 / (12 * cellsizeY), 1 / (12 * cellsizeZ)}
+	jM := float3{float32(jMul[X]), float32(jMul[Y]), float32(jMul[Z])}
+	eM, aM := float32(eeMul), float32(alphaMul)
+
+	// First-order derivative, 5-point stencil
+	deriv := func(a []float32, n [4]int) float32 {
+		return a[n[0]] - 8*a[n[1]] + 8*a[n[2]] - a[n[3]]
+	}
+
+	for i := 0; i < N0; i++ {
+		for jj := 0; jj < N1; jj++ {
+			for k := 0; k < N2; k++ {
+				x0 := i*N1*N2 + jj*N2 + k
+
+				m_sat := maskUnity(msatMsk, x0)
+				j0 := float3{maskUnity(jx, x0) * jM.x, maskUnity(jy, x0) * jM.y, maskUnity(jz, x0) * jM.z}
+				njn := lenf(j0)
+
+				if m_sat == 0 || njn == 0 {
+					sttx[x0], stty[x0], sttz[x0] = 0, 0, 0
+					continue
+				}
+
+				m_sat = 1 / m_sat
+				PP := maskUnity(polMsk, x0)
+				pre := njn * PP * pred // since polarization is mask
+				e := eM * maskUnity(eeMsk, x0)
+				a := aM * maskUnity(alphaMsk, x0)
+
+				pre = pre / ((1 + e*e) * (1 + a*a)) // now it is v' (or b_j' / |j| in some notations)
+				pret := pre * (e - a)               // torque-like term prefactor
+				pre = pre * (1 + e*a)               // damping-like term prefactor
+
+				M := float3{mx[x0], my[x0], mz[x0]}
+
+				var xn, yn, zn [4]int
+				for n, d := range [4]int{-2, -1, 1, 2} {
+					xn[n] = stencilIdx(i, d, N0, pbc[X] != 0)*N1*N2 + jj*N2 + k
+					yn[n] = i*N1*N2 + stencilIdx(jj, d, N1, pbc[Y] != 0)*N2 + k
+					zn[n] = i*N1*N2 + jj*N2 + stencilIdx(k, d, N2, pbc[Z] != 0)
+				}
+
+				dmdx := float3{mstep.x * deriv(mx, xn), mstep.y * deriv(mx, yn), mstep.z * deriv(mx, zn)}
+				dmdy := float3{mstep.x * deriv(my, xn), mstep.y * deriv(my, yn), mstep.z * deriv(my, zn)}
+				dmdz := float3{mstep.x * deriv(mz, xn), mstep.y * deriv(mz, yn), mstep.z * deriv(mz, zn)}
+
+				nj0 := normalize(j0)
+				dmdj := float3{dotf(dmdx, nj0), dotf(dmdy, nj0), dotf(dmdz, nj0)}
+
+				dmdjxm := crossf(dmdj, M)   // with minus in it
+				mxdmxm := crossf(M, dmdjxm) // with minus from [dmdj x m]
+
+				sttx[x0] = m_sat * ((pre * mxdmxm.x) + (pret * dmdjxm.x))
+				stty[x0] = m_sat * ((pre * mxdmxm.y) + (pret * dmdjxm.y))
+				sttz[x0] = m_sat * ((pre * mxdmxm.z) + (pret * dmdjxm.z))
+			}
+		}
+	}
+}
+
+// INTERNAL: neighbor idx+d for the 5-point stencil.
+// Without periodicity, out-of-range neighbors are replaced by the center cell.
+func stencilIdx(idx, d, N int, periodic bool) int {
+	n := idx + d
+	if !periodic && (n < 0 || n >= N) {
+		return idx
+	}
+	if n < 0 {
+		return N + n
+	}
+	if n >= N {
+		return n - N
+	}
+	return n
+}
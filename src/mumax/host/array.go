@@ -11,10 +11,8 @@ package host
 // Author: Arne Vansteenkiste
 
 import (
-	cu "cuda/driver"
 	. "mumax/common"
 	"sync"
-	"unsafe"
 )
 
 // A MuMax Array represents a 3-dimensional array of N-vectors.
@@ -59,7 +57,7 @@ func NewArray(components int, size3D []int) *Array {
 func NewArrayPinned(components int, size3D []int) *Array {
 	t := new(Array)
 	t.Init(components, size3D)
-	cu.MemHostRegister(cu.HostPtr(unsafe.Pointer(&t.List[0])), t.SizeInBytes, cu.MEMHOSTREGISTER_PORTABLE)
+	pin(t.List, t.SizeInBytes)
 	Debug("Successfully pinned.")
 	t.isPinned = 1
 	return t
@@ -67,7 +65,7 @@ func NewArrayPinned(components int, size3D []int) *Array {
 
 func (a *Array) Pin() {
 	if a.isPinned == 0 {
-		cu.MemHostRegister(cu.HostPtr(unsafe.Pointer(&a.List[0])), a.SizeInBytes, cu.MEMHOSTREGISTER_PORTABLE)
+		pin(a.List, a.SizeInBytes)
 		Debug("Successfully pinned.")
 		a.isPinned = 1
 	}
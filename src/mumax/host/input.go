@@ -0,0 +1,48 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package host
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	. "mumax/common"
+	"unsafe"
+)
+
+// Reads an array written by WriteBinary.
+// Like WriteBinary, assumes little-endian 32-bit words.
+func ReadBinary(in io.Reader) *Array {
+	var header [2]int32
+	readBinary(in, header[:])
+	if header[0] != T_MAGIC {
+		panic(IOErr(fmt.Sprintf("not a binary tensor: bad magic number: %x", header[0])))
+	}
+	if header[1] != 4 {
+		panic(IOErr(fmt.Sprint("binary tensor: unsupported rank: ", header[1])))
+	}
+	var size [4]int32
+	readBinary(in, size[:])
+	for _, s := range size {
+		if s <= 0 {
+			panic(IOErr(fmt.Sprint("binary tensor: illegal size: ", size)))
+		}
+	}
+
+	t := NewArray(int(size[0]), []int{int(size[1]), int(size[2]), int(size[3])})
+	if _, err := io.ReadFull(in, unsafe.Slice((*byte)(unsafe.Pointer(&t.List[0])), 4*len(t.List))); err != nil {
+		panic(IOErr("binary tensor: " + err.Error()))
+	}
+	return t
+}
+
+func readBinary(in io.Reader, data []int32) {
+	if err := binary.Read(in, binary.LittleEndian, data); err != nil {
+		panic(IOErr("binary tensor: " + err.Error()))
+	}
+}
@@ -0,0 +1,21 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+//go:build !cpu
+// +build !cpu
+
+package host
+
+import (
+	cu "cuda/driver"
+	"unsafe"
+)
+
+// INTERNAL: page-locks the list for fast transfers to the GPU.
+func pin(list []float32, bytes int64) {
+	cu.MemHostRegister(cu.HostPtr(unsafe.Pointer(&list[0])), bytes, cu.MEMHOSTREGISTER_PORTABLE)
+}
@@ -0,0 +1,87 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package modules
+
+import (
+	"fmt"
+	"math"
+	. "mumax/common"
+	. "mumax/engine"
+	"mumax/host"
+	"testing"
+)
+
+// The energies per region add up to the total energy.
+func TestRegionEnergy(t *testing.T) {
+	size := []int{2, 8, 8}
+	e := initTestEngine(t, size, []float64{2e-9, 3e-9, 3e-9})
+	for _, mod := range []string{"zeeman", "exchange6", "regions", "micromag/energy"} {
+		e.LoadModule(mod)
+	}
+	e.Quant("Msat").SetValue([]float64{800e3})
+	e.Quant("Aex").SetValue([]float64{1.3e-11})
+	e.Quant("B_ext").SetValue([]float64{0.1, -0.02, 0.05})
+
+	m := host.NewArray(3, size)
+	for i := range m.Comp[0] {
+		theta, phi := float64(i)*0.3, float64(i)*0.7
+		m.Comp[X][i] = float32(math.Cos(theta))
+		m.Comp[Y][i] = float32(math.Sin(theta) * math.Sin(phi))
+		m.Comp[Z][i] = float32(math.Sin(theta) * math.Cos(phi))
+	}
+	e.Quant("m").SetField(m)
+
+	const NREGION = 4 // region 3 is empty
+	def := host.NewArray(1, size)
+	for i := range def.List {
+		def.List[i] = float32((i / 5) % 3)
+	}
+	e.Quant(REGIONS).SetMask(def)
+	e.Quant(REGIONS).SetValue([]float64{1})
+
+	value := func(name string) float64 {
+		q := e.Quant(name)
+		q.Update()
+		return q.Multiplier()[0]
+	}
+	near := func(a, b, scale float64) bool {
+		return math.Abs(a-b) <= 1e-5*scale
+	}
+
+	for _, E := range []string{"E_zeeman", "E_ex", "E"} {
+		total := value(E)
+		scale := 0.
+		sum := 0.
+		for r := 0; r < NREGION; r++ {
+			Er := value(fmt.Sprint(E, "_region", r))
+			sum += Er
+			scale += math.Abs(Er)
+		}
+		if !near(sum, total, scale) || total == 0 {
+			t.Error(E, ": sum over regions", sum, "total", total)
+		}
+	}
+	if E3 := value("E_region3"); E3 != 0 {
+		t.Error("energy of empty region:", E3)
+	}
+
+	// Zeeman energy of region 1, computed here
+	mBuf, B := e.Quant("m").Buffer(), e.Quant("B_ext").Multiplier()
+	want := 0.
+	for i, r := range def.List {
+		if r == 1 {
+			for c := range B {
+				want -= float64(mBuf.Comp[c][i]) * B[c]
+			}
+		}
+	}
+	want *= 800e3 * e.CellVolume()
+	if have := value("E_zeeman_region1"); !near(have, want, math.Abs(want)) {
+		t.Error("E_zeeman_region1: have", have, "want", want)
+	}
+}
@@ -0,0 +1,79 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package modules
+
+import (
+	. "mumax/common"
+	. "mumax/engine"
+	"mumax/host"
+	"testing"
+)
+
+// Each link between a cell and its next neighbor gets the scaling
+// between their regions, wrapping around along periodic axes.
+func TestExchangeScale(t *testing.T) {
+	size := []int{2, 3, 4}
+	e := initTestEngine(t, size, []float64{1e-9, 1e-9, 1e-9})
+	e.SetPeriodic([]int{0, 1, 0}) // periodic along internal Y only
+
+	// setexchangescale before loading exchange6
+	e.LoadModule("regions")
+	def := host.NewArray(1, size)
+	for i := range def.List {
+		def.List[i] = float32(i % 3)
+	}
+	e.Quant(REGIONS).SetMask(def)
+	e.Quant(REGIONS).SetValue([]float64{1})
+	e.SetExchangeScale(0, 1, 0.5)
+	e.SetExchangeScale(2, 1, 0)
+	e.SetExchangeScale(2, 2, 0.25)
+	e.LoadModule("exchange6")
+
+	check := func() {
+		scale := e.Quant(EXCHANGE_SCALE)
+		scale.Update()
+		have := scale.Buffer().Array
+		for i := 0; i < size[X]; i++ {
+			for j := 0; j < size[Y]; j++ {
+				for k := 0; k < size[Z]; k++ {
+					region := int(def.Array[0][i][j][k])
+					next := [3]int{region, region, region}
+					// neighbors: -1 if none
+					if i+1 < size[X] {
+						next[X] = int(def.Array[0][i+1][j][k])
+					} else {
+						next[X] = -1
+					}
+					next[Y] = int(def.Array[0][i][(j+1)%size[Y]][k]) // periodic
+					if k+1 < size[Z] {
+						next[Z] = int(def.Array[0][i][j][k+1])
+					} else {
+						next[Z] = -1
+					}
+					for c := range next {
+						want := float32(1)
+						if next[c] >= 0 {
+							want = float32(e.ExchangeScale(region, next[c]))
+						}
+						if have[c][i][j][k] != want {
+							t.Error("cell", i, j, k, "axis", c, "regions", region, next[c], ": have", have[c][i][j][k], "want", want)
+						}
+					}
+				}
+			}
+		}
+	}
+	check()
+
+	// changing the regions updates the links
+	for i := range def.List {
+		def.List[i] = float32((i / 4) % 3)
+	}
+	e.Quant(REGIONS).SetMask(def)
+	check()
+}
@@ -0,0 +1,30 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package modules
+
+import (
+	. "mumax/engine"
+	"mumax/gpu"
+	"testing"
+)
+
+func init() {
+	gpu.InitDebugGPUs()
+}
+
+// Resets the global engine to a fresh one with the given internal grid size
+// and cell size, writing output to a temporary directory.
+func initTestEngine(t *testing.T, size3D []int, cellSize []float64) *Engine {
+	e := GetEngine()
+	*e = Engine{}
+	Init()
+	e.SetGridSize(size3D)
+	e.SetCellSize(cellSize)
+	e.SetOutputDirectory(t.TempDir())
+	return e
+}
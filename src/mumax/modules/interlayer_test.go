@@ -0,0 +1,167 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package modules
+
+import (
+	"math"
+	. "mumax/common"
+	. "mumax/engine"
+	"mumax/host"
+	"testing"
+)
+
+// Returns the InputErr raised by f, if any.
+func catchInputErr(f func()) (err InputErr) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = e.(InputErr)
+		}
+	}()
+	f()
+	return ""
+}
+
+// Each column couples its closest cells in both regions,
+// with the energy -(J1 m1·m2 + J2 (m1·m2)²) per unit of area,
+// half of which is counted in each region.
+func TestInterlayer(t *testing.T) {
+	size := []int{4, 2, 3} // 4 layers of 2x3 columns
+	layer := size[Y] * size[Z]
+
+	// regions of each column, from the bottom layer up,
+	// and the layers of the coupled cells in regions 1 and 2, if any
+	columns := []struct {
+		regions [4]int
+		z1, z2  int
+	}{
+		{[4]int{1, 0, 2, 0}, 0, 2},
+		{[4]int{1, 1, 2, 2}, 1, 2},   // closest pair
+		{[4]int{2, 0, 0, 1}, 3, 0},   // r2 below r1
+		{[4]int{1, 0, 0, 0}, -1, -1}, // no cell in r2
+		{[4]int{0, 0, 0, 0}, -1, -1},
+		{[4]int{2, 1, 1, 2}, 1, 0}, // first of two closest pairs
+	}
+	def := host.NewArray(1, size)
+	for c, col := range columns {
+		for z, r := range col.regions {
+			def.List[z*layer+c] = float32(r)
+		}
+	}
+
+	// fresh engine with the regions and the given coupled layers and regions,
+	// as a quantity can not be updated anymore after its updater panicked
+	var e *Engine
+	setup := func(z1, z2, r1, r2 float64) {
+		e = initTestEngine(t, size, []float64{1e-9, 2e-9, 3e-9})
+		e.LoadModule("exchange/interlayer")
+		e.LoadModule("micromag/energy")
+		e.Quant(REGIONS).SetMask(def)
+		e.Quant(REGIONS).SetValue([]float64{1})
+		e.Quant("interlayer_z1").SetScalar(z1)
+		e.Quant("interlayer_z2").SetScalar(z2)
+		e.Quant("interlayer_region1").SetScalar(r1)
+		e.Quant("interlayer_region2").SetScalar(r2)
+	}
+
+	// layers out of range, or together with regions
+	for _, in := range [][4]float64{{0, 4, 0, 0}, {-1, 2, 0, 0}, {0, 3, 1, 2}} {
+		setup(in[0], in[1], in[2], in[3])
+		if err := catchInputErr(e.Quant("interlayer_dz").Update); err == "" {
+			t.Error("no error for layers, regions", in)
+		}
+	}
+
+	// checks interlayer_dz against want(cell layer z, column c)
+	checkDz := func(msg string, want func(z, c int) int) {
+		dz := e.Quant("interlayer_dz")
+		dz.Update()
+		have := dz.Buffer().List
+		for z := 0; z < size[X]; z++ {
+			for c := 0; c < layer; c++ {
+				if int(have[z*layer+c]) != want(z, c) {
+					t.Error(msg, ": layer", z, "column", c, ": have dz", have[z*layer+c], "want", want(z, c))
+				}
+			}
+		}
+	}
+	setup(0, 3, 0, 0)
+	checkDz("layers 0, 3", func(z, c int) int {
+		switch z {
+		case 0:
+			return 3
+		case 3:
+			return -3
+		}
+		return 0
+	})
+	setup(0, 0, 1, 2)
+	checkDz("regions 1, 2", func(z, c int) int {
+		switch col := columns[c]; z {
+		case col.z1:
+			return col.z2 - col.z1
+		case col.z2:
+			return col.z1 - col.z2
+		}
+		return 0
+	})
+
+	// energy, with a spatially varying J1
+	m := host.NewArray(3, size)
+	for i := range m.Comp[0] {
+		theta, phi := float64(i)*0.4, float64(i)*0.9
+		m.Comp[X][i] = float32(math.Cos(theta))
+		m.Comp[Y][i] = float32(math.Sin(theta) * math.Sin(phi))
+		m.Comp[Z][i] = float32(math.Sin(theta) * math.Cos(phi))
+	}
+	e.Quant("m").SetField(m)
+	J1mask := host.NewArray(1, size)
+	for i := range J1mask.List {
+		J1mask.List[i] = 1 + 0.1*float32(i)
+	}
+	e.Quant("J1").SetMask(J1mask)
+	e.Quant("J1").SetValue([]float64{-1e-3})
+	e.Quant("J2").SetValue([]float64{2e-4})
+
+	mBuf := e.Quant("m").Buffer()
+	J1, J2 := e.Quant("J1").Buffer().List, e.Quant("J2").Buffer().List
+	want := 0.
+	for c, col := range columns {
+		if col.z1 < 0 {
+			continue
+		}
+		i1, i2 := col.z1*layer+c, col.z2*layer+c
+		dot := 0.
+		for comp := range mBuf.Comp {
+			dot += float64(mBuf.Comp[comp][i1]) * float64(mBuf.Comp[comp][i2])
+		}
+		j1 := 0.5 * (float64(J1[i1]) + float64(J1[i2]))
+		j2 := 0.5 * (float64(J2[i1]) + float64(J2[i2]))
+		want -= j1*dot + j2*dot*dot
+	}
+	want *= e.CellSize()[Y] * e.CellSize()[Z]
+
+	value := func(name string) float64 {
+		q := e.Quant(name)
+		q.Update()
+		return q.Multiplier()[0]
+	}
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) <= 1e-5*math.Abs(b)
+	}
+	E := value("E_inter")
+	if !near(E, want) || want == 0 {
+		t.Error("E_inter: have", E, "want", want)
+	}
+	E1, E2 := value("E_inter_region1"), value("E_inter_region2")
+	if !near(E1+E2, E) || !near(E1, E/2) {
+		t.Error("E_inter_region1, 2:", E1, E2, "E_inter", E)
+	}
+	if E0 := value("E_inter_region0"); E0 != 0 {
+		t.Error("E_inter_region0 of uncoupled cells:", E0)
+	}
+}
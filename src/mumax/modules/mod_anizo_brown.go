@@ -11,7 +11,6 @@ package modules
 // Author: Arne Vansteenkiste
 
 import (
-	"cuda/curand"
 	. "mumax/common"
 	. "mumax/engine"
 	"mumax/gpu"
@@ -80,8 +79,8 @@ func LoadAnizBrown(e *Engine, args ...Arguments) {
 
 // Updates the thermal field
 type AnizBrownUpdater struct {
-	rng              []curand.Generator // Random number generator for each GPU
-	htherm           *Quant             // The quantity I will update
+	rng              gpu.RNG // Random number generator for each GPU
+	htherm           *Quant  // The quantity I will update
 	therm_seed       *Quant
 	mu               *Quant
 	msat             *Quant
@@ -102,11 +101,7 @@ func NewAnizBrownUpdater(htherm, therm_seed, cutoff_dt, T, mu, msat, msat0T0 *Qu
 	u.msat = msat
 	u.msat0T0 = msat0T0
 	u.T = T
-	u.rng = make([]curand.Generator, gpu.NDevice())
-	for dev := range u.rng {
-		gpu.SetDeviceForIndex(dev)
-		u.rng[dev] = curand.CreateGenerator(curand.PSEUDO_DEFAULT)
-	}
+	u.rng = gpu.NewRNG()
 	return u
 }
 
@@ -117,10 +112,7 @@ func (u *AnizBrownUpdater) Update() {
 	therm_seed := int64(u.therm_seed.Scalar())
 
 	if therm_seed != u.therm_seed_cache {
-		for dev := range u.rng {
-			seed := therm_seed + int64(dev)
-			u.rng[dev].SetSeed(seed)
-		}
+		u.rng.SetSeed(therm_seed)
 	}
 
 	u.therm_seed_cache = therm_seed
@@ -146,14 +138,8 @@ func (u *AnizBrownUpdater) Update() {
 
 	// Make standard normal noise
 	noise := u.htherm.Array()
-	devPointers := noise.Pointers()
-	N := int64(noise.PartLen4D())
 	// Fills H_therm with gaussian noise.
-	// CURAND does not provide an out-of-the-box way to do this in parallel over the GPUs
-	for dev := range u.rng {
-		gpu.SetDeviceForIndex(dev)
-		u.rng[dev].GenerateNormal(uintptr(devPointers[dev]), N, 0, 1)
-	}
+	u.rng.GenerateNormal(noise)
 
 	// Scale the noise according to local parameters
 	cellSize := e.CellSize()
@@ -0,0 +1,122 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package modules
+
+// This file implements the cubic anisotropy module.
+// The energy density is
+//	K1 (a1²a2² + a2²a3² + a3²a1²) + K2 a1²a2²a3²
+// with ai = m·ci the direction cosines of m with respect to the cubic axes
+// c1, c2 (given by anisC1, anisC2) and c3 = c1 × c2.
+// K1 > 0 makes the cubic axes easy axes (e.g. Fe),
+// K1 < 0 the body diagonals (e.g. Ni, YIG).
+
+import (
+	. "mumax/common"
+	. "mumax/engine"
+	"mumax/gpu"
+)
+
+// Register this module
+func init() {
+	RegisterModule("anisotropy/cubic", "Cubic magnetocrystalline anisotropy", LoadAnisCubic)
+}
+
+func LoadAnisCubic(e *Engine) {
+	LoadHField(e)
+	LoadMagnetization(e)
+
+	Hanis := e.AddNewQuant("H_anis_cubic", VECTOR, FIELD, Unit("A/m"), "cubic anisotropy field")
+	k1 := e.AddNewQuant("K1", SCALAR, MASK, Unit("J/m3"), "first order cubic anisotropy constant")
+	k2 := e.AddNewQuant("K2", SCALAR, MASK, Unit("J/m3"), "second order cubic anisotropy constant")
+	c1 := e.AddNewQuant("anisC1", VECTOR, MASK, Unit(""), "first cubic anisotropy axis (unit vector)")
+	c2 := e.AddNewQuant("anisC2", VECTOR, MASK, Unit(""), "second cubic anisotropy axis (unit vector, orthogonal to anisC1)")
+
+	hfield := e.Quant("H_eff")
+	sum := hfield.Updater().(*SumUpdater)
+	sum.AddParent("H_anis_cubic")
+	e.Depends("H_anis_cubic", "K1", "K2", "anisC1", "anisC2", "MSat", "m")
+
+	Hanis.SetUpdater(&CubicAnisUpdater{e.Quant("m"), Hanis, k1, k2, e.Quant("msat"), c1, c2})
+}
+
+type CubicAnisUpdater struct {
+	m, hanis, k1, k2, msat, c1, c2 *Quant
+}
+
+func (u *CubicAnisUpdater) Update() {
+	hanis := u.hanis.Array()
+	stream := u.hanis.Array().Stream
+	Mu0Msat := Mu0 * u.msat.Multiplier()[0]
+
+	gpu.CubicAnisotropyAsync(hanis, u.m.Array(),
+		u.k1.Array(), u.k1.Multiplier()[0]/Mu0Msat,
+		u.k2.Array(), u.k2.Multiplier()[0]/Mu0Msat,
+		u.msat.Array(),
+		u.c1.Array(), u.c1.Multiplier(),
+		u.c2.Array(), u.c2.Multiplier(), stream)
+
+	stream.Sync()
+}
+
+// Adds the cubic anisotropy energy E_anis_cubic to micromag/energy.
+// Unlike the quadratic terms, it is not proportional to m·H_anis_cubic,
+// so it is computed from the energy density on the host.
+func LoadCubicAnisEnergy(e *Engine) *Quant {
+	energy := e.AddNewQuant("E_anis_cubic", SCALAR, VALUE, Unit("J"), "Cubic anisotropy energy")
+	e.Depends("E_anis_cubic", "K1", "K2", "anisC1", "anisC2", "m")
+	energy.SetUpdater(&CubicAnisEnergyUpdater{energy, e.Quant("m"), e.Quant("K1"), e.Quant("K2"), e.Quant("anisC1"), e.Quant("anisC2"), e.CellVolume(), -1})
+	return energy
+}
+
+// Computes the cubic anisotropy energy on the host, in double precision.
+type CubicAnisEnergyUpdater struct {
+	energy, m, k1, k2, c1, c2 *Quant
+	cellVolume                float64
+	region                    int // only sum over this region, -1 means all cells
+}
+
+func (u *CubicAnisEnergyUpdater) Update() {
+	m, k1, k2 := u.m.Buffer().Comp, u.k1.Buffer().List, u.k2.Buffer().List
+	c1, c2 := u.c1.Buffer().Comp, u.c2.Buffer().Comp
+	var regions []float32
+	if u.region >= 0 {
+		regions = GetEngine().Quant(REGIONS).Buffer().List
+	}
+
+	sum := 0.
+	for i := range k1 {
+		if regions != nil && int(regions[i]+0.5) != u.region {
+			continue
+		}
+		var M, C1, C2 [3]float64
+		for c := range M {
+			M[c], C1[c], C2[c] = float64(m[c][i]), float64(c1[c][i]), float64(c2[c][i])
+		}
+		sum += CubicAnisEnergyDensity(M, C1, C2, float64(k1[i]), float64(k2[i]))
+	}
+	u.energy.SetScalar(sum * u.cellVolume)
+}
+
+// Energy in one region, e.g. E_anis_cubic_region2.
+func (u *CubicAnisEnergyUpdater) RegionUpdater(out *Quant, region int) Updater {
+	GetEngine().Depends(out.Name(), "K1", "K2", "anisC1", "anisC2", "m")
+	regional := *u
+	regional.energy = out
+	regional.region = region
+	return &regional
+}
+
+// Cubic anisotropy energy density (J/m3) for magnetization direction m
+// and cubic axes c1, c2.
+func CubicAnisEnergyDensity(m, c1, c2 [3]float64, K1, K2 float64) float64 {
+	c3 := [3]float64{c1[1]*c2[2] - c1[2]*c2[1], c1[2]*c2[0] - c1[0]*c2[2], c1[0]*c2[1] - c1[1]*c2[0]}
+	dot := func(a, b [3]float64) float64 { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+	a1, a2, a3 := dot(m, c1), dot(m, c2), dot(m, c3)
+	a1a1, a2a2, a3a3 := a1*a1, a2*a2, a3*a3
+	return K1*(a1a1*a2a2+a2a2*a3a3+a3a3*a1a1) + K2*a1a1*a2a2*a3a3
+}
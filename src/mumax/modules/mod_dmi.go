@@ -0,0 +1,88 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package modules
+
+// This file implements the Dzyaloshinskii-Moriya interaction modules.
+// The energy densities are
+//	interfacial: Dind [mz (∇·m) - (m·∇) mz]
+//	bulk:        Dbulk m·(∇×m)
+// Both modules may be loaded together, they share the field H_dmi.
+// At the edges, and next to cells with Msat = 0, the boundary condition
+// of exchange + DMI is used instead of the plain Neumann condition of exchange6,
+// so that the magnetization tilts there as it should.
+
+import (
+	. "mumax/common"
+	. "mumax/engine"
+	"mumax/gpu"
+)
+
+// Register this module
+func init() {
+	RegisterModule("dmi/interfacial", "Interfacial Dzyaloshinskii-Moriya interaction (with z as interface normal)", LoadDMIInterfacial)
+	RegisterModule("dmi/bulk", "Bulk Dzyaloshinskii-Moriya interaction", LoadDMIBulk)
+}
+
+func LoadDMIInterfacial(e *Engine) {
+	u := loadDMI(e)
+	u.dind = e.AddNewQuant("Dind", SCALAR, MASK, Unit("J/m2"), "interfacial Dzyaloshinskii-Moriya constant")
+	e.Depends("H_dmi", "Dind")
+}
+
+func LoadDMIBulk(e *Engine) {
+	u := loadDMI(e)
+	u.dbulk = e.AddNewQuant("Dbulk", SCALAR, MASK, Unit("J/m2"), "bulk Dzyaloshinskii-Moriya constant")
+	e.Depends("H_dmi", "Dbulk")
+}
+
+// Adds H_dmi if not yet present, returns its updater.
+func loadDMI(e *Engine) *dmiUpdater {
+	if e.HasQuant("H_dmi") {
+		return e.Quant("H_dmi").Updater().(*dmiUpdater)
+	}
+	LoadHField(e)
+	LoadMagnetization(e)
+	if !e.HasQuant("Aex") {
+		e.LoadModule("exchange6") // the boundary conditions need Aex
+	}
+	Hdmi := e.AddNewQuant("H_dmi", VECTOR, FIELD, Unit("A/m"), "Dzyaloshinskii-Moriya field")
+	hfield := e.Quant("H_eff")
+	sum := hfield.Updater().(*SumUpdater)
+	sum.AddParent("H_dmi")
+	e.Depends("H_dmi", "Aex", "Msat", "m")
+	u := &dmiUpdater{m: e.Quant("m"), Aex: e.Quant("Aex"), Hdmi: Hdmi, Msat: e.Quant("msat"), nilMask: gpu.NilArray(1, e.GridSize())}
+	Hdmi.SetUpdater(u)
+	return u
+}
+
+type dmiUpdater struct {
+	m, Aex, Hdmi, Msat *Quant
+	dind, dbulk        *Quant // nil if not loaded
+	nilMask            *gpu.Array
+}
+
+func (u *dmiUpdater) Update() {
+	e := GetEngine()
+	Msat := u.Msat
+
+	Mu0MsatMul := Mu0 * Msat.Multiplier()[0]
+	Aex2_mu0MsatMul := (2 * u.Aex.Multiplier()[0]) / Mu0MsatMul
+	// an absent DMI term has a zero multiplier
+	dind, dindMul := u.nilMask, 0.
+	if u.dind != nil {
+		dind, dindMul = u.dind.Array(), u.dind.Multiplier()[0]/Mu0MsatMul
+	}
+	dbulk, dbulkMul := u.nilMask, 0.
+	if u.dbulk != nil {
+		dbulk, dbulkMul = u.dbulk.Array(), u.dbulk.Multiplier()[0]/Mu0MsatMul
+	}
+
+	stream := u.Hdmi.Array().Stream
+	gpu.DMIAsync(u.Hdmi.Array(), u.m.Array(), Msat.Array(), u.Aex.Array(), Aex2_mu0MsatMul, dind, dindMul, dbulk, dbulkMul, e.CellSize(), e.Periodic(), stream)
+	stream.Sync()
+}
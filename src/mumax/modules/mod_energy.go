@@ -59,6 +59,24 @@ func LoadEnergy(e *Engine) {
 		sumUpd.AddParent(term.Name())
 	}
 
+	if e.HasQuant("H_dmi") {
+		term := LoadEnergyTerm(e, "E_dmi", M, "H_dmi", -0.5*e.CellVolume()*Mu0, "Dzyaloshinskii-Moriya energy")
+		Log("Loaded Dzyaloshinskii-Moriya energy E_dmi")
+		sumUpd.AddParent(term.Name())
+	}
+
+	if e.HasQuant("H_anis_cubic") {
+		term := LoadCubicAnisEnergy(e)
+		Log("Loaded cubic anisotropy energy E_anis_cubic")
+		sumUpd.AddParent(term.Name())
+	}
+
+	if e.HasQuant("H_inter") {
+		term := LoadInterlayerEnergy(e)
+		Log("Loaded interlayer exchange energy E_inter")
+		sumUpd.AddParent(term.Name())
+	}
+
 	if e.HasQuant("H_lf") {
 		term := LoadEnergyTerm(e, "E_lf", M, "H_lf", -e.CellVolume()*Mu0, "Longitudinal field energy")
 		Log("Loaded anisotropy energy E_lf")
@@ -93,3 +111,19 @@ func (u *EnergyUpdater) Update() {
 	u.SDotUpdater.Update()
 	u.energy.Multiplier()[0] *= u.msat.Multiplier()[0]
 }
+
+// Energy in one region, e.g. E_ex_region2.
+func (u *EnergyUpdater) RegionUpdater(out *Quant, region int) Updater {
+	return &regionEnergyUpdater{u.SDotUpdater.RegionUpdater(out, region), out, u.msat}
+}
+
+type regionEnergyUpdater struct {
+	sdot   Updater
+	energy *Quant
+	msat   *Quant
+}
+
+func (u *regionEnergyUpdater) Update() {
+	u.sdot.Update()
+	u.energy.Multiplier()[0] *= u.msat.Multiplier()[0]
+}
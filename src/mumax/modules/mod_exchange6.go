@@ -9,11 +9,16 @@ package modules
 
 // 6-neighbor exchange interaction
 // Author: Arne Vansteenkiste
+//
+// The exchange between two regions can be scaled with setexchangescale(),
+// which sets up the mask Aex_scale. Its component c is the scaling of the
+// link between each cell and its next neighbor along axis c.
 
 import (
 	. "mumax/common"
 	. "mumax/engine"
 	"mumax/gpu"
+	"mumax/host"
 )
 
 // Register this module
@@ -29,12 +34,19 @@ func LoadExch6(e *Engine) {
 	hfield := e.Quant("H_eff")
 	sum := hfield.Updater().(*SumUpdater)
 	sum.AddParent("H_ex")
-	e.Depends("H_ex", "Aex", "Msat", "m")
-	Hex.SetUpdater(&exch6Updater{m: e.Quant("m"), Aex: Aex, Hex: Hex, Msat: e.Quant("msat")})
+	scale := e.AddNewQuant(EXCHANGE_SCALE, VECTOR, MASK, Unit(""), "exchange scaling with the next cell along x, y, z, set by setexchangescale")
+	scale.SetValue([]float64{1, 1, 1})
+	scale.SetUpdater(&exchScaleUpdater{scale})
+	if e.HasQuant(REGIONS) {
+		// setexchangescale may have been called before loading this module
+		e.Depends(EXCHANGE_SCALE, REGIONS)
+	}
+	e.Depends("H_ex", "Aex", "Msat", "m", EXCHANGE_SCALE)
+	Hex.SetUpdater(&exch6Updater{m: e.Quant("m"), Aex: Aex, Hex: Hex, Msat: e.Quant("msat"), scale: scale})
 }
 
 type exch6Updater struct {
-	m, Aex, Hex, Msat *Quant
+	m, Aex, Hex, Msat, scale *Quant
 }
 
 func (u *exch6Updater) Update() {
@@ -46,6 +58,47 @@ func (u *exch6Updater) Update() {
 
 	Aex2_mu0MsatMul := (2 * u.Aex.Multiplier()[0]) / (Mu0 * Msat.Multiplier()[0])
 	stream := u.Hex.Array().Stream
-	gpu.Exchange6Async(Hex.Array(), m.Array(), Msat.Array(), Aex.Array(), Aex2_mu0MsatMul, e.CellSize(), e.Periodic(), stream)
+	gpu.Exchange6Async(Hex.Array(), m.Array(), Msat.Array(), Aex.Array(), u.scale.Array(), Aex2_mu0MsatMul, e.CellSize(), e.Periodic(), stream)
 	stream.Sync()
 }
+
+// Sets up the Aex_scale mask from the engine's table of
+// exchange scalings between regions. Leaves the mask nil
+// (no scaling) as long as the table is empty.
+type exchScaleUpdater struct {
+	scale *Quant
+}
+
+func (u *exchScaleUpdater) Update() {
+	e := GetEngine()
+	if !e.HasExchangeScale() {
+		return
+	}
+	size := e.GridSize()
+	periodic := e.Periodic()
+	def := e.Quant(REGIONS).Buffer().List
+	region := func(I int) int { return int(def[I] + 0.5) }
+	scale := host.NewArray(3, size)
+	stride := [3]int{size[Y] * size[Z], size[Z], 1}
+
+	for i := 0; i < size[X]; i++ {
+		for j := 0; j < size[Y]; j++ {
+			for k := 0; k < size[Z]; k++ {
+				I := i*stride[X] + j*stride[Y] + k
+				idx := [3]int{i, j, k}
+				for c := range idx {
+					next := I + stride[c]
+					if idx[c] == size[c]-1 {
+						if periodic[c] == 0 {
+							scale.Comp[c][I] = 1 // no neighbor, not used
+							continue
+						}
+						next = I - idx[c]*stride[c] // wraps around
+					}
+					scale.Comp[c][I] = float32(e.ExchangeScale(region(I), region(next)))
+				}
+			}
+		}
+	}
+	u.scale.SetMask(scale)
+}
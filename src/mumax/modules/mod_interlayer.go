@@ -0,0 +1,209 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package modules
+
+// This file implements the interlayer (RKKY) exchange module,
+// coupling two layers across a spacer, e.g. in synthetic antiferromagnets.
+// The energy per unit of interface area is
+//	-J1 (m1·m2) - J2 (m1·m2)²
+// J1 < 0 couples antiferromagnetically, J2 < 0 favors 90° alignment.
+//
+// The coupled layers are set by either
+//	sets('interlayer_z1', z1); sets('interlayer_z2', z2)
+//		cell layers z1 and z2 (z cell indices, starting from 0)
+//	sets('interlayer_region1', r1); sets('interlayer_region2', r2)
+//		regions r1 and r2 of regionDefinition (see regionindex() for named regions)
+// For regions, each column of cells along z couples its cell in r1
+// to the closest cell in r2, if it has both.
+
+import (
+	"fmt"
+	"math"
+	. "mumax/common"
+	. "mumax/engine"
+	"mumax/gpu"
+	"mumax/host"
+)
+
+// Register this module
+func init() {
+	RegisterModule("exchange/interlayer", "Interlayer (RKKY) exchange coupling between two layers or regions", LoadInterlayer)
+}
+
+func LoadInterlayer(e *Engine) {
+	LoadHField(e)
+	LoadMagnetization(e)
+	e.LoadModule("regions") // interlayer_region1,2 refer to regionDefinition
+
+	Hinter := e.AddNewQuant("H_inter", VECTOR, FIELD, Unit("A/m"), "interlayer exchange field")
+	J1 := e.AddNewQuant("J1", SCALAR, MASK, Unit("J/m2"), "bilinear interlayer exchange constant")
+	J2 := e.AddNewQuant("J2", SCALAR, MASK, Unit("J/m2"), "biquadratic interlayer exchange constant")
+	z1 := e.AddNewQuant("interlayer_z1", SCALAR, VALUE, Unit(""), "z cell index of the first coupled layer")
+	z2 := e.AddNewQuant("interlayer_z2", SCALAR, VALUE, Unit(""), "z cell index of the second coupled layer")
+	r1 := e.AddNewQuant("interlayer_region1", SCALAR, VALUE, Unit(""), "index of the first coupled region")
+	r2 := e.AddNewQuant("interlayer_region2", SCALAR, VALUE, Unit(""), "index of the second coupled region")
+	dz := e.AddNewQuant("interlayer_dz", SCALAR, FIELD, Unit(""), "number of cells to the coupled cell along z, 0 if not coupled")
+
+	e.Depends("interlayer_dz", "interlayer_z1", "interlayer_z2", "interlayer_region1", "interlayer_region2", REGIONS)
+	dz.SetUpdater(&interlayerDzUpdater{dz: dz, regionDef: e.Quant(REGIONS), z: [2]*Quant{z1, z2}, regions: [2]*Quant{r1, r2}})
+
+	hfield := e.Quant("H_eff")
+	sum := hfield.Updater().(*SumUpdater)
+	sum.AddParent("H_inter")
+	e.Depends("H_inter", "J1", "J2", "Msat", "m", "interlayer_dz")
+	Hinter.SetUpdater(&InterlayerUpdater{e.Quant("m"), Hinter, J1, J2, e.Quant("msat"), dz})
+}
+
+type InterlayerUpdater struct {
+	m, hinter, J1, J2, msat, dz *Quant
+}
+
+func (u *InterlayerUpdater) Update() {
+	e := GetEngine()
+	stream := u.hinter.Array().Stream
+	thickness := e.CellSize()[X] // internal X is user z
+	Mu0MsatMul_t := Mu0 * u.msat.Multiplier()[0] * thickness
+
+	gpu.InterlayerExchangeAsync(u.hinter.Array(), u.m.Array(), u.msat.Array(),
+		u.J1.Array(), u.J1.Multiplier()[0]/Mu0MsatMul_t,
+		u.J2.Array(), u.J2.Multiplier()[0]/Mu0MsatMul_t,
+		u.dz.Array(), stream)
+
+	stream.Sync()
+}
+
+// Sets up interlayer_dz from interlayer_z1,2 or interlayer_region1,2.
+type interlayerDzUpdater struct {
+	dz, regionDef *Quant
+	z, regions    [2]*Quant
+}
+
+func (u *interlayerDzUpdater) Update() {
+	size := GetEngine().GridSize() // internal order: size[X] is the number of z layers
+	dz := host.NewArray(1, size)
+	layer := size[Y] * size[Z]
+
+	z1, z2 := intPair(u.z)
+	r1, r2 := intPair(u.regions)
+	switch {
+	case z1 != z2 && r1 != r2:
+		panic(InputErr("set either interlayer_z1,2 or interlayer_region1,2, not both"))
+	case z1 != z2:
+		for _, z := range []int{z1, z2} {
+			if z < 0 || z >= size[X] {
+				panic(InputErr(fmt.Sprint("exchange/interlayer: layer ", z, " out of range [0, ", size[X]-1, "]")))
+			}
+		}
+		for c := 0; c < layer; c++ {
+			dz.List[z1*layer+c] = float32(z2 - z1)
+			dz.List[z2*layer+c] = float32(z1 - z2)
+		}
+	case r1 != r2:
+		def := u.regionDef.Buffer().List
+		coupled := 0
+		for c := 0; c < layer; c++ {
+			// closest pair of cells in r1 and r2 in this column
+			best, zr1, zr2 := size[X], -1, -1
+			for a := 0; a < size[X]; a++ {
+				if int(def[a*layer+c]+0.5) != r1 {
+					continue
+				}
+				for b := 0; b < size[X]; b++ {
+					if int(def[b*layer+c]+0.5) == r2 && abs(b-a) < best {
+						best, zr1, zr2 = abs(b-a), a, b
+					}
+				}
+			}
+			if zr1 >= 0 {
+				dz.List[zr1*layer+c] = float32(zr2 - zr1)
+				dz.List[zr2*layer+c] = float32(zr1 - zr2)
+				coupled++
+			}
+		}
+		if coupled == 0 {
+			Warn("exchange/interlayer: regions ", r1, " and ", r2, " have no cells above each other, no coupling")
+		}
+	default:
+		Warn("exchange/interlayer: set interlayer_z1,2 or interlayer_region1,2 to couple two layers")
+	}
+	u.dz.Array().CopyFromHost(dz)
+}
+
+// The values of two scalar VALUEs, which should be integers.
+func intPair(q [2]*Quant) (a, b int) {
+	var v [2]int
+	for i := range q {
+		x := q[i].Scalar()
+		if x != math.Floor(x) {
+			panic(InputErr(fmt.Sprint(q[i].Name(), " should be an integer, have: ", x)))
+		}
+		v[i] = int(x)
+	}
+	return v[0], v[1]
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Adds the interlayer exchange energy E_inter to micromag/energy.
+// Because of the biquadratic term, it is not proportional to m·H_inter,
+// so it is computed on the host.
+func LoadInterlayerEnergy(e *Engine) *Quant {
+	energy := e.AddNewQuant("E_inter", SCALAR, VALUE, Unit("J"), "Interlayer exchange energy")
+	e.Depends("E_inter", "J1", "J2", "m", "interlayer_dz")
+	area := e.CellSize()[Y] * e.CellSize()[Z]
+	energy.SetUpdater(&InterlayerEnergyUpdater{energy, e.Quant("m"), e.Quant("J1"), e.Quant("J2"), e.Quant("interlayer_dz"), area, -1})
+	return energy
+}
+
+// Computes the interlayer exchange energy on the host, in double precision.
+type InterlayerEnergyUpdater struct {
+	energy, m, J1, J2, dz *Quant
+	cellArea              float64
+	region                int // only sum over this region, -1 means all cells
+}
+
+func (u *InterlayerEnergyUpdater) Update() {
+	m, J1, J2, dz := u.m.Buffer().Comp, u.J1.Buffer().List, u.J2.Buffer().List, u.dz.Buffer().List
+	size := GetEngine().GridSize()
+	layer := size[Y] * size[Z]
+	var regions []float32
+	if u.region >= 0 {
+		regions = GetEngine().Quant(REGIONS).Buffer().List
+	}
+
+	sum := 0.
+	for i := range dz {
+		if dz[i] == 0 || regions != nil && int(regions[i]+0.5) != u.region {
+			continue
+		}
+		i2 := i + int(dz[i])*layer
+		dot := 0.
+		for c := range m {
+			dot += float64(m[c][i]) * float64(m[c][i2])
+		}
+		j1 := 0.5 * (float64(J1[i]) + float64(J1[i2]))
+		j2 := 0.5 * (float64(J2[i]) + float64(J2[i2]))
+		sum += -0.5 * (j1*dot + j2*dot*dot) // each pair is counted twice
+	}
+	u.energy.SetScalar(sum * u.cellArea)
+}
+
+// Energy in one region, e.g. E_inter_region2:
+// half of the coupling energy of each of its cells.
+func (u *InterlayerEnergyUpdater) RegionUpdater(out *Quant, region int) Updater {
+	GetEngine().Depends(out.Name(), "J1", "J2", "m", "interlayer_dz")
+	regional := *u
+	regional.energy = out
+	regional.region = region
+	return &regional
+}
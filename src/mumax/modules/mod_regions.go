@@ -13,6 +13,13 @@ import (
 	. "mumax/engine"
 )
 
+// The regions module defines the regionDefinition mask,
+// which holds the region index of each cell. Once loaded:
+//	defregion('top', box(0, 0, 5e-9))  adds the cells inside a shape to a named region
+//	setregion('Msat', 2, [600e3])   sets a parameter in region 2
+//	tabulate(['m_region2', 'E_ex_region2'], 'regions.txt')
+// See engine/regions.go and engine/geometry.go
+
 // Register this module
 func init() {
 	RegisterModule("regions", "Region definition", LoadRegions)
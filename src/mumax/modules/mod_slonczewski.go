@@ -26,7 +26,7 @@ func LoadSlonczewskiTorque(e *Engine) {
 	e.LoadModule("llg") // needed for alpha, hfield, ...
 
 	// ============ New Quantities =============
-	e.AddNewQuant("t_fl", SCALAR, MASK, Unit(""), "Free layer thickness")
+	LoadFreeLayerThickness(e)
 
 	labmda := e.AddNewQuant("lambda", SCALAR, MASK, Unit(""), "Scattering control parameter")
 	labmda.SetValue([]float64{1.0})
@@ -49,6 +49,14 @@ func LoadSlonczewskiTorque(e *Engine) {
 	AddTermToQuant(e.Quant("torque"), stt)
 }
 
+// Loads the free layer thickness t_fl, shared by slonczewski and sot.
+func LoadFreeLayerThickness(e *Engine) {
+	if e.HasQuant("t_fl") {
+		return
+	}
+	e.AddNewQuant("t_fl", SCALAR, MASK, Unit(""), "Free layer thickness")
+}
+
 type slonczewskiUpdater struct {
 	stt *Quant
 }
@@ -0,0 +1,91 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package modules
+
+// Module implementing the spin-orbit torque of the spin Hall effect,
+// for a free layer of thickness t_fl on a heavy-metal layer carrying the current j.
+// The spin current is polarized along s = j × sot_normal, with sot_normal the interface normal
+// (default z). Its strength corresponds to a field
+//	H_so = theta_SH ħ |j| / (2 e μ0 Msat t_fl)
+// The damping-like torque γ xi_DL H_so m × (s × m) drives m towards s,
+// the field-like torque γ xi_FL H_so s × m acts like a field xi_FL H_so along s.
+
+import (
+	. "mumax/common"
+	. "mumax/engine"
+	"mumax/gpu"
+)
+
+// Register this module
+func init() {
+	RegisterModule("sot", "Spin-orbit torque (spin Hall effect).", LoadSOT)
+}
+
+func LoadSOT(e *Engine) {
+	e.LoadModule("llg") // needed for alpha, hfield, ...
+
+	// ============ New Quantities =============
+	LoadFreeLayerThickness(e)
+	e.AddNewQuant("theta_SH", SCALAR, MASK, Unit(""), "Spin Hall angle")
+	xiDL := e.AddNewQuant("xi_DL", SCALAR, MASK, Unit(""), "Damping-like spin-orbit torque efficiency")
+	xiDL.SetValue([]float64{1.0})
+	e.AddNewQuant("xi_FL", SCALAR, MASK, Unit(""), "Field-like spin-orbit torque efficiency")
+	normal := e.AddNewQuant("sot_normal", VECTOR, VALUE, Unit(""), "Interface normal, pointing from the heavy metal into the free layer")
+	normal.SetValue([]float64{1, 0, 0}) // internal X = user z
+	LoadUserDefinedCurrentDensity(e)
+	sot := e.AddNewQuant("sot", VECTOR, FIELD, Unit("/s"), "Spin-orbit torque")
+
+	// ============ Dependencies =============
+	e.Depends("sot", "theta_SH", "xi_DL", "xi_FL", "sot_normal", "t_fl", "j", "m", "msat", "alpha", "gamma")
+
+	// ============ Updating the torque =============
+	sot.SetUpdater(&sotUpdater{sot: sot})
+
+	// Add spin-orbit torque to LLG torque
+	AddTermToQuant(e.Quant("torque"), sot)
+}
+
+type sotUpdater struct {
+	sot *Quant
+}
+
+func (u *sotUpdater) Update() {
+	e := GetEngine()
+
+	sot := u.sot
+	m := e.Quant("m")
+	msat := e.Quant("msat")
+	theta := e.Quant("theta_SH")
+	xiDL := e.Quant("xi_DL")
+	xiFL := e.Quant("xi_FL")
+	normal := e.Quant("sot_normal")
+	curr := e.Quant("j")
+	alpha := e.Quant("alpha")
+	gamma := e.Quant("gamma").Scalar()
+	t_fl := e.Quant("t_fl")
+
+	// γ H_so / |j|, without the masks
+	pre := 0.
+	if theta.Multiplier()[0] != 0 && msat.Multiplier()[0] != 0 {
+		if t_fl.Multiplier()[0] == 0 {
+			panic(InputErr("sot: the free layer thickness t_fl should be set"))
+		}
+		pre = gamma * H_bar * theta.Multiplier()[0] / (2 * E * Mu0 * msat.Multiplier()[0] * t_fl.Multiplier()[0])
+	}
+
+	stream := sot.Array().Stream
+	gpu.SOTAsync(sot.Array(), m.Array(), msat.Array(),
+		curr.Array(), curr.Multiplier(),
+		theta.Array(),
+		xiDL.Array(), pre*xiDL.Multiplier()[0],
+		xiFL.Array(), pre*xiFL.Multiplier()[0],
+		t_fl.Array(),
+		alpha.Array(), alpha.Multiplier()[0],
+		normal.Multiplier(), stream)
+	stream.Sync()
+}
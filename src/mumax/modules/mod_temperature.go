@@ -11,7 +11,6 @@ package modules
 // Author: Arne Vansteenkiste
 
 import (
-	"cuda/curand"
 	. "mumax/common"
 	. "mumax/engine"
 	"mumax/gpu"
@@ -46,8 +45,8 @@ func LoadTempBrown(e *Engine) {
 
 // Updates the thermal field
 type TempBrownUpdater struct {
-	rng              []curand.Generator // Random number generator for each GPU
-	htherm           *Quant             // The quantity I will update
+	rng              gpu.RNG // Random number generator for each GPU
+	htherm           *Quant  // The quantity I will update
 	therm_seed       *Quant
 	therm_seed_cache int64
 	last_time        float64 // time of last htherm update
@@ -58,12 +57,7 @@ func NewTempBrownUpdater(htherm *Quant, therm_seed *Quant) Updater {
 	u.therm_seed = therm_seed
 	u.therm_seed_cache = -1e10
 	u.htherm = htherm
-	u.rng = make([]curand.Generator, gpu.NDevice())
-	for dev := range u.rng {
-		gpu.SetDeviceForIndex(dev)
-		u.rng[dev] = curand.CreateGenerator(curand.PSEUDO_DEFAULT)
-		//u.rng[dev].SetSeed(int64(dev)) // TODO: use proper seed
-	}
+	u.rng = gpu.NewRNG()
 	return u
 }
 
@@ -74,10 +68,7 @@ func (u *TempBrownUpdater) Update() {
 	therm_seed := int64(u.therm_seed.Scalar())
 
 	if therm_seed != u.therm_seed_cache {
-		for dev := range u.rng {
-			seed := therm_seed + int64(dev)
-			u.rng[dev].SetSeed(seed)
-		}
+		u.rng.SetSeed(therm_seed)
 	}
 
 	u.therm_seed_cache = therm_seed
@@ -103,14 +94,8 @@ func (u *TempBrownUpdater) Update() {
 
 	// Make standard normal noise
 	noise := u.htherm.Array()
-	devPointers := noise.Pointers()
-	N := int64(noise.PartLen4D())
 	// Fills H_therm with gaussian noise.
-	// CURAND does not provide an out-of-the-box way to do this in parallel over the GPUs
-	for dev := range u.rng {
-		gpu.SetDeviceForIndex(dev)
-		u.rng[dev].GenerateNormal(uintptr(devPointers[dev]), N, 0, 1)
-	}
+	u.rng.GenerateNormal(noise)
 
 	// Scale the noise according to local parameters
 	cellSize := e.CellSize()
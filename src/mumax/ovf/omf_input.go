@@ -12,45 +12,55 @@ package ovf
 import ()
 
 //TODO: move to package omf
-
 import (
 	"bufio"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	. "mumax/common"
 	"mumax/engine"
 	"mumax/host"
 	"strconv"
 	. "strings"
-	"unsafe"
 )
 
 func init() {
 	engine.RegisterInputFormat(".omf", ReadOMF)
+	engine.RegisterInputFormat(".ovf", ReadOMF)
 }
 
+// Reads an OOMMF vector field file: OVF 1.0 or 2.0,
+// in text, binary 4 or binary 8 format, on a rectangular or irregular mesh.
+// An OVF 2.0 file may hold any number of components (valuedim),
+// OVF 1.0 always holds 3. Vectors and tensors (3, 6 or 9 components)
+// are stored in the internal ZYX order, other components as in the file.
 func ReadOMF(file string) *host.Array {
+	data, _ := ReadOMFInfo(file)
+	return data
+}
+
+// Like ReadOMF, but also returns the header.
+func ReadOMFInfo(file string) (*host.Array, *Info) {
+	defer func() {
+		// tell which file is broken
+		if err := recover(); err != nil {
+			if e, ok := err.(IOErr); ok {
+				err = IOErr(file + ": " + string(e))
+			}
+			panic(err)
+		}
+	}()
+
 	in_ := OpenRDONLY(file)
+	defer in_.Close()
 	in := NewBlockingReader(bufio.NewReader(in_))
 	info := ReadHeader(in)
 
 	size := []int{info.Size[Z], info.Size[Y], info.Size[X]}
-	data := host.NewArray(3, size)
-
-	switch info.Format {
-	default:
-		panic("Unknown format: " + info.Format)
-	case "text":
-		readDataText(in, data)
-	case "binary":
-		switch info.DataFormat {
-		default:
-			panic("Unknown format: " + info.Format + " " + info.DataFormat)
-		case "4":
-			readDataBinary4(in, data)
-		}
-	}
-	return data
+	data := host.NewArray(info.ValueDim, size)
+	readData(in, info, data)
+	return data, info
 }
 
 // omf.Info represents the header part of an omf file.
@@ -58,6 +68,7 @@ func ReadOMF(file string) *host.Array {
 // Perhaps CheckErr() func
 type Info struct {
 	Desc            map[string]interface{}
+	Title           string
 	Size            [3]int
 	ValueMultiplier float32
 	ValueUnit       string
@@ -68,6 +79,12 @@ type Info struct {
 	DataFormat      string // 4 or 8
 	StepSize        [3]float32
 	MeshUnit        string
+	MeshType        string     // rectangular or irregular
+	Min, Max        [3]float64 // mesh bounds
+	PointCount      int        // number of points of an irregular mesh
+	ValueDim        int        // number of components
+	ValueLabels     []string   // OVF 2.0 only
+	ValueUnits      []string   // OVF 2.0 only
 }
 
 // Safe way to get Desc values: panics when key not present
@@ -89,70 +106,134 @@ func (i *Info) DescGetFloat32(key string) float32 {
 	return float32(fl)
 }
 
-func readDataText(in io.Reader, t *host.Array) {
-	size := t.Size3D
+// Reads the data section into t, which has info.ValueDim components.
+// Values are multiplied by info.ValueMultiplier.
+func readData(in io.Reader, info *Info, t *host.Array) {
+	ncomp := info.ValueDim
+	irregular := info.MeshType == "irregular"
+
+	// each node has ncomp values, preceded by its position on an irregular mesh
+	ncol := ncomp
+	if irregular {
+		ncol += 3
+	}
+	var next func([]float64)
+	switch info.Format {
+	default:
+		panic(IOErr("unknown data format: " + info.Format))
+	case "text":
+		next = textReader(in)
+	case "binary":
+		next = binaryReader(in, info)
+	}
+
+	size := info.Size
+	nodes := size[X] * size[Y] * size[Z]
+	if irregular {
+		nodes = info.PointCount
+	}
 	data := t.Array
-	// Here we loop over X,Y,Z, not Z,Y,X, because
-	// internal in C-order == external in Fortran-order
-	for i := 0; i < size[X]; i++ {
-		for j := 0; j < size[Y]; j++ {
-			for k := 0; k < size[Z]; k++ {
-				for c := Z; c >= X; c-- {
-					_, err := fmt.Fscan(in, &data[c][i][j][k])
-					if err != nil {
-						panic(err)
-					}
-				}
-			}
+	mult := float64(info.ValueMultiplier)
+	values := make([]float64, ncol)
+	for n := 0; n < nodes; n++ {
+		next(values)
+		// Here x runs fastest, z slowest, because
+		// internal in C-order == external in Fortran-order
+		x, y, z := n%size[X], (n/size[X])%size[Y], n/(size[X]*size[Y])
+		v := values
+		if irregular {
+			x, y, z = info.cellIndex(values[:3])
+			v = values[3:]
+		}
+		for c := 0; c < ncomp; c++ {
+			data[componentIndex(c, ncomp)][z][y][x] = float32(mult * v[c])
 		}
 	}
 }
 
-func readDataBinary4(in io.Reader, t *host.Array) {
+// Returns a function that reads the next values from text data.
+func textReader(in io.Reader) func([]float64) {
+	return func(values []float64) {
+		for i := range values {
+			_, err := fmt.Fscan(in, &values[i])
+			if err != nil {
+				panic(IOErr("reading text data: " + err.Error()))
+			}
+		}
+	}
+}
 
-	size := t.Size3D
-	data := t.Array
+// Returns a function that reads the next values from binary 4 or binary 8 data,
+// after checking the control number.
+// OVF 1.0 is big-endian, OVF 2.0 is little-endian.
+func binaryReader(in io.Reader, info *Info) func([]float64) {
+	var order binary.ByteOrder = binary.BigEndian
+	if info.OVFVersion == 2 {
+		order = binary.LittleEndian
+	}
 
-	var bytes4 [4]byte
-	bytes := bytes4[:]
+	var decode func([]byte) float64
+	var control float64
+	switch info.DataFormat {
+	default:
+		panic(IOErr("unknown data format: binary " + info.DataFormat))
+	case "4":
+		decode = func(b []byte) float64 { return float64(math.Float32frombits(order.Uint32(b))) }
+		control = OMF_CONTROL_NUMBER
+	case "8":
+		decode = func(b []byte) float64 { return math.Float64frombits(order.Uint64(b)) }
+		control = OMF_CONTROL_NUMBER8
+	}
+	width := atoi(info.DataFormat) // bytes per number
 
-	in.Read(bytes)                                                                  // TODO: check for error, also on output (iotool.MustReader/MustWriter?)
-	bytes[0], bytes[1], bytes[2], bytes[3] = bytes[3], bytes[2], bytes[1], bytes[0] // swap endianess
+	var buf []byte
+	read := func(n int) []byte {
+		if cap(buf) < n {
+			buf = make([]byte, n)
+		}
+		buf = buf[:n]
+		if _, err := io.ReadFull(in, buf); err != nil {
+			panic(IOErr("reading binary data: " + err.Error()))
+		}
+		return buf
+	}
 
 	// OOMMF requires this number to be first to check the format
-	var controlnumber float32 = 0.
-
-	// Wicked conversion form float32 [4]byte in big-endian
-	// encoding/binary is too slow
-	// Inlined for performance, terabytes of data will pass here...
-	controlnumber = *((*float32)(unsafe.Pointer(&bytes4)))
-	// 	fmt.Println("Control number:", controlnumber)
-	if controlnumber != OMF_CONTROL_NUMBER {
-		panic("invalid control number: " + fmt.Sprint(controlnumber))
+	if c := decode(read(width)); c != control {
+		panic(IOErr(fmt.Sprint("invalid control number: ", c, ", expecting ", control, " (wrong byte order?)")))
 	}
 
-	// Here we loop over X,Y,Z, not Z,Y,X, because
-	// internal in C-order == external in Fortran-order
-	for i := 0; i < size[X]; i++ {
-		for j := 0; j < size[Y]; j++ {
-			for k := 0; k < size[Z]; k++ {
-				for c := Z; c >= X; c-- {
-					n, err := in.Read(bytes) // TODO: check for error, also on output (iotool.MustReader/MustWriter?, have to block until all input is read)
-					if err != nil || n != 4 {
-						panic(err)
-					}
-					bytes[0], bytes[1], bytes[2], bytes[3] = bytes[3], bytes[2], bytes[1], bytes[0] // swap endianess
-					data[c][i][j][k] = *((*float32)(unsafe.Pointer(&bytes4)))
-				}
-			}
+	return func(values []float64) {
+		b := read(width * len(values))
+		for i := range values {
+			values[i] = decode(b[i*width:])
 		}
 	}
+}
 
+// Cell of the grid spanned by the mesh bounds and step sizes
+// that holds the position of a point of an irregular mesh.
+// The point should lie on the cell center.
+func (info *Info) cellIndex(pos []float64) (x, y, z int) {
+	var index [3]int
+	for c := range index {
+		step := float64(info.StepSize[c])
+		f := (pos[c]-info.Min[c])/step - 0.5
+		index[c] = int(math.Floor(f + 0.5))
+		if index[c] < 0 || index[c] >= info.Size[c] || math.Abs(f-float64(index[c])) > 0.01 {
+			panic(IOErr(fmt.Sprint("irregular mesh: point (", pos[0], ", ", pos[1], ", ", pos[2],
+				") does not lie on a cell center of the ", info.Size[X], "x", info.Size[Y], "x", info.Size[Z], " grid")))
+		}
+	}
+	return index[X], index[Y], index[Z]
 }
 
 // INTERNAL: Splits "# key: value" into "key", "value"
 func parseHeaderLine(str string) (key, value string) {
 	strs := SplitN(str, ":", 2)
+	if len(strs) != 2 {
+		panic(IOErr("syntax error in header: " + str))
+	}
 	key = Trim(strs[0], "# ")
 	value = Trim(strs[1], "# ")
 	return
@@ -170,25 +251,79 @@ func ReadHeader(in io.Reader) *Info {
 	desc := make(map[string]interface{})
 	info := new(Info)
 	info.Desc = desc
+	info.ValueMultiplier = 1
 
 	line, eof := ReadLine(in)
 	for !eof && !isHeaderEnd(line) {
+		// OVF 2.0 comments start with ##
+		if i := Index(line, "##"); i >= 0 {
+			line = line[:i]
+		}
+		line = TrimSpace(line)
+		switch {
+		case Trim(line, "# ") == "":
+			line, eof = ReadLine(in)
+			continue
+		case HasPrefix(ToLower(Replace(line, " ", "", -1)), "#oommfovf2.0"):
+			info.OVFVersion = 2
+			line, eof = ReadLine(in)
+			continue
+		}
 		key, value := parseHeaderLine(line)
 
 		switch ToLower(key) {
 		default:
-			panic("Unknown key: " + key)
+			panic(IOErr("unknown header key: " + key))
 			// ignored
-		case "oommf", "segment count", "begin", "title", "meshtype", "xbase", "ybase", "zbase", "xstepsize", "ystepsize", "zstepsize", "xmin", "ymin", "zmin", "xmax", "ymax", "zmax", "valuerangeminmag", "valuerangemaxmag", "end":
+		case "segment count", "begin", "xbase", "ybase", "zbase", "valuerangeminmag", "valuerangemaxmag", "end", "boundary":
+		case "oommf":
+			// OVF 1.0: "rectangular mesh v1.0" or "irregular mesh v1.0"
+			info.OVFVersion = 1
+		case "title":
+			info.Title = value
+		case "meshtype":
+			info.MeshType = ToLower(value)
+			if info.MeshType != "rectangular" && info.MeshType != "irregular" {
+				panic(IOErr("unknown meshtype: " + value))
+			}
 		case "xnodes":
 			info.Size[X] = atoi(value)
 		case "ynodes":
 			info.Size[Y] = atoi(value)
 		case "znodes":
 			info.Size[Z] = atoi(value)
+		case "xstepsize":
+			info.StepSize[X] = float32(atof(value))
+		case "ystepsize":
+			info.StepSize[Y] = float32(atof(value))
+		case "zstepsize":
+			info.StepSize[Z] = float32(atof(value))
+		case "xmin":
+			info.Min[X] = atof(value)
+		case "ymin":
+			info.Min[Y] = atof(value)
+		case "zmin":
+			info.Min[Z] = atof(value)
+		case "xmax":
+			info.Max[X] = atof(value)
+		case "ymax":
+			info.Max[Y] = atof(value)
+		case "zmax":
+			info.Max[Z] = atof(value)
+		case "pointcount":
+			info.PointCount = atoi(value)
 		case "valuemultiplier":
+			info.ValueMultiplier = float32(atof(value))
 		case "valueunit":
+			info.ValueUnit = value
+		case "valuedim":
+			info.ValueDim = atoi(value)
+		case "valuelabels":
+			info.ValueLabels = splitList(value)
+		case "valueunits":
+			info.ValueUnits = splitList(value)
 		case "meshunit":
+			info.MeshUnit = value
 			// desc tags: parse further and add to metadata table
 		case "desc":
 			strs := SplitN(value, ":", 2)
@@ -205,28 +340,124 @@ func ReadHeader(in io.Reader) *Info {
 
 		line, eof = ReadLine(in)
 	}
+	if eof {
+		panic(IOErr("unexpected end of file: no data"))
+	}
 	// the remaining line should now be the begin:data clause
 	key, value := parseHeaderLine(line)
 	value = TrimSpace(value)
-	strs := SplitN(value, " ", 3)
-	if ToLower(key) != "begin" || ToLower(strs[0]) != "data" {
-		panic("Expected: Begin: Data")
+	strs := Fields(value)
+	if ToLower(key) != "begin" || len(strs) < 2 || ToLower(strs[0]) != "data" {
+		panic(IOErr("expected: Begin: Data, have: " + line))
 	}
 	info.Format = ToLower(strs[1])
 	if len(strs) >= 3 { // dataformat for text is empty
 		info.DataFormat = strs[2]
 	}
+
+	checkHeader(info)
 	return info
 }
 
+// Internal index of component c out of ncomp:
+// swapped to ZYX order for vectors and tensors, unchanged otherwise.
+func componentIndex(c, ncomp int) int {
+	switch ncomp {
+	case 1, 3, 6, 9:
+		return SwapIndex(c, ncomp)
+	}
+	return c
+}
+
+// Checks that the header has all we need to read the data.
+func checkHeader(info *Info) {
+	switch info.OVFVersion {
+	default:
+		panic(IOErr("not an OOMMF OVF 1.0 or 2.0 file"))
+	case 1:
+		// OVF 1.0 holds vectors, valuedim is not in the header
+		info.ValueDim = 3
+	case 2:
+		if info.ValueDim == 0 {
+			panic(IOErr("OVF 2.0 header misses valuedim"))
+		}
+	}
+	if info.ValueDim < 1 {
+		panic(IOErr(fmt.Sprint("valuedim ", info.ValueDim, ": need at least 1")))
+	}
+
+	switch info.MeshType {
+	default:
+		panic(IOErr("header misses meshtype"))
+	case "rectangular":
+		if info.Size[X] <= 0 || info.Size[Y] <= 0 || info.Size[Z] <= 0 {
+			panic(IOErr(fmt.Sprint("rectangular mesh needs xnodes, ynodes, znodes > 0, have ", info.Size[X], ", ", info.Size[Y], ", ", info.Size[Z])))
+		}
+	case "irregular":
+		// We can only read irregular meshes whose points lie on a grid,
+		// which is defined by the step sizes and the mesh bounds.
+		for c := range info.StepSize {
+			if info.StepSize[c] <= 0 {
+				panic(IOErr("irregular mesh without xstepsize, ystepsize, zstepsize can not be mapped onto a grid"))
+			}
+			info.Size[c] = int(math.Floor((info.Max[c]-info.Min[c])/float64(info.StepSize[c]) + 0.5))
+			if info.Size[c] <= 0 {
+				panic(IOErr("irregular mesh: xmin, ymin, zmin must be smaller than xmax, ymax, zmax"))
+			}
+		}
+		if info.PointCount <= 0 {
+			panic(IOErr("irregular mesh header misses pointcount"))
+		}
+	}
+}
+
+// Splits a Tcl-style list like `a {b c} "d e"` into a, b c, d e.
+func splitList(list string) []string {
+	var words []string
+	for {
+		list = TrimSpace(list)
+		if list == "" {
+			return words
+		}
+		end := " "
+		switch list[0] {
+		case '{':
+			end = "}"
+			list = list[1:]
+		case '"':
+			end = `"`
+			list = list[1:]
+		}
+		i := Index(list, end)
+		if i < 0 {
+			i = len(list)
+		}
+		words = append(words, list[:i])
+		if i < len(list) {
+			i++
+		}
+		list = list[i:]
+	}
+	return words
+}
+
+
 func atoi(a string) int {
-	i, err := strconv.Atoi(a)
+	i, err := strconv.Atoi(TrimSpace(a))
 	if err != nil {
-		panic(err)
+		panic(IOErr("header: " + err.Error()))
 	}
 	return i
 }
 
+func atof(a string) float64 {
+	f, err := strconv.ParseFloat(TrimSpace(a), 64)
+	if err != nil {
+		panic(IOErr("header: " + err.Error()))
+	}
+	return f
+}
+
 // Blocks until all requested bytes are read.
 type BlockingReader struct {
 	In io.Reader
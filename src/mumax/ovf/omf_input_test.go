@@ -0,0 +1,124 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package ovf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	. "mumax/common"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const ovf2Header = `# OOMMF OVF 2.0
+#
+# Segment count: 1
+# Begin: Segment
+# Begin: Header
+# Title: test ## a comment
+# meshtype: rectangular
+# meshunit: m
+# xmin: 0
+# ymin: 0
+# zmin: 0
+# xmax: 2e-9
+# ymax: 1e-9
+# zmax: 1e-9
+# valuedim: %v
+# valuelabels: %v
+# valueunits: %v
+# xbase: 0.5e-9
+# ybase: 0.5e-9
+# zbase: 0.5e-9
+# xnodes: 2
+# ynodes: 1
+# znodes: 1
+# xstepsize: 1e-9
+# ystepsize: 1e-9
+# zstepsize: 1e-9
+# End: Header
+# Begin: Data %v
+`
+
+func writeTestFile(t *testing.T, name string, content []byte) string {
+	file := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(file, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestReadOVF2(t *testing.T) {
+	// text, scalar
+	text := fmt.Sprintf(ovf2Header, 1, "{energy density}", "J/m3", "Text") + "1.5\n-2\n# End: Data Text\n"
+	a := ReadOMF(writeTestFile(t, "text.ovf", []byte(text)))
+	if a.NComp() != 1 || a.Array[0][0][0][0] != 1.5 || a.Array[0][0][0][1] != -2 {
+		t.Error("text: have", a.Array)
+	}
+
+	// binary 8, vector, little-endian
+	buf := bytes.NewBufferString(fmt.Sprintf(ovf2Header, 3, "m_x m_y m_z", "1 1 1", "Binary 8"))
+	for _, v := range []float64{OMF_CONTROL_NUMBER8, 1, 2, 3, 4, 5, 6} {
+		binary.Write(buf, binary.LittleEndian, v)
+	}
+	a = ReadOMF(writeTestFile(t, "binary8.ovf", buf.Bytes()))
+	// internal component order is ZYX
+	if a.NComp() != 3 || a.Array[Z][0][0][0] != 1 || a.Array[X][0][0][0] != 3 || a.Array[X][0][0][1] != 6 {
+		t.Error("binary 8: have", a.Array)
+	}
+
+	// text, 2 components, stored in the order of the file
+	text = fmt.Sprintf(ovf2Header, 2, "a b", "1 1", "Text") + "1 2\n3 4\n# End: Data Text\n"
+	a = ReadOMF(writeTestFile(t, "valuedim2.ovf", []byte(text)))
+	if a.NComp() != 2 || a.Array[0][0][0][0] != 1 || a.Array[1][0][0][0] != 2 || a.Array[0][0][0][1] != 3 || a.Array[1][0][0][1] != 4 {
+		t.Error("valuedim 2: have", a.Array)
+	}
+
+	info := ReadHeader(strings.NewReader(fmt.Sprintf(ovf2Header, 1, "{energy density}", "J/m3", "Text")))
+	if info.OVFVersion != 2 || len(info.ValueLabels) != 1 || info.ValueLabels[0] != "energy density" || info.ValueUnits[0] != "J/m3" {
+		t.Error("header: have", info)
+	}
+}
+
+func TestReadIrregular(t *testing.T) {
+	header := `# OOMMF: irregular mesh v1.0
+# Segment count: 1
+# Begin: Segment
+# Begin: Header
+# meshtype: irregular
+# meshunit: m
+# xmin: 0
+# ymin: 0
+# zmin: 0
+# xmax: 2
+# ymax: 1
+# zmax: 1
+%v# pointcount: 1
+# End: Header
+# Begin: Data Text
+1.5 0.5 0.5  1 0 0
+# End: Data Text
+`
+	steps := "# xstepsize: 1\n# ystepsize: 1\n# zstepsize: 1\n"
+	a := ReadOMF(writeTestFile(t, "irregular.omf", []byte(fmt.Sprintf(header, steps))))
+	if a.Array[Z][0][0][1] != 1 || a.Array[Z][0][0][0] != 0 {
+		t.Error("irregular: have", a.Array)
+	}
+
+	// without step sizes, the points can not be put on a grid
+	defer func() {
+		err := recover()
+		if _, ok := err.(IOErr); !ok || !strings.Contains(fmt.Sprint(err), "stepsize") {
+			t.Error("irregular without step sizes: have", err)
+		}
+	}()
+	ReadOMF(writeTestFile(t, "nosteps.omf", []byte(fmt.Sprintf(header, ""))))
+}
@@ -50,7 +50,8 @@ func (f *FormatOmf) Write(out io.Writer, q *Quant, options []string) {
 }
 
 const (
-	OMF_CONTROL_NUMBER = 1234567.0 // The omf format requires the first encoded number in the binary data section to be this control number
+	OMF_CONTROL_NUMBER  = 1234567.0         // The omf format requires the first encoded number in the binary data section to be this control number
+	OMF_CONTROL_NUMBER8 = 123456789012345.0 // Control number for binary 8 data
 )
 
 func writeOmfData(out io.Writer, q *Quant, dataformat string) {
@@ -129,8 +130,10 @@ func writeOmfBinary4(out io.Writer, array *host.Array) {
 		for j := 0; j < gridsize[Y]; j++ {
 			for k := 0; k < gridsize[Z]; k++ {
 				for c := 0; c < ncomp; c++ {
-					// dirty conversion from float32 to [4]byte
-					bytes = (*[4]byte)(unsafe.Pointer(&data[SwapIndex(c, ncomp)][i][j][k]))[:]
+					// dirty conversion from float32 to [4]byte,
+					// of a copy so that the swap does not touch the quantity's buffer
+					value := data[SwapIndex(c, ncomp)][i][j][k]
+					bytes = (*[4]byte)(unsafe.Pointer(&value))[:]
 					bytes[0], bytes[1], bytes[2], bytes[3] = bytes[3], bytes[2], bytes[1], bytes[0]
 					out.Write(bytes)
 				}
@@ -94,7 +94,7 @@ func writeOvf2Header(out io.Writer, q *Quant) {
 		labels = []interface{}{name}
 	} else {
 		for i := 0; i < q.NComp(); i++ {
-			labels = append(labels, name+"_"+string('x'+i))
+			labels = append(labels, name+"_"+string(rune('x'+i)))
 		}
 	}
 	hdr(out, "valuedim", q.NComp())
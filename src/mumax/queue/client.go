@@ -0,0 +1,71 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package queue
+
+// This file implements the client side of the daemon's RPC interface,
+// used by the mumax2-queue commands and by other programs, like the template sweep driver.
+
+import (
+	"net/rpc"
+	"path/filepath"
+)
+
+// Default unix socket of the daemon.
+const DEFAULT_SOCK = "/tmp/mumax2-queue.sock"
+
+// A connection to the daemon.
+// The daemon knows the user from the connection,
+// submitted tasks belong to that user.
+type Client struct {
+	rpc *rpc.Client
+}
+
+// Connects to the daemon listening on the unix socket.
+func Dial(sock string) (*Client, error) {
+	c, err := rpc.Dial("unix", sock)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{c}, nil
+}
+
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// Queues the input files, returns their task IDs.
+func (c *Client) Submit(files []string, megabytes int, force bool) (ids []int, err error) {
+	args := SubmitArgs{Megabytes: megabytes, Force: force}
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return nil, err
+		}
+		args.Files = append(args.Files, abs)
+	}
+	err = c.rpc.Call("Server.Submit", args, &ids)
+	return
+}
+
+// Cancels the user's tasks.
+func (c *Client) Cancel(ids []int) error {
+	var ok bool
+	return c.rpc.Call("Server.Cancel", CancelArgs{ids}, &ok)
+}
+
+// Returns all tasks, in order of submission.
+func (c *Client) Tasks() (list []Task, err error) {
+	err = c.rpc.Call("Server.List", 0, &list)
+	return
+}
+
+// Returns the nodes and GPUs with their state.
+func (c *Client) Info() (info string, err error) {
+	err = c.rpc.Call("Server.Info", 0, &info)
+	return
+}
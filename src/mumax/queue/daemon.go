@@ -0,0 +1,237 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package queue
+
+// This file implements the queue daemon. Clients (see main.go) talk to it
+// with net/rpc over a unix socket. The daemon identifies the user behind
+// each connection by the socket's peer credentials, not by anything the client sends.
+//
+// Who may connect depends on how the daemon runs:
+//	as root: everybody. Each task runs as the user who submitted it.
+//	otherwise: only the daemon's owner, or the members of -group.
+//	           All tasks run as the daemon's owner, so the group should only
+//	           contain users who may run code under that account.
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Arguments for Server.Submit.
+type SubmitArgs struct {
+	Files     []string // absolute paths
+	Megabytes int
+	Force     bool
+}
+
+// Arguments for Server.Cancel.
+type CancelArgs struct {
+	IDs []int
+}
+
+// The user at the other end of a connection.
+type caller struct {
+	uid, gid int
+	name     string
+}
+
+// RPC methods served by the daemon, to one connection.
+type Server struct {
+	caller caller
+}
+
+// Queues the files, returns their task IDs.
+func (s *Server) Submit(args SubmitArgs, ids *[]int) error {
+	if freeGPUEver(args.Megabytes) == nil {
+		return fmt.Errorf("no GPU has %v MB", args.Megabytes)
+	}
+	for _, f := range args.Files {
+		*ids = append(*ids, Submit(f, s.caller, args.Megabytes, args.Force).ID)
+	}
+	return nil
+}
+
+// Cancels the tasks, stops at the first error.
+func (s *Server) Cancel(args CancelArgs, ok *bool) error {
+	for _, id := range args.IDs {
+		if err := Cancel(id, s.caller.uid); err != nil {
+			return err
+		}
+	}
+	*ok = true
+	return nil
+}
+
+// Returns all tasks.
+func (s *Server) List(_ int, list *[]Task) error {
+	*list = Tasks()
+	return nil
+}
+
+// Returns the nodes and GPUs with their state.
+func (s *Server) Info(_ int, info *string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	buf := new(bytes.Buffer)
+	for _, n := range nodes {
+		fmt.Fprintln(buf, n)
+	}
+	*info = buf.String()
+	return nil
+}
+
+// GPU that could ever fit the memory, busy or not.
+func freeGPUEver(megabytes int) *GPU {
+	for _, n := range nodes {
+		for _, g := range n.gpus {
+			if g.megabytes >= megabytes {
+				return g
+			}
+		}
+	}
+	return nil
+}
+
+// Runs the daemon: serves clients on the socket until killed.
+// group, if not empty, may also connect when not running as root.
+func daemon(sock, gpus, group string) {
+	if conn, err := net.Dial("unix", sock); err == nil {
+		conn.Close()
+		fatal("a daemon is already running on ", sock)
+	}
+	os.Remove(sock) // stale socket of a daemon that was killed
+
+	setup(gpus)
+	if err := load(); err != nil {
+		fatal(err)
+	}
+	log.Println("nodes:", nodes)
+
+	// the socket is only accessible by the owner until its permissions are set,
+	// so nobody can connect in between
+	umask := syscall.Umask(0177)
+	listener, err := net.Listen("unix", sock)
+	syscall.Umask(umask)
+	if err != nil {
+		fatal(err)
+	}
+	defer listener.Close()
+	if err := setSockPerm(sock, group); err != nil {
+		fatal(err)
+	}
+
+	log.Println("serving on", sock)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			fatal(err)
+		}
+		go serve(conn.(*net.UnixConn))
+	}
+}
+
+// Lets everybody connect to the socket if the daemon runs as root
+// (tasks then run as their user), otherwise only the owner and the group.
+func setSockPerm(sock, group string) error {
+	if os.Getuid() == 0 {
+		return os.Chmod(sock, 0666)
+	}
+	if group == "" {
+		return nil // 0600 from the umask
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return err
+	}
+	gid, _ := strconv.Atoi(g.Gid)
+	if err := os.Chown(sock, -1, gid); err != nil {
+		return err
+	}
+	return os.Chmod(sock, 0660)
+}
+
+// Serves the RPC calls of one client, on behalf of the user who connected.
+func serve(conn *net.UnixConn) {
+	c, err := peer(conn)
+	if err != nil {
+		log.Println("rejecting connection:", err)
+		conn.Close()
+		return
+	}
+	server := rpc.NewServer()
+	server.Register(&Server{c})
+	server.ServeConn(conn)
+}
+
+// The user at the other end of the connection, from its peer credentials.
+func peer(conn *net.UnixConn) (caller, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return caller{}, err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+	if err != nil {
+		return caller{}, err
+	}
+	c := caller{uid: int(cred.Uid), gid: int(cred.Gid), name: fmt.Sprint(cred.Uid)}
+	if u, err := user.LookupId(c.name); err == nil {
+		c.name = u.Username
+	}
+	return c, nil
+}
+
+// Sets up the GPUs of localhost from a list like "0:3072,1:1024"
+// (device:megabytes). An empty list asks nvidia-smi.
+func setup(gpus string) {
+	if gpus == "" {
+		gpus = detectGPUs()
+	}
+	localhost := NewNode("localhost")
+	for _, gpu := range strings.Split(gpus, ",") {
+		split := strings.Split(gpu, ":")
+		if len(split) != 2 {
+			fatal("syntax error in -gpus: expecting device:megabytes, have ", gpu)
+		}
+		id, err1 := strconv.Atoi(split[0])
+		mb, err2 := strconv.Atoi(split[1])
+		if err1 != nil || err2 != nil {
+			fatal("syntax error in -gpus: expecting device:megabytes, have ", gpu)
+		}
+		localhost.AddGPU(NewGPU(id, mb))
+	}
+	AddNode(localhost)
+}
+
+// Lists the GPUs and their memory in the format of the -gpus flag, using nvidia-smi.
+func detectGPUs() string {
+	out, err := exec.Command("nvidia-smi", "--query-gpu=index,memory.total", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		fatal("could not detect GPUs with nvidia-smi (", err, "), please specify -gpus")
+	}
+	var gpus []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		gpus = append(gpus, strings.Replace(strings.Replace(line, ",", ":", 1), " ", "", -1))
+	}
+	return strings.Join(gpus, ",")
+}
@@ -5,16 +5,17 @@ import (
 )
 
 type GPU struct {
+	id        int // CUDA device number, passed to mumax2 -gpu
 	megabytes int
 	busy      bool
 }
 
-func NewGPU(megabytes int) *GPU {
-	return &GPU{megabytes, false}
+func NewGPU(id, megabytes int) *GPU {
+	return &GPU{id, megabytes, false}
 }
 
 func (g *GPU) String() string {
-	return fmt.Sprint(g.megabytes, "MB,", busyStr(g.busy))
+	return fmt.Sprint("gpu", g.id, ":", g.megabytes, "MB,", busyStr(g.busy))
 }
 
 func busyStr(busy bool) string {
@@ -1,31 +1,243 @@
 package queue
 
+// This file implements the mumax2-queue command:
+//
+//	mumax2-queue daemon [-gpus=0:3072,1:3072] [-mumax2=command] [-state=file] [-group=name]
+//	mumax2-queue submit [-mem=MB] [-f] file.py ...
+//	mumax2-queue list [-a]
+//	mumax2-queue cancel id ...
+//	mumax2-queue tail [-f] [-n=lines] id
+//	mumax2-queue info
+//
+// The daemon runs the submitted input files on the GPUs of the machine,
+// one task per GPU. The other commands talk to the daemon.
+
 import (
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
 )
 
 var (
 	nodes []*Node
-	queue []string
-
-	//done chan(*Task)
+	sock  string // unix socket of the daemon
 )
 
-func init() {
-
-}
+const USAGE = `usage: mumax2-queue [-sock=file] command [flags] [args]
+commands:
+	daemon [-gpus=0:3072,1:3072] [-mumax2=command] [-state=file] [-group=name]
+	submit [-mem=MB] [-f] file.py ...
+	list [-a]
+	cancel id ...
+	tail [-f] [-n=lines] id
+	info`
 
 func Main() {
+	flag.StringVar(&sock, "sock", DEFAULT_SOCK, "Unix socket of the daemon")
+	flag.Usage = func() { fmt.Fprintln(os.Stderr, USAGE) }
 	flag.Parse()
+	if flag.NArg() == 0 {
+		fatal(USAGE)
+	}
 
-	setup()
-
-	PrintInfo()
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	flags := flag.NewFlagSet(cmd, flag.ExitOnError)
+	switch cmd {
+	default:
+		fatal("unknown command: ", cmd, "\n", USAGE)
+	case "daemon":
+		gpus := flags.String("gpus", "", "GPUs to use, as device:megabytes, e.g. 0:3072,1:3072. Default: detect with nvidia-smi")
+		flags.StringVar(&mumax2, "mumax2", defaultMumax2(), "Command to run mumax2")
+		flags.StringVar(&stateFile, "state", os.Getenv("HOME")+"/.mumax2-queue.json", "File to save the tasks to")
+		group := flags.String("group", "", "Group that may submit tasks when the daemon does not run as root. They run as the daemon's user")
+		flags.Parse(args)
+		daemon(sock, *gpus, *group)
+	case "submit":
+		mem := flags.Int("mem", 0, "GPU memory needed, in MB")
+		force := flags.Bool("f", false, "Remove existing output directories")
+		flags.Parse(args)
+		submitMain(flags.Args(), *mem, *force)
+	case "list":
+		all := flags.Bool("a", false, "Also list finished tasks")
+		flags.Parse(args)
+		listMain(*all)
+	case "cancel":
+		flags.Parse(args)
+		cancelMain(flags.Args())
+	case "tail":
+		follow := flags.Bool("f", false, "Keep printing output until the task finishes")
+		n := flags.Int("n", 10, "Number of lines to print")
+		flags.Parse(args)
+		if flags.NArg() != 1 {
+			fatal("tail needs exactly 1 task id")
+		}
+		tailMain(parseID(flags.Arg(0)), *n, *follow)
+	case "info":
+		PrintInfo()
+	}
 }
 
 func PrintInfo() {
-	PrintNodes()
+	info, err := dial().Info()
+	check(err)
+	fmt.Print(info)
+}
+
+func submitMain(files []string, mem int, force bool) {
+	if len(files) == 0 {
+		fatal("no input files")
+	}
+	for _, f := range files {
+		_, err := os.Stat(f)
+		check(err)
+	}
+	ids, err := dial().Submit(files, mem, force)
+	check(err)
+	for i := range ids {
+		fmt.Println(ids[i], files[i])
+	}
+}
+
+func listMain(all bool) {
+	list, err := dial().Tasks()
+	check(err)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSER\tSTATE\tGPU\tMEM\tEXIT\tSUBMITTED\tFILE")
+	for _, t := range list {
+		if !all && !t.Active() {
+			continue
+		}
+		gpu, exit := "-", "-"
+		if t.GPU >= 0 {
+			gpu = fmt.Sprint(t.GPU)
+		}
+		if !t.Active() {
+			exit = fmt.Sprint(t.ExitStatus)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", t.ID, t.User, t.State, gpu, t.Megabytes, exit,
+			t.Submitted.Format("Jan 2 15:04"), t.File)
+	}
+	w.Flush()
+}
+
+func cancelMain(ids []string) {
+	if len(ids) == 0 {
+		fatal("no task ids")
+	}
+	var list []int
+	for _, id := range ids {
+		list = append(list, parseID(id))
+	}
+	check(dial().Cancel(list))
+}
+
+// Prints the last n lines of the task's output.
+// With follow, keeps printing new output until the task is finished.
+func tailMain(id, n int, follow bool) {
+	c := dial()
+	t := getTask(c, id)
+	if t.State == QUEUED && !follow {
+		fatal("task ", id, " has not started yet")
+	}
+	for t.State == QUEUED {
+		time.Sleep(time.Second)
+		t = getTask(c, id)
+	}
+
+	in, err := os.Open(t.LogFile())
+	check(err)
+	defer in.Close()
+	printTail(in, n)
+	for follow {
+		active := getTask(c, id).Active() // before copying, so no output is missed
+		_, err := io.Copy(os.Stdout, in)
+		check(err)
+		if !active {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// Prints the last n lines of the file, leaves the file offset at the end.
+func printTail(in *os.File, n int) {
+	const chunk = 4096
+	size, err := in.Seek(0, io.SeekEnd)
+	check(err)
+	// search backwards for the start of the n'th last line
+	start := size
+	buf := make([]byte, chunk)
+	lines := 0
+search:
+	for start > 0 {
+		off := start - chunk
+		if off < 0 {
+			off = 0
+		}
+		nread, err := in.ReadAt(buf[:start-off], off)
+		check(err)
+		for i := nread - 1; i >= 0; i-- {
+			if buf[i] == '\n' && off+int64(i) != size-1 {
+				lines++
+				if lines == n {
+					start = off + int64(i) + 1
+					break search
+				}
+			}
+		}
+		start = off
+	}
+	_, err = in.Seek(start, io.SeekStart)
+	check(err)
+	_, err = io.Copy(os.Stdout, in)
+	check(err)
+}
+
+func getTask(c *Client, id int) *Task {
+	list, err := c.Tasks()
+	check(err)
+	for i := range list {
+		if list[i].ID == id {
+			return &list[i]
+		}
+	}
+	fatal("no such task: ", id)
+	return nil
+}
+
+// Connects to the daemon, exits on error.
+// The connection is closed when the command exits.
+func dial() *Client {
+	c, err := Dial(sock)
+	if err != nil {
+		fatal("can not connect to the daemon (", err, "), start it with: mumax2-queue daemon")
+	}
+	return c
+}
+
+func parseID(s string) int {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		fatal("not a task id: ", s)
+	}
+	return id
+}
+
+// mumax2 next to the mumax2-queue executable, if present.
+func defaultMumax2() string {
+	exe, err := os.Executable()
+	if err == nil {
+		m := filepath.Join(filepath.Dir(exe), "mumax2")
+		if _, err := os.Stat(m); err == nil {
+			return m
+		}
+	}
+	return "mumax2"
 }
 
 func PrintNodes() {
@@ -34,13 +246,19 @@ func PrintNodes() {
 	}
 }
 
-func setup() {
-	localhost := NewNode("localhost")
-	localhost.AddGPU(NewGPU(512))
-	localhost.AddGPU(NewGPU(512))
-	AddNode(localhost)
-}
-
 func AddNode(n *Node) {
 	nodes = append(nodes, n)
 }
+
+// Exits on error.
+func check(err error) {
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// Prints the message and exits.
+func fatal(msg ...interface{}) {
+	fmt.Fprintln(os.Stderr, "mumax2-queue: "+fmt.Sprint(msg...))
+	os.Exit(1)
+}
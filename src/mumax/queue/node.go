@@ -20,6 +20,29 @@ func (n *Node) AddGPU(g *GPU) {
 	n.gpus = append(n.gpus, g)
 }
 
+// Returns the smallest free GPU with at least the requested memory,
+// or nil if there is none. Taking the smallest one that fits keeps
+// the large GPUs available for large tasks.
+func (n *Node) FreeGPU(megabytes int) *GPU {
+	var best *GPU
+	for _, g := range n.gpus {
+		if !g.busy && g.megabytes >= megabytes && (best == nil || g.megabytes < best.megabytes) {
+			best = g
+		}
+	}
+	return best
+}
+
+// Returns the GPU with the given device number, or nil.
+func (n *Node) GPU(id int) *GPU {
+	for _, g := range n.gpus {
+		if g.id == id {
+			return g
+		}
+	}
+	return nil
+}
+
 func (n *Node) String() string {
 	return n.host + ":" + fmt.Sprint(n.gpus)
 }
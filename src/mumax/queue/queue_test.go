@@ -14,5 +14,42 @@ import (
 )
 
 func TestQueue(t *testing.T) {
+	nodes = nil
+	localhost := NewNode("localhost")
+	localhost.AddGPU(NewGPU(0, 4096))
+	localhost.AddGPU(NewGPU(1, 1024))
+	AddNode(localhost)
 
+	tasks = []*Task{
+		NewTask(1, "a1.py", "alice", 0, false),
+		NewTask(2, "a2.py", "alice", 0, false),
+		NewTask(3, "a3.py", "alice", 2048, false),
+		NewTask(4, "b1.py", "bob", 0, false),
+	}
+
+	// first come, first served, on the smallest GPU that fits
+	task, gpu := next()
+	if task.ID != 1 || gpu.id != 1 {
+		t.Fatal("have", task, gpu)
+	}
+	task.State, gpu.busy = RUNNING, true
+
+	// bob goes before alice, who already has a task running
+	task, gpu = next()
+	if task.ID != 4 || gpu.id != 0 {
+		t.Fatal("have", task, gpu)
+	}
+	task.State, gpu.busy = RUNNING, true
+
+	// all GPUs busy
+	if task, gpu = next(); task != nil {
+		t.Fatal("have", task, gpu)
+	}
+
+	// alice's big task does not fit on the small GPU, her small one does
+	localhost.GPU(1).busy = false
+	task, gpu = next()
+	if task.ID != 2 || gpu.id != 1 {
+		t.Fatal("have", task, gpu)
+	}
 }
@@ -0,0 +1,301 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package queue
+
+// This file implements the scheduler of the queue daemon:
+// queued tasks are started on free GPUs with enough memory,
+// each as a separate "mumax2 -gpu=N file" process, in its own process group.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	lock      sync.Mutex // protects all scheduler state, including the GPUs
+	tasks     []*Task    // all tasks, in order of submission
+	nextID    = 1
+	mumax2    = "mumax2" // command to run tasks
+	stateFile string     // tasks are saved here, "" means not saved
+)
+
+// Adds a task of the caller to the queue and starts it if possible.
+func Submit(file string, c caller, megabytes int, force bool) *Task {
+	lock.Lock()
+	defer lock.Unlock()
+	t := NewTask(nextID, file, c.name, megabytes, force)
+	t.Uid, t.Gid = c.uid, c.gid
+	nextID++
+	tasks = append(tasks, t)
+	log.Println("submitted", t)
+	schedule()
+	save()
+	return t
+}
+
+// Cancels a queued or running task. Only the user who submitted it,
+// or root, may do so.
+func Cancel(id int, uid int) error {
+	lock.Lock()
+	defer lock.Unlock()
+	t := findTask(id)
+	if t == nil {
+		return fmt.Errorf("no such task: %v", id)
+	}
+	if t.Uid != uid && uid != 0 {
+		return fmt.Errorf("task %v belongs to %v", id, t.User)
+	}
+	if !t.Active() {
+		return fmt.Errorf("task %v is already %v", id, t.State)
+	}
+	if t.State == RUNNING {
+		// kill the whole process group, so that e.g. python dies with mumax2
+		if err := syscall.Kill(-t.proc.Pid, syscall.SIGKILL); err != nil {
+			return err
+		}
+		// wait() will free the GPU and record the exit status
+	} else {
+		t.Finished = time.Now()
+	}
+	t.State = CANCELLED
+	log.Println("cancelled", t)
+	save()
+	return nil
+}
+
+// Returns a copy of all tasks.
+func Tasks() []Task {
+	lock.Lock()
+	defer lock.Unlock()
+	list := make([]Task, len(tasks))
+	for i, t := range tasks {
+		list[i] = *t
+		list[i].proc = nil
+	}
+	return list
+}
+
+func findTask(id int) *Task {
+	for _, t := range tasks {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// Starts queued tasks for as long as there are free GPUs that fit them.
+// Must be called with the lock held.
+func schedule() {
+	for {
+		t, g := next()
+		if t == nil {
+			return
+		}
+		start(t, g)
+	}
+}
+
+// Picks the next task to start, and the GPU to start it on.
+// To share the GPUs fairly, users with the fewest running tasks go first,
+// so that a user who submits many tasks does not claim all GPUs.
+// Among them, the earliest submitted task goes first.
+// Tasks that do not fit on any free GPU are skipped, so they do not
+// block smaller tasks behind them.
+// Returns nil if nothing can be started.
+func next() (*Task, *GPU) {
+	running := make(map[string]int)
+	for _, t := range tasks {
+		if t.State == RUNNING {
+			running[t.User]++
+		}
+	}
+	var best *Task
+	var bestGPU *GPU
+	for _, t := range tasks {
+		if t.State != QUEUED {
+			continue
+		}
+		g := freeGPU(t.Megabytes)
+		if g == nil {
+			continue
+		}
+		if best == nil || running[t.User] < running[best.User] {
+			best, bestGPU = t, g
+		}
+	}
+	return best, bestGPU
+}
+
+// Free GPU on any node, see Node.FreeGPU.
+func freeGPU(megabytes int) *GPU {
+	for _, n := range nodes {
+		if g := n.FreeGPU(megabytes); g != nil {
+			return g
+		}
+	}
+	return nil
+}
+
+// Starts mumax2 for the task on the GPU.
+// The output of mumax2 goes to the task's LogFile.
+func start(t *Task, g *GPU) {
+	t.GPU = g.id
+	t.Started = time.Now()
+
+	cmd := exec.Command("/bin/sh", "-c", START_SCRIPT, "sh",
+		t.OutputDir, t.LogFile(), fmt.Sprint(t.Force), mumax2, fmt.Sprint("-gpu=", g.id), t.File)
+	cmd.Stderr = os.Stderr // errors of the script itself go to the daemon's log
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if os.Getuid() == 0 {
+		if err := runAs(cmd, t); err != nil {
+			fail(t, err)
+			return
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		fail(t, err)
+		return
+	}
+
+	g.busy = true
+	t.State = RUNNING
+	t.proc = cmd.Process
+	log.Println("started", t, "on gpu", g.id, "pid", cmd.Process.Pid)
+	go wait(t, g, cmd)
+}
+
+// Shell script that starts a task: sh -c START_SCRIPT sh outputdir logfile force command args...
+// It creates the output directory and log file, with the permissions of the task's user.
+// With force, the existing output directory is removed first:
+// this can not be left to mumax2 -f, which would remove the log file as well.
+const START_SCRIPT = `out=$1 log=$2 force=$3
+shift 3
+if [ "$force" = true ]; then rm -rf -- "$out" || exit; fi
+mkdir -p -- "$out" || exit
+exec "$@" >"$log" 2>&1`
+
+// Makes the command run as the user who submitted the task,
+// with the user's groups and home directory.
+func runAs(cmd *exec.Cmd, t *Task) error {
+	u, err := user.LookupId(fmt.Sprint(t.Uid))
+	if err != nil {
+		return err
+	}
+	var groups []uint32
+	ids, _ := u.GroupIds()
+	for _, id := range ids {
+		if gid, err := strconv.Atoi(id); err == nil {
+			groups = append(groups, uint32(gid))
+		}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(t.Uid), Gid: uint32(t.Gid), Groups: groups}
+	cmd.Env = append(os.Environ(), "HOME="+u.HomeDir, "USER="+u.Username, "LOGNAME="+u.Username)
+	return nil
+}
+
+// Marks a task that could not be started as failed.
+func fail(t *Task, err error) {
+	log.Println("failed to start", t, ":", err)
+	t.State = FAILED
+	t.ExitStatus = -1
+	t.Finished = time.Now()
+}
+
+// Waits for the task to finish, then frees its GPU and starts the next task.
+func wait(t *Task, g *GPU, cmd *exec.Cmd) {
+	cmd.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+	g.busy = false
+	t.proc = nil
+	t.Finished = time.Now()
+	t.ExitStatus = cmd.ProcessState.ExitCode()
+	if t.State != CANCELLED {
+		if t.ExitStatus == 0 {
+			t.State = DONE
+		} else {
+			t.State = FAILED
+		}
+	}
+	log.Println("finished", t, "with status", t.ExitStatus)
+	schedule()
+	save()
+}
+
+// On-disk state of the queue.
+type savedState struct {
+	NextID int
+	Tasks  []*Task
+}
+
+// Saves all tasks to the state file, so they survive a restart of the daemon.
+// Must be called with the lock held.
+func save() {
+	if stateFile == "" {
+		return
+	}
+	bytes, err := json.MarshalIndent(savedState{nextID, tasks}, "", "\t")
+	if err == nil {
+		tmp := stateFile + ".tmp"
+		err = ioutil.WriteFile(tmp, bytes, 0666)
+		if err == nil {
+			err = os.Rename(tmp, stateFile)
+		}
+	}
+	if err != nil {
+		log.Println("saving state:", err)
+	}
+}
+
+// Loads the tasks saved by a previous daemon.
+// Tasks that were still running can not be tracked anymore and are marked failed,
+// queued tasks are scheduled again.
+func load() error {
+	bytes, err := ioutil.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var s savedState
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return fmt.Errorf("%v: %v", stateFile, err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	nextID = s.NextID
+	tasks = s.Tasks
+	for _, t := range tasks {
+		if t.State == RUNNING {
+			log.Println("lost track of", t, ": daemon was restarted")
+			t.State = FAILED
+			t.ExitStatus = -1
+		}
+		// saved by a daemon that did not record the uid: do not run it as root
+		if t.State == QUEUED && t.Uid == 0 && t.User != "root" && os.Getuid() == 0 {
+			log.Println("unknown uid of", t, ": please submit again")
+			t.State = FAILED
+			t.ExitStatus = -1
+		}
+	}
+	schedule()
+	save()
+	return nil
+}
@@ -1,7 +1,57 @@
 package queue
 
-import ()
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
 
+// State of a task.
+type TaskState string
+
+const (
+	QUEUED    TaskState = "queued"
+	RUNNING   TaskState = "running"
+	DONE      TaskState = "done"      // exited with status 0
+	FAILED    TaskState = "failed"    // non-zero exit status, or could not be started
+	CANCELLED TaskState = "cancelled" // cancelled by the user, possibly while running
+)
+
+// A mumax2 input file to be run.
+// Exported fields are sent to the client and saved in the state file.
 type Task struct {
-	command string
+	ID         int
+	File       string // absolute path of the input file
+	User       string
+	Uid, Gid   int  // of the user, the task runs as this user if the daemon runs as root
+	Megabytes  int  // GPU memory needed
+	Force      bool // remove the existing output directory first (like mumax2 -f)
+	State      TaskState
+	GPU        int // device the task runs or ran on, -1 if never started
+	ExitStatus int
+	OutputDir  string
+	Submitted  time.Time
+	Started    time.Time
+	Finished   time.Time
+	proc       *os.Process // nil unless running
+}
+
+func NewTask(id int, file, user string, megabytes int, force bool) *Task {
+	return &Task{ID: id, File: file, User: user, Megabytes: megabytes, Force: force,
+		State: QUEUED, GPU: -1, OutputDir: file + ".out", Submitted: time.Now()}
+}
+
+// File to which the output of mumax2 is written.
+func (t *Task) LogFile() string {
+	return filepath.Join(t.OutputDir, "queue.log")
+}
+
+// Queued or running.
+func (t *Task) Active() bool {
+	return t.State == QUEUED || t.State == RUNNING
+}
+
+func (t *Task) String() string {
+	return fmt.Sprint(t.ID, " ", t.User, " ", t.State, " ", t.File)
 }
@@ -0,0 +1,18 @@
+//  This file is part of MuMax, a high-performance micromagnetic simulator.
+//  Copyright 2011  Arne Vansteenkiste and Ben Van de Wiele.
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+// mumax2-queue runs mumax2 input files on the GPUs of a shared machine,
+// see mumax/queue.
+package main
+
+import (
+	"mumax/queue"
+)
+
+func main() {
+	queue.Main()
+}
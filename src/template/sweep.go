@@ -0,0 +1,323 @@
+//  Copyright 2012  Arne Vansteenkiste
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package main
+
+// This file implements the sweep mode: the generated files are run,
+// one after another or by the mumax2-queue daemon, and the tables
+// they write are collected into one table, with a column for each key. E.g.:
+//
+//	template -sweep -table=m.txt -last Bx=0:10 alpha=0.01,0.1 file.py.template
+//
+// runs file_Bx0_alpha0.01.py etc. and writes the last line of each m.txt
+// to file_m.txt, preceded by the values of Bx and alpha.
+//
+// A run that exited successfully is marked completed in its output directory,
+// and is not run again. So an interrupted sweep can be continued by
+// repeating the command, and new values can be added to a finished sweep.
+// An output directory that is not marked completed (of an interrupted run,
+// or of a run made outside the sweep) is left alone, and its run skipped,
+// unless -clean is given: then it is removed and the file is run again.
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mumax/queue"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	flag_sweep = flag.Bool("sweep", false, "Run the generated files and collect their tables")
+	flag_exec  = flag.String("exec", defaultMumax2(), "Sweep mode: command to run each file")
+	flag_queue = flag.Bool("queue", false, "Sweep mode: run the files with the mumax2-queue daemon instead of -exec")
+	flag_sock  = flag.String("sock", queue.DEFAULT_SOCK, "Unix socket of the mumax2-queue daemon")
+	flag_mem   = flag.Int("mem", 0, "Sweep mode with -queue: GPU memory needed per run, in MB")
+	flag_table = flag.String("table", "", "Sweep mode: table file to collect from each output directory, e.g. datatable.txt")
+	flag_last  = flag.Bool("last", false, "Sweep mode: only collect the last line of each table")
+	flag_out   = flag.String("o", "", "Sweep mode: file for the collected table, default: file_table.txt")
+	flag_clean = flag.Bool("clean", false, "Sweep mode: remove output directories of runs that were not completed by the sweep, and run them again")
+)
+
+// Marks an output directory as completed.
+const DONE_MARKER = "sweep.done"
+
+// Runs the documents that were not yet completed and collects their tables.
+// base is the template file name without extensions.
+func sweep(docs []*Document, base string) {
+	var todo []*Document
+	var unclean []string
+	for _, d := range docs {
+		if completed(d) {
+			fmt.Println("skipping", d.File(), ": already completed")
+			continue
+		}
+		if _, err := os.Stat(outputDir(d)); err == nil {
+			if !*flag_clean {
+				fmt.Fprintln(os.Stderr, "skipping", d.File(), ":", outputDir(d), "exists, but was not completed by the sweep")
+				unclean = append(unclean, d.File())
+				continue
+			}
+			fmt.Println("removing", outputDir(d))
+			if err := os.RemoveAll(outputDir(d)); err != nil {
+				fatal(err)
+			}
+		}
+		todo = append(todo, d)
+	}
+
+	var failed []string
+	if *flag_queue {
+		failed = runQueued(todo)
+	} else {
+		failed = runSerial(todo)
+	}
+
+	if *flag_table != "" {
+		out := *flag_out
+		if out == "" {
+			out = base + "_" + *flag_table
+		}
+		collect(docs, *flag_table, out)
+	}
+
+	if len(failed) > 0 {
+		fmt.Fprintln(os.Stderr, "template:", len(failed), "runs failed:", strings.Join(failed, " "))
+	}
+	if len(unclean) > 0 {
+		fmt.Fprintln(os.Stderr, "template:", len(unclean), "runs skipped because their output directory exists, use -clean to remove it and run them again:", strings.Join(unclean, " "))
+	}
+	if len(failed) > 0 || len(unclean) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Runs the files one after another with the -exec command.
+// Returns the files that failed.
+func runSerial(docs []*Document) (failed []string) {
+	command := strings.Fields(*flag_exec)
+	if len(command) == 0 {
+		fatal("-exec: no command")
+	}
+	for i, d := range docs {
+		fmt.Println("running", d.File(), "(", i+1, "of", len(docs), ")")
+		cmd := exec.Command(command[0], append(command[1:], d.File())...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintln(os.Stderr, d.File(), ":", err)
+			failed = append(failed, d.File())
+			continue
+		}
+		markCompleted(d)
+	}
+	return
+}
+
+// Submits the files to the mumax2-queue daemon and waits for them to finish.
+// Returns the files that failed.
+func runQueued(docs []*Document) (failed []string) {
+	if len(docs) == 0 {
+		return
+	}
+	c, err := queue.Dial(*flag_sock)
+	if err != nil {
+		fatal("can not connect to the mumax2-queue daemon: ", err)
+	}
+	defer c.Close()
+
+	files := make([]string, len(docs))
+	for i, d := range docs {
+		files[i] = d.File()
+	}
+	ids, err := c.Submit(files, *flag_mem, false)
+	if err != nil {
+		fatal(err)
+	}
+	pending := make(map[int]*Document)
+	for i, id := range ids {
+		pending[id] = docs[i]
+	}
+	fmt.Println("submitted", len(ids), "runs to mumax2-queue, waiting for them to finish")
+
+	for len(pending) > 0 {
+		time.Sleep(2 * time.Second)
+		tasks, err := c.Tasks()
+		if err != nil {
+			fatal(err)
+		}
+		for _, t := range tasks {
+			d, ok := pending[t.ID]
+			if !ok || t.Active() {
+				continue
+			}
+			delete(pending, t.ID)
+			if t.State == queue.DONE {
+				markCompleted(d)
+				fmt.Println("finished", d.File(), "(", len(ids)-len(pending), "of", len(ids), ")")
+			} else {
+				fmt.Fprintln(os.Stderr, d.File(), ":", t.State, ", exit status", t.ExitStatus, ", see", t.LogFile())
+				failed = append(failed, d.File())
+			}
+		}
+	}
+	return
+}
+
+// Appends the tables of the completed runs into one file,
+// with the values of the keys as first columns,
+// separated by the delimiter of the tables.
+func collect(docs []*Document, table, outfile string) {
+	out, err := os.Create(outfile)
+	if err != nil {
+		fatal(err)
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+
+	wroteHeader := false
+	for _, d := range docs {
+		if !completed(d) {
+			continue
+		}
+		fname := filepath.Join(outputDir(d), table)
+		lines := readTable(fname)
+		if len(lines) == 0 {
+			fmt.Fprintln(os.Stderr, fname, ": no data")
+			continue
+		}
+		delim := tableDelimiter(fname, lines)
+		header, data := splitTable(lines, delim)
+		if !wroteHeader && header != "" {
+			if strings.HasPrefix(header, "#") {
+				fmt.Fprintln(w, "#"+strings.Join(d.keys, delim)+delim+header[1:])
+			} else {
+				fmt.Fprintln(w, strings.Join(d.keys, delim)+delim+header)
+			}
+			wroteHeader = true
+		}
+		if *flag_last && len(data) > 0 {
+			data = data[len(data)-1:]
+		}
+		for _, l := range data {
+			fmt.Fprintln(w, strings.Join(d.vals, delim)+delim+l)
+		}
+	}
+	fmt.Println("collected", table, "in", outfile)
+}
+
+// Delimiter between the values of a table: as described in its
+// json sidecar (settableformat json), if present, or else guessed
+// from the last line, which holds numbers. Tab by default.
+func tableDelimiter(fname string, lines []string) string {
+	if f, err := os.Open(fname + ".json"); err == nil {
+		defer f.Close()
+		var desc struct {
+			Delimiter string `json:"delimiter"`
+		}
+		if err := json.NewDecoder(f).Decode(&desc); err != nil {
+			fatal(fname+".json: ", err)
+		}
+		if desc.Delimiter != "" {
+			return desc.Delimiter
+		}
+	}
+	last := lines[len(lines)-1]
+	for _, delim := range []string{"\t", ",", ";", " "} {
+		if strings.Contains(last, delim) {
+			return delim
+		}
+	}
+	return "\t"
+}
+
+// Splits the lines of a table into its header and data.
+// The header is the first line, if it starts with # (tsv)
+// or does not start with a number (csv and other formats).
+// Other lines starting with # are comments, and skipped.
+func splitTable(lines []string, delim string) (header string, data []string) {
+	first := strings.TrimSpace(strings.SplitN(lines[0], delim, 2)[0])
+	if _, err := strconv.ParseFloat(first, 64); err != nil || strings.HasPrefix(lines[0], "#") {
+		header = lines[0]
+		lines = lines[1:]
+	}
+	for _, l := range lines {
+		if !strings.HasPrefix(l, "#") {
+			data = append(data, l)
+		}
+	}
+	return
+}
+
+// Reads the non-empty lines of a table file.
+func readTable(fname string) []string {
+	f, err := os.Open(fname)
+	if err != nil {
+		fatal(err)
+	}
+	defer f.Close()
+	var lines []string
+	in := bufio.NewReader(f)
+	for {
+		l, err := in.ReadString('\n')
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+		if err == io.EOF {
+			return lines
+		}
+		if err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// Output directory of the document's run, like mumax2 uses by default.
+func outputDir(d *Document) string {
+	return d.File() + ".out"
+}
+
+func completed(d *Document) bool {
+	_, err := os.Stat(filepath.Join(outputDir(d), DONE_MARKER))
+	return err == nil
+}
+
+func markCompleted(d *Document) {
+	if err := os.MkdirAll(outputDir(d), 0777); err != nil {
+		fatal(err)
+	}
+	f, err := os.Create(filepath.Join(outputDir(d), DONE_MARKER))
+	if err != nil {
+		fatal(err)
+	}
+	f.Close()
+}
+
+// mumax2 next to the template executable, if present.
+func defaultMumax2() string {
+	exe, err := os.Executable()
+	if err == nil {
+		m := filepath.Join(filepath.Dir(exe), "mumax2")
+		if _, err := os.Stat(m); err == nil {
+			return m
+		}
+	}
+	return "mumax2"
+}
+
+// Like Error, but without the usage message.
+func fatal(msg ...interface{}) {
+	fmt.Fprintln(os.Stderr, "template: "+fmt.Sprint(msg...))
+	os.Exit(1)
+}
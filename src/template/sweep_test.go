@@ -0,0 +1,63 @@
+//  Copyright 2012  Arne Vansteenkiste
+//  Use of this source code is governed by the GNU General Public License version 3
+//  (as published by the Free Software Foundation) that can be found in the license.txt file.
+//  Note that you are welcome to modify this code under the condition that you do not remove any
+//  copyright notices and prominently state that you modified it, giving a relevant date.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tables in the formats of settableformat are collected
+// with their own delimiter, and their header only once.
+func TestCollect(t *testing.T) {
+	tests := []struct {
+		name    string
+		sidecar string // json description, if any
+		tables  [2]string
+		want    string
+	}{
+		{"tsv", "",
+			[2]string{"#t (s)\tm_x ()\t\n0\t1\t\n1e-12\t0.5\t\n", "#t (s)\tm_x ()\t\n0\t-1\t\n"},
+			"#Bx\tt (s)\tm_x ()\n0\t0\t1\n0\t1e-12\t0.5\n1\t0\t-1\n"},
+		{"csv", "",
+			[2]string{"t (s),m_x ()\n0,1\n1e-12,0.5\n", "t (s),m_x ()\n0,-1\n"},
+			"Bx,t (s),m_x ()\n0,0,1\n0,1e-12,0.5\n1,0,-1\n"},
+		{"delimiter", `{"delimiter": "; "}`,
+			[2]string{"t (s); m_x ()\n0; 1\n", "t (s); m_x ()\n0; -1\n"},
+			"Bx; t (s); m_x ()\n0; 0; 1\n1; 0; -1\n"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		template := &Document{content: "Bx={Bx}", name: filepath.Join(dir, "file"), ext: ".py"}
+		var docs []*Document
+		for i, val := range []string{"0", "1"} {
+			d := template.Replace("Bx", val)
+			markCompleted(d)
+			table := filepath.Join(outputDir(d), "m.txt")
+			if err := os.WriteFile(table, []byte(test.tables[i]), 0666); err != nil {
+				t.Fatal(err)
+			}
+			if test.sidecar != "" {
+				if err := os.WriteFile(table+".json", []byte(test.sidecar), 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+			docs = append(docs, d)
+		}
+
+		out := filepath.Join(dir, "file_m.txt")
+		collect(docs, "m.txt", out)
+		have, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(have) != test.want {
+			t.Errorf("%v: have\n%q\nwant\n%q", test.name, have, test.want)
+		}
+	}
+}
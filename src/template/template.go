@@ -42,7 +42,7 @@ func main() {
 	file = RemoveExtension(file) // remove the last extension (typically ".template")
 	ext := path.Ext(file)        // keep the extension before that for later  (typically ".in", ".py", ...)
 	file = RemoveExtension(file)
-	docs := []*Document{&Document{content, file, ext}}
+	docs := []*Document{&Document{content: content, name: file, ext: ext}}
 
 	for f := 0; f < flag.NArg()-1; f++ {
 
@@ -70,21 +70,26 @@ func main() {
 		}
 	}
 
+	var written []*Document
 	for _, d := range docs {
 		if d != nil {
 			if strings.Contains(d.content, "{") {
 				Error("Not all {key}'s were specified.")
 				// TODO: it might be nice to show which ones...
 			}
-			out, err := os.OpenFile(d.name+d.ext, os.O_WRONLY|os.O_CREATE, 0666)
+			out, err := os.OpenFile(d.File(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 			if err != nil {
 				Error(err)
 			}
 			out.Write([]byte(d.content))
 			out.Close() // This should not be a deferred call, otherwise too many files may wind up opened at the same time...
+			written = append(written, d)
 		}
 	}
 
+	if *flag_sweep {
+		sweep(written, file)
+	}
 }
 
 // Generate a sequence of values,
@@ -142,6 +147,8 @@ type Document struct {
 	content string
 	name    string
 	ext     string
+	keys    []string // keys replaced so far
+	vals    []string // their values
 }
 
 func (d *Document) Replace(key, val string) *Document {
@@ -152,12 +159,19 @@ func (d *Document) Replace(key, val string) *Document {
 	d2.content = strings.Replace(d.content, "{"+key+"}", val, -1)
 	d2.name = d.name + "_" + key + val
 	d2.ext = d.ext
+	d2.keys = append(append([]string{}, d.keys...), key)
+	d2.vals = append(append([]string{}, d.vals...), val)
 	return d2
 }
 
+// Name of the generated file.
+func (d *Document) File() string {
+	return d.name + d.ext
+}
+
 func Error(msg ...interface{}) {
 	fmt.Fprintln(os.Stderr, msg...)
-	fmt.Fprintln(os.Stderr, USAGE)
+	fmt.Fprint(os.Stderr, USAGE)
 	os.Exit(-1)
 }
 
@@ -176,4 +190,5 @@ template key=start:stop:step file.in.template   As above but with a step differe
 template key1=... key2=...                      Multiple keys may be specified.
 Output files are given automaticially generated names, e.g.:
 "template n=1 file.py.template" yields "file_n1.py".
+template -sweep [flags] key=... file.py.template  Also runs the generated files, see template -h.
 `